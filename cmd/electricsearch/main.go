@@ -0,0 +1,413 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/etcd"
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/service"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+// 运维工具。migrate和rekey是离线命令，运行期间要求对应的索引服务已经停止（bolt和badger的文件只能被一个进程打开）；
+// topology和reshard在线执行，通过init.yml中的etcd配置连接集群
+//
+//	go run ./cmd/electricsearch migrate --from bolt:data/bolt_db/bolt_0 --to badger:data/badger_db_0
+//	go run ./cmd/electricsearch rekey --db badger:data/badger_db_0 --old-key old.key --new-key new.key
+//	go run ./cmd/electricsearch topology --shards 3 --replicas 2
+//	go run ./cmd/electricsearch reshard --add-group 2
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "migrate":
+		err = migrate(os.Args[2:])
+	case "rekey":
+		err = rekey(os.Args[2:])
+	case "topology":
+		err = topology(os.Args[2:])
+	case "reshard":
+		err = reshard(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: electricsearch <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  migrate   copy a shard's forward index between storage engines")
+	fmt.Fprintln(os.Stderr, "  rekey     change the encryption key of a shard's forward index")
+	fmt.Fprintln(os.Stderr, "  topology  show or edit the declared shards, replicas and worker assignments")
+	fmt.Fprintln(os.Stderr, "  reshard   move slots between groups of a running cluster")
+}
+
+// 解析engine:path形式的参数，相对路径以项目根目录为基准，与init.yml中的db-path一致
+func parseTarget(s string) (int, string, error) {
+	name, path, ok := strings.Cut(s, ":")
+	if !ok || len(path) == 0 {
+		return 0, "", fmt.Errorf("invalid target %q, want engine:path", s)
+	}
+	engine, err := kvdb.ParseEngine(name)
+	if err != nil {
+		return 0, "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = util.RootPath + path
+	}
+	return engine, path, nil
+}
+
+// 读取密钥文件，路径为空表示不加密
+func loadKey(path string) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = util.RootPath + path
+	}
+	return kvdb.LoadEncryptionKey(path)
+}
+
+// Ctrl+C时写完当前批次再退出，下次使用同样的参数继续
+func interruptible(resumeHint string) func(kvdb.MigrateProgress) error {
+	var interrupted atomic.Bool
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		interrupted.Store(true)
+	}()
+
+	var lastPrint time.Time
+	return func(p kvdb.MigrateProgress) error {
+		if time.Since(lastPrint) >= time.Second {
+			lastPrint = time.Now()
+			fmt.Printf("copied %d records in %v, last key %q\n", p.Copied, p.Elapsed.Round(time.Millisecond), p.LastKey)
+		}
+		if interrupted.Load() {
+			return fmt.Errorf("interrupted after %d records, %s", p.Copied, resumeHint)
+		}
+		return nil
+	}
+}
+
+func printReport(report *kvdb.MigrateReport, verify bool) {
+	if report == nil {
+		return
+	}
+	if report.Resumed {
+		fmt.Println("resumed from checkpoint")
+	}
+	fmt.Printf("copied %d records in %v\n", report.Copied, report.Duration.Round(time.Millisecond))
+	if verify {
+		printVerified(report)
+	}
+}
+
+func printVerified(report *kvdb.MigrateReport) {
+	fmt.Printf("verified %d/%d records, %d mismatched, %d of %d destination records not in the source\n",
+		report.Verified, report.SourceKeys, report.Mismatched, report.Extra, report.TargetKeys)
+}
+
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "source, engine:path (engine is bolt, badger or memory)")
+	to := fs.String("to", "", "destination, engine:path")
+	batch := fs.Int("batch", 1000, "number of records per batch")
+	checkpoint := fs.String("checkpoint", "", "checkpoint file for resuming, defaults to <to path>.migrate")
+	verify := fs.Bool("verify", true, "compare every record in the destination after copying")
+	verifyOnly := fs.Bool("verify-only", false, "only compare the destination with the source, copy nothing")
+	overwrite := fs.Bool("overwrite", false, "clear a non-empty destination before copying, ignored when resuming from a checkpoint")
+	fromKey := fs.String("from-key", "", "encryption key file of the source, empty if not encrypted")
+	toKey := fs.String("to-key", "", "encryption key file of the destination, empty to store in plain form")
+	fs.Parse(args)
+	if len(*from) == 0 || len(*to) == 0 {
+		fs.Usage()
+		return errors.New("--from and --to are required")
+	}
+
+	srcEngine, srcPath, err := parseTarget(*from)
+	if err != nil {
+		return err
+	}
+	dstEngine, dstPath, err := parseTarget(*to)
+	if err != nil {
+		return err
+	}
+	if srcPath == dstPath {
+		return errors.New("source and destination are the same")
+	}
+	if _, err := os.Stat(srcPath); err != nil { //不存在时GetKeyValueDB会创建一个空库
+		return fmt.Errorf("source: %w", err)
+	}
+	if len(*checkpoint) == 0 {
+		*checkpoint = strings.TrimSuffix(dstPath, "/") + ".migrate"
+	}
+
+	srcKey, err := loadKey(*fromKey)
+	if err != nil {
+		return err
+	}
+	dstKey, err := loadKey(*toKey)
+	if err != nil {
+		return err
+	}
+
+	src, err := kvdb.GetEncryptedKeyValueDB(srcEngine, srcPath, srcKey)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	defer src.Close()
+	dst, err := kvdb.GetEncryptedKeyValueDB(dstEngine, dstPath, dstKey)
+	if err != nil {
+		return fmt.Errorf("open destination: %w", err)
+	}
+	defer dst.Close()
+
+	if *verifyOnly {
+		report, err := kvdb.NewMigrator(src, dst).WithBatchSize(*batch).Verify()
+		if report != nil {
+			printVerified(report)
+		}
+		return err
+	}
+
+	report, err := kvdb.NewMigrator(src, dst).
+		WithBatchSize(*batch).
+		WithCheckpoint(*checkpoint).
+		WithVerify(*verify).
+		WithOverwrite(*overwrite).
+		WithProgress(interruptible("rerun with --checkpoint " + *checkpoint + " to resume")).
+		Run()
+	printReport(report, *verify)
+	return err
+}
+
+// 离线更换加密密钥。两个密钥都非空时默认只重新加密数据密钥；加密明文库、解密成明文或指定--full时重写全部数据
+func rekey(args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	db := fs.String("db", "", "store to re-encrypt, engine:path (engine is bolt or badger)")
+	oldKey := fs.String("old-key", "", "current encryption key file, empty if not encrypted")
+	newKey := fs.String("new-key", "", "new encryption key file, empty to decrypt")
+	full := fs.Bool("full", false, "rewrite every record with a new data key instead of rewrapping the data key")
+	wal := fs.String("wal", "", "write-ahead log directory of the store, default is the store path with suffix _wal")
+	batch := fs.Int("batch", 1000, "number of records per batch when rewriting")
+	fs.Parse(args)
+	if len(*db) == 0 {
+		fs.Usage()
+		return errors.New("--db is required")
+	}
+
+	engine, path, err := parseTarget(*db)
+	if err != nil {
+		return err
+	}
+	oldKeyBytes, err := loadKey(*oldKey)
+	if err != nil {
+		return err
+	}
+	newKeyBytes, err := loadKey(*newKey)
+	if err != nil {
+		return err
+	}
+	if len(oldKeyBytes) == 0 && len(newKeyBytes) == 0 {
+		return errors.New("at least one of --old-key and --new-key is required")
+	}
+	// 预写日志用主密钥加密，换密钥前必须已经全部持久化到正排索引
+	if len(*wal) == 0 {
+		*wal = strings.TrimSuffix(path, "/") + "_wal"
+	}
+	if err := service.ClearWAL(*wal, oldKeyBytes); errors.Is(err, service.ErrWALPending) {
+		return fmt.Errorf("%w, start and stop the worker once to checkpoint it before rekeying", err)
+	} else if err != nil {
+		return fmt.Errorf("check wal %s: %w", *wal, err)
+	}
+
+	if !*full && len(oldKeyBytes) > 0 && len(newKeyBytes) > 0 {
+		if err := kvdb.RewrapKey(engine, path, oldKeyBytes, newKeyBytes); err != nil {
+			return err
+		}
+		fmt.Println("data key rewrapped with the new master key")
+		return nil
+	}
+	report, err := kvdb.Reencrypt(engine, path, oldKeyBytes, newKeyBytes, *batch, interruptible("rerun the same command to resume"))
+	printReport(report, true)
+	return err
+}
+
+// 在线重新分片。不指定--add-group和--remove-group时继续执行未完成的槽位迁移
+func reshard(args []string) error {
+	fs := flag.NewFlagSet("reshard", flag.ExitOnError)
+	addGroup := fs.Int("add-group", -1, "move part of the slots to this group, its workers must be registered")
+	removeGroup := fs.Int("remove-group", -1, "move all slots of this group to the others")
+	showStatus := fs.Bool("status", false, "print the shard map and exit")
+	fs.Parse(args)
+	if *addGroup >= 0 && *removeGroup >= 0 {
+		return errors.New("--add-group and --remove-group can not be used together")
+	}
+
+	servers, err := etcdServers()
+	if err != nil {
+		return err
+	}
+	sentinel := service.NewSentinel(servers)
+	defer sentinel.Close()
+	coordinator, err := service.NewShardCoordinator(sentinel)
+	if err != nil {
+		return err
+	}
+
+	if !*showStatus {
+		switch {
+		case *addGroup >= 0:
+			err = coordinator.AddGroup(*addGroup)
+		case *removeGroup >= 0:
+			err = coordinator.RemoveGroup(*removeGroup)
+		default:
+			err = coordinator.Resume()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	shardMap, err := coordinator.ShardMap()
+	if err != nil {
+		return err
+	}
+	printShardMap(shardMap)
+	return nil
+}
+
+// 查看或修改集群拓扑。不指定修改参数时只打印拓扑和各group认领了位置的worker
+func topology(args []string) error {
+	fs := flag.NewFlagSet("topology", flag.ExitOnError)
+	index := fs.String("index", "", "index name, defaults to distributed.index-name in init.yml")
+	shards := fs.Int("shards", 0, "declare the number of shards (groups)")
+	replicas := fs.Int("replicas", 0, "declare the max number of workers per shard")
+	assign := fs.String("assign", "", "pin a worker to a group, endpoint=group")
+	unassign := fs.String("unassign", "", "remove the pin of a worker endpoint")
+	remove := fs.Bool("remove", false, "remove the index from the topology")
+	fs.Parse(args)
+	if len(*index) == 0 {
+		distributed, _ := util.ConfigMap["distributed"].(map[string]any)
+		*index, _ = distributed["index-name"].(string)
+	}
+
+	servers, err := etcdServers()
+	if err != nil {
+		return err
+	}
+	client, err := etcd.GetEtcdClient(servers)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := service.NewTopologyAdmin(client)
+
+	switch {
+	case *remove:
+		_, err = admin.RemoveIndex(*index)
+	case *shards > 0 || *replicas > 0:
+		var current *service.Topology
+		if current, err = admin.Topology(); err != nil {
+			return err
+		}
+		// 只指定其中一项时另一项保持不变
+		if declared := current.Index(*index); declared != nil {
+			if *shards == 0 {
+				*shards = declared.Shards
+			}
+			if *replicas == 0 {
+				*replicas = declared.Replicas
+			}
+		}
+		_, err = admin.SetIndex(*index, *shards, *replicas)
+	case len(*assign) > 0:
+		endpoint, group, ok := strings.Cut(*assign, "=")
+		groupIndex, convErr := strconv.Atoi(group)
+		if !ok || convErr != nil {
+			return fmt.Errorf("invalid --assign %q, want endpoint=group", *assign)
+		}
+		_, err = admin.Assign(*index, endpoint, groupIndex)
+	case len(*unassign) > 0:
+		_, err = admin.Unassign(*index, *unassign)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := admin.Topology()
+	if err != nil {
+		return err
+	}
+	declared := current.Index(*index)
+	if declared == nil {
+		fmt.Printf("index %s is not declared\n", *index)
+		return nil
+	}
+	claims, err := admin.Claims(*index)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("index %s: %d shards, %d replicas, topology version %d\n", *index, declared.Shards, declared.Replicas, current.Version)
+	for group := range declared.Shards {
+		fmt.Printf("  group-%d: %d/%d claimed %v\n", group, len(claims[group]), declared.Replicas, claims[group])
+	}
+	for endpoint, group := range declared.Assignments {
+		fmt.Printf("  %s pinned to group-%d\n", endpoint, group)
+	}
+	return nil
+}
+
+func etcdServers() ([]string, error) {
+	etcdConfig, ok := util.ConfigMap["etcd"].(map[string]any)
+	if !ok {
+		return nil, errors.New("etcd configuration not found in init.yml")
+	}
+	list, _ := etcdConfig["servers"].([]any)
+	servers := make([]string, 0, len(list))
+	for _, server := range list {
+		servers = append(servers, strings.Trim(strings.TrimSpace(fmt.Sprintf("%v", server)), "\""))
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("etcd servers not found in init.yml")
+	}
+	return servers, nil
+}
+
+func printShardMap(shardMap *service.ShardMap) {
+	if shardMap == nil {
+		fmt.Println("shard map not created yet")
+		return
+	}
+	owned := make(map[int]int)
+	for _, group := range shardMap.Slots {
+		owned[group]++
+	}
+	fmt.Printf("epoch %d, %d slots\n", shardMap.Epoch, len(shardMap.Slots))
+	for _, group := range shardMap.Groups() {
+		fmt.Printf("  group-%d: %d slots\n", group, owned[group])
+	}
+	for _, migration := range shardMap.Migrations {
+		fmt.Printf("  slot %d: group-%d -> group-%d, %s\n", migration.Slot, migration.From, migration.To, migration.State)
+	}
+}
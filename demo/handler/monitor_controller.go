@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/WlayRay/ElectricSearch/service"
+	"github.com/gin-gonic/gin"
+)
+
+// 分布式部署时返回Sentinel中每台worker的熔断器状态
+func BreakerStates(ctx *gin.Context) {
+	sentinel, ok := Indexer.(*service.Sentinel)
+	if !ok {
+		ctx.String(http.StatusNotFound, "熔断器只在分布式部署时启用")
+		return
+	}
+	states := make(gin.H)
+	for endpoint, stats := range sentinel.BreakerStates() {
+		states[endpoint] = gin.H{
+			"state":     stats.State.String(),
+			"requests":  stats.Requests,
+			"failures":  stats.Failures,
+			"slow":      stats.Slow,
+			"opened_at": stats.OpenedAt,
+		}
+	}
+	ctx.JSON(http.StatusOK, states)
+}
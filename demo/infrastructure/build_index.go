@@ -1,6 +1,7 @@
 package infrastructure
 
 import (
+	"context"
 	"encoding/csv"
 	"io"
 	"log"
@@ -12,11 +13,11 @@ import (
 	"github.com/WlayRay/ElectricSearch/service"
 	"github.com/WlayRay/ElectricSearch/types"
 	"github.com/WlayRay/ElectricSearch/util"
-	"github.com/dgryski/go-farm"
 	"github.com/gogo/protobuf/proto"
 )
 
-func BuildIndexFromCSVFile(csvFile string, indexer service.IIndexer, totalShards, currentGroup int) {
+// BuildIndexFromCSVFile 从csv文件构建索引，owns不为nil时只写入owns返回true的文档
+func BuildIndexFromCSVFile(csvFile string, indexer service.IIndexer, owns func(docId string) bool) {
 	file, err := os.Open(csvFile)
 	if err != nil {
 		log.Printf("open file %s failed, err: %v", csvFile, err)
@@ -29,6 +30,7 @@ func BuildIndexFromCSVFile(csvFile string, indexer service.IIndexer, totalShards
 	reader.Comma = '|'       // 设置分隔符为竖线
 	reader.LazyQuotes = true // 允许不匹配的引号
 	progress := 0
+	batch := make([]types.Document, 0, batchSize)
 	for {
 		record, err := reader.Read()
 		if err != nil {
@@ -43,7 +45,7 @@ func BuildIndexFromCSVFile(csvFile string, indexer service.IIndexer, totalShards
 		}
 		docId := strings.TrimPrefix(record[0], "https://www.bilibili.com/video/")
 
-		if totalShards > 0 && int(farm.Hash32WithSeed([]byte(docId), 0))%totalShards != currentGroup {
+		if owns != nil && !owns(docId) {
 			continue
 		}
 
@@ -81,23 +83,54 @@ func BuildIndexFromCSVFile(csvFile string, indexer service.IIndexer, totalShards
 				}
 			}
 		}
-		AddVideoToIndex(video, indexer)
-		progress++
+		doc, err := VideoToDoc(video)
+		if err != nil {
+			log.Printf("serialize video %s failed, err: %v", video.Id, err)
+			continue
+		}
+		batch = append(batch, *doc)
+		if len(batch) >= batchSize {
+			progress += addBatchToIndex(batch, indexer)
+			batch = batch[:0]
+		}
 		// util.Log.Printf("add %d documents to index currently", progress)
 	}
+	if len(batch) > 0 {
+		progress += addBatchToIndex(batch, indexer)
+	}
 	util.Log.Printf("add %d documents to index totally", progress)
 }
 
-func AddVideoToIndex(video *BiliBiliVideo, indexer service.IIndexer) {
-	doc := types.Document{Id: video.Id}
-	bs, err := proto.Marshal(video)
+// 每攒够batchSize个文档批量写一次索引
+const batchSize = 1000
+
+func addBatchToIndex(batch []types.Document, indexer service.IIndexer) int {
+	n, docErrors := indexer.BatchAddDoc(context.Background(), batch)
+	for _, docError := range docErrors {
+		log.Printf("add doc %s to index failed, code: %d, err: %s", docError.DocId, docError.Code, docError.Reason)
+	}
+	return n
+}
 
-	if err == nil {
-		doc.Bytes = bs
-	} else {
+func AddVideoToIndex(ctx context.Context, video *BiliBiliVideo, indexer service.IIndexer) {
+	doc, err := VideoToDoc(video)
+	if err != nil {
 		log.Printf("serialize video %s failed, err: %v", video.Id, err)
 		return
 	}
+	if _, err := indexer.AddDoc(ctx, *doc); err != nil {
+		log.Printf("add video %s to index failed, err: %v", video.Id, err)
+	}
+}
+
+// VideoToDoc 把视频转换成索引中的文档
+func VideoToDoc(video *BiliBiliVideo) (*types.Document, error) {
+	doc := types.Document{Id: video.Id}
+	bs, err := proto.Marshal(video)
+	if err != nil {
+		return nil, err
+	}
+	doc.Bytes = bs
 
 	keywords := make([]*types.Keyword, 0, len(video.Keywords))
 	for _, keyword := range video.Keywords {
@@ -109,6 +142,5 @@ func AddVideoToIndex(video *BiliBiliVideo, indexer service.IIndexer) {
 	}
 	doc.Keywords = keywords
 	doc.BitsFeature = GetCategoriesBits(video.Keywords)
-
-	indexer.AddDoc(doc)
+	return &doc, nil
 }
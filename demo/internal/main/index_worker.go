@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/WlayRay/ElectricSearch/demo/infrastructure"
 	"github.com/WlayRay/ElectricSearch/service"
 	"github.com/WlayRay/ElectricSearch/util"
@@ -27,15 +28,20 @@ func GrpcIndexerInit() {
 	}
 
 	service.RegisterIndexServiceServer(server, indexService)
+	// 新加入group的worker先从其他worker恢复数据，追上之后再注册
+	recovered := indexService.RecoverFromPeer()
+	if !recovered && !rebuildIndex {
+		indexService.Indexer.LoadFromIndexFile() //直接从正排索引中加载，加载完成后再注册
+	}
 	if err := indexService.Register(port); err != nil {
 		_ = indexService.Close()
 		util.Log.Fatalf("failed to register: %v", err)
 	}
 
-	if rebuildIndex {
-		infrastructure.BuildIndexFromCSVFile(csvFilePath, indexService.Indexer, indexService.Hub.CountIndexGroup(), currentGroup)
-	} else {
-		indexService.Indexer.LoadFromIndexFile() //直接从正排索引中加载
+	if recovered {
+		util.Log.Printf("index recovered from peer, %d docs", indexService.Indexer.Count(context.Background()))
+	} else if rebuildIndex {
+		infrastructure.BuildIndexFromCSVFile(csvFilePath, indexService.Indexer, indexService.ShardFilter())
 	}
 
 	err = server.Serve(lis)
@@ -5,34 +5,48 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/WlayRay/ElectricSearch/demo/handler"
 	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/service"
 	"github.com/WlayRay/ElectricSearch/util"
 	"github.com/gin-gonic/gin"
 )
 
 var (
-	mode                int
-	documentEstimateNum int
-	dbType              int
-	dbPath              string
-	rebuildIndex        bool
-	currentGroup        int
-	csvFilePath         string
-	port                int
-	etcdEndpoints       []string
-	heartRate           int
+	mode                     int
+	documentEstimateNum      int
+	dbType                   int
+	dbPath                   string
+	compression              service.Compression
+	walDir                   string
+	encryptionKey            []byte
+	maintenanceInterval      time.Duration
+	maintenanceSizeThreshold int64
+	rebuildIndex             bool
+	currentGroup             int
+	csvFilePath              string
+	port                     int
+	etcdEndpoints            []string
+	heartRate                int
+	localShards              = 1
+	localReplicas            = 1
+	localReplication         bool
+	searchOptions            = service.DefaultSearchOptions
 )
 
 func startGin() {
 	engine := gin.Default()
 	gin.SetMode(gin.ReleaseMode)
+	// gin.Context的Done、Deadline和Err使用http请求的context，客户端断开时取消对索引的检索
+	engine.ContextWithFallback = true
 
 	engine.Use(handler.GetUserInfo)
 
 	engine.POST("/search", handler.SearchAll)
 	engine.POST("/up_search", handler.SearchByAuthor)
+	engine.GET("/breakers", handler.BreakerStates)
 
 	if err := engine.Run("0.0.0.0:" + "9000"); err != nil {
 		util.Log.Println("Server failed to start:", err)
@@ -42,7 +56,7 @@ func startGin() {
 
 func main() {
 	switch mode {
-	case 1, 3:
+	case 1, 3, 4:
 		WebServerMain(mode)
 		startGin()
 	case 2:
@@ -51,6 +65,7 @@ func main() {
 	// 模式1为单机部署，模式2为启动分布式部署下的每个索引服务节点，相当于一个grpc server
 	// 模式3为启动分布式部署下的etcd代理（Sentinel），后续的添加、搜索、删除文档等都通过代理操作
 	// 在分布式部署时，需要先通过模式2启动多个索引服务节点，然后再通过模式3启动etcd代理和web server
+	// 模式4在一个进程内启动shards×replicas个索引服务节点和Sentinel，节点之间通过内存连接通信，不依赖etcd，用于开发时模拟分布式部署
 }
 
 func init() {
@@ -63,14 +78,14 @@ func init() {
 			panic("mode not found in ConfigMap!")
 		} else {
 			mode, _ = strconv.Atoi(fmt.Sprintf("%v", v))
-			if mode < 1 || mode > 3 {
+			if mode < 1 || mode > 4 {
 				panic("mode invalid!")
 			}
 		}
 	} else {
 		var err error
 		mode, err = strconv.Atoi(modeStr)
-		if err != nil || mode < 1 || mode > 3 {
+		if err != nil || mode < 1 || mode > 4 {
 			panic("mode invalid!")
 		}
 	}
@@ -123,6 +138,32 @@ func init() {
 		}
 	}
 
+	// 本地集群的分片数、每个分片的worker数和是否开启主从复制
+	if mode == 4 {
+		if v, ok := distributedConfig["shards"].(int); ok {
+			localShards = v
+		}
+		if v, ok := distributedConfig["replicas"].(int); ok {
+			localReplicas = v
+		}
+		localReplication, _ = distributedConfig["replication"].(bool)
+	}
+
+	// 检索时有group失败是否返回其他group的结果
+	if v, ok := distributedConfig["allow-partial-results"].(bool); ok {
+		searchOptions.AllowPartialResults = v
+	}
+	// worker失败时的重试和慢请求的对冲
+	if v, ok := distributedConfig["search-retries"].(int); ok {
+		searchOptions.MaxRetries = v
+	}
+	if v, ok := distributedConfig["hedge-percentile"].(float64); ok {
+		searchOptions.HedgePercentile = v
+	}
+	if v, ok := distributedConfig["max-hedges"].(int); ok {
+		searchOptions.MaxHedges = v
+	}
+
 	// 读取 index 配置
 	indexConfig, ok := util.ConfigMap["index"].(map[string]any)
 	if !ok {
@@ -152,12 +193,40 @@ func init() {
 		case "badger":
 			dbType = kvdb.BADGER
 			dbPath += "badger_db"
+		case "memory":
+			dbType = kvdb.MEMORY
+			dbPath += "memory_db/memory"
+			if aof, _ := indexConfig["memory-aof"].(bool); !aof {
+				dbPath = ""
+			}
 		default:
 			dbType = kvdb.BOLT
 			dbPath += "bolt_db/bolt"
 		}
 	}
 
+	// 正排索引value的压缩算法
+	if v, ok := indexConfig["compression"]; ok {
+		var err error
+		if compression, err = service.ParseCompression(fmt.Sprintf("%v", v)); err != nil {
+			panic(err)
+		}
+	}
+
+	// 预写日志，纯内存的正排索引不需要
+	if enabled, _ := indexConfig["wal"].(bool); enabled && len(dbPath) > 0 {
+		walDir = dbPath + "_wal"
+	}
+
+	// 正排索引和预写日志的加密
+	var err error
+	if encryptionKey, err = service.ParseEncryptionConfig(indexConfig); err != nil {
+		panic(err)
+	}
+
+	// 正排索引的定期维护
+	maintenanceInterval, maintenanceSizeThreshold = service.ParseMaintenanceConfig(indexConfig)
+
 	// 预估文档数量
 	if v, ok := indexConfig["document-estimate-num"]; !ok {
 		panic("documentEstimateNum not found in ConfigMap!")
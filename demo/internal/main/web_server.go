@@ -10,21 +10,35 @@ import (
 	"github.com/WlayRay/ElectricSearch/service"
 )
 
+var localCluster *service.LocalCluster // 模式4的本地集群
+
 func WebServerInit(mode int) {
 	switch mode {
 	case 1:
-		standaloneIndexer := new(service.Indexer)
+		standaloneIndexer := new(service.Indexer).WithWAL(walDir).WithEncryptionKey(encryptionKey)
 		if err := standaloneIndexer.Init(documentEstimateNum, dbType, dbPath); err != nil {
 			panic(err)
 		}
+		standaloneIndexer.SetCompression(compression)
+		standaloneIndexer.StartMaintenance(maintenanceInterval, maintenanceSizeThreshold)
 		if rebuildIndex {
-			infrastructure.BuildIndexFromCSVFile(csvFilePath, standaloneIndexer, 0, 0)
+			infrastructure.BuildIndexFromCSVFile(csvFilePath, standaloneIndexer, nil)
 		} else {
 			standaloneIndexer.LoadFromIndexFile()
 		}
 		handler.Indexer = standaloneIndexer
 	case 3:
-		handler.Indexer = service.NewSentinel(etcdEndpoints)
+		handler.Indexer = service.NewSentinel(etcdEndpoints).WithSearchOptions(searchOptions)
+	case 4:
+		service.LocalClusterDocNumEstimate = documentEstimateNum
+		localCluster = service.NewLocalCluster(localShards, localReplicas).WithReplication(localReplication)
+		if err := localCluster.Start(); err != nil {
+			panic(err)
+		}
+		sentinel := localCluster.Sentinel.WithSearchOptions(searchOptions)
+		// worker的数据都在内存中，每次启动都需要重建索引
+		infrastructure.BuildIndexFromCSVFile(csvFilePath, sentinel, nil)
+		handler.Indexer = sentinel
 	default:
 		panic("Unsupported mode")
 	}
@@ -32,7 +46,11 @@ func WebServerInit(mode int) {
 
 func WebServerTeardown(signalCh chan os.Signal) {
 	<-signalCh
-	_ = handler.Indexer.Close()
+	if localCluster != nil {
+		_ = localCluster.Close() // 同时关闭Sentinel
+	} else {
+		_ = handler.Indexer.Close()
+	}
 	os.Exit(0)
 }
 
@@ -35,7 +35,11 @@ func (KeywordRecaller) Recall(ctx *infrastructure.VideoSearchContext) []*infrast
 		query = query.And(types.NewTermQuery("author", strings.ToLower(request.Author)))
 	}
 	orFlags := []uint64{(infrastructure.GetCategoriesBits(request.Categories))}
-	docs := indexer.Search(query, 0, 0, orFlags)
+	docs, err := indexer.Search(ctx.Ctx, query, 0, 0, orFlags)
+	if err != nil {
+		util.Log.Printf("recall by keywords failed: %v", err)
+		return nil
+	}
 
 	videos := make([]*infrastructure.BiliBiliVideo, 0, len(docs))
 	for _, doc := range docs {
@@ -73,7 +77,11 @@ func (KeywordAuthorRecaller) Recall(ctx *infrastructure.VideoSearchContext) []*i
 	}
 
 	orFlags := []uint64{(infrastructure.GetCategoriesBits(request.Categories))}
-	docs := indexer.Search(query, 0, 0, orFlags)
+	docs, err := indexer.Search(ctx.Ctx, query, 0, 0, orFlags)
+	if err != nil {
+		util.Log.Printf("recall by keywords failed: %v", err)
+		return nil
+	}
 	videos := make([]*infrastructure.BiliBiliVideo, 0, len(docs))
 	for _, doc := range docs {
 		var video infrastructure.BiliBiliVideo
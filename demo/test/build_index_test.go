@@ -28,5 +28,5 @@ func TestBuildIndex(t *testing.T) {
 	Init()
 	defer indexer.Close()
 	csvFile := util.RootPath + "data/bilibili_video.csv" // 改成项目中实际的csv文件路径
-	infrastructure.BuildIndexFromCSVFile(csvFile, indexer, 0, 0)
+	infrastructure.BuildIndexFromCSVFile(csvFile, indexer, nil)
 }
@@ -2,9 +2,11 @@ package kvdb
 
 import (
 	"errors"
+	"io"
 	"os"
 	"path"
 	"sync/atomic"
+	"time"
 
 	"github.com/WlayRay/ElectricSearch/util"
 
@@ -14,6 +16,7 @@ import (
 type Badger struct {
 	db   *badger.DB
 	path string
+	key  []byte // 主密钥，为空时不加密
 }
 
 func (s *Badger) WithDataPath(path string) *Badger {
@@ -21,14 +24,34 @@ func (s *Badger) WithDataPath(path string) *Badger {
 	return s
 }
 
+// WithEncryptionKey 使用badger原生的加密，key为16、24或32字节的主密钥，数据密钥按BadgerKeyRotationDuration定期轮换
+func (s *Badger) WithEncryptionKey(key []byte) *Badger {
+	s.key = key
+	return s
+}
+
 func (s *Badger) Open() error {
 	DataDir := s.GetDbPath()
 	if err := os.MkdirAll(path.Dir(DataDir), os.ModePerm); err != nil { //如果DataDir对应的文件夹已存在则什么都不做，如果DataDir对应的文件已存在则返回错误
 		return err
 	}
 	option := badger.DefaultOptions(DataDir).WithNumVersionsToKeep(1).WithLoggingLevel(badger.ERROR) //Builder模式，可以连续使用多个With()函数来构造对象
-	db, err := badger.Open(option)                                                                   //文件只能被一个进程使用，如果不调用Close则下次无法Open。手动释放锁的办法：把LOCK文件删掉
-	if err != nil {
+	if len(s.key) > 0 {
+		if err := checkKey(s.key); err != nil {
+			return err
+		}
+		option = option.WithEncryptionKey(s.key).WithIndexCacheSize(BadgerEncryptedIndexCacheSize)
+		if BadgerKeyRotationDuration > 0 {
+			option = option.WithEncryptionKeyRotationDuration(BadgerKeyRotationDuration)
+		}
+	}
+	db, err := badger.Open(option) //文件只能被一个进程使用，如果不调用Close则下次无法Open。手动释放锁的办法：把LOCK文件删掉
+	if errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		if len(s.key) == 0 {
+			return ErrEncryptionKeyRequired
+		}
+		return ErrEncryptionKeyMismatch
+	} else if err != nil {
 		return err
 	} else {
 		s.db = db
@@ -40,17 +63,13 @@ func (s *Badger) GetDbPath() string {
 	return s.path
 }
 
-// (额外定义的方法）
+// (额外定义的方法）执行value log GC，打印GC前后的大小
 func (s *Badger) CheckAndGC() {
-	lsmSize1, vlogSize1 := s.db.Size()
-	for {
-		if err := s.db.RunValueLogGC(0.5); err == badger.ErrNoRewrite || err == badger.ErrRejected {
-			break
-		}
-	}
-	lsmSize2, vlogSize2 := s.db.Size()
-	if vlogSize2 < vlogSize1 {
-		util.Log.Printf("badger before GC, LSM %d, vlog %d. after GC, LSM %d, vlog %d", lsmSize1, vlogSize1, lsmSize2, vlogSize2)
+	report, err := s.Maintain()
+	if err != nil {
+		util.Log.Printf("badger GC failed: %v", err)
+	} else if report.SizeAfter < report.SizeBefore {
+		util.Log.Printf("badger before GC %d, after GC %d", report.SizeBefore, report.SizeAfter)
 	} else {
 		util.Log.Printf("collect zero garbage")
 	}
@@ -65,6 +84,13 @@ func (s *Badger) Set(k, v []byte) error {
 	return err
 }
 
+// SetWithTTL 写入一个ttl之后自动过期的<key, value>
+func (s *Badger) SetWithTTL(k, v []byte, ttl time.Duration) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(k, v).WithTTL(ttl))
+	})
+}
+
 // BatchSet 多个写操作使用一个事务
 func (s *Badger) BatchSet(keys, values [][]byte) error {
 	if len(keys) != len(values) {
@@ -105,37 +131,32 @@ func (s *Badger) Get(k []byte) ([]byte, error) {
 	return ival, err
 }
 
-// BatchGet 返回的values与传入的keys顺序保持一致。如果key不存在或读取失败则对应的value是空数组
+// BatchGet 返回的values与传入的keys顺序保持一致。如果key不存在或读取失败则对应的value是空数组，读取失败时返回第一个错误。
+// 在一个只读事务内按key的字节序依次读取，value是拷贝出来的，可以在事务外使用
 func (s *Badger) BatchGet(keys [][]byte) ([][]byte, error) {
-	var err error
-	txn := s.db.NewTransaction(false) //只读事务
 	values := make([][]byte, len(keys))
-	for i, key := range keys {
-		var item *badger.Item
-		item, err = txn.Get(key)
-		if err == nil {
-			//buffer := make([]byte, badgerOptions.ValueLogMaxEntries)
-			var ival []byte
-			//ival, err = item.ValueCopy(buffer)
-			err = item.Value(func(val []byte) error {
-				ival = val
-				return nil
-			})
+	var failure error
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, i := range sortedKeyOrder(keys) {
+			values[i] = []byte{}
+			item, err := txn.Get(keys[i])
 			if err == nil {
-				values[i] = ival
-			} else { //拷贝失败
-				values[i] = []byte{} //拷贝失败就把value设为空数组
+				var value []byte
+				if value, err = item.ValueCopy(nil); err == nil {
+					values[i] = value
+					continue
+				}
 			}
-		} else { //读取失败
-			values[i] = []byte{}              //读取失败就把value设为空数组
-			if err != badger.ErrKeyNotFound { //如果真的发生异常，则开一个新事务继续读后面的key
-				txn.Discard()
-				txn = s.db.NewTransaction(false)
+			if err != badger.ErrKeyNotFound && failure == nil {
+				failure = err
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return values, err
 	}
-	txn.Discard() //只读事务调Discard就可以了，不需要调Commit。Commit内部也会调Discard
-	return values, err
+	return values, failure
 }
 
 // Delete
@@ -229,6 +250,72 @@ func (s *Badger) IterKey(fn func(k []byte) error) int64 {
 	return atomic.LoadInt64(&total)
 }
 
+// Scan 从start开始Seek，按key升序遍历[start, end)
+func (s *Badger) Scan(start, end []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	return s.scan(start, end, nil, limit, fn)
+}
+
+// PrefixScan 遍历以prefix开头的数据，迭代器只会访问包含该前缀的SST文件
+func (s *Badger) PrefixScan(prefix []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	return s.scan(prefix, nil, prefix, limit, fn)
+}
+
+func (s *Badger) scan(start, end, prefix []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	var total int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		if limit > 0 && limit < opts.PrefetchSize {
+			opts.PrefetchSize = limit
+		}
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(start); it.Valid(); it.Next() {
+			item := it.Item()
+			k := item.Key()
+			if pastEnd(k, end) {
+				break
+			}
+			err := item.Value(func(v []byte) error {
+				return fn(k, v)
+			})
+			if err != nil {
+				return err
+			}
+			total++
+			if limit > 0 && total >= int64(limit) {
+				break
+			}
+		}
+		return nil
+	})
+	if err == ErrStopScan {
+		err = nil
+	}
+	return total, err
+}
+
+// Backup 使用badger原生的备份流，导出版本号大于since的数据（包括删除标记），返回导出数据的最大版本号。
+// 增量备份时把上一次返回的版本号作为since（badger的迭代器会跳过版本号不大于since的数据）
+func (s *Badger) Backup(w io.Writer, since uint64) (uint64, error) {
+	return s.db.Backup(w, since)
+}
+
+// Restore 导入Backup导出的数据，数据保留原来的版本号。先恢复全量备份，再依次恢复增量备份
+func (s *Badger) Restore(r io.Reader) error {
+	return s.db.Load(r, 256)
+}
+
+// DropAll 删除全部数据，期间会阻塞所有读写
+func (s *Badger) DropAll() error {
+	return s.db.DropAll()
+}
+
+// Sync 默认配置下badger的写入不会立即fsync，需要持久化时手动调用
+func (s *Badger) Sync() error {
+	return s.db.Sync()
+}
+
 // Close 把内存中的数据flush到磁盘，同时释放文件锁。如果没有close，再open时会丢失很多数据
 func (s *Badger) Close() error {
 	return s.db.Close()
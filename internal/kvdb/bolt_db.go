@@ -1,10 +1,17 @@
 package kvdb
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
 )
 
 var ErrNoData = errors.New("没有数据")
@@ -14,6 +21,9 @@ type Bolt struct {
 	db     *bolt.DB
 	path   string
 	bucket []byte
+	mu     sync.RWMutex // 压缩时需要替换db，此时阻塞所有读写
+	key    []byte       // 主密钥，为空时不加密
+	cipher *Cipher      // 加密value的数据密钥，未开启加密时为nil
 }
 
 // 使用 Builder 模式来构建 Bolt 结构体
@@ -27,6 +37,12 @@ func (s *Bolt) WithBucket(bucket string) *Bolt {
 	return s
 }
 
+// WithEncryptionKey 开启value的信封加密，key为16、24或32字节的主密钥
+func (s *Bolt) WithEncryptionKey(key []byte) *Bolt {
+	s.key = key
+	return s
+}
+
 // 初始化DB
 func (s *Bolt) Open() error {
 	dataDir := s.GetDbPath()
@@ -35,8 +51,10 @@ func (s *Bolt) Open() error {
 		return err
 	}
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(s.bucket)
-		return err
+		if _, err := tx.CreateBucketIfNotExists(s.bucket); err != nil {
+			return err
+		}
+		return s.initEncryption(tx)
 	})
 	if err != nil {
 		db.Close()
@@ -54,39 +72,52 @@ func (s *Bolt) GetDbPath() string {
 
 // WALName returns the path to currently open database file.(额外定义的方法）
 func (s *Bolt) WALName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.db.Path()
 }
 
 // 写入<key, value>
 func (s *Bolt) Set(k, v []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(s.bucket).Put(k, v)
+		return tx.Bucket(s.bucket).Put(k, s.seal(v))
 	})
 }
 
+// bolt不支持TTL，直接写入，过期数据由上层负责清理
+func (s *Bolt) SetWithTTL(k, v []byte, ttl time.Duration) error {
+	return s.Set(k, v)
+}
+
 // 批量写入<key, value>
 func (s *Bolt) BatchSet(keys, values [][]byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	if len(keys) != len(values) {
 		return errors.New("the key and the value are not the same length")
 	}
-	s.db.Batch(func(tx *bolt.Tx) error {
+	return s.db.Batch(func(tx *bolt.Tx) error {
 		for i, key := range keys {
-			value := values[i]
+			value := s.seal(values[i])
 			if err := tx.Bucket(s.bucket).Put(key, value); err != nil {
 				return err
 			}
 		}
 		return nil
 	})
-	return nil
 }
 
 // 读取key对应的value
 func (s *Bolt) Get(k []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var ival []byte
 	err := s.db.View(func(tx *bolt.Tx) error {
-		ival = tx.Bucket(s.bucket).Get(k)
-		return nil
+		var err error
+		ival, err = s.open(tx.Bucket(s.bucket).Get(k))
+		return err
 	})
 	if len(ival) == 0 {
 		return nil, ErrNoData
@@ -94,21 +125,34 @@ func (s *Bolt) Get(k []byte) ([]byte, error) {
 	return ival, err
 }
 
-// 批量读取，注意不保证顺序
+// 批量读取，返回的values与传入的keys顺序保持一致，key不存在时对应的value为nil。按key的字节序依次读取
 func (s *Bolt) BatchGet(keys [][]byte) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	values := make([][]byte, len(keys))
-	s.db.Batch(func(tx *bolt.Tx) error {
-		for i, key := range keys {
-			ival := tx.Bucket(s.bucket).Get(key)
-			values[i] = ival
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		for _, i := range sortedKeyOrder(keys) {
+			if v := b.Get(keys[i]); v != nil {
+				if s.cipher != nil {
+					var err error
+					if values[i], err = s.cipher.Open(v); err != nil {
+						return err
+					}
+				} else {
+					values[i] = bytes.Clone(v) //bolt返回的value只在事务内有效
+				}
+			}
 		}
 		return nil
 	})
-	return values, nil
+	return values, err
 }
 
 // 删除
 func (s *Bolt) Delete(k []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.db.Update(func(tx *bolt.Tx) error {
 		return tx.Bucket(s.bucket).Delete(k)
 	})
@@ -116,7 +160,9 @@ func (s *Bolt) Delete(k []byte) error {
 
 // 批量删除
 func (s *Bolt) BatchDelete(keys [][]byte) error {
-	s.db.Batch(func(tx *bolt.Tx) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Batch(func(tx *bolt.Tx) error {
 		for _, key := range keys {
 			if err := tx.Bucket(s.bucket).Delete(key); err != nil {
 				return err
@@ -124,11 +170,12 @@ func (s *Bolt) BatchDelete(keys [][]byte) error {
 		}
 		return nil
 	})
-	return nil
 }
 
 // 判断某个key是否存在
 func (s *Bolt) Has(k []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var b []byte
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b = tx.Bucket(s.bucket).Get(k)
@@ -144,11 +191,17 @@ func (s *Bolt) Has(k []byte) bool {
 
 // 遍历数据库，返回数据的条数
 func (s *Bolt) IterDB(fn func(k, v []byte) error) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var total int64
 	s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(s.bucket)
 		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
+			v, err := s.open(v)
+			if err != nil {
+				return err
+			}
 			if err := fn(k, v); err != nil {
 				return err
 			} else {
@@ -163,6 +216,8 @@ func (s *Bolt) IterDB(fn func(k, v []byte) error) int64 {
 
 // 遍历数据库，返回key的条数
 func (s *Bolt) IterKey(fn func(k []byte) error) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var total int64
 	s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(s.bucket)
@@ -179,7 +234,139 @@ func (s *Bolt) IterKey(fn func(k []byte) error) int64 {
 	return atomic.LoadInt64(&total)
 }
 
+// 用游标Seek到start，按key升序遍历[start, end)
+func (s *Bolt) Scan(start, end []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	return s.scan(start, end, limit, fn)
+}
+
+// 遍历以prefix开头的数据
+func (s *Bolt) PrefixScan(prefix []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	return s.scan(prefix, prefixEnd(prefix), limit, fn)
+}
+
+func (s *Bolt) scan(start, end []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var total int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(s.bucket).Cursor()
+		for k, v := c.Seek(start); k != nil && !pastEnd(k, end); k, v = c.Next() {
+			v, err := s.open(v)
+			if err != nil {
+				return err
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+			total++
+			if limit > 0 && total >= int64(limit) {
+				break
+			}
+		}
+		return nil
+	})
+	if err == ErrStopScan {
+		err = nil
+	}
+	return total, err
+}
+
+// 在一个只读事务内用Tx.WriteTo导出整个数据库文件，返回事务ID。bolt没有数据版本，since必须为0
+func (s *Bolt) Backup(w io.Writer, since uint64) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if since > 0 {
+		return 0, ErrIncrementalBackup
+	}
+	var txId uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		txId = uint64(tx.ID())
+		_, err := tx.WriteTo(w)
+		return err
+	})
+	return txId, err
+}
+
+// 导入Backup导出的数据库文件：先写到临时文件，再把其中的数据复制到当前bucket。
+// 备份和当前库的数据密钥可能不同，value先用备份的数据密钥解密，再用当前库的数据密钥加密
+func (s *Bolt) Restore(r io.Reader) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tmpPath := s.path + ".restore"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := bolt.Open(tmpPath, 0o600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return src.View(func(srcTx *bolt.Tx) error {
+		srcBucket := srcTx.Bucket(s.bucket)
+		if srcBucket == nil {
+			return fmt.Errorf("bucket %s not found in backup", s.bucket)
+		}
+		var srcCipher *Cipher
+		if kb := srcTx.Bucket(boltKeyBucket); kb != nil && kb.Get(boltDataKeyName) != nil {
+			if s.key == nil {
+				return ErrEncryptionKeyRequired
+			}
+			dataKey, err := unwrapDataKey(s.key, kb.Get(boltDataKeyName))
+			if err != nil {
+				return err
+			}
+			if srcCipher, err = NewCipher(dataKey); err != nil {
+				return err
+			}
+		}
+		return s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(s.bucket)
+			return srcBucket.ForEach(func(k, v []byte) error {
+				if srcCipher != nil {
+					var err error
+					if v, err = srcCipher.Open(v); err != nil {
+						return err
+					}
+				}
+				return b.Put(k, s.seal(v))
+			})
+		})
+	})
+}
+
+// 删除bucket后重建
+func (s *Bolt) DropAll() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(s.bucket); err != nil && err != berrors.ErrBucketNotFound {
+			return err
+		}
+		_, err := tx.CreateBucket(s.bucket)
+		return err
+	})
+}
+
+// bolt在事务提交时已经fsync，这里只对NoSync模式有意义
+func (s *Bolt) Sync() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Sync()
+}
+
 // 释放所有数据库资源。在关闭数据库之前，必须先关闭所有事务。
 func (s *Bolt) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.db.Close()
 }
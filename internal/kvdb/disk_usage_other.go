@@ -0,0 +1,10 @@
+//go:build !unix
+
+package kvdb
+
+import "os"
+
+// 文件实际占用的磁盘空间，无法统计时按文件长度计算
+func diskUsage(info os.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package kvdb
+
+import (
+	"os"
+	"syscall"
+)
+
+// 文件实际占用的磁盘空间，稀疏文件中没有写入的部分不计算在内
+func diskUsage(info os.FileInfo) int64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(stat.Blocks) * 512
+	}
+	return info.Size()
+}
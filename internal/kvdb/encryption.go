@@ -0,0 +1,170 @@
+package kvdb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrEncryptionKeyRequired = errors.New("store is encrypted, an encryption key is required")
+	ErrEncryptionKeyMismatch = errors.New("encryption key does not match the store")
+	ErrPlaintextStore        = errors.New("store contains unencrypted data, re-encrypt it offline with the rekey command first")
+	ErrEncryptionUnsupported = errors.New("storage engine does not support encryption")
+)
+
+// badger每隔这段时间生成一个新的数据密钥，数据密钥用主密钥加密后保存在KEYREGISTRY文件中。0表示使用badger的默认值（10天）
+var BadgerKeyRotationDuration time.Duration
+
+// 开启加密后badger要求配置索引缓存，否则每次读取都要解密SST的索引块
+var BadgerEncryptedIndexCacheSize int64 = 100 << 20
+
+// LoadEncryptionKey 读取密钥文件。文件内容可以是16、24、32字节的原始密钥（对应AES-128、AES-192、AES-256），也可以是它们的十六进制编码
+func LoadEncryptionKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if text := bytes.TrimSpace(data); len(text) == 32 || len(text) == 48 || len(text) == 64 {
+		if key, err := hex.DecodeString(string(text)); err == nil {
+			return key, nil
+		}
+	}
+	if err := checkKey(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+func checkKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("invalid encryption key length %d, must be 16, 24 or 32 bytes", len(key))
+}
+
+// Cipher AES-GCM加密，密文格式：nonce(12字节) + 密文 + tag(16字节)
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+func NewCipher(key []byte) (*Cipher, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal 每次加密使用随机的nonce，相同的明文得到不同的密文
+func (c *Cipher) Seal(plain []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plain)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err) //crypto/rand不会失败
+	}
+	return c.aead.Seal(nonce, nonce, plain, nil)
+}
+
+// Open 解密并校验完整性，密钥不对或数据被篡改时返回error。返回的明文是新分配的
+func (c *Cipher) Open(sealed []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n+c.aead.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return c.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// 保存bolt数据密钥的bucket。数据密钥用主密钥加密后保存，更换主密钥时只需要重新加密数据密钥
+var boltKeyBucket = []byte("github.com/WlayRay/ElectricSearch/encryption")
+
+var boltDataKeyName = []byte("data-key")
+
+// 信封加密：value用随机生成的数据密钥加密，数据密钥用主密钥加密后保存在boltKeyBucket中。只加密value，key保持明文以支持按key遍历
+func (s *Bolt) initEncryption(tx *bolt.Tx) error {
+	var wrapped []byte
+	if b := tx.Bucket(boltKeyBucket); b != nil {
+		wrapped = b.Get(boltDataKeyName)
+	}
+	if wrapped == nil {
+		if s.key == nil {
+			return nil
+		}
+		if k, _ := tx.Bucket(s.bucket).Cursor().First(); k != nil {
+			return ErrPlaintextStore
+		}
+		return s.createDataKey(tx)
+	}
+	if s.key == nil {
+		return ErrEncryptionKeyRequired
+	}
+	dataKey, err := unwrapDataKey(s.key, wrapped)
+	if err != nil {
+		return err
+	}
+	s.cipher, err = NewCipher(dataKey)
+	return err
+}
+
+func (s *Bolt) createDataKey(tx *bolt.Tx) error {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	master, err := NewCipher(s.key)
+	if err != nil {
+		return err
+	}
+	b, err := tx.CreateBucketIfNotExists(boltKeyBucket)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(boltDataKeyName, master.Seal(dataKey)); err != nil {
+		return err
+	}
+	s.cipher, err = NewCipher(dataKey)
+	return err
+}
+
+func unwrapDataKey(masterKey, wrapped []byte) ([]byte, error) {
+	master, err := NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := master.Open(wrapped)
+	if err != nil {
+		return nil, ErrEncryptionKeyMismatch
+	}
+	return dataKey, nil
+}
+
+// 写入前加密value，未开启加密时原样返回
+func (s *Bolt) seal(v []byte) []byte {
+	if s.cipher == nil {
+		return v
+	}
+	return s.cipher.Seal(v)
+}
+
+// 读出后解密value。未开启加密时原样返回，此时返回的value只在事务内有效
+func (s *Bolt) open(v []byte) ([]byte, error) {
+	if s.cipher == nil || v == nil {
+		return v, nil
+	}
+	return s.cipher.Open(v)
+}
@@ -1,9 +1,14 @@
 package kvdb
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/WlayRay/ElectricSearch/util"
 )
@@ -11,26 +16,105 @@ import (
 const (
 	BOLT = iota
 	BADGER
+	MEMORY
 )
 
 // 操作各类数据库的接口
 type IKeyValueDB interface {
-	Open() error                              //初始化DB
-	GetDbPath() string                        //获取存储数据的目录
-	Set(k, v []byte) error                    //写入<key, value>
-	BatchSet(keys, values [][]byte) error     //批量写入<key, value>
-	Get(k []byte) ([]byte, error)             //读取key对应的value
-	BatchGet(keys [][]byte) ([][]byte, error) //批量读取，注意不保证顺序
-	Delete(k []byte) error                    //删除
-	BatchDelete(keys [][]byte) error          //批量删除
-	Has(k []byte) bool                        //判断某个key是否存在
-	IterDB(fn func(k, v []byte) error) int64  //遍历数据库，返回数据的条数
-	IterKey(fn func(k []byte) error) int64    //遍历数据库，返回key的条数
-	Close() error                             //把内存中的数据flush到磁盘，同时释放文件锁
-}
-
-// 工厂模式，可以根据传入的dbType构建不同的数据库产品，返回产品的接口
+	Open() error                                                                    //初始化DB
+	GetDbPath() string                                                              //获取存储数据的目录
+	Set(k, v []byte) error                                                          //写入<key, value>
+	SetWithTTL(k, v []byte, ttl time.Duration) error                                //写入<key, value>，ttl之后自动过期（不支持TTL的引擎直接写入）
+	BatchSet(keys, values [][]byte) error                                           //批量写入<key, value>
+	Get(k []byte) ([]byte, error)                                                   //读取key对应的value
+	BatchGet(keys [][]byte) ([][]byte, error)                                       //批量读取，返回的values与keys一一对应，key不存在或读取失败时对应的value长度为0
+	Delete(k []byte) error                                                          //删除
+	BatchDelete(keys [][]byte) error                                                //批量删除
+	Has(k []byte) bool                                                              //判断某个key是否存在
+	IterDB(fn func(k, v []byte) error) int64                                        //遍历数据库，返回数据的条数
+	IterKey(fn func(k []byte) error) int64                                          //遍历数据库，返回key的条数
+	Scan(start, end []byte, limit int, fn func(k, v []byte) error) (int64, error)   //按key升序遍历[start, end)，返回遍历的条数
+	PrefixScan(prefix []byte, limit int, fn func(k, v []byte) error) (int64, error) //按key升序遍历以prefix开头的数据
+	Backup(w io.Writer, since uint64) (uint64, error)                               //把版本号大于since的数据导出到w（since为0时全量导出），返回导出数据的最大版本号
+	Restore(r io.Reader) error                                                      //导入Backup导出的数据，与已有数据合并
+	DropAll() error                                                                 //删除全部数据
+	Sync() error                                                                    //把已写入的数据持久化到磁盘
+	Close() error                                                                   //把内存中的数据flush到磁盘，同时释放文件锁
+}
+
+// ErrIncrementalBackup 存储引擎没有数据版本，只能做全量备份
+var ErrIncrementalBackup = errors.New("incremental backup is not supported")
+
+// Scan和PrefixScan中，end为空表示没有上界，limit<=0表示不限制条数；回调函数中的k和v只在回调内有效。
+// 回调函数返回ErrStopScan时提前结束遍历，且不把它当作错误返回，返回其他error时结束遍历并返回该error
+var ErrStopScan = errors.New("stop scan")
+
+// NextKey 返回字节序上紧跟在k之后的key。从上次遍历到的最后一个key继续遍历时，把NextKey(lastKey)作为start
+func NextKey(k []byte) []byte {
+	next := make([]byte, len(k)+1)
+	copy(next, k)
+	return next
+}
+
+// prefix的上界（不包含），prefix为空或全是0xFF时没有上界，返回nil
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xFF {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// 判断k是否已经超出了[start, end)的上界，end为空表示没有上界
+func pastEnd(k, end []byte) bool {
+	return len(end) > 0 && bytes.Compare(k, end) >= 0
+}
+
+// keys按字节序排序后的下标，按这个顺序读取可以让存储引擎顺序访问磁盘
+func sortedKeyOrder(keys [][]byte) []int {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return bytes.Compare(keys[a], keys[b])
+	})
+	return order
+}
+
+// EngineOf 返回db对应的存储引擎类型，未知的实现返回-1
+func EngineOf(db IKeyValueDB) int {
+	switch db.(type) {
+	case *Bolt:
+		return BOLT
+	case *Badger:
+		return BADGER
+	case *Memory:
+		return MEMORY
+	}
+	return -1
+}
+
+// 工厂模式，可以根据传入的dbType构建不同的数据库产品，返回产品的接口。
+// MEMORY类型的path可以为空，此时数据只保存在内存中
 func GetKeyValueDB(dbType int, path string) (IKeyValueDB, error) {
+	return GetEncryptedKeyValueDB(dbType, path, nil)
+}
+
+// GetEncryptedKeyValueDB 与GetKeyValueDB相同，key非空时对落盘的数据加密。
+// badger使用原生加密，bolt对value做信封加密，memory不持久化时数据不落盘，开启AOF时不支持加密
+func GetEncryptedKeyValueDB(dbType int, path string, key []byte) (IKeyValueDB, error) {
+	if dbType == MEMORY && len(path) == 0 {
+		db := new(Memory)
+		return db, db.Open()
+	}
+	if dbType == MEMORY && len(key) > 0 {
+		return nil, ErrEncryptionUnsupported
+	}
+
 	paths := strings.Split(path, "/")
 	parentPath := strings.Join(paths[:len(paths)-1], "/") //获取父目录
 
@@ -52,9 +136,11 @@ func GetKeyValueDB(dbType int, path string) (IKeyValueDB, error) {
 
 	switch dbType {
 	case BOLT:
-		db = new(Bolt).WithDataPath(path).WithBucket("github.com/WlayRay/ElectricSearch")
+		db = new(Bolt).WithDataPath(path).WithBucket("github.com/WlayRay/ElectricSearch").WithEncryptionKey(key)
+	case MEMORY:
+		db = new(Memory).WithDataPath(path)
 	default: //默认使用badger
-		db = new(Badger).WithDataPath(path)
+		db = new(Badger).WithDataPath(path).WithEncryptionKey(key)
 	}
 	err = db.Open()
 	return db, err
@@ -0,0 +1,279 @@
+package kvdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/util"
+
+	"github.com/dgraph-io/badger/v4"
+	bolt "go.etcd.io/bbolt"
+)
+
+// MaintenanceReport 一次存储引擎维护的结果
+type MaintenanceReport struct {
+	Engine     int           // 存储引擎，BOLT、BADGER或MEMORY
+	Path       string        // 数据存放的路径
+	SizeBefore int64         // 维护前占用的磁盘空间，单位字节
+	SizeAfter  int64         // 维护后占用的磁盘空间，单位字节
+	StartedAt  time.Time     // 开始维护的时间
+	Duration   time.Duration // 维护的耗时
+}
+
+// IMaintainable 需要定期回收磁盘空间的存储引擎
+type IMaintainable interface {
+	DiskSize() int64                       //数据占用的磁盘空间，单位字节
+	Maintain() (*MaintenanceReport, error) //回收磁盘空间：badger做value log GC，bolt压缩到新文件，memory重写AOF
+}
+
+// value log中无效数据的比例超过这个值时才会被GC
+var BadgerGCDiscardRatio = 0.5
+
+// DiskSize LSM tree和value log实际占用的磁盘空间。badger的db.Size()每分钟才更新一次，刚GC完得到的是旧值，
+// 而且正在写的value log文件是按最大长度预先分配的稀疏文件，所以直接统计目录中文件实际占用的空间
+func (s *Badger) DiskSize() int64 {
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return 0
+	}
+	var size int64
+	for _, entry := range entries {
+		if ext := filepath.Ext(entry.Name()); ext != ".sst" && ext != ".vlog" {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			size += diskUsage(info)
+		}
+	}
+	return size
+}
+
+// Maintain 反复执行value log GC，直到没有可以回收的文件
+func (s *Badger) Maintain() (*MaintenanceReport, error) {
+	report := &MaintenanceReport{Engine: BADGER, Path: s.path, StartedAt: time.Now(), SizeBefore: s.DiskSize()}
+	for {
+		if err := s.db.RunValueLogGC(BadgerGCDiscardRatio); err != nil {
+			if err != badger.ErrNoRewrite && err != badger.ErrRejected {
+				return nil, err
+			}
+			break
+		}
+	}
+	report.SizeAfter = s.DiskSize()
+	report.Duration = time.Since(report.StartedAt)
+	return report, nil
+}
+
+// 数据库文件的大小
+func (s *Bolt) DiskSize() int64 {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// bolt删除数据后不会缩小文件，把数据压缩到一个新文件后替换原文件。期间阻塞所有读写
+func (s *Bolt) Maintain() (*MaintenanceReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := &MaintenanceReport{Engine: BOLT, Path: s.path, StartedAt: time.Now(), SizeBefore: s.DiskSize()}
+
+	tmpPath := s.path + ".compact"
+	_ = os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0o600, bolt.DefaultOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := bolt.Compact(dst, s.db, 64<<20); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := s.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	renameErr := os.Rename(tmpPath, s.path)
+	// 无论替换是否成功都要重新打开，保证db可用
+	db, err := bolt.Open(s.path, 0o600, bolt.DefaultOptions)
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	if renameErr != nil {
+		os.Remove(tmpPath)
+		return nil, renameErr
+	}
+	report.SizeAfter = s.DiskSize()
+	report.Duration = time.Since(report.StartedAt)
+	return report, nil
+}
+
+// AOF文件的大小，纯内存模式下为0
+func (s *Memory) DiskSize() int64 {
+	if len(s.path) == 0 {
+		return 0
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// 把AOF重写成只包含当前数据的紧凑文件，期间阻塞所有读写
+func (s *Memory) Maintain() (*MaintenanceReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := &MaintenanceReport{Engine: MEMORY, Path: s.path, StartedAt: time.Now(), SizeBefore: s.DiskSize()}
+	if s.aof != nil {
+		if err := s.aof.Close(); err != nil {
+			return nil, err
+		}
+		s.aof = nil
+		err := s.rewrite()
+		// 无论重写是否成功都要重新打开AOF，保证后续写入可以持久化
+		aof, openErr := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if openErr != nil {
+			return nil, openErr
+		}
+		s.aof = aof
+		if err != nil {
+			return nil, err
+		}
+	}
+	report.SizeAfter = s.DiskSize()
+	report.Duration = time.Since(report.StartedAt)
+	return report, nil
+}
+
+var ErrMaintenanceRunning = errors.New("maintenance is already running")
+
+// MaintenanceScheduler 定期维护存储引擎。每隔interval维护一次，或者数据比上次维护后增长超过sizeThreshold时提前维护
+type MaintenanceScheduler struct {
+	db            IMaintainable
+	interval      time.Duration // 0表示不定期维护
+	sizeThreshold int64         // 0表示不按大小触发
+	checkInterval time.Duration // 检查是否需要维护的间隔
+
+	running    sync.Mutex
+	mu         sync.Mutex // 保护lastReport
+	lastReport *MaintenanceReport
+	lastSize   int64
+	lastRun    time.Time
+	closeCh    chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewMaintenanceScheduler db没有实现IMaintainable时返回nil
+func NewMaintenanceScheduler(db IKeyValueDB) *MaintenanceScheduler {
+	maintainable, ok := db.(IMaintainable)
+	if !ok {
+		return nil
+	}
+	return &MaintenanceScheduler{
+		db:            maintainable,
+		checkInterval: time.Minute,
+		lastSize:      maintainable.DiskSize(),
+		lastRun:       time.Now(),
+	}
+}
+
+func (m *MaintenanceScheduler) WithInterval(interval time.Duration) *MaintenanceScheduler {
+	m.interval = interval
+	return m
+}
+
+func (m *MaintenanceScheduler) WithSizeThreshold(bytes int64) *MaintenanceScheduler {
+	m.sizeThreshold = bytes
+	return m
+}
+
+func (m *MaintenanceScheduler) WithCheckInterval(interval time.Duration) *MaintenanceScheduler {
+	m.checkInterval = interval
+	return m
+}
+
+// Start 启动后台协程，interval和sizeThreshold都为0时什么都不做
+func (m *MaintenanceScheduler) Start() {
+	if m.interval <= 0 && m.sizeThreshold <= 0 {
+		return
+	}
+	m.closeCh = make(chan struct{})
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !m.due() {
+					continue
+				}
+				if _, err := m.RunNow(); err != nil && err != ErrMaintenanceRunning {
+					util.Log.Printf("maintain %T failed: %v", m.db, err)
+				}
+			case <-m.closeCh:
+				return
+			}
+		}
+	}()
+}
+
+func (m *MaintenanceScheduler) due() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.interval > 0 && time.Since(m.lastRun) >= m.interval {
+		return true
+	}
+	return m.sizeThreshold > 0 && m.db.DiskSize()-m.lastSize >= m.sizeThreshold
+}
+
+// RunNow 立即维护一次。已有维护在进行时返回ErrMaintenanceRunning
+func (m *MaintenanceScheduler) RunNow() (*MaintenanceReport, error) {
+	if !m.running.TryLock() {
+		return nil, ErrMaintenanceRunning
+	}
+	defer m.running.Unlock()
+
+	report, err := m.db.Maintain()
+	m.mu.Lock()
+	m.lastRun = time.Now()
+	if err == nil {
+		m.lastReport = report
+		m.lastSize = report.SizeAfter
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	util.Log.Printf("maintain %s in %v, size %d -> %d", report.Path, report.Duration, report.SizeBefore, report.SizeAfter)
+	return report, nil
+}
+
+// LastReport 最近一次成功维护的结果，还没有维护过时返回nil
+func (m *MaintenanceScheduler) LastReport() *MaintenanceReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastReport
+}
+
+// Stop 停止后台协程，等待正在进行的维护结束
+func (m *MaintenanceScheduler) Stop() {
+	if m.closeCh == nil {
+		return
+	}
+	close(m.closeCh)
+	m.wg.Wait()
+	m.running.Lock()
+	m.running.Unlock()
+}
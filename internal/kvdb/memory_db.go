@@ -0,0 +1,451 @@
+package kvdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/util"
+
+	"github.com/huandu/skiplist"
+)
+
+// AOF中每条记录的操作类型
+const (
+	aofSet byte = iota + 1
+	aofDelete
+)
+
+type memoryValue struct {
+	value    []byte
+	expireAt int64 // 过期时间（Unix时间戳，单位纳秒），0表示永不过期
+}
+
+func (v memoryValue) expired(now int64) bool {
+	return v.expireAt > 0 && v.expireAt <= now
+}
+
+// Memory 基于跳表的有序内存存储。path非空时把每次写操作追加到AOF文件，Open时重放AOF恢复数据
+type Memory struct {
+	mu   sync.RWMutex
+	data *skiplist.SkipList // key为string(k)，按字节序排列
+	path string
+	aof  *os.File
+}
+
+func (s *Memory) WithDataPath(path string) *Memory {
+	s.path = path
+	return s
+}
+
+func (s *Memory) Open() error {
+	s.data = skiplist.New(skiplist.String)
+	if len(s.path) == 0 { // 纯内存模式，不做持久化
+		return nil
+	}
+	if err := s.replay(); err != nil {
+		return err
+	}
+	// 重放之后把当前数据重写成一个紧凑的AOF，去掉被覆盖和删除的记录
+	if err := s.rewrite(); err != nil {
+		return err
+	}
+	aof, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	s.aof = aof
+	return nil
+}
+
+func (s *Memory) GetDbPath() string {
+	return s.path
+}
+
+// 重放AOF。文件末尾不完整的记录（写入过程中进程退出）直接丢弃
+func (s *Memory) replay() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	n := 0
+	for {
+		op, k, v, err := readAOFRecord(reader)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			util.Log.Printf("aof %s is truncated after %d records: %v", s.path, n, err)
+			break
+		}
+		switch op {
+		case aofSet:
+			s.data.Set(string(k), v)
+		case aofDelete:
+			s.data.Remove(string(k))
+		}
+		n++
+	}
+	return nil
+}
+
+func (s *Memory) rewrite() error {
+	tmpPath := s.path + ".rewrite"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	now := time.Now().UnixNano()
+	for elem := s.data.Front(); elem != nil; elem = elem.Next() {
+		v := elem.Value.(memoryValue)
+		if v.expired(now) {
+			continue
+		}
+		if _, err := writer.Write(appendAOFRecord(nil, aofSet, []byte(elem.Key().(string)), v)); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// AOF记录格式：操作类型(1字节) + 过期时间(8字节) + key长度(uvarint) + key + value长度(uvarint) + value
+func appendAOFRecord(buf []byte, op byte, k []byte, v memoryValue) []byte {
+	buf = append(buf, op)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(v.expireAt))
+	buf = binary.AppendUvarint(buf, uint64(len(k)))
+	buf = append(buf, k...)
+	buf = binary.AppendUvarint(buf, uint64(len(v.value)))
+	return append(buf, v.value...)
+}
+
+func readAOFRecord(reader *bufio.Reader) (byte, []byte, memoryValue, error) {
+	var v memoryValue
+	op, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, v, err
+	}
+	if op != aofSet && op != aofDelete {
+		return 0, nil, v, fmt.Errorf("invalid aof operation %d", op)
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, nil, v, io.ErrUnexpectedEOF
+	}
+	v.expireAt = int64(binary.BigEndian.Uint64(header[:]))
+	k, err := readAOFBytes(reader)
+	if err != nil {
+		return 0, nil, v, err
+	}
+	if v.value, err = readAOFBytes(reader); err != nil {
+		return 0, nil, v, err
+	}
+	return op, k, v, nil
+}
+
+func readAOFBytes(reader *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// 调用方需持有写锁
+func (s *Memory) appendAOF(buf []byte) error {
+	if s.aof == nil {
+		return nil
+	}
+	_, err := s.aof.Write(buf)
+	return err
+}
+
+func (s *Memory) set(k, v []byte, expireAt int64) error {
+	value := memoryValue{value: append([]byte(nil), v...), expireAt: expireAt} //拷贝一份，调用方可能复用v
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendAOF(appendAOFRecord(nil, aofSet, k, value)); err != nil {
+		return err
+	}
+	s.data.Set(string(k), value)
+	return nil
+}
+
+// 写入<key, value>
+func (s *Memory) Set(k, v []byte) error {
+	return s.set(k, v, 0)
+}
+
+// 写入一个ttl之后自动过期的<key, value>
+func (s *Memory) SetWithTTL(k, v []byte, ttl time.Duration) error {
+	return s.set(k, v, time.Now().Add(ttl).UnixNano())
+}
+
+// 批量写入<key, value>，AOF中的多条记录一次写入
+func (s *Memory) BatchSet(keys, values [][]byte) error {
+	if len(keys) != len(values) {
+		return errors.New("the key and the value are not the same length")
+	}
+	buf := make([]byte, 0, 64*len(keys))
+	copies := make([]memoryValue, len(keys))
+	for i, key := range keys {
+		copies[i] = memoryValue{value: append([]byte(nil), values[i]...)}
+		buf = appendAOFRecord(buf, aofSet, key, copies[i])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendAOF(buf); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		s.data.Set(string(key), copies[i])
+	}
+	return nil
+}
+
+// 调用方需持有读锁
+func (s *Memory) get(k []byte, now int64) ([]byte, bool) {
+	elem := s.data.Get(string(k))
+	if elem == nil {
+		return nil, false
+	}
+	v := elem.Value.(memoryValue)
+	if v.expired(now) {
+		return nil, false
+	}
+	return v.value, true
+}
+
+// 读取key对应的value，key不存在时返回ErrNoData
+func (s *Memory) Get(k []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if v, ok := s.get(k, time.Now().UnixNano()); ok {
+		return v, nil
+	}
+	return nil, ErrNoData
+}
+
+// 批量读取，返回的values与传入的keys顺序保持一致。如果key不存在则对应的value是空数组
+func (s *Memory) BatchGet(keys [][]byte) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	now := time.Now().UnixNano()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, key := range keys {
+		if v, ok := s.get(key, now); ok {
+			values[i] = v
+		} else {
+			values[i] = []byte{}
+		}
+	}
+	return values, nil
+}
+
+// 删除
+func (s *Memory) Delete(k []byte) error {
+	return s.BatchDelete([][]byte{k})
+}
+
+// 批量删除
+func (s *Memory) BatchDelete(keys [][]byte) error {
+	var buf []byte
+	for _, key := range keys {
+		buf = appendAOFRecord(buf, aofDelete, key, memoryValue{})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendAOF(buf); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		s.data.Remove(string(key))
+	}
+	return nil
+}
+
+// 判断某个key是否存在
+func (s *Memory) Has(k []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.get(k, time.Now().UnixNano())
+	return ok
+}
+
+// 按key的字节序遍历数据库，返回数据的条数。遍历期间持有读锁，fn中不能写当前数据库
+func (s *Memory) IterDB(fn func(k, v []byte) error) int64 {
+	var total int64
+	now := time.Now().UnixNano()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for elem := s.data.Front(); elem != nil; elem = elem.Next() {
+		v := elem.Value.(memoryValue)
+		if v.expired(now) {
+			continue
+		}
+		if err := fn([]byte(elem.Key().(string)), v.value); err != nil {
+			return total
+		}
+		total++
+	}
+	return total
+}
+
+// 按key的字节序遍历数据库，返回key的条数
+func (s *Memory) IterKey(fn func(k []byte) error) int64 {
+	return s.IterDB(func(k, v []byte) error {
+		return fn(k)
+	})
+}
+
+// 在跳表上定位到第一个不小于start的key，按key升序遍历[start, end)
+func (s *Memory) Scan(start, end []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	var total int64
+	now := time.Now().UnixNano()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for elem := s.data.Find(string(start)); elem != nil; elem = elem.Next() {
+		k := []byte(elem.Key().(string))
+		if pastEnd(k, end) {
+			break
+		}
+		v := elem.Value.(memoryValue)
+		if v.expired(now) {
+			continue
+		}
+		if err := fn(k, v.value); err == ErrStopScan {
+			break
+		} else if err != nil {
+			return total, err
+		}
+		total++
+		if limit > 0 && total >= int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// 遍历以prefix开头的数据
+func (s *Memory) PrefixScan(prefix []byte, limit int, fn func(k, v []byte) error) (int64, error) {
+	return s.Scan(prefix, prefixEnd(prefix), limit, fn)
+}
+
+// 按AOF的记录格式导出全部未过期的数据。内存存储没有数据版本，since必须为0。
+// 写入的value不会被原地修改，加读锁时只记下当前的全部数据，写到w时不阻塞写操作
+func (s *Memory) Backup(w io.Writer, since uint64) (uint64, error) {
+	if since > 0 {
+		return 0, ErrIncrementalBackup
+	}
+	type entry struct {
+		key   string
+		value memoryValue
+	}
+	now := time.Now().UnixNano()
+	s.mu.RLock()
+	entries := make([]entry, 0, s.data.Len())
+	for elem := s.data.Front(); elem != nil; elem = elem.Next() {
+		if v := elem.Value.(memoryValue); !v.expired(now) {
+			entries = append(entries, entry{key: elem.Key().(string), value: v})
+		}
+	}
+	s.mu.RUnlock()
+
+	writer := bufio.NewWriter(w)
+	var buf []byte
+	for _, e := range entries {
+		buf = appendAOFRecord(buf[:0], aofSet, []byte(e.key), e.value)
+		if _, err := writer.Write(buf); err != nil {
+			return 0, err
+		}
+	}
+	return 0, writer.Flush()
+}
+
+// 导入Backup导出的数据，同时追加到AOF
+func (s *Memory) Restore(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	var buf []byte
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		op, k, v, err := readAOFRecord(reader)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		buf = appendAOFRecord(buf[:0], op, k, v)
+		if err := s.appendAOF(buf); err != nil {
+			return err
+		}
+		switch op {
+		case aofSet:
+			s.data.Set(string(k), v)
+		case aofDelete:
+			s.data.Remove(string(k))
+		}
+	}
+	return nil
+}
+
+// 清空数据，AOF也随之截断
+func (s *Memory) DropAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Init()
+	if s.aof == nil {
+		return nil
+	}
+	return s.aof.Truncate(0)
+}
+
+// 把AOF文件fsync到磁盘，纯内存模式下什么都不做
+func (s *Memory) Sync() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.aof == nil {
+		return nil
+	}
+	return s.aof.Sync()
+}
+
+// 关闭AOF文件，纯内存模式下数据随之丢弃
+func (s *Memory) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Init()
+	if s.aof == nil {
+		return nil
+	}
+	err := s.aof.Close()
+	s.aof = nil
+	return err
+}
@@ -0,0 +1,312 @@
+package kvdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ParseEngine 把init.yml中db-type的取值（bolt、badger、memory）转换成存储引擎类型
+func ParseEngine(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "bolt":
+		return BOLT, nil
+	case "badger":
+		return BADGER, nil
+	case "memory":
+		return MEMORY, nil
+	}
+	return -1, fmt.Errorf("unknown db type %q", name)
+}
+
+// MigrateProgress 迁移进度，每写完一批回调一次
+type MigrateProgress struct {
+	Copied  int64         // 已经写入目标库的条数（包括断点之前写入的）
+	LastKey []byte        // 最后写入的key
+	Elapsed time.Duration // 本次运行的耗时
+}
+
+// MigrateReport 迁移结果
+type MigrateReport struct {
+	Copied     int64 // 写入目标库的条数（包括断点之前写入的）
+	Resumed    bool  // 是否从断点继续
+	SourceKeys int64 // 校验时源库的条数，没有校验时为0
+	TargetKeys int64 // 校验时目标库的条数
+	Verified   int64 // 校验时在目标库中找到且value一致的条数
+	Mismatched int64 // 校验时在目标库中缺失或value不一致的条数
+	Extra      int64 // 校验时目标库中有、源库中没有的条数
+	Duration   time.Duration
+}
+
+var (
+	ErrMigrateMismatch = errors.New("migrated data does not match the source")
+	ErrMigrateNotEmpty = errors.New("migration destination is not empty")
+)
+
+// 断点文件的内容，记录源库和目标库，防止用错断点
+type migrateCheckpoint struct {
+	From    string
+	To      string
+	LastKey []byte
+	Copied  int64
+}
+
+// Migrator 在两个存储引擎之间复制数据。按key升序分批Scan源库并BatchSet到目标库，每批写完后把最后一个key记到断点文件，中断后可以从断点继续。
+// 只复制<key, value>，存储引擎自身的TTL不会迁移（文档的过期时间保存在value中，加载索引时会重新计算）
+type Migrator struct {
+	src            IKeyValueDB
+	dst            IKeyValueDB
+	batchSize      int
+	checkpointPath string                      // 为空时不支持断点续传
+	verify         bool                        // 复制完成后逐条核对目标库
+	overwrite      bool                        // 目标库不为空时先清空，否则拒绝迁移
+	progress       func(MigrateProgress) error // 返回error时中断迁移，已写入的数据和断点会保留
+}
+
+func NewMigrator(src, dst IKeyValueDB) *Migrator {
+	return &Migrator{src: src, dst: dst, batchSize: 1000, verify: true}
+}
+
+func (m *Migrator) WithBatchSize(n int) *Migrator {
+	if n > 0 {
+		m.batchSize = n
+	}
+	return m
+}
+
+func (m *Migrator) WithCheckpoint(path string) *Migrator {
+	m.checkpointPath = path
+	return m
+}
+
+func (m *Migrator) WithVerify(verify bool) *Migrator {
+	m.verify = verify
+	return m
+}
+
+// WithOverwrite 允许迁移到已有数据的目标库，开始复制前清空目标库。从断点继续时不清空
+func (m *Migrator) WithOverwrite(overwrite bool) *Migrator {
+	m.overwrite = overwrite
+	return m
+}
+
+func (m *Migrator) WithProgress(fn func(MigrateProgress) error) *Migrator {
+	m.progress = fn
+	return m
+}
+
+// Run 执行迁移。不是从断点继续时目标库必须为空，否则返回ErrMigrateNotEmpty，除非指定了WithOverwrite。
+// 复制全部完成并且校验通过后删除断点文件；校验不通过时返回ErrMigrateMismatch，同时返回report
+func (m *Migrator) Run() (*MigrateReport, error) {
+	begin := time.Now()
+	report := &MigrateReport{}
+	cp, err := m.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	var start []byte
+	if cp.LastKey != nil {
+		start = NextKey(cp.LastKey)
+		report.Resumed = true
+	} else if err := m.prepareTarget(); err != nil {
+		return nil, err
+	}
+	report.Copied = cp.Copied
+
+	keys := make([][]byte, 0, m.batchSize)
+	values := make([][]byte, 0, m.batchSize)
+	for {
+		keys, values = keys[:0], values[:0]
+		if _, err := m.src.Scan(start, nil, m.batchSize, func(k, v []byte) error {
+			keys = append(keys, bytes.Clone(k)) //k和v只在回调内有效
+			values = append(values, bytes.Clone(v))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			break
+		}
+		if err := m.dst.BatchSet(keys, values); err != nil {
+			return nil, err
+		}
+		// 先持久化数据再记断点，断点之前的数据一定已经落盘
+		if err := m.dst.Sync(); err != nil {
+			return nil, err
+		}
+		last := keys[len(keys)-1]
+		cp.LastKey = last
+		cp.Copied += int64(len(keys))
+		report.Copied = cp.Copied
+		if err := m.saveCheckpoint(cp); err != nil {
+			return nil, err
+		}
+		if m.progress != nil {
+			if err := m.progress(MigrateProgress{Copied: cp.Copied, LastKey: last, Elapsed: time.Since(begin)}); err != nil {
+				return nil, err
+			}
+		}
+		if len(keys) < m.batchSize {
+			break
+		}
+		start = NextKey(last)
+	}
+
+	if m.verify {
+		if err := m.verifyAll(report); err != nil {
+			return nil, err
+		}
+	}
+	report.Duration = time.Since(begin)
+	if report.Mismatched > 0 || report.Extra > 0 {
+		return report, ErrMigrateMismatch
+	}
+	if len(m.checkpointPath) > 0 {
+		if err := os.Remove(m.checkpointPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// 目标库中已有数据时，按overwrite清空或者拒绝迁移
+func (m *Migrator) prepareTarget() error {
+	n, err := m.dst.Scan(nil, nil, 1, func(k, v []byte) error { return nil })
+	if err != nil || n == 0 {
+		return err
+	}
+	if !m.overwrite {
+		return fmt.Errorf("%w: %s", ErrMigrateNotEmpty, m.dst.GetDbPath())
+	}
+	return m.dst.DropAll()
+}
+
+// Verify 只校验不复制，用于确认已经完成的迁移。有缺失、不一致或者多出来的数据时返回ErrMigrateMismatch，同时返回report
+func (m *Migrator) Verify() (*MigrateReport, error) {
+	begin := time.Now()
+	report := &MigrateReport{}
+	if err := m.verifyAll(report); err != nil {
+		return nil, err
+	}
+	report.Duration = time.Since(begin)
+	if report.Mismatched > 0 || report.Extra > 0 {
+		return report, ErrMigrateMismatch
+	}
+	return report, nil
+}
+
+// 先按批遍历源库，用BatchGet在目标库中逐条核对value；再遍历目标库，找出源库中没有的key
+func (m *Migrator) verifyAll(report *MigrateReport) error {
+	if err := m.verifySource(report); err != nil {
+		return err
+	}
+	return m.verifyTarget(report)
+}
+
+func (m *Migrator) verifySource(report *MigrateReport) error {
+	var start []byte
+	keys := make([][]byte, 0, m.batchSize)
+	values := make([][]byte, 0, m.batchSize)
+	for {
+		keys, values = keys[:0], values[:0]
+		if _, err := m.src.Scan(start, nil, m.batchSize, func(k, v []byte) error {
+			keys = append(keys, bytes.Clone(k))
+			values = append(values, bytes.Clone(v))
+			return nil
+		}); err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		got, err := m.dst.BatchGet(keys)
+		if err != nil {
+			return err
+		}
+		for i := range keys {
+			report.SourceKeys++
+			// BatchGet对不存在的key返回空value，value为空时再确认目标库中有这个key
+			if bytes.Equal(got[i], values[i]) && (len(got[i]) > 0 || m.dst.Has(keys[i])) {
+				report.Verified++
+			} else {
+				report.Mismatched++
+			}
+		}
+		if len(keys) < m.batchSize {
+			return nil
+		}
+		start = NextKey(keys[len(keys)-1])
+	}
+}
+
+func (m *Migrator) verifyTarget(report *MigrateReport) error {
+	var start []byte
+	keys := make([][]byte, 0, m.batchSize)
+	for {
+		keys = keys[:0]
+		if _, err := m.dst.Scan(start, nil, m.batchSize, func(k, v []byte) error {
+			keys = append(keys, bytes.Clone(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		got, err := m.src.BatchGet(keys)
+		if err != nil {
+			return err
+		}
+		report.TargetKeys += int64(len(keys))
+		for i := range keys {
+			if len(got[i]) == 0 && !m.src.Has(keys[i]) {
+				report.Extra++
+			}
+		}
+		if len(keys) < m.batchSize {
+			return nil
+		}
+		start = NextKey(keys[len(keys)-1])
+	}
+}
+
+func (m *Migrator) loadCheckpoint() (*migrateCheckpoint, error) {
+	cp := &migrateCheckpoint{From: m.src.GetDbPath(), To: m.dst.GetDbPath()}
+	if len(m.checkpointPath) == 0 {
+		return cp, nil
+	}
+	data, err := os.ReadFile(m.checkpointPath)
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var saved migrateCheckpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", m.checkpointPath, err)
+	}
+	if saved.From != cp.From || saved.To != cp.To {
+		return nil, fmt.Errorf("checkpoint %s belongs to migration %s -> %s", m.checkpointPath, saved.From, saved.To)
+	}
+	return &saved, nil
+}
+
+// 先写临时文件再rename，避免中断时留下不完整的断点
+func (m *Migrator) saveCheckpoint(cp *migrateCheckpoint) error {
+	if len(m.checkpointPath) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := m.checkpointPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.checkpointPath)
+}
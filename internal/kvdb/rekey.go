@@ -0,0 +1,127 @@
+package kvdb
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	bolt "go.etcd.io/bbolt"
+)
+
+// RewrapKey 离线更换主密钥：只用新主密钥重新加密数据密钥，不重写数据，所以很快。
+// oldKey和newKey都不能为空；在明文和密文之间转换需要用Reencrypt
+func RewrapKey(dbType int, path string, oldKey, newKey []byte) error {
+	if len(oldKey) == 0 || len(newKey) == 0 {
+		return errors.New("both old and new keys are required to rewrap, use Reencrypt to encrypt or decrypt a store")
+	}
+	if err := checkKey(newKey); err != nil {
+		return err
+	}
+	switch dbType {
+	case BOLT:
+		return rewrapBoltKey(path, oldKey, newKey)
+	case BADGER:
+		return rewrapBadgerKey(path, oldKey, newKey)
+	}
+	return ErrEncryptionUnsupported
+}
+
+func rewrapBoltKey(path string, oldKey, newKey []byte) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltKeyBucket)
+		if b == nil || b.Get(boltDataKeyName) == nil {
+			return ErrPlaintextStore
+		}
+		dataKey, err := unwrapDataKey(oldKey, b.Get(boltDataKeyName))
+		if err != nil {
+			return err
+		}
+		master, err := NewCipher(newKey)
+		if err != nil {
+			return err
+		}
+		return b.Put(boltDataKeyName, master.Seal(dataKey))
+	})
+}
+
+// badger的数据密钥保存在KEYREGISTRY文件中，用新主密钥重写这个文件
+func rewrapBadgerKey(path string, oldKey, newKey []byte) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	opt := badger.KeyRegistryOptions{
+		Dir:                           path,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: BadgerKeyRotationDuration,
+	}
+	if opt.EncryptionKeyRotationDuration <= 0 {
+		opt.EncryptionKeyRotationDuration = badger.DefaultOptions(path).EncryptionKeyRotationDuration
+	}
+	kr, err := badger.OpenKeyRegistry(opt)
+	if errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		return ErrEncryptionKeyMismatch
+	} else if err != nil {
+		return err
+	}
+	defer kr.Close()
+	opt.EncryptionKey = newKey
+	return badger.WriteKeyRegistry(kr, opt)
+}
+
+// Reencrypt 离线重新加密整个库：用newKey创建一个新库（路径为path+".rekey"），把数据逐条复制过去并校验，再替换原来的库。
+// oldKey为空表示原来的库没有加密，newKey为空表示解密成明文。复制中断后重新执行会从断点继续
+func Reencrypt(dbType int, path string, oldKey, newKey []byte, batchSize int, progress func(MigrateProgress) error) (*MigrateReport, error) {
+	tmpPath := path + ".rekey"
+	oldPath := path + ".old"
+	// 上次在两次rename之间中断，原来的库已经改名为oldPath，新库已经复制完成
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(oldPath); err == nil {
+			return &MigrateReport{}, finishReencrypt(path, tmpPath, oldPath)
+		}
+		return nil, err
+	}
+
+	src, err := GetEncryptedKeyValueDB(dbType, path, oldKey)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := GetEncryptedKeyValueDB(dbType, tmpPath, newKey)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	report, err := NewMigrator(src, dst).
+		WithBatchSize(batchSize).
+		WithCheckpoint(tmpPath + ".migrate").
+		WithOverwrite(true). // 临时库中没有断点的数据是上次中断时留下的
+		WithProgress(progress).
+		Run()
+	src.Close()
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return report, err
+	}
+	if err := os.Rename(path, oldPath); err != nil {
+		return report, err
+	}
+	return report, finishReencrypt(path, tmpPath, oldPath)
+}
+
+func finishReencrypt(path, tmpPath, oldPath string) error {
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return os.RemoveAll(oldPath)
+}
@@ -0,0 +1,106 @@
+package kvdbtest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, 32)
+	newKey = bytes.Repeat([]byte{2}, 16)
+)
+
+func TestEncryptedBolt(t *testing.T) {
+	path := util.RootPath + "data/encrypted/bolt"
+	os.RemoveAll(util.RootPath + "data/encrypted")
+	setup = func() {
+		var err error
+		db, err = kvdb.GetEncryptedKeyValueDB(kvdb.BOLT, path, oldKey)
+		if err != nil {
+			panic(err)
+		}
+	}
+	t.Run("bolt_test", testPipeline)
+
+	// value在文件中不是明文
+	db, _ := kvdb.GetEncryptedKeyValueDB(kvdb.BOLT, path, oldKey)
+	db.Set([]byte("secret"), []byte("plain-text-value"))
+	db.Close()
+	if data, _ := os.ReadFile(path); bytes.Contains(data, []byte("plain-text-value")) {
+		t.Error("value is stored in plain form")
+	}
+	testKeyErrors(t, kvdb.BOLT, path)
+}
+
+func TestEncryptedBadger(t *testing.T) {
+	path := util.RootPath + "data/encrypted/badger"
+	os.RemoveAll(util.RootPath + "data/encrypted")
+	setup = func() {
+		var err error
+		db, err = kvdb.GetEncryptedKeyValueDB(kvdb.BADGER, path, oldKey)
+		if err != nil {
+			panic(err)
+		}
+	}
+	t.Run("badger_test", testPipeline)
+
+	db, _ := kvdb.GetEncryptedKeyValueDB(kvdb.BADGER, path, oldKey)
+	db.Set([]byte("secret"), []byte("plain-text-value"))
+	db.Close()
+	testKeyErrors(t, kvdb.BADGER, path)
+}
+
+// 用错误的密钥打开会失败；更换主密钥后旧密钥失效，新密钥可以读出数据；最后解密成明文
+func testKeyErrors(t *testing.T, engine int, path string) {
+	if _, err := kvdb.GetEncryptedKeyValueDB(engine, path, nil); !errors.Is(err, kvdb.ErrEncryptionKeyRequired) {
+		t.Fatalf("open without key: %v", err)
+	}
+	if _, err := kvdb.GetEncryptedKeyValueDB(engine, path, newKey); !errors.Is(err, kvdb.ErrEncryptionKeyMismatch) {
+		t.Fatalf("open with wrong key: %v", err)
+	}
+
+	if err := kvdb.RewrapKey(engine, path, oldKey, newKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kvdb.GetEncryptedKeyValueDB(engine, path, oldKey); !errors.Is(err, kvdb.ErrEncryptionKeyMismatch) {
+		t.Fatalf("open with old key after rewrap: %v", err)
+	}
+	expectSecret(t, engine, path, newKey)
+
+	report, err := kvdb.Reencrypt(engine, path, newKey, nil, 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Mismatched != 0 || report.Verified != report.Copied {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	expectSecret(t, engine, path, nil)
+
+	// 明文库需要先重新加密才能用密钥打开
+	if engine == kvdb.BOLT {
+		if _, err := kvdb.GetEncryptedKeyValueDB(engine, path, oldKey); !errors.Is(err, kvdb.ErrPlaintextStore) {
+			t.Fatalf("open plaintext store with key: %v", err)
+		}
+	}
+	if _, err := kvdb.Reencrypt(engine, path, nil, oldKey, 100, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectSecret(t, engine, path, oldKey)
+}
+
+func expectSecret(t *testing.T, engine int, path string, key []byte) {
+	t.Helper()
+	db, err := kvdb.GetEncryptedKeyValueDB(engine, path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if v, err := db.Get([]byte("secret")); err != nil || string(v) != "plain-text-value" {
+		t.Fatalf("secret=%s, err=%v", v, err)
+	}
+}
@@ -1,6 +1,7 @@
 package kvdbtest
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"testing"
@@ -77,15 +78,14 @@ func testBatchGetSetDelete(db kvdb.IKeyValueDB) error {
 
 	db.BatchSet([][]byte{k1, k2}, [][]byte{v1, v2})
 
-	values, err := db.BatchGet([][]byte{k1, k2})
+	// 按与字节序相反的顺序读取，结果仍与传入的keys一一对应
+	values, err := db.BatchGet([][]byte{k2, []byte("k0"), k1})
 	if err != nil {
 		return err
 	}
-	fmt.Printf("values ")
-	for _, v := range values {
-		fmt.Printf("%s ", string(v))
+	if len(values) != 3 || string(values[0]) != "v2" || len(values[1]) != 0 || string(values[2]) != "v1" {
+		return fmt.Errorf("BatchGet got %q", values)
 	}
-	fmt.Println()
 
 	db.BatchDelete([][]byte{k1, k2})
 
@@ -127,6 +127,81 @@ func testIterDB(db kvdb.IKeyValueDB) error {
 	return nil
 }
 
+func testScan(db kvdb.IKeyValueDB) error {
+	keys := [][]byte{[]byte("doc_3"), []byte("doc_1"), []byte("img_1"), []byte("doc_2"), []byte("doc_10")}
+	values := [][]byte{[]byte("v3"), []byte("v1"), []byte("i1"), []byte("v2"), []byte("v10")}
+	db.BatchSet(keys, values)
+	defer db.BatchDelete(keys)
+
+	var scanned []string
+	collect := func(k, v []byte) error {
+		scanned = append(scanned, string(k))
+		return nil
+	}
+	expect := func(want ...string) error {
+		defer func() { scanned = nil }()
+		if fmt.Sprint(scanned) != fmt.Sprint(want) {
+			return fmt.Errorf("scan got %v, want %v", scanned, want)
+		}
+		return nil
+	}
+
+	db.Scan([]byte("doc_1"), []byte("doc_3"), 0, collect)
+	if err := expect("doc_1", "doc_10", "doc_2"); err != nil {
+		return err
+	}
+	db.Scan(kvdb.NextKey([]byte("doc_10")), nil, 2, collect) //从上次遍历到的key之后继续
+	if err := expect("doc_2", "doc_3"); err != nil {
+		return err
+	}
+	db.PrefixScan([]byte("doc_"), 0, collect)
+	if err := expect("doc_1", "doc_10", "doc_2", "doc_3"); err != nil {
+		return err
+	}
+	n, err := db.PrefixScan([]byte("doc_"), 0, func(k, v []byte) error {
+		if string(k) == "doc_2" {
+			return kvdb.ErrStopScan
+		}
+		return nil
+	})
+	if err != nil || n != 2 {
+		return fmt.Errorf("stop scan got n=%d err=%v", n, err)
+	}
+
+	// BatchGet的结果与传入的keys一一对应，不受key的字节序影响
+	ordered, err := db.BatchGet([][]byte{[]byte("img_1"), []byte("none"), []byte("doc_1")})
+	if err != nil {
+		return err
+	}
+	if len(ordered) != 3 || string(ordered[0]) != "i1" || len(ordered[1]) != 0 || string(ordered[2]) != "v1" {
+		return fmt.Errorf("BatchGet got %q", ordered)
+	}
+	return nil
+}
+
+func testBackupRestore(db kvdb.IKeyValueDB) error {
+	db.BatchSet([][]byte{[]byte("b1"), []byte("b2")}, [][]byte{[]byte("v1"), []byte("v2")})
+	defer db.BatchDelete([][]byte{[]byte("b1"), []byte("b2")})
+
+	var backup bytes.Buffer
+	if _, err := db.Backup(&backup, 0); err != nil {
+		return err
+	}
+	if err := db.DropAll(); err != nil {
+		return err
+	}
+	if db.Has([]byte("b1")) {
+		return errors.New("key仍然存在，DropAll没有删除数据")
+	}
+	if err := db.Restore(&backup); err != nil {
+		return err
+	}
+	if v, err := db.Get([]byte("b2")); err != nil || string(v) != "v2" {
+		return fmt.Errorf("restore b2=%s, err=%v", v, err)
+	}
+	return nil
+}
+
 func testPipeline(t *testing.T) { //整个测试流
 	defer teardown()
 	setup()
@@ -154,4 +229,18 @@ func testPipeline(t *testing.T) { //整个测试流
 		t.Fail()
 	}
 	fmt.Println()
+
+	err = testScan(db)
+	if err != nil {
+		fmt.Println(err)
+		t.Fail()
+	}
+	fmt.Println()
+
+	err = testBackupRestore(db)
+	if err != nil {
+		fmt.Println(err)
+		t.Fail()
+	}
+	fmt.Println()
 }
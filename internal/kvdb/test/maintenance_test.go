@@ -0,0 +1,86 @@
+package kvdbtest
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+// 大量写入再删除后维护，磁盘占用不应增大，保留的数据仍然可读
+func testMaintain(t *testing.T, engine int, path string) {
+	os.RemoveAll(path)
+	db, err := kvdb.GetKeyValueDB(engine, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	keys := make([][]byte, 0, 2000)
+	values := make([][]byte, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("key_%04d", i)))
+		values = append(values, make([]byte, 1024))
+	}
+	db.BatchSet(keys, values)
+	db.BatchDelete(keys[1:])
+
+	scheduler := kvdb.NewMaintenanceScheduler(db)
+	if scheduler == nil {
+		t.Fatalf("engine %d does not support maintenance", engine)
+	}
+	report, err := scheduler.RunNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("maintain %s, size %d -> %d", report.Path, report.SizeBefore, report.SizeAfter)
+	if report.SizeAfter > report.SizeBefore {
+		t.Errorf("size grew after maintenance: %d -> %d", report.SizeBefore, report.SizeAfter)
+	}
+	if scheduler.LastReport() != report {
+		t.Error("LastReport does not return the latest report")
+	}
+	if v, err := db.Get(keys[0]); err != nil || len(v) != 1024 {
+		t.Errorf("read after maintenance: len=%d err=%v", len(v), err)
+	}
+	if db.Has(keys[1]) {
+		t.Error("deleted key exists after maintenance")
+	}
+}
+
+func TestMaintain(t *testing.T) {
+	t.Run("bolt", func(t *testing.T) {
+		testMaintain(t, kvdb.BOLT, util.RootPath+"data/maintain_bolt_db")
+	})
+	t.Run("memory", func(t *testing.T) {
+		testMaintain(t, kvdb.MEMORY, util.RootPath+"data/memory_db/maintain.aof")
+	})
+	t.Run("badger", func(t *testing.T) {
+		testMaintain(t, kvdb.BADGER, util.RootPath+"data/maintain_badger_db")
+	})
+}
+
+// badger的磁盘占用要反映刚写入的数据，不能用每分钟才更新一次的统计，也不能把预先分配的value log算进去
+func TestBadgerDiskSize(t *testing.T) {
+	db, err := kvdb.GetKeyValueDB(kvdb.BADGER, t.TempDir()+"/badger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sized := db.(kvdb.IMaintainable)
+	before := sized.DiskSize()
+	if before > 1<<20 {
+		t.Fatalf("empty db takes %d bytes", before)
+	}
+	const valueSize = 2 << 20 // 大于ValueThreshold，直接写入value log
+	for i := 0; i < 3; i++ {
+		if err := db.Set([]byte(fmt.Sprintf("big_%d", i)), make([]byte, valueSize)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := sized.DiskSize(); after-before < 3*valueSize {
+		t.Fatalf("disk size %d -> %d after writing %d bytes", before, after, 3*valueSize)
+	}
+}
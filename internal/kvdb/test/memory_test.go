@@ -0,0 +1,58 @@
+package kvdbtest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+func TestMemory(t *testing.T) {
+	setup = func() {
+		var err error
+		db, err = kvdb.GetKeyValueDB(kvdb.MEMORY, "") //不持久化
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	t.Run("memory_test", testPipeline)
+}
+
+func TestMemoryAOF(t *testing.T) {
+	path := util.RootPath + "data/memory_db/memory.aof"
+	os.Remove(path)
+	db, err := kvdb.GetKeyValueDB(kvdb.MEMORY, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.BatchSet([][]byte{[]byte("k2"), []byte("k1"), []byte("k3")}, [][]byte{[]byte("v2"), []byte("v1"), []byte("v3")})
+	db.Set([]byte("k1"), []byte("v1-new"))
+	db.Delete([]byte("k3"))
+	db.SetWithTTL([]byte("k4"), []byte("v4"), time.Millisecond)
+	db.Close()
+
+	time.Sleep(10 * time.Millisecond)
+	db, err = kvdb.GetKeyValueDB(kvdb.MEMORY, path) //重放AOF
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var keys []string
+	db.IterKey(func(k []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	})
+	if len(keys) != 2 || keys[0] != "k1" || keys[1] != "k2" {
+		t.Fatalf("unexpected keys after replay: %v", keys)
+	}
+	if v, err := db.Get([]byte("k1")); err != nil || string(v) != "v1-new" {
+		t.Fatalf("k1=%s, err=%v", v, err)
+	}
+	if _, err := db.Get([]byte("k3")); err != kvdb.ErrNoData {
+		t.Fatalf("deleted key k3 is still readable, err=%v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package kvdbtest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+func TestMigrate(t *testing.T) {
+	srcPath := util.RootPath + "data/migrate/bolt"
+	dstPath := util.RootPath + "data/migrate/badger"
+	checkpoint := dstPath + ".migrate"
+	os.RemoveAll(util.RootPath + "data/migrate")
+
+	src, err := kvdb.GetKeyValueDB(kvdb.BOLT, srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	const total = 250
+	for i := 0; i < total; i++ {
+		src.Set([]byte(fmt.Sprintf("doc_%03d", i)), []byte(fmt.Sprintf("value_%d", i)))
+	}
+	dst, err := kvdb.GetKeyValueDB(kvdb.BADGER, dstPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// 写完第二批后中断，断点文件保留
+	stop := errors.New("stop")
+	_, err = kvdb.NewMigrator(src, dst).WithBatchSize(100).WithCheckpoint(checkpoint).
+		WithProgress(func(p kvdb.MigrateProgress) error {
+			if p.Copied >= 200 {
+				return stop
+			}
+			return nil
+		}).Run()
+	if err != stop {
+		t.Fatalf("expect interrupted, got %v", err)
+	}
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := kvdb.NewMigrator(src, dst).WithBatchSize(100).WithCheckpoint(checkpoint).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Resumed || report.Copied != total || report.SourceKeys != total || report.Verified != total || report.Mismatched != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Fatalf("checkpoint should be removed after migration, got %v", err)
+	}
+	if v, err := dst.Get([]byte("doc_249")); err != nil || string(v) != "value_249" {
+		t.Fatalf("doc_249=%s, err=%v", v, err)
+	}
+
+	// 目标库被改动后校验不通过
+	dst.Set([]byte("doc_000"), []byte("changed"))
+	report, err = kvdb.NewMigrator(src, dst).WithBatchSize(100).Verify()
+	if err != kvdb.ErrMigrateMismatch || report.Mismatched != 1 {
+		t.Fatalf("expect 1 mismatch, got %v %+v", err, report)
+	}
+	// 目标库中多出源库没有的数据也校验不通过
+	dst.Set([]byte("doc_000"), []byte("value_0"))
+	dst.Set([]byte("extra"), []byte("extra"))
+	report, err = kvdb.NewMigrator(src, dst).WithBatchSize(100).Verify()
+	if err != kvdb.ErrMigrateMismatch || report.Mismatched != 0 || report.Extra != 1 || report.TargetKeys != total+1 {
+		t.Fatalf("expect 1 extra record, got %v %+v", err, report)
+	}
+
+	// 目标库不为空时拒绝迁移，指定overwrite时先清空
+	if _, err := kvdb.NewMigrator(src, dst).WithBatchSize(100).Run(); !errors.Is(err, kvdb.ErrMigrateNotEmpty) {
+		t.Fatalf("expect destination not empty, got %v", err)
+	}
+	report, err = kvdb.NewMigrator(src, dst).WithBatchSize(100).WithOverwrite(true).Run()
+	if err != nil || report.Verified != total || report.Extra != 0 {
+		t.Fatalf("overwrite: %v %+v", err, report)
+	}
+	if dst.Has([]byte("extra")) {
+		t.Fatal("overwrite should clear the destination")
+	}
+}
@@ -1,6 +1,9 @@
 package reverseindex
 
 import (
+	"context"
+	"io"
+
 	"github.com/WlayRay/ElectricSearch/types"
 )
 
@@ -12,6 +15,35 @@ type IReverseIndex interface {
 	// 删除Keyword对应的Document
 	Delete(IntId uint64, keyword *types.Keyword)
 
-	// 搜索，返回文档ID列表
-	Search(q *types.TermQuery, onFlag uint64, offFlag uint64, orFlags []uint64) []string
+	// 搜索，返回文档ID列表。ctx结束时中止搜索并返回ctx.Err()
+	Search(ctx context.Context, q *types.TermQuery, onFlag uint64, offFlag uint64, orFlags []uint64) ([]string, error)
+}
+
+// ISnapshotter 可以导出和导入快照的倒排索引，用于分片的备份与恢复
+type ISnapshotter interface {
+	// 把整个倒排索引写入w
+	Snapshot(w io.Writer) error
+
+	// 从Snapshot导出的数据中加载倒排索引，同一个Keyword的倒排链会被覆盖
+	LoadSnapshot(r io.Reader) error
+
+	// 清空倒排索引
+	Reset()
+}
+
+// IStatsReporter 可以统计自身规模的倒排索引
+type IStatsReporter interface {
+	Stats() *Stats
+}
+
+// Stats 倒排索引的统计信息
+type Stats struct {
+	FieldTerms   map[string]int // 每个Field下不同Keyword的数量
+	PostingLists int            // 非空倒排链的数量
+	Postings     int64          // 所有倒排链的元素总数
+	PostingP50   int            // 倒排链长度的50分位数
+	PostingP90   int
+	PostingP99   int
+	PostingMax   int
+	MemoryBytes  int64 // 估算的内存占用，单位字节
 }
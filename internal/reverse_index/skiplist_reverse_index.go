@@ -1,8 +1,10 @@
 package reverseindex
 
 import (
+	"context"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/WlayRay/ElectricSearch/types"
 	"github.com/WlayRay/ElectricSearch/util"
@@ -33,6 +35,7 @@ func (idx SkipListReverseIndex) getLock(key string) *sync.RWMutex {
 type SkipListValue struct {
 	Id          string
 	BitsFeature uint64
+	ExpireAt    int64 // 文档的过期时间（Unix时间戳，单位秒），0表示永不过期
 }
 
 func (idx SkipListReverseIndex) Add(doc types.Document) {
@@ -44,6 +47,7 @@ func (idx SkipListReverseIndex) Add(doc types.Document) {
 		skipListValue := SkipListValue{
 			Id:          doc.Id,
 			BitsFeature: doc.BitsFeature,
+			ExpireAt:    doc.ExpireAt,
 		}
 		if value, exists := idx.table.Get(key); exists {
 			list := value.(*skiplist.SkipList)
@@ -162,47 +166,68 @@ func (idx SkipListReverseIndex) FilterByBits(bits uint64, onFlag uint64, offFlag
 	return true
 }
 
-func (idx SkipListReverseIndex) search(tq *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) *skiplist.SkipList {
+// 已过期的文档在被后台清理之前仍留在倒排链上，检索时需要过滤掉
+func (value SkipListValue) Expired(now int64) bool {
+	return value.ExpireAt > 0 && value.ExpireAt <= now
+}
+
+// 遍历倒排链时每隔多少个节点检查一次ctx是否已经结束
+const cancelCheckInterval = 1024
+
+func (idx SkipListReverseIndex) search(ctx context.Context, tq *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64, now int64) (*skiplist.SkipList, error) {
 	if tq.Keyword != nil {
 		keyword := tq.Keyword.ToString()
 		if value, exists := idx.table.Get(keyword); exists {
 			// 找到关键词对应的跳表进行遍历
 			result := skiplist.New(skiplist.Uint64)
 			list := value.(*skiplist.SkipList)
+			scanned := 0
 			for node := list.Front(); node != nil; node = node.Next() {
+				if scanned++; scanned%cancelCheckInterval == 0 && ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
 				intId := node.Key().(uint64)
 				skiplistValue := node.Value.(SkipListValue)
-				if idx.FilterByBits(skiplistValue.BitsFeature, onFlag, offFlag, orFlags) {
+				if !skiplistValue.Expired(now) && idx.FilterByBits(skiplistValue.BitsFeature, onFlag, offFlag, orFlags) {
 					result.Set(intId, skiplistValue)
 				}
 			}
-			return result
+			return result, nil
 		}
-	} else if len(tq.Must) > 0 {
-		results := make([]*skiplist.SkipList, 0, len(tq.Must))
-		for _, subQuery := range tq.Must {
-			results = append(results, idx.search(subQuery, onFlag, offFlag, orFlags))
+	} else if len(tq.Must) > 0 || len(tq.Should) > 0 {
+		subQueries := tq.Must
+		if len(subQueries) == 0 {
+			subQueries = tq.Should
 		}
-		return IntersectionOfSkipList(results...)
-	} else if len(tq.Should) > 0 {
-		results := make([]*skiplist.SkipList, 0, len(tq.Should))
-		for _, subQuery := range tq.Should {
-			results = append(results, idx.search(subQuery, onFlag, offFlag, orFlags))
+		results := make([]*skiplist.SkipList, 0, len(subQueries))
+		for _, subQuery := range subQueries {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			result, err := idx.search(ctx, subQuery, onFlag, offFlag, orFlags, now)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
 		}
-		return UnionOfSkipList(results...)
+		if len(tq.Must) > 0 {
+			return IntersectionOfSkipList(results...), nil
+		}
+		return UnionOfSkipList(results...), nil
 	}
-	return nil
+	return nil, nil
 }
 
-func (idx SkipListReverseIndex) Search(tq *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) []string {
-	skp := idx.search(tq, onFlag, offFlag, orFlags)
-	if skp == nil {
-		return nil
+// Search ctx结束时中止遍历并返回ctx.Err()
+func (idx SkipListReverseIndex) Search(ctx context.Context, tq *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) ([]string, error) {
+	skp, err := idx.search(ctx, tq, onFlag, offFlag, orFlags, time.Now().Unix())
+	if skp == nil || err != nil {
+		return nil, err
 	}
 	result := make([]string, 0, skp.Len())
 	for node := skp.Front(); node != nil; node = node.Next() {
 		skiplistValue := node.Value.(SkipListValue)
 		result = append(result, skiplistValue.Id)
 	}
-	return result
+	return result, nil
 }
@@ -0,0 +1,100 @@
+package reverseindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/huandu/skiplist"
+)
+
+// 快照格式：依次写入每个Keyword的倒排链，以长度为0的Keyword结尾。
+// 每条倒排链：Keyword长度(uvarint) + Keyword + 文档数(uvarint) + 每个文档的IntId、Id、BitsFeature、ExpireAt
+func (idx SkipListReverseIndex) Snapshot(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	var buf []byte
+	iter := idx.table.NewIterator()
+	for entry := iter.Next(); entry != nil; entry = iter.Next() {
+		if entry.Value == nil || len(entry.Key) == 0 {
+			continue
+		}
+		lock := idx.getLock(entry.Key)
+		lock.RLock()
+		list := entry.Value.(*skiplist.SkipList)
+		buf = binary.AppendUvarint(buf[:0], uint64(len(entry.Key)))
+		buf = append(buf, entry.Key...)
+		buf = binary.AppendUvarint(buf, uint64(list.Len()))
+		for node := list.Front(); node != nil; node = node.Next() {
+			value := node.Value.(SkipListValue)
+			buf = binary.AppendUvarint(buf, node.Key().(uint64))
+			buf = binary.AppendUvarint(buf, uint64(len(value.Id)))
+			buf = append(buf, value.Id...)
+			buf = binary.AppendUvarint(buf, value.BitsFeature)
+			buf = binary.AppendVarint(buf, value.ExpireAt)
+		}
+		lock.RUnlock()
+		if _, err := writer.Write(buf); err != nil {
+			return err
+		}
+	}
+	if _, err := writer.Write(binary.AppendUvarint(nil, 0)); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func (idx SkipListReverseIndex) LoadSnapshot(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		key, err := readSnapshotString(reader)
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return nil
+		}
+		n, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return err
+		}
+		list := skiplist.New(skiplist.Uint64)
+		for i := uint64(0); i < n; i++ {
+			intId, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return err
+			}
+			var value SkipListValue
+			if value.Id, err = readSnapshotString(reader); err != nil {
+				return err
+			}
+			if value.BitsFeature, err = binary.ReadUvarint(reader); err != nil {
+				return err
+			}
+			if value.ExpireAt, err = binary.ReadVarint(reader); err != nil {
+				return err
+			}
+			list.Set(intId, value)
+		}
+
+		lock := idx.getLock(key)
+		lock.Lock()
+		idx.table.Set(key, list)
+		lock.Unlock()
+	}
+}
+
+func readSnapshotString(reader *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (idx SkipListReverseIndex) Reset() {
+	idx.table.Clear()
+}
@@ -0,0 +1,60 @@
+package reverseindex
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/huandu/skiplist"
+)
+
+// 估算内存时使用的常量：每条倒排链的跳表头和map中的一项，每个跳表节点的指针、层级数组和value
+const (
+	skipListOverhead     = 256
+	skipListNodeOverhead = 96
+)
+
+// Stats 遍历所有倒排链统计Keyword数量和倒排链长度分布，内存占用是按节点数估算的
+func (idx SkipListReverseIndex) Stats() *Stats {
+	stats := &Stats{FieldTerms: make(map[string]int)}
+	var lengths []int
+	iter := idx.table.NewIterator()
+	for entry := iter.Next(); entry != nil; entry = iter.Next() {
+		if entry.Value == nil {
+			continue
+		}
+		lock := idx.getLock(entry.Key)
+		lock.RLock()
+		list := entry.Value.(*skiplist.SkipList)
+		n := list.Len()
+		stats.MemoryBytes += int64(skipListOverhead + len(entry.Key))
+		for node := list.Front(); node != nil; node = node.Next() {
+			value := node.Value.(SkipListValue)
+			stats.MemoryBytes += int64(skipListNodeOverhead + unsafe.Sizeof(value) + uintptr(len(value.Id)))
+		}
+		lock.RUnlock()
+		if n == 0 { // 文档全部删除后倒排链仍然保留
+			continue
+		}
+		field, _, _ := strings.Cut(entry.Key, "\001") // Keyword.ToString()的格式为Field\001Word
+		stats.FieldTerms[field]++
+		stats.PostingLists++
+		stats.Postings += int64(n)
+		lengths = append(lengths, n)
+	}
+	if len(lengths) > 0 {
+		slices.Sort(lengths)
+		stats.PostingP50 = percentile(lengths, 0.5)
+		stats.PostingP90 = percentile(lengths, 0.9)
+		stats.PostingP99 = percentile(lengths, 0.99)
+		stats.PostingMax = lengths[len(lengths)-1]
+	}
+	return stats
+}
+
+// sorted已升序排列，返回最近秩法的分位数
+func percentile(sorted []int, p float64) int {
+	i := int(math.Ceil(float64(len(sorted))*p)) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
@@ -1,11 +1,14 @@
 package reverseindextest
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
 
 	reverseindex "github.com/WlayRay/ElectricSearch/internal/reverse_index"
+	"github.com/WlayRay/ElectricSearch/types"
 
 	"github.com/huandu/skiplist"
 )
@@ -117,3 +120,24 @@ func TestSkipList(t *testing.T) {
 	}
 	fmt.Println("\n" + strings.Repeat("-", 50))
 }
+
+func TestSearchCanceled(t *testing.T) {
+	index := reverseindex.NewSkipListReverseIndex(5000)
+	for i := range 5000 {
+		index.Add(types.Document{Id: fmt.Sprintf("doc_%d", i), IntId: uint64(i + 1), Keywords: []*types.Keyword{{Field: "content", Word: "取消"}}})
+	}
+	query := types.NewTermQuery("content", "取消")
+	if docIds, err := index.Search(context.Background(), query, 0, 0, nil); err != nil || len(docIds) != 5000 {
+		t.Fatalf("search %d docs: %v", len(docIds), err)
+	}
+
+	// 遍历长倒排链的途中发现ctx已经结束
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if docIds, err := index.Search(ctx, query, 0, 0, nil); !errors.Is(err, context.Canceled) || docIds != nil {
+		t.Fatalf("search with canceled context: %d docs, %v", len(docIds), err)
+	}
+	if _, err := index.Search(ctx, query.And(types.NewTermQuery("content", "其他")), 0, 0, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("compound search with canceled context: %v", err)
+	}
+}
@@ -1,13 +1,18 @@
 package service
 
 import (
+	"context"
+
 	"github.com/WlayRay/ElectricSearch/types"
 )
 
+// IIndexer 单机的Indexer和分布式的Sentinel都实现了该接口。ctx的取消和超时会传递到worker和倒排索引
 type IIndexer interface {
-	AddDoc(doc types.Document) (int, error)
-	DeleteDoc(docId string) int
-	Search(querys *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) []*types.Document
-	Count() int
+	AddDoc(ctx context.Context, doc types.Document) (int, error)
+	UpdateDoc(ctx context.Context, patch *types.DocPatch) (int, error)
+	BatchAddDoc(ctx context.Context, docs []types.Document) (int, []*DocError) // 返回写入成功的文档数和每个失败文档的原因
+	DeleteDoc(ctx context.Context, docId string) int
+	Search(ctx context.Context, querys *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) ([]*types.Document, error)
+	Count(ctx context.Context) int
 	Close() error
 }
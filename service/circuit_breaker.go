@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	BreakerWindow           = 10 * time.Second // 统计错误率和慢请求比例的滑动窗口
+	BreakerMinRequests      = 20               // 窗口内请求数少于该值时不熔断
+	BreakerErrorRate        = 0.5              // 窗口内失败比例达到该值时熔断
+	BreakerSlowCallDuration = time.Second      // 超过该延迟的请求记为慢请求
+	BreakerSlowCallRate     = 0.8              // 窗口内慢请求比例达到该值时熔断
+	BreakerOpenDuration     = 5 * time.Second  // 熔断后多久进入半开状态
+	BreakerHalfOpenProbes   = 3                // 半开状态下同时放行的探测请求数，全部成功后恢复
+	breakerBuckets          = 10               // 滑动窗口的分桶数
+)
+
+// BreakerState 熔断器的状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常放行
+	BreakerOpen                         // 熔断，拒绝所有请求
+	BreakerHalfOpen                     // 放行少量探测请求，成功后关闭，失败后重新熔断
+)
+
+func (state BreakerState) String() string {
+	switch state {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerStats 熔断器的状态和当前窗口内的统计，用于监控
+type BreakerStats struct {
+	State    BreakerState
+	Requests int       // 窗口内的请求数
+	Failures int       // 窗口内失败的请求数
+	Slow     int       // 窗口内的慢请求数
+	OpenedAt time.Time // 最近一次熔断的时间
+}
+
+type breakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+	slow     int
+}
+
+// CircuitBreaker 一台worker的熔断器。窗口内错误率或者慢请求比例过高时熔断，经过BreakerOpenDuration后放行少量探测请求，
+// 探测全部成功则恢复，任一失败则重新熔断
+type CircuitBreaker struct {
+	mu         sync.Mutex
+	state      BreakerState
+	openedAt   time.Time
+	buckets    []breakerBucket
+	probing    int // 半开状态下正在进行的探测请求数
+	probed     int // 半开状态下已经成功的探测请求数
+	probeStart time.Time
+}
+
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{buckets: make([]breakerBucket, breakerBuckets)}
+}
+
+// 熔断时间已过时进入半开状态，需要持有锁
+func (breaker *CircuitBreaker) refresh(now time.Time) {
+	if breaker.state == BreakerOpen && now.Sub(breaker.openedAt) >= BreakerOpenDuration {
+		breaker.state = BreakerHalfOpen
+		breaker.probing, breaker.probed = 0, 0
+		breaker.probeStart = now
+	}
+	// 探测请求没有报告结果（例如调用方放弃了流），超时后重新放行
+	if breaker.state == BreakerHalfOpen && breaker.probing > 0 && now.Sub(breaker.probeStart) >= BreakerOpenDuration {
+		breaker.probing = 0
+		breaker.probeStart = now
+	}
+}
+
+// Ready 是否可以向worker发送请求，不占用探测名额。负载均衡据此跳过熔断的worker
+func (breaker *CircuitBreaker) Ready() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.refresh(time.Now())
+	switch breaker.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		return breaker.probing+breaker.probed < BreakerHalfOpenProbes
+	}
+	return true
+}
+
+// Allow 请求发送前调用，返回false时不能发送。返回true后必须调用Record报告结果
+func (breaker *CircuitBreaker) Allow() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.refresh(time.Now())
+	switch breaker.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if breaker.probing+breaker.probed >= BreakerHalfOpenProbes {
+			return false
+		}
+		breaker.probing++
+	}
+	return true
+}
+
+// Record 报告请求的延迟和错误。被取消的请求不计入统计
+func (breaker *CircuitBreaker) Record(latency time.Duration, err error) {
+	canceled := errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled
+	failed := !canceled && breakerFailure(err)
+	slow := !canceled && latency >= BreakerSlowCallDuration
+
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	now := time.Now()
+	breaker.refresh(now)
+	switch breaker.state {
+	case BreakerHalfOpen:
+		if breaker.probing > 0 {
+			breaker.probing--
+		}
+		if canceled {
+			return
+		}
+		if failed || slow {
+			breaker.open(now)
+			return
+		}
+		if breaker.probed++; breaker.probed >= BreakerHalfOpenProbes {
+			breaker.close()
+		}
+	case BreakerClosed:
+		if canceled {
+			return
+		}
+		bucket := breaker.bucket(now)
+		bucket.requests++
+		if failed {
+			bucket.failures++
+		}
+		if slow {
+			bucket.slow++
+		}
+		requests, failures, slowCalls := breaker.counts(now)
+		if requests < BreakerMinRequests {
+			return
+		}
+		if float64(failures) >= BreakerErrorRate*float64(requests) || float64(slowCalls) >= BreakerSlowCallRate*float64(requests) {
+			breaker.open(now)
+		}
+	}
+}
+
+// State 返回熔断器的当前状态
+func (breaker *CircuitBreaker) State() BreakerState {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.refresh(time.Now())
+	return breaker.state
+}
+
+// Stats 返回熔断器的状态和窗口内的统计
+func (breaker *CircuitBreaker) Stats() BreakerStats {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	now := time.Now()
+	breaker.refresh(now)
+	requests, failures, slow := breaker.counts(now)
+	return BreakerStats{State: breaker.state, Requests: requests, Failures: failures, Slow: slow, OpenedAt: breaker.openedAt}
+}
+
+func (breaker *CircuitBreaker) open(now time.Time) {
+	breaker.state = BreakerOpen
+	breaker.openedAt = now
+	breaker.probing, breaker.probed = 0, 0
+}
+
+func (breaker *CircuitBreaker) close() {
+	breaker.state = BreakerClosed
+	clear(breaker.buckets)
+}
+
+// 当前时间所在的桶，桶已经过期时清空
+func (breaker *CircuitBreaker) bucket(now time.Time) *breakerBucket {
+	width := BreakerWindow / time.Duration(len(breaker.buckets))
+	start := now.Truncate(width)
+	bucket := &breaker.buckets[int(start.UnixNano()/int64(width))%len(breaker.buckets)]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+func (breaker *CircuitBreaker) counts(now time.Time) (requests, failures, slow int) {
+	for _, bucket := range breaker.buckets {
+		if now.Sub(bucket.start) < BreakerWindow {
+			requests += bucket.requests
+			failures += bucket.failures
+			slow += bucket.slow
+		}
+	}
+	return
+}
+
+// 说明worker不健康的错误，版本冲突、参数错误等由请求本身导致的错误不计入
+func breakerFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+// breaker 返回worker的熔断器，不存在时创建
+func (sentinel *Sentinel) breaker(endpoint string) *CircuitBreaker {
+	if v, ok := sentinel.breakers.Load(endpoint); ok {
+		return v.(*CircuitBreaker)
+	}
+	v, _ := sentinel.breakers.LoadOrStore(endpoint, NewCircuitBreaker())
+	return v.(*CircuitBreaker)
+}
+
+// BreakerStates 返回每台连接过的worker的熔断器状态，用于监控
+func (sentinel *Sentinel) BreakerStates() map[string]BreakerStats {
+	states := make(map[string]BreakerStats)
+	sentinel.breakers.Range(func(key, value any) bool {
+		states[key.(string)] = value.(*CircuitBreaker).Stats()
+		return true
+	})
+	return states
+}
+
+// availableEndpoints 过滤掉熔断的worker
+func (sentinel *Sentinel) availableEndpoints(endpoints []string) []string {
+	available := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if sentinel.breaker(endpoint).Ready() {
+			available = append(available, endpoint)
+		}
+	}
+	return available
+}
+
+// 每个请求发送前经过熔断器，结束后报告结果
+func breakerUnaryInterceptor(endpoint string, breaker *CircuitBreaker) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !breaker.Allow() {
+			return status.Errorf(codes.Unavailable, "circuit breaker of worker %s is open", endpoint)
+		}
+		begin := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		breaker.Record(time.Since(begin), err)
+		return err
+	}
+}
+
+// 流式请求只统计错误，不统计延迟
+func breakerStreamInterceptor(endpoint string, breaker *CircuitBreaker) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !breaker.Allow() {
+			return nil, status.Errorf(codes.Unavailable, "circuit breaker of worker %s is open", endpoint)
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			breaker.Record(0, err)
+			return nil, err
+		}
+		return &breakerStream{ClientStream: stream, breaker: breaker, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+type breakerStream struct {
+	grpc.ClientStream
+	breaker       *CircuitBreaker
+	serverStreams bool
+	once          sync.Once
+}
+
+func (stream *breakerStream) RecvMsg(m any) error {
+	err := stream.ClientStream.RecvMsg(m)
+	// 客户端流只有一个响应；服务端流读到io.EOF或者出错时结束
+	if !stream.serverStreams || err != nil {
+		stream.once.Do(func() {
+			if err == io.EOF {
+				stream.breaker.Record(0, nil)
+			} else {
+				stream.breaker.Record(0, err)
+			}
+		})
+	}
+	return err
+}
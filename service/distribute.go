@@ -2,293 +2,393 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
+	"io"
+	"net"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/WlayRay/ElectricSearch/etcd"
-	"github.com/dgryski/go-farm"
-	etcdv3 "go.etcd.io/etcd/client/v3"
-
 	"github.com/WlayRay/ElectricSearch/types"
 	"github.com/WlayRay/ElectricSearch/util"
 
 	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
+// ErrPartialWrite group没有主节点时写请求发给所有worker，部分worker写入失败，各worker的数据已经不一致，调用方需要重试
+var ErrPartialWrite = errors.New("write failed on some workers of the group")
+
 type Sentinel struct {
-	Hub      IServiceHub
-	seed     int
-	connPool sync.Map
+	Hub           IServiceHub
+	connPool      sync.Map
+	breakers      sync.Map // endpoint -> *CircuitBreaker
+	searchOptions SearchOptions
+	dialer        Dialer          // 为nil时通过网络连接worker
+	searchLatency latencyRecorder // 最近检索请求的延迟，用于计算对冲的阈值
+	countLatency  latencyRecorder
 }
 
+// NewSentinel 按init.yml中的registry连接注册中心，使用etcd时etcdServers为etcd集群地址
 func NewSentinel(etcdServers []string) *Sentinel {
+	// Hub: GetServiceHub(etcdServers, 10), //直接访问ServiceHub
+	hub, err := NewConfiguredServiceHub(etcdServers, 10, 100) //使用etcd时走代理HubProxy
+	if err != nil {
+		util.Log.Fatalf("init service hub failed: %v", err)
+	}
+	return NewSentinelWithHub(hub)
+}
+
+// NewSentinelWithHub 使用指定的注册中心创建Sentinel
+func NewSentinelWithHub(hub IServiceHub) *Sentinel {
 	return &Sentinel{
-		// Hub: GetServiceHub(etcdServers, 10), //直接访问ServiceHub
-		Hub:      GetServiceHubProxy(etcdServers, 10, 100), //走代理HubProxy
-		connPool: sync.Map{},
-		seed:     0,
+		Hub:           hub,
+		connPool:      sync.Map{},
+		searchOptions: DefaultSearchOptions,
+	}
+}
+
+// Dialer 建立到worker的连接，用于替换默认的网络连接，例如本地集群中的内存连接
+type Dialer func(ctx context.Context, endpoint string) (net.Conn, error)
+
+// dialWorker 创建到worker的grpc连接，dialer不为nil时通过dialer建立连接
+func dialWorker(endpoint string, dialer Dialer, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if dialer == nil {
+		return grpc.NewClient(endpoint, opts...)
 	}
+	// endpoint不是真实的地址，跳过DNS解析直接交给dialer
+	return grpc.NewClient("passthrough:///"+endpoint, append(opts, grpc.WithContextDialer(dialer))...)
+}
+
+// WithDialer 设置连接worker的方式，需要在第一次请求之前调用
+func (sentinel *Sentinel) WithDialer(dialer Dialer) *Sentinel {
+	sentinel.dialer = dialer
+	return sentinel
 }
 
+// GetGrpcConn 返回worker的连接，worker的熔断器打开时返回nil。连接断开后由grpc按退避策略重连，只有连接被关闭时才重新创建
 func (sentinel *Sentinel) GetGrpcConn(endpoint string) *grpc.ClientConn {
+	breaker := sentinel.breaker(endpoint)
+	if !breaker.Ready() {
+		util.Log.Printf("circuit breaker of worker %s is %s, skip it", endpoint, breaker.State())
+		return nil
+	}
 	if v, exists := sentinel.connPool.Load(endpoint); exists {
 		conn := v.(*grpc.ClientConn)
-		// 检查连接状态是否为Ready
-		if conn.GetState() != connectivity.Ready {
-			util.Log.Printf("sentinel %s is not ready (state: %v), close it", endpoint, conn.GetState())
-			conn.Close()
-			sentinel.connPool.Delete(endpoint)
-		} else {
+		if conn.GetState() != connectivity.Shutdown {
 			return conn
 		}
+		sentinel.connPool.CompareAndDelete(endpoint, conn)
 	}
 
-	conn, err := grpc.NewClient(
-		endpoint,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	conn, err := dialWorker(endpoint, sentinel.dialer,
+		grpc.WithUnaryInterceptor(breakerUnaryInterceptor(endpoint, breaker)),
+		grpc.WithStreamInterceptor(breakerStreamInterceptor(endpoint, breaker)),
 	)
 	if err != nil {
 		util.Log.Printf("dial %s failed: %s", endpoint, err)
 		return nil
 	}
+	if v, loaded := sentinel.connPool.LoadOrStore(endpoint, conn); loaded { // 其他goroutine已经创建了连接
+		conn.Close()
+		return v.(*grpc.ClientConn)
+	}
 	util.Log.Printf("successfully connected to grpc server %s", endpoint)
-	sentinel.connPool.Store(endpoint, conn)
 	return conn
 }
 
-func (sentinel *Sentinel) AddDoc(doc types.Document) (int, error) {
-	groupIndex := sentinel.getGroupIndex(doc.Id)
-	endpoints := sentinel.Hub.GetServiceEndpoints(fmt.Sprintf("group-%d", groupIndex))
-	if len(endpoints) == 0 {
-		return 0, fmt.Errorf("there is no group can be used")
+// writeEndpoints 返回写请求要发送的worker。group选出了主节点时只发给主节点，由主节点复制给其他worker；否则发给group内的所有worker
+func (sentinel *Sentinel) writeEndpoints(group string) (endpoints []string, primary bool) {
+	if endpoint := sentinel.Hub.GetPrimaryEndpoint(group); len(endpoint) > 0 {
+		return []string{endpoint}, true
 	}
+	return sentinel.Hub.GetServiceEndpoints(group), false
+}
 
-	var total uint32
-	var wg sync.WaitGroup
-	wg.Add(len(endpoints))
-	for _, endpoint := range endpoints {
-		go func(string) {
-			defer wg.Done()
-			conn := sentinel.GetGrpcConn(endpoint)
-			if conn != nil {
-				client := NewIndexServiceClient(conn)
-				affected, err := client.AddDoc(context.Background(), &doc)
-				if err != nil {
-					util.Log.Printf("add doc %s to worker %s failed: %s", doc.Id, endpoint, err)
-				} else {
-					atomic.AddUint32(&total, uint32(affected.Count))
-				}
-			}
-
-		}(endpoint)
+// writeToGroup 按分片映射把写请求发送给docId所属的group，返回受影响的文档数。
+// 槽位迁移中时再把请求发给目标group，目标group写入失败不影响结果，由ShardCoordinator同步
+func (sentinel *Sentinel) writeToGroup(ctx context.Context, docId, action string, write func(ctx context.Context, client IndexServiceClient) (*AffectedCount, error)) (int, error) {
+	shardMap := sentinel.getShardMap()
+	if shardMap == nil {
+		return 0, fmt.Errorf("there is no group can be used")
 	}
-
-	wg.Wait()
-	util.Log.Printf("add doc %s to workers %v, affected %d", doc.Id, endpoints, total)
-	return int(total), nil
+	groups, err := shardMap.WriteGroups(docId)
+	if err != nil {
+		return 0, err
+	}
+	n, err := sentinel.writeGroup(ctx, groups[0], docId, action, write)
+	if err == nil && len(groups) > 1 {
+		if _, err := sentinel.writeGroup(ctx, groups[1], docId, action, write); err != nil {
+			util.Log.Printf("%s doc %s on migration target group-%d failed: %s", action, docId, groups[1], err)
+		}
+	}
+	return n, err
 }
 
-func (sentinel *Sentinel) DeleteDoc(docId string) int {
-	groupIndex := sentinel.getGroupIndex(docId)
-	endpoints := sentinel.Hub.GetServiceEndpoints(fmt.Sprintf("group-%d", groupIndex))
+// writeGroup 把写请求发送给group的主节点，没有主节点时发送给所有worker，汇总受影响的文档数。任一worker版本冲突都会返回ErrVersionConflict。
+// 写主节点时，主节点写入成功就返回，从节点异步复制，主节点宕机时可能丢失还没有复制到从节点的写入。
+// 发给所有worker时，任一worker写入失败都返回ErrPartialWrite，列出失败的worker，这次写入不算成功
+func (sentinel *Sentinel) writeGroup(ctx context.Context, groupIndex int, docId, action string, write func(ctx context.Context, client IndexServiceClient) (*AffectedCount, error)) (int, error) {
+	endpoints, primary := sentinel.writeEndpoints(fmt.Sprintf("group-%d", groupIndex))
 	if len(endpoints) == 0 {
-		return 0
+		return 0, fmt.Errorf("group-%d has no worker", groupIndex)
 	}
 
 	var total uint32
-	wg := sync.WaitGroup{}
+	var conflict atomic.Value
+	var mu sync.Mutex
+	var failures []error
+	var wg sync.WaitGroup
 	wg.Add(len(endpoints))
 	for _, endpoint := range endpoints {
-		go func(string) {
+		go func(endpoint string) {
 			defer wg.Done()
-			conn := sentinel.GetGrpcConn(endpoint)
-			if conn != nil {
-				client := NewIndexServiceClient(conn)
-				affected, err := client.DeleteDoc(context.Background(), &DocId{docId})
-				if err != nil {
-					util.Log.Printf("delete doc %s from worker %s failed: %s", docId, endpoint, err)
-				} else if affected.Count > 0 {
-					atomic.AddUint32(&total, affected.Count)
-				}
+			var err error
+			var affected *AffectedCount
+			if conn := sentinel.GetGrpcConn(endpoint); conn == nil {
+				err = fmt.Errorf("failed to get connection for endpoint %s", endpoint)
+			} else if affected, err = write(ctx, NewIndexServiceClient(conn)); status.Code(err) == codes.FailedPrecondition {
+				conflict.Store(status.Convert(err).Message())
+				return
+			} else if err == nil {
+				atomic.AddUint32(&total, affected.Count)
+				return
 			}
+			util.Log.Printf("%s doc %s on worker %s failed: %s", action, docId, endpoint, err)
+			mu.Lock()
+			failures = append(failures, fmt.Errorf("worker %s: %w", endpoint, err))
+			mu.Unlock()
 		}(endpoint)
 	}
 
 	wg.Wait()
-	util.Log.Printf("add delete %s to workers %v, affected %d", docId, endpoints, total)
-	return int(total)
+	util.Log.Printf("%s doc %s on workers %v, affected %d", action, docId, endpoints, total)
+	if msg := conflict.Load(); msg != nil {
+		return int(total), fmt.Errorf("%w: %s", ErrVersionConflict, msg)
+	}
+	if len(failures) == 0 {
+		return int(total), nil
+	}
+	// 写主节点失败时文档没有写入任何worker；没有主节点时部分worker已经写入，各worker的数据不一致
+	if primary {
+		return 0, failures[0]
+	}
+	reasons := make([]string, len(failures))
+	for i, failure := range failures {
+		reasons[i] = failure.Error()
+	}
+	return 0, fmt.Errorf("%w: %d of %d workers failed: %s", ErrPartialWrite, len(failures), len(endpoints), strings.Join(reasons, "; "))
 }
 
-func (sentinel *Sentinel) Search(querys *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) []*types.Document {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// AddDoc 把文档写入对应group的主节点（没有主节点时写入所有worker）。doc.Version非0时任一worker版本冲突都会返回ErrVersionConflict
+func (sentinel *Sentinel) AddDoc(ctx context.Context, doc types.Document) (int, error) {
+	return sentinel.writeToGroup(ctx, doc.Id, "add", func(ctx context.Context, client IndexServiceClient) (*AffectedCount, error) {
+		return client.AddDoc(ctx, &doc)
+	})
+}
 
-	docs := make([]*types.Document, 0, 1500)
-	groupCount := sentinel.getGroupCount()
-	if groupCount == 0 {
-		return nil
-	}
+// UpdateDoc 对文档做局部更新，请求发送给对应group的主节点（没有主节点时发送给所有worker）
+func (sentinel *Sentinel) UpdateDoc(ctx context.Context, patch *types.DocPatch) (int, error) {
+	return sentinel.writeToGroup(ctx, patch.Id, "update", func(ctx context.Context, client IndexServiceClient) (*AffectedCount, error) {
+		return client.UpdateDoc(ctx, patch)
+	})
+}
 
-	// 使用多个通道来收集结果
-	resultChs := make([]chan *types.Document, groupCount)
-	for i := range resultChs {
-		resultChs[i] = make(chan *types.Document, 300)
+// BatchAddDoc 按分片映射对文档分组，每个分片以流的形式分批发送给group的主节点（没有主节点时发送给所有worker）。
+// 槽位迁移中的文档在所属group写入成功后，再发送给目标group
+func (sentinel *Sentinel) BatchAddDoc(ctx context.Context, docs []types.Document) (int, []*DocError) {
+	var docErrors []*DocError
+	shardMap := sentinel.getShardMap()
+	if shardMap == nil {
+		docErrors = make([]*DocError, 0, len(docs))
+		for _, doc := range docs {
+			docErrors = append(docErrors, newDocError(doc.Id, codes.Unavailable, fmt.Errorf("there is no group can be used")))
+		}
+		return 0, docErrors
 	}
 
-	var producerWg sync.WaitGroup
-	producerWg.Add(groupCount)
-
-	// 生产者：向通道发送数据
-	for i := range groupCount {
-		group := fmt.Sprintf("group-%d", i)
-		endpoints := sentinel.Hub.GetServiceEndpoints(group)
-		if len(endpoints) == 0 {
-			producerWg.Done() // 跳过空组
+	shards := make(map[int][]*types.Document)
+	targets := make(map[int][]*types.Document) // 迁移中的槽位的目标group
+	for i := range docs {
+		groups, err := shardMap.WriteGroups(docs[i].Id)
+		if err != nil {
+			docErrors = append(docErrors, newDocError(docs[i].Id, codes.Unavailable, err))
 			continue
 		}
+		shards[groups[0]] = append(shards[groups[0]], &docs[i])
+		if len(groups) > 1 {
+			targets[groups[1]] = append(targets[groups[1]], &docs[i])
+		}
+	}
 
-		endpoint := sentinel.Hub.GetServiceEndpoint(group)
-		go func(endpoint string, resultCh chan<- *types.Document) {
-			defer producerWg.Done()
-			conn := sentinel.GetGrpcConn(endpoint)
-			if conn == nil {
-				util.Log.Fatalf("failed to get connection for endpoint %s", endpoint)
-				return
-			}
-
-			client := NewIndexServiceClient(conn)
-			result, err := client.Search(ctx, &SearchRequest{
-				Query:   querys,
-				OnFlag:  onFlag,
-				OffFlag: offFlag,
-				OrFlags: orFlags,
+	total, shardErrors := sentinel.bulkAddToGroups(ctx, shards)
+	docErrors = append(docErrors, shardErrors...)
+	if len(targets) > 0 {
+		failed := make(map[string]struct{}, len(docErrors))
+		for _, docError := range docErrors {
+			failed[docError.DocId] = struct{}{}
+		}
+		for group, targetDocs := range targets {
+			targets[group] = slices.DeleteFunc(targetDocs, func(doc *types.Document) bool {
+				_, ok := failed[doc.Id]
+				return ok
 			})
+		}
+		if _, targetErrors := sentinel.bulkAddToGroups(ctx, targets); len(targetErrors) > 0 {
+			util.Log.Printf("bulk add %d docs to migration target groups failed", len(targetErrors))
+		}
+	}
+	util.Log.Printf("bulk add %d docs to %d groups, affected %d, failed %d", len(docs), len(shards), total, len(docErrors))
+	return total, docErrors
+}
 
-			if err != nil {
-				util.Log.Fatalf("search from worker %s failed: %s", endpoint, err)
-				return
+// bulkAddToGroups 并发地把每个group的文档发送给该group的主节点（没有主节点时发送给所有worker）
+func (sentinel *Sentinel) bulkAddToGroups(ctx context.Context, shards map[int][]*types.Document) (int, []*DocError) {
+	var total uint32
+	var mu sync.Mutex
+	var docErrors []*DocError
+	var wg sync.WaitGroup
+	for groupIndex, shardDocs := range shards {
+		if len(shardDocs) == 0 {
+			continue
+		}
+		endpoints, _ := sentinel.writeEndpoints(fmt.Sprintf("group-%d", groupIndex))
+		if len(endpoints) == 0 {
+			mu.Lock()
+			for _, doc := range shardDocs {
+				docErrors = append(docErrors, newDocError(doc.Id, codes.Unavailable, fmt.Errorf("group-%d has no worker", groupIndex)))
 			}
-
-			for _, doc := range result.Documents {
-				select {
-				case resultCh <- doc:
-				case <-ctx.Done():
+			mu.Unlock()
+			continue
+		}
+		wg.Add(len(endpoints))
+		for _, endpoint := range endpoints {
+			go func(endpoint string, shardDocs []*types.Document) {
+				defer wg.Done()
+				response, err := sentinel.bulkAddDoc(ctx, endpoint, shardDocs)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					util.Log.Printf("bulk add %d docs to worker %s failed: %s", len(shardDocs), endpoint, err)
+					for _, doc := range shardDocs {
+						docErrors = append(docErrors, newDocError(doc.Id, status.Code(err), fmt.Errorf("worker %s: %w", endpoint, err)))
+					}
 					return
 				}
-			}
-		}(endpoint, resultChs[i])
+				total += response.Count
+				docErrors = append(docErrors, response.Errors...)
+			}(endpoint, shardDocs)
+		}
 	}
+	wg.Wait()
+	return int(total), docErrors
+}
 
-	var consumerWg sync.WaitGroup
-	consumerWg.Add(groupCount)
-	mu := sync.Mutex{}
-
-	for i := range groupCount {
-		go func() {
-			defer consumerWg.Done()
-			for doc := range resultChs[i] {
-				mu.Lock()
-				docs = append(docs, doc)
-				mu.Unlock()
-			}
-		}()
+func (sentinel *Sentinel) bulkAddDoc(ctx context.Context, endpoint string, docs []*types.Document) (*BulkAddResponse, error) {
+	conn := sentinel.GetGrpcConn(endpoint)
+	if conn == nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get connection for endpoint %s", endpoint)
 	}
-
-	// 等待所有生产者完成并关闭通道
-	go func() {
-		producerWg.Wait()
-		for _, ch := range resultChs {
-			close(ch)
+	stream, err := NewIndexServiceClient(conn).BulkAddDoc(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if err := stream.Send(doc); err != nil {
+			if err == io.EOF { // 服务端提前结束了流，真正的错误需要通过CloseAndRecv获取
+				break
+			}
+			return nil, err
 		}
-	}()
+	}
+	return stream.CloseAndRecv()
+}
 
-	// 等待所有消费者完成
-	consumerWg.Wait()
+func (sentinel *Sentinel) DeleteDoc(ctx context.Context, docId string) int {
+	n, _ := sentinel.DeleteDocWithVersion(ctx, docId, 0)
+	return n
+}
 
-	return docs
+// DeleteDocWithVersion 从对应group的主节点（没有主节点时所有worker）上删除文档。expectedVersion非0时任一worker版本冲突都会返回ErrVersionConflict
+func (sentinel *Sentinel) DeleteDocWithVersion(ctx context.Context, docId string, expectedVersion uint64) (int, error) {
+	return sentinel.writeToGroup(ctx, docId, "delete", func(ctx context.Context, client IndexServiceClient) (*AffectedCount, error) {
+		return client.DeleteDoc(ctx, &DocId{DocId: docId, ExpectedVersion: expectedVersion})
+	})
 }
 
-func (sentinel *Sentinel) Count() int {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Search 按WithSearchOptions设置的选项检索。需要失败汇总时使用SearchWithOptions
+func (sentinel *Sentinel) Search(ctx context.Context, querys *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) ([]*types.Document, error) {
+	result, err := sentinel.SearchWithOptions(ctx, querys, onFlag, offFlag, orFlags, sentinel.searchOptions)
+	if err != nil {
+		return nil, err
+	}
+	if result.Shards.Failed > 0 {
+		util.Log.Printf("search returned partial results from %d of %d groups", result.Shards.Successful, result.Shards.Total)
+	}
+	return result.Documents, nil
+}
 
-	var n uint32
-	groupCount := sentinel.getGroupCount()
-	if groupCount == 0 {
+// Count 按WithSearchOptions设置的选项计数，失败时记录日志并返回0。需要失败汇总时使用CountWithOptions
+func (sentinel *Sentinel) Count(ctx context.Context) int {
+	n, summary, err := sentinel.CountWithOptions(ctx, sentinel.searchOptions)
+	if err != nil {
+		util.Log.Printf("count failed: %s", err)
 		return 0
 	}
-
-	// 使用多个通道来收集结果
-	resultChs := make([]chan uint32, groupCount)
-	for i := range resultChs {
-		resultChs[i] = make(chan uint32, 300)
+	if summary.Failed > 0 {
+		util.Log.Printf("count returned partial results from %d of %d groups", summary.Successful, summary.Total)
 	}
+	return n
+}
 
-	var producerWg sync.WaitGroup
-	producerWg.Add(groupCount)
+// Stats 从每个Group中选一台worker获取统计信息，返回以Group名为key的结果，获取失败的Group不在结果中
+func (sentinel *Sentinel) Stats() map[string]*StatsResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), SearchTimeout)
+	defer cancel()
 
-	// 生产者：向通道发送数据
-	for i := range groupCount {
-		group := fmt.Sprintf("group-%d", i)
-		endpoints := sentinel.Hub.GetServiceEndpoints(group)
-		if len(endpoints) == 0 {
-			producerWg.Done() // 跳过空组
+	results := make(map[string]*StatsResponse)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	shardMap := sentinel.getShardMap()
+	if shardMap == nil {
+		return results
+	}
+	balancer := sentinel.Hub.GetLoadBalancer()
+	for _, groupIndex := range shardMap.Groups() {
+		group := fmt.Sprintf("group-%d", groupIndex)
+		endpoint := balancer.Take(sentinel.availableEndpoints(sentinel.Hub.GetServiceEndpoints(group)))
+		if len(endpoint) == 0 {
 			continue
 		}
-
-		endpoint := sentinel.Hub.GetServiceEndpoint(group)
-		go func(endpoint string, resultCh chan<- uint32) {
-			defer producerWg.Done()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 			conn := sentinel.GetGrpcConn(endpoint)
 			if conn == nil {
-				util.Log.Fatalf("failed to get connection for endpoint %s", endpoint)
+				balancer.Done(endpoint, 0, status.Error(codes.Unavailable, "failed to get connection"))
 				return
 			}
-
-			client := NewIndexServiceClient(conn)
-			result, err := client.Count(ctx, &CountRequest{})
+			begin := time.Now()
+			stats, err := NewIndexServiceClient(conn).Stats(ctx, &StatsRequest{})
+			balancer.Done(endpoint, time.Since(begin), err)
 			if err != nil {
-				util.Log.Fatalf("count from worker %s failed: %s", endpoint, err)
+				util.Log.Printf("stats from worker %s failed: %s", endpoint, err)
 				return
 			}
-
-			select {
-			case resultCh <- result.Count:
-			case <-ctx.Done():
-				return
-			}
-		}(endpoint, resultChs[i])
-	}
-
-	var consumerWg sync.WaitGroup
-	consumerWg.Add(groupCount)
-
-	for i := range groupCount {
-		go func() {
-			defer consumerWg.Done()
-			for count := range resultChs[i] {
-				atomic.AddUint32(&n, count)
-			}
+			mu.Lock()
+			results[group] = stats
+			mu.Unlock()
 		}()
 	}
-
-	// 等待所有生产者完成并关闭通道
-	go func() {
-		producerWg.Wait()
-		for _, ch := range resultChs {
-			close(ch)
-		}
-	}()
-
-	// 等待所有消费者完成
-	consumerWg.Wait()
-
-	return int(n)
+	wg.Wait()
+	return results
 }
 
 func (sentinel *Sentinel) Close() (err error) {
@@ -302,35 +402,18 @@ func (sentinel *Sentinel) Close() (err error) {
 	return
 }
 
-// getGroupCount 获取当前索引的分组数量
-func (*Sentinel) getGroupCount() int {
-	var etcdServers []string
-	for _, v := range util.ConfigMap["etcd"].(map[string]any)["servers"].([]any) {
-		etcdServers = append(etcdServers, v.(string))
-	}
-
-	etcdConn, err := etcd.GetEtcdClient(etcdServers)
-	if err != nil {
-		util.Log.Fatalf("get etcd client failed: %s", err)
-	}
-
-	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
-	defer cancel()
-
-	resp, getErr := etcdConn.Get(timeoutCtx, ServiceRootPath+indexName+"/total-shards", etcdv3.WithPrefix())
-	if getErr == nil {
-		if resp != nil {
-			if resp.Count > 0 {
-				totalGroups, _ := strconv.Atoi(string(resp.Kvs[0].Value))
-				return totalGroups
-			}
-		}
-	}
-
-	return 0
+// getShardMap 从注册中心获取分片映射，没有可用的group时返回nil
+func (sentinel *Sentinel) getShardMap() *ShardMap {
+	return sentinel.Hub.GetShardMap()
 }
 
-// getGroupIndex 通过哈希选择一个group
-func (sentinel *Sentinel) getGroupIndex(key string) int {
-	return int(farm.Hash32WithSeed([]byte(key), uint32(sentinel.seed)) % uint32(sentinel.getGroupCount()))
+// etcdHub 返回Sentinel使用的etcd注册中心，使用其他注册中心时返回nil
+func (sentinel *Sentinel) etcdHub() *ServiceHub {
+	switch hub := sentinel.Hub.(type) {
+	case *ServiceHub:
+		return hub
+	case *ServiceHubProxy:
+		return hub.ServiceHub
+	}
+	return nil
 }
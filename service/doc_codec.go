@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 正排索引中value的格式：1个字节的格式版本号 + 载荷。
+// 早期版本直接存储gob编码的types.Document，gob流以消息长度开头，而一条合法的gob消息长度不可能小于16，
+// 所以把[1, 16)留作格式版本号，不在这个范围内的value都当作旧的gob格式
+const (
+	formatProtobufV1   byte = 1  // 载荷为protobuf编码的types.Document
+	formatCompressedV2 byte = 2  // 1个字节的压缩算法 + 压缩后的protobuf编码的types.Document
+	maxFormatVersion   byte = 15 // 可用的最大格式版本号
+)
+
+// Compression 正排索引value使用的压缩算法，记录在每条value的头部，所以不同压缩算法写入的数据可以共存
+type Compression byte
+
+const (
+	NoCompression Compression = iota
+	Snappy
+	Zstd
+)
+
+var compressionNames = map[Compression]string{NoCompression: "none", Snappy: "snappy", Zstd: "zstd"}
+
+func (c Compression) String() string {
+	if name, ok := compressionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", byte(c))
+}
+
+// ParseCompression 解析配置文件中的压缩算法名称，空字符串表示不压缩
+func ParseCompression(name string) (Compression, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return NoCompression, nil
+	}
+	for c, n := range compressionNames {
+		if n == name {
+			return c, nil
+		}
+	}
+	return NoCompression, fmt.Errorf("unsupported compression %q, should be none, snappy or zstd", name)
+}
+
+// EncodeAll和DecodeAll可以并发调用，全局共用一个zstd编解码器
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func compress(c Compression, src []byte) ([]byte, error) {
+	switch c {
+	case Snappy:
+		return snappy.Encode(nil, src), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(src, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", byte(c))
+	}
+}
+
+func decompress(c Compression, src []byte) ([]byte, error) {
+	switch c {
+	case Snappy:
+		return snappy.Decode(nil, src)
+	case Zstd:
+		return zstdDecoder.DecodeAll(src, nil)
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", byte(c))
+	}
+}
+
+// isLegacyRecord 判断value是否为旧的gob格式，需要迁移
+func isLegacyRecord(value []byte) bool {
+	return len(value) > 0 && (value[0] == 0 || value[0] > maxFormatVersion)
+}
+
+// encodeDoc 按指定的压缩算法编码文档。压缩后没有变小的文档按不压缩的格式存储
+func encodeDoc(doc *types.Document, c Compression) ([]byte, error) {
+	payload, err := doc.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if c != NoCompression {
+		compressed, err := compress(c, payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed)+1 < len(payload) {
+			value := make([]byte, 2+len(compressed))
+			value[0] = formatCompressedV2
+			value[1] = byte(c)
+			copy(value[2:], compressed)
+			return value, nil
+		}
+	}
+	value := make([]byte, 1+len(payload))
+	value[0] = formatProtobufV1
+	copy(value[1:], payload)
+	return value, nil
+}
+
+func decodeDoc(value []byte) (*types.Document, error) {
+	if len(value) == 0 {
+		return nil, fmt.Errorf("empty forward index record")
+	}
+	if isLegacyRecord(value) {
+		return decodeGobDoc(value)
+	}
+
+	var doc types.Document
+	switch value[0] {
+	case formatProtobufV1:
+		if err := doc.Unmarshal(value[1:]); err != nil {
+			return nil, err
+		}
+	case formatCompressedV2:
+		if len(value) < 2 {
+			return nil, fmt.Errorf("truncated forward index record")
+		}
+		payload, err := decompress(Compression(value[1]), value[2:])
+		if err != nil {
+			return nil, err
+		}
+		if err := doc.Unmarshal(payload); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported forward index record format %d", value[0])
+	}
+	return &doc, nil
+}
+
+// CompressionStats 正排索引写入路径上的压缩统计
+type CompressionStats struct {
+	Codec       string
+	Records     int64 // 写入的文档数
+	Compressed  int64 // 压缩存储的文档数，压缩后没有变小的文档按原样存储
+	RawBytes    int64 // 压缩前protobuf编码的字节数
+	StoredBytes int64 // 实际写入正排索引的字节数
+}
+
+// Ratio 压缩率，即实际写入的字节数/压缩前的字节数
+func (stats CompressionStats) Ratio() float64 {
+	if stats.RawBytes == 0 {
+		return 1
+	}
+	return float64(stats.StoredBytes) / float64(stats.RawBytes)
+}
+
+type compressionCounter struct {
+	records, compressed, rawBytes, storedBytes atomic.Int64
+}
+
+// SetCompression 设置写入正排索引时使用的压缩算法，只影响之后写入的文档
+func (indexer *Indexer) SetCompression(c Compression) {
+	indexer.compression = c
+}
+
+// encodeDoc 按Indexer配置的压缩算法编码文档，并记录压缩统计
+func (indexer *Indexer) encodeDoc(doc *types.Document) ([]byte, error) {
+	value, err := encodeDoc(doc, indexer.compression)
+	if err != nil {
+		return nil, err
+	}
+	indexer.compressionCounter.records.Add(1)
+	indexer.compressionCounter.rawBytes.Add(int64(doc.Size()))
+	indexer.compressionCounter.storedBytes.Add(int64(len(value)))
+	if value[0] == formatCompressedV2 {
+		indexer.compressionCounter.compressed.Add(1)
+	}
+	return value, nil
+}
+
+// CompressionStats 返回本进程启动以来写入正排索引的压缩统计
+func (indexer *Indexer) CompressionStats() CompressionStats {
+	counter := &indexer.compressionCounter
+	return CompressionStats{
+		Codec:       indexer.compression.String(),
+		Records:     counter.records.Load(),
+		Compressed:  counter.compressed.Load(),
+		RawBytes:    counter.rawBytes.Load(),
+		StoredBytes: counter.storedBytes.Load(),
+	}
+}
+
+// 兼容旧版本gob编码的文档
+func decodeGobDoc(value []byte) (*types.Document, error) {
+	decoder := gob.NewDecoder(bytes.NewReader(value))
+	var doc types.Document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// 每批迁移的文档数
+const migrateBatchSize = 500
+
+// MigrateForwardIndex 在线把正排索引中旧的gob格式文档改写成当前格式，返回改写的文档数。
+// 改写时持有文档锁，并重新检查文档是否仍是旧格式，可以与正常的读写并发执行
+func (indexer *Indexer) MigrateForwardIndex() (int, error) {
+	var legacyKeys []string
+	indexer.forwardIndex.IterDB(func(k, v []byte) error {
+		if isLegacyRecord(v) {
+			legacyKeys = append(legacyKeys, string(k)) // k只在遍历期间有效，需要拷贝
+		}
+		return nil
+	})
+
+	total := 0
+	for start := 0; start < len(legacyKeys); start += migrateBatchSize {
+		end := min(start+migrateBatchSize, len(legacyKeys))
+		n, err := indexer.migrateBatch(legacyKeys[start:end])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	if total > 0 {
+		util.Log.Printf("migrate %d gob encoded documents of forward index", total)
+	}
+	return total, nil
+}
+
+func (indexer *Indexer) migrateBatch(docIds []string) (int, error) {
+	unlock := indexer.lockDocs(docIds)
+	defer unlock()
+
+	keys := make([][]byte, 0, len(docIds))
+	for _, docId := range docIds {
+		keys = append(keys, []byte(docId))
+	}
+	values, _ := indexer.forwardIndex.BatchGet(keys)
+
+	n := 0
+	var setKeys, setValues [][]byte
+	for i, value := range values {
+		if !isLegacyRecord(value) { // 已被删除或者已被新的写入覆盖
+			continue
+		}
+		doc, err := decodeGobDoc(value)
+		if err != nil {
+			util.Log.Printf("Decode error: %v", err)
+			continue
+		}
+		if ttlOf(doc) > 0 {
+			if err := indexer.putDoc(docIds[i], doc); err != nil {
+				return n, err
+			}
+			n++
+			continue
+		}
+		newValue, err := indexer.encodeDoc(doc)
+		if err != nil {
+			return n, err
+		}
+		setKeys = append(setKeys, keys[i])
+		setValues = append(setValues, newValue)
+	}
+	if len(setKeys) > 0 {
+		if err := indexer.forwardIndex.BatchSet(setKeys, setValues); err != nil {
+			return n, err
+		}
+	}
+	return n + len(setKeys), nil
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+// ParseEncryptionConfig 从init.yml的index配置中读取主密钥文件（encryption-key-file，相对路径以项目根目录为基准）和badger数据密钥的轮换周期（encryption-key-rotation，单位小时）。
+// 没有配置密钥文件时返回nil，表示不加密
+func ParseEncryptionConfig(indexConfig map[string]any) ([]byte, error) {
+	if hours, ok := indexConfig["encryption-key-rotation"].(int); ok && hours > 0 {
+		kvdb.BadgerKeyRotationDuration = time.Duration(hours) * time.Hour
+	}
+	path, _ := indexConfig["encryption-key-file"].(string)
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = util.RootPath + path
+	}
+	return kvdb.LoadEncryptionKey(path)
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+// 后台清理过期文档的时间间隔
+var ExpireSweepInterval = time.Minute
+
+// 待过期的文档。正排索引可能已经被存储引擎按TTL删除了，所以需要自己记住IntId和Keywords才能清理倒排索引
+type expireItem struct {
+	docId    string
+	intId    uint64
+	expireAt int64
+	keywords []*types.Keyword
+}
+
+// 按过期时间排序的小根堆
+type expireHeap []*expireItem
+
+func (h expireHeap) Len() int           { return len(h) }
+func (h expireHeap) Less(i, j int) bool { return h[i].expireAt < h[j].expireAt }
+func (h expireHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expireHeap) Push(x any)        { *h = append(*h, x.(*expireItem)) }
+func (h *expireHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+type expireQueue struct {
+	mu    sync.Mutex
+	items expireHeap
+	live  map[string]*expireItem // 每个文档当前有效的过期项。文档被覆盖、更新或删除后，堆中旧的过期项失效
+}
+
+// push 记录文档的过期时间，调用方需持有docId对应的锁。文档不再过期时使之前的过期项失效
+func (q *expireQueue) push(doc *types.Document) {
+	docId := strings.TrimSpace(doc.Id)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if doc.ExpireAt <= 0 {
+		delete(q.live, docId)
+		return
+	}
+	item := &expireItem{docId: docId, intId: doc.IntId, expireAt: doc.ExpireAt, keywords: doc.Keywords}
+	heap.Push(&q.items, item)
+	q.setLive(item)
+}
+
+func (q *expireQueue) setLive(item *expireItem) {
+	if q.live == nil {
+		q.live = make(map[string]*expireItem)
+	}
+	q.live[item.docId] = item
+}
+
+// requeue 把已经take的过期项重新放回队列，调用方需持有docId对应的锁
+func (q *expireQueue) requeue(item *expireItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	heap.Push(&q.items, item)
+	q.setLive(item)
+}
+
+// remove 文档被删除后它的过期项失效，调用方需持有docId对应的锁
+func (q *expireQueue) remove(docId string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.live, docId)
+}
+
+// take 判断弹出的过期项是否仍然有效，有效时把它标记为已处理。调用方需持有docId对应的锁
+func (q *expireQueue) take(item *expireItem) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.live[item.docId] != item {
+		return false
+	}
+	delete(q.live, item.docId)
+	return true
+}
+
+// 弹出所有在now之前过期的文档，其中可能有已经失效的过期项，需要用take判断
+func (q *expireQueue) popExpired(now int64) []*expireItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var expired []*expireItem
+	for q.items.Len() > 0 && q.items[0].expireAt <= now {
+		expired = append(expired, heap.Pop(&q.items).(*expireItem))
+	}
+	return expired
+}
+
+func (q *expireQueue) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = nil
+	q.live = nil
+}
+
+// 把待过期的文档写入w，与倒排索引一起作为分片快照的一部分
+func (q *expireQueue) snapshot(w io.Writer) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var buf []byte
+	for _, item := range q.items {
+		if q.live[item.docId] != item {
+			continue
+		}
+		buf = appendString(buf[:0], item.docId)
+		buf = binary.AppendUvarint(buf, item.intId)
+		buf = binary.AppendVarint(buf, item.expireAt)
+		buf = binary.AppendUvarint(buf, uint64(len(item.keywords)))
+		for _, keyword := range item.keywords {
+			buf = appendString(buf, keyword.Field)
+			buf = appendString(buf, keyword.Word)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 读取snapshot写入的数据，直到r结束
+func (q *expireQueue) load(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		docId, err := readString(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		item := &expireItem{docId: docId}
+		if item.intId, err = binary.ReadUvarint(reader); err != nil {
+			return err
+		}
+		if item.expireAt, err = binary.ReadVarint(reader); err != nil {
+			return err
+		}
+		n, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			keyword := &types.Keyword{}
+			if keyword.Field, err = readString(reader); err != nil {
+				return err
+			}
+			if keyword.Word, err = readString(reader); err != nil {
+				return err
+			}
+			item.keywords = append(item.keywords, keyword)
+		}
+		q.mu.Lock()
+		heap.Push(&q.items, item)
+		q.setLive(item)
+		q.mu.Unlock()
+	}
+}
+
+// 文档剩余的存活时长，永不过期返回0
+func ttlOf(doc *types.Document) time.Duration {
+	if doc.ExpireAt <= 0 {
+		return 0
+	}
+	return time.Until(time.Unix(doc.ExpireAt, 0))
+}
+
+// SweepExpired 把已过期的文档从倒排索引和正排索引上删除，返回删除的文档数
+func (indexer *Indexer) SweepExpired() int {
+	n := 0
+	now := time.Now().Unix()
+	for _, item := range indexer.expireQueue.popExpired(now) {
+		lock := indexer.getLock(item.docId)
+		lock.Lock()
+		// 文档过期前已被删除、覆盖或更新时，过期项已经失效，只需跳过
+		if !indexer.expireQueue.take(item) {
+			lock.Unlock()
+			continue
+		}
+		// 正排索引中的文档可能已经被存储引擎按TTL删除了，也可能只是读不到（例如内存存储中过期但还没有回收的数据），
+		// 无论哪种情况都要记录删除并删掉正排索引中的数据
+		doc := indexer.getDoc(item.docId)
+		if doc == nil || (doc.IntId == item.intId && doc.Expired(now)) {
+			keywords := item.keywords
+			if doc != nil {
+				keywords = doc.Keywords
+			}
+			if err := indexer.appendWAL(&WALRecord{Op: WALDelete, DocId: item.docId}); err != nil {
+				util.Log.Printf("delete expired doc %s: %v", item.docId, err)
+				indexer.expireQueue.requeue(item) // 下次清理时重试
+				lock.Unlock()
+				continue
+			}
+			for _, keyword := range keywords {
+				indexer.reverseIndex.Delete(item.intId, keyword)
+			}
+			_ = indexer.forwardIndex.Delete([]byte(item.docId))
+			indexer.counter.deleted(1)
+			n++
+		} else if doc.IntId == item.intId {
+			indexer.expireQueue.push(doc) // 还没有到期，重新放回队列
+		}
+		lock.Unlock()
+	}
+	if n > 0 {
+		util.Log.Printf("sweep %d expired documents", n)
+	}
+	return n
+}
+
+func (indexer *Indexer) runExpireSweeper() {
+	ticker := time.NewTicker(ExpireSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			indexer.SweepExpired()
+		case <-indexer.closeCh:
+			return
+		}
+	}
+}
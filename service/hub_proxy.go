@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/WlayRay/ElectricSearch/util"
@@ -12,19 +14,26 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// IServiceHub 注册中心，与具体的后端无关。ServiceHub和ServiceHubProxy使用etcd，StaticServiceHub使用静态的YAML文件
 type IServiceHub interface {
-	Register(group, endpoint string, leaseID etcdv3.LeaseID) (etcdv3.LeaseID, error) // 注册服务
-	UnRegister(group, endpoint string) error                                         // 注销服务
-	GetServiceEndpoints(group string) []string                                       // 服务发现
-	GetServiceEndpoint(group string) string                                          // 根据负载均衡获取一台服务的endpoint
-	Close()                                                                          // 关闭etcd连接
+	Register(group, endpoint string) error     // 注册服务，注册中心负责保活直到UnRegister或者Close
+	UnRegister(group, endpoint string) error   // 注销服务
+	GetServiceEndpoints(group string) []string // 服务发现
+	GetServiceEndpoint(group string) string    // 根据负载均衡获取一台服务的endpoint
+	GetPrimaryEndpoint(group string) string    // 获取group的主节点，没有主节点时返回空字符串
+	GetLoadBalancer() LoadBalancer             // 获取负载均衡算法
+	GetShardMap() *ShardMap                    // 获取当前索引的分片映射，没有可用的group时返回nil
+	Close()                                    // 关闭与注册中心的连接
 }
 
-// 代理模式，对ServiceHub做一层代理，提供缓存和限流保护
+// 代理模式，对ServiceHub做一层代理，提供缓存和限流保护。worker地址、主节点和分片映射都缓存在本地，通过etcd watch更新
 type ServiceHubProxy struct {
 	*ServiceHub // ServiceHubProxy拥有ServiceHub的所有方法（匿名成员变量）
 	// 服务端地址缓存
 	endpointCache sync.Map //维护每一个service下的所有servers
+	primaryCache  sync.Map // group -> 主节点，没有主节点时为空字符串
+	shardMap      atomic.Pointer[ShardMap]
+	shardMapWatch sync.Once
 	limiter       *rate.Limiter
 }
 
@@ -52,7 +61,7 @@ func (proxy *ServiceHubProxy) watchEndpointsOfGroup(group string) {
 		return
 	}
 
-	prefix := strings.TrimRight(ServiceRootPath, "/") + indexName + "/" + group + "/"
+	prefix := ServiceRootPath + indexName + "/" + group + "/"
 	watchChan := proxy.client.Watch(context.Background(), prefix, etcdv3.WithPrefix())
 	util.Log.Printf("watch group: %s", group)
 
@@ -94,3 +103,70 @@ func (proxy *ServiceHubProxy) GetServiceEndpoints(group string) []string {
 		return endpoints
 	}
 }
+
+// GetPrimaryEndpoint 返回缓存的主节点，第一次查询group时从etcd读取并监听选主key的变化
+func (proxy *ServiceHubProxy) GetPrimaryEndpoint(group string) string {
+	proxy.watchPrimaryOfGroup(group)
+	if primary, exists := proxy.primaryCache.Load(group); exists {
+		return primary.(string)
+	}
+	// watch先于读取启动，watch已经更新过缓存时以缓存为准
+	primary, _ := proxy.primaryCache.LoadOrStore(group, proxy.ServiceHub.GetPrimaryEndpoint(group))
+	return primary.(string)
+}
+
+func (proxy *ServiceHubProxy) watchPrimaryOfGroup(group string) {
+	prefix := primaryPrefix(group) + "/"
+	if _, exists := proxy.watched.LoadOrStore(prefix, true); exists {
+		return
+	}
+	watchChan := proxy.client.Watch(context.Background(), prefix, etcdv3.WithPrefix())
+	util.Log.Printf("watch primary of group: %s", group)
+
+	go func() {
+		// 候选者的key变化时重新按创建时间确定主节点
+		for range watchChan {
+			primary := proxy.ServiceHub.GetPrimaryEndpoint(group)
+			proxy.primaryCache.Store(group, primary)
+			util.Log.Printf("primary of group %s changed to %q", group, primary)
+		}
+	}()
+}
+
+// GetShardMap 返回缓存的分片映射，第一次调用时从etcd读取并监听分片映射的变化。返回的分片映射不能修改
+func (proxy *ServiceHubProxy) GetShardMap() *ShardMap {
+	proxy.shardMapWatch.Do(proxy.watchShardMap)
+	if shardMap := proxy.shardMap.Load(); shardMap != nil {
+		return shardMap
+	}
+	shardMap := proxy.ServiceHub.GetShardMap()
+	if shardMap == nil || proxy.shardMap.CompareAndSwap(nil, shardMap) {
+		return shardMap
+	}
+	return proxy.shardMap.Load()
+}
+
+func (proxy *ServiceHubProxy) watchShardMap() {
+	key := shardMapKey(indexName)
+	watchChan := proxy.client.Watch(context.Background(), key)
+	util.Log.Printf("watch shard map: %s", key)
+
+	go func() {
+		for response := range watchChan {
+			for _, event := range response.Events {
+				if event.Type == etcdv3.EventTypeDelete {
+					proxy.shardMap.Store(nil)
+					continue
+				}
+				shardMap := new(ShardMap)
+				if err := json.Unmarshal(event.Kv.Value, shardMap); err != nil {
+					util.Log.Printf("invalid shard map: %s", err)
+					proxy.shardMap.Store(nil) // 下次读取时直接查询etcd
+					continue
+				}
+				proxy.shardMap.Store(shardMap)
+				util.Log.Printf("shard map changed to epoch %d", shardMap.Epoch)
+			}
+		}
+	}()
+}
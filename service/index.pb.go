@@ -28,7 +28,8 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type DocId struct {
-	DocId string `protobuf:"bytes,1,opt,name=DocId,proto3" json:"DocId,omitempty"`
+	DocId           string `protobuf:"bytes,1,opt,name=DocId,proto3" json:"DocId,omitempty"`
+	ExpectedVersion uint64 `protobuf:"varint,2,opt,name=ExpectedVersion,proto3" json:"ExpectedVersion,omitempty"`
 }
 
 func (m *DocId) Reset()         { *m = DocId{} }
@@ -71,8 +72,16 @@ func (m *DocId) GetDocId() string {
 	return ""
 }
 
+func (m *DocId) GetExpectedVersion() uint64 {
+	if m != nil {
+		return m.ExpectedVersion
+	}
+	return 0
+}
+
 type AffectedCount struct {
-	Count uint32 `protobuf:"varint,1,opt,name=Count,proto3" json:"Count,omitempty"`
+	Count   uint32 `protobuf:"varint,1,opt,name=Count,proto3" json:"Count,omitempty"`
+	Version uint64 `protobuf:"varint,2,opt,name=Version,proto3" json:"Version,omitempty"`
 }
 
 func (m *AffectedCount) Reset()         { *m = AffectedCount{} }
@@ -115,6 +124,13 @@ func (m *AffectedCount) GetCount() uint32 {
 	return 0
 }
 
+func (m *AffectedCount) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
 type SearchRequest struct {
 	Query   *types.TermQuery `protobuf:"bytes,1,opt,name=Query,proto3" json:"Query,omitempty"`
 	OnFlag  uint64           `protobuf:"varint,2,opt,name=OnFlag,proto3" json:"OnFlag,omitempty"`
@@ -228,6 +244,9 @@ func (m *SearchResponse) GetDocuments() []*types.Document {
 }
 
 type CountRequest struct {
+	Slots        uint32   `protobuf:"varint,1,opt,name=Slots,proto3" json:"Slots,omitempty"`
+	Seed         uint32   `protobuf:"varint,2,opt,name=Seed,proto3" json:"Seed,omitempty"`
+	ExcludeSlots []uint32 `protobuf:"varint,3,rep,packed,name=ExcludeSlots,proto3" json:"ExcludeSlots,omitempty"`
 }
 
 func (m *CountRequest) Reset()         { *m = CountRequest{} }
@@ -263,506 +282,4893 @@ func (m *CountRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_CountRequest proto.InternalMessageInfo
 
-func init() {
-	proto.RegisterType((*DocId)(nil), "raybox.service.DocId")
-	proto.RegisterType((*AffectedCount)(nil), "raybox.service.AffectedCount")
-	proto.RegisterType((*SearchRequest)(nil), "raybox.service.SearchRequest")
-	proto.RegisterType((*SearchResponse)(nil), "raybox.service.SearchResponse")
-	proto.RegisterType((*CountRequest)(nil), "raybox.service.CountRequest")
+func (m *CountRequest) GetSlots() uint32 {
+	if m != nil {
+		return m.Slots
+	}
+	return 0
 }
 
-func init() { proto.RegisterFile("index.proto", fileDescriptor_f750e0f7889345b5) }
-
-var fileDescriptor_f750e0f7889345b5 = []byte{
-	// 379 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0xcb, 0x8e, 0xda, 0x40,
-	0x10, 0x64, 0x78, 0x18, 0x79, 0x78, 0x28, 0x1a, 0x25, 0x91, 0x65, 0x81, 0xe5, 0x58, 0x8a, 0xc4,
-	0xc9, 0x07, 0x73, 0x8f, 0x44, 0xe2, 0x24, 0xe2, 0x84, 0x62, 0x72, 0xca, 0x25, 0x32, 0x76, 0x3b,
-	0x41, 0x02, 0x0f, 0x8c, 0xc7, 0x11, 0x7c, 0x45, 0xf6, 0x13, 0xf6, 0x73, 0xf6, 0xc8, 0x71, 0x8f,
-	0x2b, 0xf8, 0x91, 0x95, 0x67, 0xc6, 0xbb, 0x98, 0xd5, 0x8a, 0x5b, 0x57, 0x57, 0xb5, 0x5d, 0x5d,
-	0x3d, 0xb8, 0xb3, 0x4c, 0x63, 0xd8, 0xb9, 0x1b, 0x46, 0x39, 0x25, 0x7d, 0x16, 0xee, 0x17, 0x74,
-	0xe7, 0x66, 0xc0, 0xfe, 0x2d, 0x23, 0x30, 0xf5, 0x98, 0x46, 0x92, 0x32, 0xdf, 0x70, 0x60, 0xeb,
-	0xdf, 0xdb, 0x1c, 0xd8, 0x5e, 0x76, 0x9c, 0x21, 0x6e, 0xf9, 0x34, 0x9a, 0xc6, 0xe4, 0xad, 0x2a,
-	0x0c, 0x64, 0xa3, 0x91, 0x1e, 0x48, 0xe0, 0x7c, 0xc4, 0xbd, 0x49, 0x92, 0x40, 0xc4, 0x21, 0xfe,
-	0x42, 0xf3, 0x94, 0x17, 0x32, 0x51, 0x08, 0x59, 0x2f, 0x90, 0xc0, 0xf9, 0x8f, 0x70, 0x6f, 0x0e,
-	0x21, 0x8b, 0xfe, 0x06, 0xb0, 0xcd, 0x21, 0xe3, 0xc4, 0xc3, 0xad, 0x1f, 0xc5, 0x6f, 0x84, 0xae,
-	0xe3, 0x0d, 0x5c, 0x65, 0xea, 0xcc, 0xc0, 0x4f, 0x60, 0x6b, 0xa1, 0x09, 0xa4, 0x94, 0xbc, 0xc7,
-	0xda, 0x2c, 0xfd, 0xb6, 0x0a, 0xff, 0x18, 0x75, 0x1b, 0x8d, 0x9a, 0x81, 0x42, 0xc4, 0xc0, 0xed,
-	0x59, 0x92, 0x08, 0xa2, 0x21, 0x88, 0x12, 0x0a, 0x86, 0x15, 0x55, 0x66, 0x34, 0xed, 0x86, 0x60,
-	0x24, 0x74, 0xbe, 0xe2, 0x7e, 0x69, 0x28, 0xdb, 0xd0, 0x34, 0x03, 0x32, 0xc6, 0xba, 0x4f, 0xa3,
-	0x7c, 0x0d, 0x29, 0xcf, 0x0c, 0x64, 0x37, 0x46, 0x1d, 0xef, 0x5d, 0xe9, 0x2a, 0x0e, 0x79, 0xe8,
-	0x96, 0x6c, 0xf0, 0xac, 0x73, 0xfa, 0xb8, 0x2b, 0x36, 0x54, 0x6b, 0x79, 0xb7, 0x75, 0xdc, 0x9d,
-	0x16, 0x59, 0xcf, 0x65, 0xb8, 0x64, 0x82, 0x75, 0x1f, 0x56, 0xc0, 0xc1, 0xa7, 0x11, 0x79, 0xfa,
-	0x9e, 0x8a, 0xde, 0x15, 0x21, 0x9a, 0xc3, 0xcb, 0x76, 0x35, 0xd2, 0x4f, 0x58, 0x9b, 0xc4, 0x71,
-	0x65, 0xbe, 0xe2, 0xe7, 0xda, 0xfc, 0x77, 0xac, 0xc9, 0x55, 0xc9, 0x0b, 0x61, 0xe5, 0x26, 0xa6,
-	0xf5, 0x1a, 0xad, 0x12, 0xf2, 0xd5, 0x6d, 0xc9, 0xe0, 0x52, 0x78, 0x9e, 0xc1, 0x15, 0x3b, 0x9f,
-	0x3f, 0xdc, 0x1d, 0x2d, 0x74, 0x38, 0x5a, 0xe8, 0xe1, 0x68, 0xa1, 0x9b, 0x93, 0x55, 0x3b, 0x9c,
-	0xac, 0xda, 0xfd, 0xc9, 0xaa, 0xfd, 0x6a, 0xab, 0x81, 0x85, 0x26, 0xde, 0xde, 0xf8, 0x31, 0x00,
-	0x00, 0xff, 0xff, 0x33, 0xc4, 0x8f, 0x23, 0xb7, 0x02, 0x00, 0x00,
+func (m *CountRequest) GetSeed() uint32 {
+	if m != nil {
+		return m.Seed
+	}
+	return 0
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// IndexServiceClient is the client API for IndexService service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type IndexServiceClient interface {
-	DeleteDoc(ctx context.Context, in *DocId, opts ...grpc.CallOption) (*AffectedCount, error)
-	AddDoc(ctx context.Context, in *types.Document, opts ...grpc.CallOption) (*AffectedCount, error)
-	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
-	Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*AffectedCount, error)
+func (m *CountRequest) GetExcludeSlots() []uint32 {
+	if m != nil {
+		return m.ExcludeSlots
+	}
+	return nil
 }
 
-type indexServiceClient struct {
-	cc *grpc.ClientConn
+type DocError struct {
+	DocId  string `protobuf:"bytes,1,opt,name=DocId,proto3" json:"DocId,omitempty"`
+	Code   uint32 `protobuf:"varint,2,opt,name=Code,proto3" json:"Code,omitempty"`
+	Reason string `protobuf:"bytes,3,opt,name=Reason,proto3" json:"Reason,omitempty"`
 }
 
-func NewIndexServiceClient(cc *grpc.ClientConn) IndexServiceClient {
-	return &indexServiceClient{cc}
+func (m *DocError) Reset()         { *m = DocError{} }
+func (m *DocError) String() string { return proto.CompactTextString(m) }
+func (*DocError) ProtoMessage()    {}
+func (*DocError) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{5}
 }
-
-func (c *indexServiceClient) DeleteDoc(ctx context.Context, in *DocId, opts ...grpc.CallOption) (*AffectedCount, error) {
-	out := new(AffectedCount)
-	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/DeleteDoc", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *DocError) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DocError) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DocError.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return out, nil
+}
+func (m *DocError) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DocError.Merge(m, src)
+}
+func (m *DocError) XXX_Size() int {
+	return m.Size()
+}
+func (m *DocError) XXX_DiscardUnknown() {
+	xxx_messageInfo_DocError.DiscardUnknown(m)
 }
 
-func (c *indexServiceClient) AddDoc(ctx context.Context, in *types.Document, opts ...grpc.CallOption) (*AffectedCount, error) {
-	out := new(AffectedCount)
-	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/AddDoc", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_DocError proto.InternalMessageInfo
+
+func (m *DocError) GetDocId() string {
+	if m != nil {
+		return m.DocId
 	}
-	return out, nil
+	return ""
 }
 
-func (c *indexServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
-	out := new(SearchResponse)
-	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/Search", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *DocError) GetCode() uint32 {
+	if m != nil {
+		return m.Code
 	}
-	return out, nil
+	return 0
 }
 
-func (c *indexServiceClient) Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*AffectedCount, error) {
-	out := new(AffectedCount)
-	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/Count", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *DocError) GetReason() string {
+	if m != nil {
+		return m.Reason
 	}
-	return out, nil
+	return ""
 }
 
-// IndexServiceServer is the server API for IndexService service.
-type IndexServiceServer interface {
-	DeleteDoc(context.Context, *DocId) (*AffectedCount, error)
-	AddDoc(context.Context, *types.Document) (*AffectedCount, error)
-	Search(context.Context, *SearchRequest) (*SearchResponse, error)
-	Count(context.Context, *CountRequest) (*AffectedCount, error)
+type BulkAddResponse struct {
+	Count  uint32      `protobuf:"varint,1,opt,name=Count,proto3" json:"Count,omitempty"`
+	Errors []*DocError `protobuf:"bytes,2,rep,name=Errors,proto3" json:"Errors,omitempty"`
 }
 
-// UnimplementedIndexServiceServer can be embedded to have forward compatible implementations.
-type UnimplementedIndexServiceServer struct {
+func (m *BulkAddResponse) Reset()         { *m = BulkAddResponse{} }
+func (m *BulkAddResponse) String() string { return proto.CompactTextString(m) }
+func (*BulkAddResponse) ProtoMessage()    {}
+func (*BulkAddResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{6}
 }
-
-func (*UnimplementedIndexServiceServer) DeleteDoc(ctx context.Context, req *DocId) (*AffectedCount, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method DeleteDoc not implemented")
+func (m *BulkAddResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
 }
-func (*UnimplementedIndexServiceServer) AddDoc(ctx context.Context, req *types.Document) (*AffectedCount, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method AddDoc not implemented")
+func (m *BulkAddResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_BulkAddResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
 }
-func (*UnimplementedIndexServiceServer) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+func (m *BulkAddResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BulkAddResponse.Merge(m, src)
 }
-func (*UnimplementedIndexServiceServer) Count(ctx context.Context, req *CountRequest) (*AffectedCount, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Count not implemented")
+func (m *BulkAddResponse) XXX_Size() int {
+	return m.Size()
 }
-
-func RegisterIndexServiceServer(s *grpc.Server, srv IndexServiceServer) {
-	s.RegisterService(&_IndexService_serviceDesc, srv)
+func (m *BulkAddResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_BulkAddResponse.DiscardUnknown(m)
 }
 
-func _IndexService_DeleteDoc_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DocId)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(IndexServiceServer).DeleteDoc(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/raybox.service.IndexService/DeleteDoc",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(IndexServiceServer).DeleteDoc(ctx, req.(*DocId))
+var xxx_messageInfo_BulkAddResponse proto.InternalMessageInfo
+
+func (m *BulkAddResponse) GetCount() uint32 {
+	if m != nil {
+		return m.Count
 	}
-	return interceptor(ctx, in, info, handler)
+	return 0
 }
 
-func _IndexService_AddDoc_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(types.Document)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(IndexServiceServer).AddDoc(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/raybox.service.IndexService/AddDoc",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(IndexServiceServer).AddDoc(ctx, req.(*types.Document))
+func (m *BulkAddResponse) GetErrors() []*DocError {
+	if m != nil {
+		return m.Errors
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-func _IndexService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SearchRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(IndexServiceServer).Search(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/raybox.service.IndexService/Search",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(IndexServiceServer).Search(ctx, req.(*SearchRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+type BackupRequest struct {
+	SinceVersion        uint64 `protobuf:"varint,1,opt,name=SinceVersion,proto3" json:"SinceVersion,omitempty"`
+	IncludeReverseIndex bool   `protobuf:"varint,2,opt,name=IncludeReverseIndex,proto3" json:"IncludeReverseIndex,omitempty"`
 }
 
-func _IndexService_Count_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CountRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(IndexServiceServer).Count(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/raybox.service.IndexService/Count",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(IndexServiceServer).Count(ctx, req.(*CountRequest))
+func (m *BackupRequest) Reset()         { *m = BackupRequest{} }
+func (m *BackupRequest) String() string { return proto.CompactTextString(m) }
+func (*BackupRequest) ProtoMessage()    {}
+func (*BackupRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{7}
+}
+func (m *BackupRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *BackupRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_BackupRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return interceptor(ctx, in, info, handler)
+}
+func (m *BackupRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BackupRequest.Merge(m, src)
+}
+func (m *BackupRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *BackupRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BackupRequest.DiscardUnknown(m)
 }
 
-var _IndexService_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "raybox.service.IndexService",
-	HandlerType: (*IndexServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "DeleteDoc",
-			Handler:    _IndexService_DeleteDoc_Handler,
-		},
-		{
-			MethodName: "AddDoc",
-			Handler:    _IndexService_AddDoc_Handler,
-		},
-		{
-			MethodName: "Search",
-			Handler:    _IndexService_Search_Handler,
-		},
-		{
-			MethodName: "Count",
-			Handler:    _IndexService_Count_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "index.proto",
+var xxx_messageInfo_BackupRequest proto.InternalMessageInfo
+
+func (m *BackupRequest) GetSinceVersion() uint64 {
+	if m != nil {
+		return m.SinceVersion
+	}
+	return 0
 }
 
-func (m *DocId) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *BackupRequest) GetIncludeReverseIndex() bool {
+	if m != nil {
+		return m.IncludeReverseIndex
 	}
-	return dAtA[:n], nil
+	return false
 }
 
-func (m *DocId) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type SnapshotChunk struct {
+	Data []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
 }
 
-func (m *DocId) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.DocId) > 0 {
-		i -= len(m.DocId)
-		copy(dAtA[i:], m.DocId)
-		i = encodeVarintIndex(dAtA, i, uint64(len(m.DocId)))
-		i--
-		dAtA[i] = 0xa
+func (m *SnapshotChunk) Reset()         { *m = SnapshotChunk{} }
+func (m *SnapshotChunk) String() string { return proto.CompactTextString(m) }
+func (*SnapshotChunk) ProtoMessage()    {}
+func (*SnapshotChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{8}
+}
+func (m *SnapshotChunk) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SnapshotChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SnapshotChunk.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *SnapshotChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotChunk.Merge(m, src)
+}
+func (m *SnapshotChunk) XXX_Size() int {
+	return m.Size()
+}
+func (m *SnapshotChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotChunk.DiscardUnknown(m)
 }
 
-func (m *AffectedCount) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_SnapshotChunk proto.InternalMessageInfo
+
+func (m *SnapshotChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *AffectedCount) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type RestoreResponse struct {
+	Count   uint32 `protobuf:"varint,1,opt,name=Count,proto3" json:"Count,omitempty"`
+	Version uint64 `protobuf:"varint,2,opt,name=Version,proto3" json:"Version,omitempty"`
 }
 
-func (m *AffectedCount) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.Count != 0 {
-		i = encodeVarintIndex(dAtA, i, uint64(m.Count))
-		i--
-		dAtA[i] = 0x8
+func (m *RestoreResponse) Reset()         { *m = RestoreResponse{} }
+func (m *RestoreResponse) String() string { return proto.CompactTextString(m) }
+func (*RestoreResponse) ProtoMessage()    {}
+func (*RestoreResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{9}
+}
+func (m *RestoreResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RestoreResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RestoreResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *RestoreResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreResponse.Merge(m, src)
+}
+func (m *RestoreResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *RestoreResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreResponse.DiscardUnknown(m)
 }
 
-func (m *SearchRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_RestoreResponse proto.InternalMessageInfo
+
+func (m *RestoreResponse) GetCount() uint32 {
+	if m != nil {
+		return m.Count
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *SearchRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *RestoreResponse) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
 }
 
-func (m *SearchRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.OrFlags) > 0 {
-		dAtA2 := make([]byte, len(m.OrFlags)*10)
-		var j1 int
-		for _, num := range m.OrFlags {
-			for num >= 1<<7 {
-				dAtA2[j1] = uint8(uint64(num)&0x7f | 0x80)
-				num >>= 7
-				j1++
-			}
-			dAtA2[j1] = uint8(num)
-			j1++
+type MaintainRequest struct {
+}
+
+func (m *MaintainRequest) Reset()         { *m = MaintainRequest{} }
+func (m *MaintainRequest) String() string { return proto.CompactTextString(m) }
+func (*MaintainRequest) ProtoMessage()    {}
+func (*MaintainRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{10}
+}
+func (m *MaintainRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MaintainRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MaintainRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i -= j1
-		copy(dAtA[i:], dAtA2[:j1])
-		i = encodeVarintIndex(dAtA, i, uint64(j1))
-		i--
-		dAtA[i] = 0x22
-	}
-	if m.OffFlag != 0 {
-		i = encodeVarintIndex(dAtA, i, uint64(m.OffFlag))
-		i--
-		dAtA[i] = 0x18
-	}
-	if m.OnFlag != 0 {
-		i = encodeVarintIndex(dAtA, i, uint64(m.OnFlag))
-		i--
-		dAtA[i] = 0x10
+		return b[:n], nil
 	}
-	if m.Query != nil {
-		{
-			size, err := m.Query.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintIndex(dAtA, i, uint64(size))
+}
+func (m *MaintainRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MaintainRequest.Merge(m, src)
+}
+func (m *MaintainRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *MaintainRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_MaintainRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MaintainRequest proto.InternalMessageInfo
+
+type MaintainResponse struct {
+	Engine     int32  `protobuf:"varint,1,opt,name=Engine,proto3" json:"Engine,omitempty"`
+	Path       string `protobuf:"bytes,2,opt,name=Path,proto3" json:"Path,omitempty"`
+	SizeBefore int64  `protobuf:"varint,3,opt,name=SizeBefore,proto3" json:"SizeBefore,omitempty"`
+	SizeAfter  int64  `protobuf:"varint,4,opt,name=SizeAfter,proto3" json:"SizeAfter,omitempty"`
+	DurationMs int64  `protobuf:"varint,5,opt,name=DurationMs,proto3" json:"DurationMs,omitempty"`
+}
+
+func (m *MaintainResponse) Reset()         { *m = MaintainResponse{} }
+func (m *MaintainResponse) String() string { return proto.CompactTextString(m) }
+func (*MaintainResponse) ProtoMessage()    {}
+func (*MaintainResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{11}
+}
+func (m *MaintainResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MaintainResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MaintainResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0xa
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
+}
+func (m *MaintainResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MaintainResponse.Merge(m, src)
+}
+func (m *MaintainResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *MaintainResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MaintainResponse.DiscardUnknown(m)
 }
 
-func (m *SearchResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_MaintainResponse proto.InternalMessageInfo
+
+func (m *MaintainResponse) GetEngine() int32 {
+	if m != nil {
+		return m.Engine
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *SearchResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *MaintainResponse) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
 }
 
-func (m *SearchResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.Documents) > 0 {
-		for iNdEx := len(m.Documents) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Documents[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintIndex(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
-		}
+func (m *MaintainResponse) GetSizeBefore() int64 {
+	if m != nil {
+		return m.SizeBefore
 	}
-	return len(dAtA) - i, nil
+	return 0
 }
 
-func (m *CountRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *MaintainResponse) GetSizeAfter() int64 {
+	if m != nil {
+		return m.SizeAfter
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *CountRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *MaintainResponse) GetDurationMs() int64 {
+	if m != nil {
+		return m.DurationMs
+	}
+	return 0
 }
 
-func (m *CountRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	return len(dAtA) - i, nil
+type StatsRequest struct {
 }
 
-func encodeVarintIndex(dAtA []byte, offset int, v uint64) int {
-	offset -= sovIndex(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
-	}
-	dAtA[offset] = uint8(v)
-	return base
+func (m *StatsRequest) Reset()         { *m = StatsRequest{} }
+func (m *StatsRequest) String() string { return proto.CompactTextString(m) }
+func (*StatsRequest) ProtoMessage()    {}
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{12}
 }
-func (m *DocId) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.DocId)
-	if l > 0 {
-		n += 1 + l + sovIndex(uint64(l))
+func (m *StatsRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StatsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StatsRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return n
+}
+func (m *StatsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatsRequest.Merge(m, src)
+}
+func (m *StatsRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *StatsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatsRequest.DiscardUnknown(m)
 }
 
-func (m *AffectedCount) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Count != 0 {
-		n += 1 + sovIndex(uint64(m.Count))
-	}
-	return n
+var xxx_messageInfo_StatsRequest proto.InternalMessageInfo
+
+type StatsResponse struct {
+	DocCount          int64            `protobuf:"varint,1,opt,name=DocCount,proto3" json:"DocCount,omitempty"`
+	DeletedCount      int64            `protobuf:"varint,2,opt,name=DeletedCount,proto3" json:"DeletedCount,omitempty"`
+	FieldTerms        map[string]int64 `protobuf:"bytes,3,rep,name=FieldTerms,proto3" json:"FieldTerms,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	PostingLists      int64            `protobuf:"varint,4,opt,name=PostingLists,proto3" json:"PostingLists,omitempty"`
+	Postings          int64            `protobuf:"varint,5,opt,name=Postings,proto3" json:"Postings,omitempty"`
+	PostingP50        int64            `protobuf:"varint,6,opt,name=PostingP50,proto3" json:"PostingP50,omitempty"`
+	PostingP90        int64            `protobuf:"varint,7,opt,name=PostingP90,proto3" json:"PostingP90,omitempty"`
+	PostingP99        int64            `protobuf:"varint,8,opt,name=PostingP99,proto3" json:"PostingP99,omitempty"`
+	PostingMax        int64            `protobuf:"varint,9,opt,name=PostingMax,proto3" json:"PostingMax,omitempty"`
+	ForwardIndexBytes int64            `protobuf:"varint,10,opt,name=ForwardIndexBytes,proto3" json:"ForwardIndexBytes,omitempty"`
+	ReverseIndexBytes int64            `protobuf:"varint,11,opt,name=ReverseIndexBytes,proto3" json:"ReverseIndexBytes,omitempty"`
+	Compression       string           `protobuf:"bytes,12,opt,name=Compression,proto3" json:"Compression,omitempty"`
+	RawBytes          int64            `protobuf:"varint,13,opt,name=RawBytes,proto3" json:"RawBytes,omitempty"`
+	StoredBytes       int64            `protobuf:"varint,14,opt,name=StoredBytes,proto3" json:"StoredBytes,omitempty"`
+	Primary           bool             `protobuf:"varint,15,opt,name=Primary,proto3" json:"Primary,omitempty"`
+	AppliedSeq        uint64           `protobuf:"varint,16,opt,name=AppliedSeq,proto3" json:"AppliedSeq,omitempty"`
 }
 
-func (m *SearchRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if m.Query != nil {
-		l = m.Query.Size()
-		n += 1 + l + sovIndex(uint64(l))
+func (m *StatsResponse) Reset()         { *m = StatsResponse{} }
+func (m *StatsResponse) String() string { return proto.CompactTextString(m) }
+func (*StatsResponse) ProtoMessage()    {}
+func (*StatsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{13}
+}
+func (m *StatsResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StatsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StatsResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	if m.OnFlag != 0 {
-		n += 1 + sovIndex(uint64(m.OnFlag))
+}
+func (m *StatsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatsResponse.Merge(m, src)
+}
+func (m *StatsResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *StatsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatsResponse proto.InternalMessageInfo
+
+func (m *StatsResponse) GetDocCount() int64 {
+	if m != nil {
+		return m.DocCount
 	}
-	if m.OffFlag != 0 {
-		n += 1 + sovIndex(uint64(m.OffFlag))
+	return 0
+}
+
+func (m *StatsResponse) GetDeletedCount() int64 {
+	if m != nil {
+		return m.DeletedCount
 	}
-	if len(m.OrFlags) > 0 {
-		l = 0
-		for _, e := range m.OrFlags {
-			l += sovIndex(uint64(e))
+	return 0
+}
+
+func (m *StatsResponse) GetFieldTerms() map[string]int64 {
+	if m != nil {
+		return m.FieldTerms
+	}
+	return nil
+}
+
+func (m *StatsResponse) GetPostingLists() int64 {
+	if m != nil {
+		return m.PostingLists
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetPostings() int64 {
+	if m != nil {
+		return m.Postings
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetPostingP50() int64 {
+	if m != nil {
+		return m.PostingP50
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetPostingP90() int64 {
+	if m != nil {
+		return m.PostingP90
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetPostingP99() int64 {
+	if m != nil {
+		return m.PostingP99
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetPostingMax() int64 {
+	if m != nil {
+		return m.PostingMax
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetForwardIndexBytes() int64 {
+	if m != nil {
+		return m.ForwardIndexBytes
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetReverseIndexBytes() int64 {
+	if m != nil {
+		return m.ReverseIndexBytes
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetCompression() string {
+	if m != nil {
+		return m.Compression
+	}
+	return ""
+}
+
+func (m *StatsResponse) GetRawBytes() int64 {
+	if m != nil {
+		return m.RawBytes
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetStoredBytes() int64 {
+	if m != nil {
+		return m.StoredBytes
+	}
+	return 0
+}
+
+func (m *StatsResponse) GetPrimary() bool {
+	if m != nil {
+		return m.Primary
+	}
+	return false
+}
+
+func (m *StatsResponse) GetAppliedSeq() uint64 {
+	if m != nil {
+		return m.AppliedSeq
+	}
+	return 0
+}
+
+type ReplicationOp struct {
+	Seq   uint64          `protobuf:"varint,1,opt,name=Seq,proto3" json:"Seq,omitempty"`
+	DocId string          `protobuf:"bytes,2,opt,name=DocId,proto3" json:"DocId,omitempty"`
+	Doc   *types.Document `protobuf:"bytes,3,opt,name=Doc,proto3" json:"Doc,omitempty"`
+	Term  uint64          `protobuf:"varint,4,opt,name=Term,proto3" json:"Term,omitempty"`
+}
+
+func (m *ReplicationOp) Reset()         { *m = ReplicationOp{} }
+func (m *ReplicationOp) String() string { return proto.CompactTextString(m) }
+func (*ReplicationOp) ProtoMessage()    {}
+func (*ReplicationOp) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{14}
+}
+func (m *ReplicationOp) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ReplicationOp) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ReplicationOp.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		n += 1 + sovIndex(uint64(l)) + l
+		return b[:n], nil
 	}
-	return n
+}
+func (m *ReplicationOp) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplicationOp.Merge(m, src)
+}
+func (m *ReplicationOp) XXX_Size() int {
+	return m.Size()
+}
+func (m *ReplicationOp) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplicationOp.DiscardUnknown(m)
 }
 
-func (m *SearchResponse) Size() (n int) {
-	if m == nil {
-		return 0
+var xxx_messageInfo_ReplicationOp proto.InternalMessageInfo
+
+func (m *ReplicationOp) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
 	}
-	var l int
-	_ = l
-	if len(m.Documents) > 0 {
-		for _, e := range m.Documents {
-			l = e.Size()
-			n += 1 + l + sovIndex(uint64(l))
+	return 0
+}
+
+func (m *ReplicationOp) GetDocId() string {
+	if m != nil {
+		return m.DocId
+	}
+	return ""
+}
+
+func (m *ReplicationOp) GetDoc() *types.Document {
+	if m != nil {
+		return m.Doc
+	}
+	return nil
+}
+
+func (m *ReplicationOp) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+type FetchOpsRequest struct {
+	Since uint64 `protobuf:"varint,1,opt,name=Since,proto3" json:"Since,omitempty"`
+	Term  uint64 `protobuf:"varint,2,opt,name=Term,proto3" json:"Term,omitempty"`
+}
+
+func (m *FetchOpsRequest) Reset()         { *m = FetchOpsRequest{} }
+func (m *FetchOpsRequest) String() string { return proto.CompactTextString(m) }
+func (*FetchOpsRequest) ProtoMessage()    {}
+func (*FetchOpsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{15}
+}
+func (m *FetchOpsRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *FetchOpsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_FetchOpsRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *FetchOpsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchOpsRequest.Merge(m, src)
+}
+func (m *FetchOpsRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *FetchOpsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchOpsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchOpsRequest proto.InternalMessageInfo
+
+func (m *FetchOpsRequest) GetSince() uint64 {
+	if m != nil {
+		return m.Since
+	}
+	return 0
+}
+
+func (m *FetchOpsRequest) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+type RecoverRequest struct {
+}
+
+func (m *RecoverRequest) Reset()         { *m = RecoverRequest{} }
+func (m *RecoverRequest) String() string { return proto.CompactTextString(m) }
+func (*RecoverRequest) ProtoMessage()    {}
+func (*RecoverRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{16}
+}
+func (m *RecoverRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RecoverRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RecoverRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *RecoverRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RecoverRequest.Merge(m, src)
+}
+func (m *RecoverRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *RecoverRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RecoverRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RecoverRequest proto.InternalMessageInfo
+
+type RecoveryChunk struct {
+	Snapshot     []byte         `protobuf:"bytes,1,opt,name=Snapshot,proto3" json:"Snapshot,omitempty"`
+	SnapshotDone bool           `protobuf:"varint,2,opt,name=SnapshotDone,proto3" json:"SnapshotDone,omitempty"`
+	Seq          uint64         `protobuf:"varint,3,opt,name=Seq,proto3" json:"Seq,omitempty"`
+	Op           *ReplicationOp `protobuf:"bytes,4,opt,name=Op,proto3" json:"Op,omitempty"`
+	Term         uint64         `protobuf:"varint,5,opt,name=Term,proto3" json:"Term,omitempty"`
+}
+
+func (m *RecoveryChunk) Reset()         { *m = RecoveryChunk{} }
+func (m *RecoveryChunk) String() string { return proto.CompactTextString(m) }
+func (*RecoveryChunk) ProtoMessage()    {}
+func (*RecoveryChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{17}
+}
+func (m *RecoveryChunk) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RecoveryChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RecoveryChunk.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *RecoveryChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RecoveryChunk.Merge(m, src)
+}
+func (m *RecoveryChunk) XXX_Size() int {
+	return m.Size()
+}
+func (m *RecoveryChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_RecoveryChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RecoveryChunk proto.InternalMessageInfo
+
+func (m *RecoveryChunk) GetSnapshot() []byte {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+func (m *RecoveryChunk) GetSnapshotDone() bool {
+	if m != nil {
+		return m.SnapshotDone
+	}
+	return false
+}
+
+func (m *RecoveryChunk) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *RecoveryChunk) GetOp() *ReplicationOp {
+	if m != nil {
+		return m.Op
+	}
+	return nil
+}
+
+func (m *RecoveryChunk) GetTerm() uint64 {
+	if m != nil {
+		return m.Term
+	}
+	return 0
+}
+
+type SlotRequest struct {
+	Slots       uint32 `protobuf:"varint,1,opt,name=Slots,proto3" json:"Slots,omitempty"`
+	Slot        uint32 `protobuf:"varint,2,opt,name=Slot,proto3" json:"Slot,omitempty"`
+	Seed        uint32 `protobuf:"varint,3,opt,name=Seed,proto3" json:"Seed,omitempty"`
+	VersionOnly bool   `protobuf:"varint,4,opt,name=VersionOnly,proto3" json:"VersionOnly,omitempty"`
+}
+
+func (m *SlotRequest) Reset()         { *m = SlotRequest{} }
+func (m *SlotRequest) String() string { return proto.CompactTextString(m) }
+func (*SlotRequest) ProtoMessage()    {}
+func (*SlotRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f750e0f7889345b5, []int{18}
+}
+func (m *SlotRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SlotRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SlotRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SlotRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SlotRequest.Merge(m, src)
+}
+func (m *SlotRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SlotRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SlotRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SlotRequest proto.InternalMessageInfo
+
+func (m *SlotRequest) GetSlots() uint32 {
+	if m != nil {
+		return m.Slots
+	}
+	return 0
+}
+
+func (m *SlotRequest) GetSlot() uint32 {
+	if m != nil {
+		return m.Slot
+	}
+	return 0
+}
+
+func (m *SlotRequest) GetSeed() uint32 {
+	if m != nil {
+		return m.Seed
+	}
+	return 0
+}
+
+func (m *SlotRequest) GetVersionOnly() bool {
+	if m != nil {
+		return m.VersionOnly
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterType((*DocId)(nil), "raybox.service.DocId")
+	proto.RegisterType((*AffectedCount)(nil), "raybox.service.AffectedCount")
+	proto.RegisterType((*SearchRequest)(nil), "raybox.service.SearchRequest")
+	proto.RegisterType((*SearchResponse)(nil), "raybox.service.SearchResponse")
+	proto.RegisterType((*CountRequest)(nil), "raybox.service.CountRequest")
+	proto.RegisterType((*DocError)(nil), "raybox.service.DocError")
+	proto.RegisterType((*BulkAddResponse)(nil), "raybox.service.BulkAddResponse")
+	proto.RegisterType((*BackupRequest)(nil), "raybox.service.BackupRequest")
+	proto.RegisterType((*SnapshotChunk)(nil), "raybox.service.SnapshotChunk")
+	proto.RegisterType((*RestoreResponse)(nil), "raybox.service.RestoreResponse")
+	proto.RegisterType((*MaintainRequest)(nil), "raybox.service.MaintainRequest")
+	proto.RegisterType((*MaintainResponse)(nil), "raybox.service.MaintainResponse")
+	proto.RegisterType((*StatsRequest)(nil), "raybox.service.StatsRequest")
+	proto.RegisterType((*StatsResponse)(nil), "raybox.service.StatsResponse")
+	proto.RegisterMapType((map[string]int64)(nil), "raybox.service.StatsResponse.FieldTermsEntry")
+	proto.RegisterType((*ReplicationOp)(nil), "raybox.service.ReplicationOp")
+	proto.RegisterType((*FetchOpsRequest)(nil), "raybox.service.FetchOpsRequest")
+	proto.RegisterType((*RecoverRequest)(nil), "raybox.service.RecoverRequest")
+	proto.RegisterType((*RecoveryChunk)(nil), "raybox.service.RecoveryChunk")
+	proto.RegisterType((*SlotRequest)(nil), "raybox.service.SlotRequest")
+}
+
+func init() { proto.RegisterFile("index.proto", fileDescriptor_f750e0f7889345b5) }
+
+var fileDescriptor_f750e0f7889345b5 = []byte{
+	// 1293 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x57, 0x4b, 0x6f, 0x23, 0x45,
+	0x10, 0xde, 0xb1, 0x63, 0xc7, 0x2e, 0xdb, 0x71, 0xb6, 0xf7, 0xa1, 0x91, 0xd9, 0x35, 0xd6, 0x70,
+	0xc0, 0x48, 0xac, 0x63, 0x65, 0x85, 0x44, 0x40, 0x3c, 0x9c, 0xd8, 0x59, 0x02, 0x1b, 0x39, 0xb4,
+	0x79, 0x08, 0x84, 0x84, 0x3a, 0x33, 0x9d, 0x78, 0x94, 0xf1, 0xf4, 0x6c, 0x4f, 0x3b, 0x1b, 0xf3,
+	0x27, 0x40, 0xe2, 0xca, 0x91, 0x7f, 0xc2, 0x85, 0xe3, 0x1e, 0x39, 0xa2, 0xdd, 0x3f, 0x82, 0xfa,
+	0x31, 0xf6, 0x8c, 0x1d, 0x27, 0x07, 0x6e, 0xf5, 0xea, 0xea, 0xaa, 0xea, 0xaf, 0xaa, 0x66, 0xa0,
+	0xe2, 0x87, 0x1e, 0xbd, 0xea, 0x44, 0x9c, 0x09, 0x86, 0xb6, 0x38, 0x99, 0x9d, 0xb2, 0xab, 0x4e,
+	0x4c, 0xf9, 0xa5, 0xef, 0xd2, 0x46, 0x35, 0x3a, 0xdd, 0xf1, 0x98, 0xab, 0xb5, 0x8d, 0x7b, 0xd1,
+	0xe9, 0x8e, 0xa0, 0x7c, 0xf2, 0xf3, 0x8b, 0x29, 0xe5, 0x33, 0x2d, 0x74, 0x9e, 0x41, 0xa1, 0xcf,
+	0xdc, 0x23, 0x0f, 0xdd, 0x37, 0x84, 0x6d, 0xb5, 0xac, 0x76, 0x19, 0x1b, 0x69, 0x1b, 0xea, 0x83,
+	0xab, 0x88, 0xba, 0x82, 0x7a, 0xdf, 0x51, 0x1e, 0xfb, 0x2c, 0xb4, 0x73, 0x2d, 0xab, 0xbd, 0x81,
+	0x97, 0xc5, 0xce, 0x67, 0x50, 0xeb, 0x9d, 0x9d, 0x29, 0xd1, 0x01, 0x9b, 0x86, 0x42, 0x3a, 0x54,
+	0x84, 0x72, 0x58, 0xc3, 0x9a, 0x41, 0x36, 0x6c, 0x66, 0x1d, 0x25, 0xac, 0xf3, 0xab, 0x05, 0xb5,
+	0x11, 0x25, 0xdc, 0x1d, 0x63, 0xfa, 0x62, 0x4a, 0x63, 0x81, 0x76, 0xa1, 0xf0, 0xb5, 0x0c, 0x55,
+	0x79, 0xa8, 0xec, 0x3e, 0xea, 0x98, 0xf4, 0x52, 0x49, 0x7c, 0x43, 0xf9, 0x44, 0xd9, 0x60, 0x6d,
+	0x8a, 0x1e, 0x42, 0x71, 0x18, 0x1e, 0x06, 0xe4, 0xdc, 0xb8, 0x37, 0x9c, 0xbc, 0x77, 0x78, 0x76,
+	0xa6, 0x14, 0x79, 0x7d, 0xaf, 0x61, 0x95, 0x86, 0x4b, 0x2a, 0xb6, 0x37, 0x5a, 0x79, 0xa5, 0xd1,
+	0xac, 0x33, 0x80, 0xad, 0x24, 0xa0, 0x38, 0x62, 0x61, 0x4c, 0xd1, 0x53, 0x28, 0xf7, 0x99, 0x3b,
+	0x9d, 0xd0, 0x50, 0xc4, 0xb6, 0xd5, 0xca, 0xb7, 0x2b, 0xbb, 0x0f, 0x92, 0xa8, 0x3c, 0x22, 0x48,
+	0x27, 0xd1, 0xe2, 0x85, 0x9d, 0xf3, 0x13, 0x54, 0x55, 0xee, 0x49, 0x5a, 0xf7, 0xa1, 0x30, 0x0a,
+	0x98, 0x88, 0x93, 0xc2, 0x28, 0x06, 0x21, 0xd8, 0x18, 0x51, 0xea, 0xa9, 0xb0, 0x6b, 0x58, 0xd1,
+	0xc8, 0x81, 0xea, 0xe0, 0xca, 0x0d, 0xa6, 0x1e, 0xd5, 0x07, 0xf2, 0xad, 0x7c, 0xbb, 0x86, 0x33,
+	0x32, 0xe7, 0x39, 0x94, 0xfa, 0xcc, 0x1d, 0x70, 0xce, 0xf8, 0x9a, 0x37, 0x44, 0xb0, 0x71, 0xc0,
+	0x3c, 0x9a, 0x78, 0x96, 0xb4, 0x2c, 0x13, 0xa6, 0x24, 0x66, 0xa1, 0xaa, 0x46, 0x19, 0x1b, 0xce,
+	0xf9, 0x01, 0xea, 0xfb, 0xd3, 0xe0, 0xa2, 0xe7, 0x79, 0xf3, 0x9c, 0xaf, 0x7f, 0xc7, 0x2e, 0x14,
+	0xd5, 0x9d, 0xb1, 0x9d, 0x53, 0x65, 0xb0, 0x3b, 0x59, 0xec, 0x75, 0x92, 0xa0, 0xb0, 0xb1, 0x73,
+	0x28, 0xd4, 0xf6, 0x89, 0x7b, 0x31, 0x8d, 0x92, 0x3a, 0x38, 0x50, 0x1d, 0xf9, 0xa1, 0x4b, 0x13,
+	0x3c, 0x58, 0xea, 0x5d, 0x32, 0x32, 0xd4, 0x85, 0x7b, 0x47, 0xa1, 0xca, 0x16, 0xd3, 0x4b, 0xca,
+	0x63, 0x7a, 0x24, 0xe1, 0xae, 0x52, 0x29, 0xe1, 0xeb, 0x54, 0xce, 0x3b, 0x50, 0x1b, 0x85, 0x24,
+	0x8a, 0xc7, 0x4c, 0x1c, 0x8c, 0xa7, 0xe1, 0x85, 0x4c, 0xbf, 0x4f, 0x04, 0x51, 0xee, 0xab, 0x58,
+	0xd1, 0x4e, 0x0f, 0xea, 0x98, 0xc6, 0x82, 0x71, 0x7a, 0x4b, 0x9a, 0xeb, 0xe1, 0x7a, 0x17, 0xea,
+	0xc7, 0xc4, 0x0f, 0x05, 0xf1, 0x43, 0x93, 0x90, 0xf3, 0x87, 0x05, 0xdb, 0x0b, 0x99, 0xf1, 0xfb,
+	0x10, 0x8a, 0x83, 0xf0, 0xdc, 0x0f, 0xa9, 0x72, 0x5c, 0xc0, 0x86, 0x93, 0x61, 0x9d, 0x10, 0x31,
+	0x56, 0x6e, 0xcb, 0x58, 0xd1, 0xa8, 0x09, 0x30, 0xf2, 0x7f, 0xa1, 0xfb, 0xf4, 0x8c, 0x71, 0xaa,
+	0x5e, 0x26, 0x8f, 0x53, 0x12, 0xf4, 0x08, 0xca, 0x92, 0xeb, 0x9d, 0x09, 0xca, 0xed, 0x0d, 0xa5,
+	0x5e, 0x08, 0xe4, 0xe9, 0xfe, 0x94, 0x13, 0xe1, 0xb3, 0xf0, 0x38, 0xb6, 0x0b, 0xfa, 0xf4, 0x42,
+	0xe2, 0x6c, 0x41, 0x75, 0x24, 0x88, 0x88, 0x93, 0x70, 0x7f, 0x2f, 0x40, 0xcd, 0x08, 0x4c, 0xac,
+	0x0d, 0x85, 0xa5, 0x45, 0x19, 0xf2, 0x78, 0xce, 0xcb, 0xd7, 0xea, 0xd3, 0x80, 0x26, 0xed, 0xad,
+	0xe2, 0xce, 0xe3, 0x8c, 0x0c, 0x1d, 0x03, 0x1c, 0xfa, 0x34, 0xf0, 0x64, 0x57, 0x6a, 0xb4, 0x56,
+	0x76, 0x9f, 0x2c, 0x03, 0x23, 0x73, 0x65, 0x67, 0x61, 0x3f, 0x08, 0x05, 0x9f, 0xe1, 0x94, 0x03,
+	0x79, 0xe5, 0x09, 0x8b, 0x85, 0x1f, 0x9e, 0x3f, 0xf7, 0x63, 0x11, 0x9b, 0x8c, 0x33, 0x32, 0x19,
+	0xb2, 0xe1, 0x93, 0x94, 0xe7, 0xbc, 0x2c, 0x88, 0xa1, 0x4f, 0x3e, 0xe8, 0xda, 0x45, 0x5d, 0x90,
+	0x85, 0x24, 0xad, 0xdf, 0xeb, 0xda, 0x9b, 0x59, 0xfd, 0x5e, 0x56, 0xbf, 0x67, 0x97, 0x96, 0xf4,
+	0x7b, 0x29, 0xfd, 0x31, 0xb9, 0xb2, 0xcb, 0x19, 0xfd, 0x31, 0xb9, 0x42, 0xef, 0xc3, 0xdd, 0x43,
+	0xc6, 0x5f, 0x12, 0xee, 0x29, 0x68, 0xee, 0xcf, 0x04, 0x8d, 0x6d, 0x50, 0x66, 0xab, 0x0a, 0x69,
+	0x9d, 0x06, 0xb2, 0xb6, 0xae, 0x68, 0xeb, 0x15, 0x05, 0x6a, 0x41, 0xe5, 0x80, 0x4d, 0x22, 0x4e,
+	0x63, 0x05, 0xce, 0xaa, 0x42, 0x51, 0x5a, 0x24, 0x2b, 0x83, 0xc9, 0x4b, 0xed, 0xa6, 0xa6, 0x2b,
+	0x93, 0xf0, 0xf2, 0xf4, 0x48, 0xa2, 0xdf, 0xd3, 0xea, 0x2d, 0xa5, 0x4e, 0x8b, 0x24, 0xf0, 0x4f,
+	0xb8, 0x3f, 0x21, 0x7c, 0x66, 0xd7, 0x55, 0xb3, 0x25, 0xac, 0xcc, 0xba, 0x17, 0x45, 0x81, 0x4f,
+	0xbd, 0x11, 0x7d, 0x61, 0x6f, 0xab, 0xae, 0x48, 0x49, 0x1a, 0x9f, 0x40, 0x7d, 0xe9, 0x51, 0xd1,
+	0x36, 0xe4, 0x2f, 0xe8, 0xcc, 0x4c, 0x25, 0x49, 0xca, 0x6e, 0xbb, 0x24, 0xc1, 0x94, 0x1a, 0x18,
+	0x69, 0xe6, 0xa3, 0xdc, 0x87, 0x96, 0x23, 0xa0, 0x86, 0x69, 0x14, 0xf8, 0xae, 0x82, 0xed, 0x30,
+	0x92, 0x87, 0xe5, 0x45, 0x7a, 0x3a, 0x48, 0x72, 0x31, 0xe6, 0x72, 0xe9, 0x31, 0xf7, 0x2e, 0xe4,
+	0xfb, 0xcc, 0x55, 0x5d, 0xb3, 0x76, 0x2a, 0x4b, 0x0b, 0xd9, 0x79, 0x32, 0x36, 0x05, 0xa7, 0x0d,
+	0xac, 0x68, 0xe7, 0x63, 0xa8, 0x1f, 0x52, 0xe1, 0x8e, 0x87, 0x51, 0x9c, 0x1e, 0xd3, 0x72, 0x14,
+	0x99, 0x9b, 0x35, 0x33, 0x3f, 0x9c, 0x4b, 0x1d, 0xde, 0x86, 0x2d, 0x4c, 0x5d, 0x76, 0x49, 0x79,
+	0xd2, 0x5a, 0x7f, 0x5a, 0x50, 0x33, 0xa2, 0x99, 0x9e, 0x42, 0x0d, 0x28, 0x25, 0x63, 0xc9, 0x4c,
+	0xa2, 0x39, 0xaf, 0x06, 0xa1, 0xa1, 0xfb, 0x2c, 0xa4, 0x66, 0xba, 0x65, 0x64, 0x49, 0x15, 0xf2,
+	0x8b, 0x2a, 0x3c, 0x81, 0xdc, 0x30, 0x52, 0x49, 0x54, 0x76, 0x1f, 0x2f, 0x37, 0x59, 0xa6, 0x84,
+	0x38, 0x37, 0x8c, 0xe6, 0x81, 0x17, 0x52, 0x81, 0x4f, 0xa0, 0x22, 0x97, 0xc8, 0xed, 0x8b, 0x29,
+	0x60, 0x62, 0xbe, 0x98, 0x02, 0x26, 0xe6, 0xcb, 0x2a, 0x9f, 0x5a, 0x56, 0x2d, 0xa8, 0x98, 0xd9,
+	0x38, 0x0c, 0x83, 0x99, 0x0a, 0xac, 0x84, 0xd3, 0xa2, 0xdd, 0xbf, 0x4a, 0x50, 0x55, 0x10, 0x1e,
+	0xe9, 0x28, 0x51, 0x0f, 0xca, 0x7a, 0x7e, 0xc8, 0x67, 0x79, 0x70, 0xcd, 0x06, 0x39, 0xf2, 0x1a,
+	0x2b, 0xa9, 0x65, 0xbf, 0x32, 0x3e, 0x85, 0x62, 0xcf, 0xf3, 0x32, 0xe7, 0x33, 0x4f, 0x7e, 0xdb,
+	0xf9, 0x1e, 0x94, 0xbf, 0x8d, 0x3c, 0x22, 0xe8, 0xb5, 0x2e, 0x4e, 0x88, 0x70, 0xc7, 0xb7, 0xb9,
+	0x38, 0x04, 0x30, 0x3b, 0xf3, 0x86, 0x30, 0xde, 0x5e, 0xf6, 0xb1, 0xb4, 0x66, 0xdb, 0x16, 0x7a,
+	0x06, 0x45, 0xfd, 0xb9, 0x81, 0x56, 0x2e, 0xcc, 0x7c, 0x17, 0x35, 0x9a, 0xeb, 0xd4, 0x66, 0x8c,
+	0xf7, 0xcd, 0x2a, 0x43, 0x8f, 0x96, 0x0d, 0xd3, 0xdf, 0x21, 0xb7, 0xa5, 0xf5, 0x05, 0x14, 0xf5,
+	0xbe, 0x5e, 0x0d, 0x27, 0xb3, 0xc7, 0x57, 0xfd, 0x64, 0xf6, 0x6f, 0xd7, 0x42, 0x5f, 0xc1, 0xa6,
+	0xd9, 0xb6, 0xe8, 0x66, 0xdb, 0xd5, 0x2a, 0x2d, 0x6d, 0xe9, 0xb6, 0x85, 0x8e, 0xa1, 0x94, 0xec,
+	0x58, 0xb4, 0x62, 0xbe, 0xb4, 0x91, 0x1b, 0xad, 0xf5, 0x06, 0x8b, 0x5a, 0xa9, 0x85, 0xb4, 0x5a,
+	0xab, 0xf4, 0xae, 0x6c, 0x3c, 0x5e, 0xa3, 0x35, 0x5e, 0x9e, 0x43, 0x29, 0x19, 0x1f, 0xab, 0x41,
+	0x2d, 0x0d, 0x96, 0xc6, 0xcd, 0xcd, 0xda, 0xb5, 0xd0, 0x97, 0xb0, 0x69, 0x86, 0x07, 0x6a, 0xae,
+	0xda, 0xa6, 0x07, 0x4d, 0xe3, 0xf1, 0x1a, 0xfd, 0x2c, 0xa9, 0xfd, 0xe7, 0x50, 0x1a, 0xb9, 0x24,
+	0x54, 0x5d, 0xfb, 0xd6, 0x4a, 0x12, 0x8b, 0xe6, 0x6f, 0x5c, 0x8f, 0xdb, 0xae, 0x25, 0xe1, 0x7d,
+	0x34, 0x89, 0x18, 0x17, 0x7d, 0xe6, 0xc6, 0xff, 0x03, 0xde, 0x87, 0x50, 0xea, 0x73, 0x16, 0xdd,
+	0x1e, 0xc9, 0xcd, 0xb8, 0xdc, 0x3f, 0xf8, 0xfb, 0x75, 0xd3, 0x7a, 0xf5, 0xba, 0x69, 0xfd, 0xfb,
+	0xba, 0x69, 0xfd, 0xf6, 0xa6, 0x79, 0xe7, 0xd5, 0x9b, 0xe6, 0x9d, 0x7f, 0xde, 0x34, 0xef, 0xfc,
+	0xf8, 0xde, 0xb9, 0x2f, 0xc6, 0xd3, 0xd3, 0x8e, 0xcb, 0x26, 0x3b, 0xdf, 0x07, 0x64, 0x86, 0xc9,
+	0x6c, 0x67, 0x10, 0x50, 0x57, 0x70, 0xdf, 0xd5, 0x2d, 0xb2, 0x63, 0x5c, 0x9e, 0x16, 0xd5, 0xdf,
+	0xcf, 0xd3, 0xff, 0x06, 0x00, 0xb9, 0xbc, 0xfc, 0xc0, 0x3f, 0x0d, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// IndexServiceClient is the client API for IndexService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type IndexServiceClient interface {
+	DeleteDoc(ctx context.Context, in *DocId, opts ...grpc.CallOption) (*AffectedCount, error)
+	AddDoc(ctx context.Context, in *types.Document, opts ...grpc.CallOption) (*AffectedCount, error)
+	UpdateDoc(ctx context.Context, in *types.DocPatch, opts ...grpc.CallOption) (*AffectedCount, error)
+	BulkAddDoc(ctx context.Context, opts ...grpc.CallOption) (IndexService_BulkAddDocClient, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*AffectedCount, error)
+	Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (IndexService_BackupClient, error)
+	Restore(ctx context.Context, opts ...grpc.CallOption) (IndexService_RestoreClient, error)
+	Maintain(ctx context.Context, in *MaintainRequest, opts ...grpc.CallOption) (*MaintainResponse, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	FetchOps(ctx context.Context, in *FetchOpsRequest, opts ...grpc.CallOption) (IndexService_FetchOpsClient, error)
+	Recover(ctx context.Context, in *RecoverRequest, opts ...grpc.CallOption) (IndexService_RecoverClient, error)
+	ScanSlot(ctx context.Context, in *SlotRequest, opts ...grpc.CallOption) (IndexService_ScanSlotClient, error)
+	ImportDocs(ctx context.Context, opts ...grpc.CallOption) (IndexService_ImportDocsClient, error)
+	DropSlot(ctx context.Context, in *SlotRequest, opts ...grpc.CallOption) (*AffectedCount, error)
+}
+
+type indexServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewIndexServiceClient(cc *grpc.ClientConn) IndexServiceClient {
+	return &indexServiceClient{cc}
+}
+
+func (c *indexServiceClient) DeleteDoc(ctx context.Context, in *DocId, opts ...grpc.CallOption) (*AffectedCount, error) {
+	out := new(AffectedCount)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/DeleteDoc", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexServiceClient) AddDoc(ctx context.Context, in *types.Document, opts ...grpc.CallOption) (*AffectedCount, error) {
+	out := new(AffectedCount)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/AddDoc", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexServiceClient) UpdateDoc(ctx context.Context, in *types.DocPatch, opts ...grpc.CallOption) (*AffectedCount, error) {
+	out := new(AffectedCount)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/UpdateDoc", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexServiceClient) BulkAddDoc(ctx context.Context, opts ...grpc.CallOption) (IndexService_BulkAddDocClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_IndexService_serviceDesc.Streams[0], "/raybox.service.IndexService/BulkAddDoc", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexServiceBulkAddDocClient{stream}
+	return x, nil
+}
+
+type IndexService_BulkAddDocClient interface {
+	Send(*types.Document) error
+	CloseAndRecv() (*BulkAddResponse, error)
+	grpc.ClientStream
+}
+
+type indexServiceBulkAddDocClient struct {
+	grpc.ClientStream
+}
+
+func (x *indexServiceBulkAddDocClient) Send(m *types.Document) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *indexServiceBulkAddDocClient) CloseAndRecv() (*BulkAddResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(BulkAddResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *indexServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexServiceClient) Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*AffectedCount, error) {
+	out := new(AffectedCount)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/Count", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexServiceClient) Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (IndexService_BackupClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_IndexService_serviceDesc.Streams[1], "/raybox.service.IndexService/Backup", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexServiceBackupClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type IndexService_BackupClient interface {
+	Recv() (*SnapshotChunk, error)
+	grpc.ClientStream
+}
+
+type indexServiceBackupClient struct {
+	grpc.ClientStream
+}
+
+func (x *indexServiceBackupClient) Recv() (*SnapshotChunk, error) {
+	m := new(SnapshotChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *indexServiceClient) Restore(ctx context.Context, opts ...grpc.CallOption) (IndexService_RestoreClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_IndexService_serviceDesc.Streams[2], "/raybox.service.IndexService/Restore", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexServiceRestoreClient{stream}
+	return x, nil
+}
+
+type IndexService_RestoreClient interface {
+	Send(*SnapshotChunk) error
+	CloseAndRecv() (*RestoreResponse, error)
+	grpc.ClientStream
+}
+
+type indexServiceRestoreClient struct {
+	grpc.ClientStream
+}
+
+func (x *indexServiceRestoreClient) Send(m *SnapshotChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *indexServiceRestoreClient) CloseAndRecv() (*RestoreResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(RestoreResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *indexServiceClient) Maintain(ctx context.Context, in *MaintainRequest, opts ...grpc.CallOption) (*MaintainResponse, error) {
+	out := new(MaintainResponse)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/Maintain", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexServiceClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexServiceClient) FetchOps(ctx context.Context, in *FetchOpsRequest, opts ...grpc.CallOption) (IndexService_FetchOpsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_IndexService_serviceDesc.Streams[3], "/raybox.service.IndexService/FetchOps", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexServiceFetchOpsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type IndexService_FetchOpsClient interface {
+	Recv() (*ReplicationOp, error)
+	grpc.ClientStream
+}
+
+type indexServiceFetchOpsClient struct {
+	grpc.ClientStream
+}
+
+func (x *indexServiceFetchOpsClient) Recv() (*ReplicationOp, error) {
+	m := new(ReplicationOp)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *indexServiceClient) Recover(ctx context.Context, in *RecoverRequest, opts ...grpc.CallOption) (IndexService_RecoverClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_IndexService_serviceDesc.Streams[4], "/raybox.service.IndexService/Recover", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexServiceRecoverClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type IndexService_RecoverClient interface {
+	Recv() (*RecoveryChunk, error)
+	grpc.ClientStream
+}
+
+type indexServiceRecoverClient struct {
+	grpc.ClientStream
+}
+
+func (x *indexServiceRecoverClient) Recv() (*RecoveryChunk, error) {
+	m := new(RecoveryChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *indexServiceClient) ScanSlot(ctx context.Context, in *SlotRequest, opts ...grpc.CallOption) (IndexService_ScanSlotClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_IndexService_serviceDesc.Streams[5], "/raybox.service.IndexService/ScanSlot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexServiceScanSlotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type IndexService_ScanSlotClient interface {
+	Recv() (*types.Document, error)
+	grpc.ClientStream
+}
+
+type indexServiceScanSlotClient struct {
+	grpc.ClientStream
+}
+
+func (x *indexServiceScanSlotClient) Recv() (*types.Document, error) {
+	m := new(types.Document)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *indexServiceClient) ImportDocs(ctx context.Context, opts ...grpc.CallOption) (IndexService_ImportDocsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_IndexService_serviceDesc.Streams[6], "/raybox.service.IndexService/ImportDocs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &indexServiceImportDocsClient{stream}
+	return x, nil
+}
+
+type IndexService_ImportDocsClient interface {
+	Send(*types.Document) error
+	CloseAndRecv() (*BulkAddResponse, error)
+	grpc.ClientStream
+}
+
+type indexServiceImportDocsClient struct {
+	grpc.ClientStream
+}
+
+func (x *indexServiceImportDocsClient) Send(m *types.Document) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *indexServiceImportDocsClient) CloseAndRecv() (*BulkAddResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(BulkAddResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *indexServiceClient) DropSlot(ctx context.Context, in *SlotRequest, opts ...grpc.CallOption) (*AffectedCount, error) {
+	out := new(AffectedCount)
+	err := c.cc.Invoke(ctx, "/raybox.service.IndexService/DropSlot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IndexServiceServer is the server API for IndexService service.
+type IndexServiceServer interface {
+	DeleteDoc(context.Context, *DocId) (*AffectedCount, error)
+	AddDoc(context.Context, *types.Document) (*AffectedCount, error)
+	UpdateDoc(context.Context, *types.DocPatch) (*AffectedCount, error)
+	BulkAddDoc(IndexService_BulkAddDocServer) error
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	Count(context.Context, *CountRequest) (*AffectedCount, error)
+	Backup(*BackupRequest, IndexService_BackupServer) error
+	Restore(IndexService_RestoreServer) error
+	Maintain(context.Context, *MaintainRequest) (*MaintainResponse, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	FetchOps(*FetchOpsRequest, IndexService_FetchOpsServer) error
+	Recover(*RecoverRequest, IndexService_RecoverServer) error
+	ScanSlot(*SlotRequest, IndexService_ScanSlotServer) error
+	ImportDocs(IndexService_ImportDocsServer) error
+	DropSlot(context.Context, *SlotRequest) (*AffectedCount, error)
+}
+
+// UnimplementedIndexServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedIndexServiceServer struct {
+}
+
+func (*UnimplementedIndexServiceServer) DeleteDoc(ctx context.Context, req *DocId) (*AffectedCount, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDoc not implemented")
+}
+func (*UnimplementedIndexServiceServer) AddDoc(ctx context.Context, req *types.Document) (*AffectedCount, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddDoc not implemented")
+}
+func (*UnimplementedIndexServiceServer) UpdateDoc(ctx context.Context, req *types.DocPatch) (*AffectedCount, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateDoc not implemented")
+}
+func (*UnimplementedIndexServiceServer) BulkAddDoc(srv IndexService_BulkAddDocServer) error {
+	return status.Errorf(codes.Unimplemented, "method BulkAddDoc not implemented")
+}
+func (*UnimplementedIndexServiceServer) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (*UnimplementedIndexServiceServer) Count(ctx context.Context, req *CountRequest) (*AffectedCount, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Count not implemented")
+}
+func (*UnimplementedIndexServiceServer) Backup(req *BackupRequest, srv IndexService_BackupServer) error {
+	return status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (*UnimplementedIndexServiceServer) Restore(srv IndexService_RestoreServer) error {
+	return status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (*UnimplementedIndexServiceServer) Maintain(ctx context.Context, req *MaintainRequest) (*MaintainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Maintain not implemented")
+}
+func (*UnimplementedIndexServiceServer) Stats(ctx context.Context, req *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (*UnimplementedIndexServiceServer) FetchOps(req *FetchOpsRequest, srv IndexService_FetchOpsServer) error {
+	return status.Errorf(codes.Unimplemented, "method FetchOps not implemented")
+}
+func (*UnimplementedIndexServiceServer) Recover(req *RecoverRequest, srv IndexService_RecoverServer) error {
+	return status.Errorf(codes.Unimplemented, "method Recover not implemented")
+}
+func (*UnimplementedIndexServiceServer) ScanSlot(req *SlotRequest, srv IndexService_ScanSlotServer) error {
+	return status.Errorf(codes.Unimplemented, "method ScanSlot not implemented")
+}
+func (*UnimplementedIndexServiceServer) ImportDocs(srv IndexService_ImportDocsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportDocs not implemented")
+}
+func (*UnimplementedIndexServiceServer) DropSlot(ctx context.Context, req *SlotRequest) (*AffectedCount, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DropSlot not implemented")
+}
+
+func RegisterIndexServiceServer(s *grpc.Server, srv IndexServiceServer) {
+	s.RegisterService(&_IndexService_serviceDesc, srv)
+}
+
+func _IndexService_DeleteDoc_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DocId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).DeleteDoc(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/DeleteDoc",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).DeleteDoc(ctx, req.(*DocId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexService_AddDoc_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(types.Document)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).AddDoc(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/AddDoc",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).AddDoc(ctx, req.(*types.Document))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexService_UpdateDoc_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(types.DocPatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).UpdateDoc(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/UpdateDoc",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).UpdateDoc(ctx, req.(*types.DocPatch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexService_BulkAddDoc_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IndexServiceServer).BulkAddDoc(&indexServiceBulkAddDocServer{stream})
+}
+
+type IndexService_BulkAddDocServer interface {
+	SendAndClose(*BulkAddResponse) error
+	Recv() (*types.Document, error)
+	grpc.ServerStream
+}
+
+type indexServiceBulkAddDocServer struct {
+	grpc.ServerStream
+}
+
+func (x *indexServiceBulkAddDocServer) SendAndClose(m *BulkAddResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *indexServiceBulkAddDocServer) Recv() (*types.Document, error) {
+	m := new(types.Document)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _IndexService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexService_Count_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).Count(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/Count",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).Count(ctx, req.(*CountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexService_Backup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BackupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IndexServiceServer).Backup(m, &indexServiceBackupServer{stream})
+}
+
+type IndexService_BackupServer interface {
+	Send(*SnapshotChunk) error
+	grpc.ServerStream
+}
+
+type indexServiceBackupServer struct {
+	grpc.ServerStream
+}
+
+func (x *indexServiceBackupServer) Send(m *SnapshotChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _IndexService_Restore_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IndexServiceServer).Restore(&indexServiceRestoreServer{stream})
+}
+
+type IndexService_RestoreServer interface {
+	SendAndClose(*RestoreResponse) error
+	Recv() (*SnapshotChunk, error)
+	grpc.ServerStream
+}
+
+type indexServiceRestoreServer struct {
+	grpc.ServerStream
+}
+
+func (x *indexServiceRestoreServer) SendAndClose(m *RestoreResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *indexServiceRestoreServer) Recv() (*SnapshotChunk, error) {
+	m := new(SnapshotChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _IndexService_Maintain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MaintainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).Maintain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/Maintain",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).Maintain(ctx, req.(*MaintainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexService_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexService_FetchOps_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FetchOpsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IndexServiceServer).FetchOps(m, &indexServiceFetchOpsServer{stream})
+}
+
+type IndexService_FetchOpsServer interface {
+	Send(*ReplicationOp) error
+	grpc.ServerStream
+}
+
+type indexServiceFetchOpsServer struct {
+	grpc.ServerStream
+}
+
+func (x *indexServiceFetchOpsServer) Send(m *ReplicationOp) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _IndexService_Recover_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RecoverRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IndexServiceServer).Recover(m, &indexServiceRecoverServer{stream})
+}
+
+type IndexService_RecoverServer interface {
+	Send(*RecoveryChunk) error
+	grpc.ServerStream
+}
+
+type indexServiceRecoverServer struct {
+	grpc.ServerStream
+}
+
+func (x *indexServiceRecoverServer) Send(m *RecoveryChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _IndexService_ScanSlot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SlotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IndexServiceServer).ScanSlot(m, &indexServiceScanSlotServer{stream})
+}
+
+type IndexService_ScanSlotServer interface {
+	Send(*types.Document) error
+	grpc.ServerStream
+}
+
+type indexServiceScanSlotServer struct {
+	grpc.ServerStream
+}
+
+func (x *indexServiceScanSlotServer) Send(m *types.Document) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _IndexService_ImportDocs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IndexServiceServer).ImportDocs(&indexServiceImportDocsServer{stream})
+}
+
+type IndexService_ImportDocsServer interface {
+	SendAndClose(*BulkAddResponse) error
+	Recv() (*types.Document, error)
+	grpc.ServerStream
+}
+
+type indexServiceImportDocsServer struct {
+	grpc.ServerStream
+}
+
+func (x *indexServiceImportDocsServer) SendAndClose(m *BulkAddResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *indexServiceImportDocsServer) Recv() (*types.Document, error) {
+	m := new(types.Document)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _IndexService_DropSlot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SlotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexServiceServer).DropSlot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/raybox.service.IndexService/DropSlot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexServiceServer).DropSlot(ctx, req.(*SlotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _IndexService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "raybox.service.IndexService",
+	HandlerType: (*IndexServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DeleteDoc",
+			Handler:    _IndexService_DeleteDoc_Handler,
+		},
+		{
+			MethodName: "AddDoc",
+			Handler:    _IndexService_AddDoc_Handler,
+		},
+		{
+			MethodName: "UpdateDoc",
+			Handler:    _IndexService_UpdateDoc_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _IndexService_Search_Handler,
+		},
+		{
+			MethodName: "Count",
+			Handler:    _IndexService_Count_Handler,
+		},
+		{
+			MethodName: "Maintain",
+			Handler:    _IndexService_Maintain_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _IndexService_Stats_Handler,
+		},
+		{
+			MethodName: "DropSlot",
+			Handler:    _IndexService_DropSlot_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BulkAddDoc",
+			Handler:       _IndexService_BulkAddDoc_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Backup",
+			Handler:       _IndexService_Backup_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Restore",
+			Handler:       _IndexService_Restore_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "FetchOps",
+			Handler:       _IndexService_FetchOps_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Recover",
+			Handler:       _IndexService_Recover_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ScanSlot",
+			Handler:       _IndexService_ScanSlot_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportDocs",
+			Handler:       _IndexService_ImportDocs_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "index.proto",
+}
+
+func (m *DocId) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DocId) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DocId) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.ExpectedVersion != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.ExpectedVersion))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.DocId) > 0 {
+		i -= len(m.DocId)
+		copy(dAtA[i:], m.DocId)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.DocId)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *AffectedCount) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *AffectedCount) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *AffectedCount) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Version != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Version))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Count != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Count))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SearchRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.OrFlags) > 0 {
+		dAtA2 := make([]byte, len(m.OrFlags)*10)
+		var j1 int
+		for _, num := range m.OrFlags {
+			for num >= 1<<7 {
+				dAtA2[j1] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j1++
+			}
+			dAtA2[j1] = uint8(num)
+			j1++
+		}
+		i -= j1
+		copy(dAtA[i:], dAtA2[:j1])
+		i = encodeVarintIndex(dAtA, i, uint64(j1))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.OffFlag != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.OffFlag))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.OnFlag != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.OnFlag))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Query != nil {
+		{
+			size, err := m.Query.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintIndex(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SearchResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Documents) > 0 {
+		for iNdEx := len(m.Documents) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Documents[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintIndex(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CountRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CountRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CountRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.ExcludeSlots) > 0 {
+		dAtA5 := make([]byte, len(m.ExcludeSlots)*10)
+		var j4 int
+		for _, num := range m.ExcludeSlots {
+			for num >= 1<<7 {
+				dAtA5[j4] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j4++
+			}
+			dAtA5[j4] = uint8(num)
+			j4++
+		}
+		i -= j4
+		copy(dAtA[i:], dAtA5[:j4])
+		i = encodeVarintIndex(dAtA, i, uint64(j4))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Seed != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Seed))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Slots != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Slots))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DocError) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DocError) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DocError) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Reason) > 0 {
+		i -= len(m.Reason)
+		copy(dAtA[i:], m.Reason)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.Reason)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Code != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Code))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.DocId) > 0 {
+		i -= len(m.DocId)
+		copy(dAtA[i:], m.DocId)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.DocId)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *BulkAddResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *BulkAddResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *BulkAddResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Errors) > 0 {
+		for iNdEx := len(m.Errors) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Errors[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintIndex(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if m.Count != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Count))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *BackupRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *BackupRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *BackupRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.IncludeReverseIndex {
+		i--
+		if m.IncludeReverseIndex {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.SinceVersion != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.SinceVersion))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SnapshotChunk) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SnapshotChunk) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SnapshotChunk) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Data) > 0 {
+		i -= len(m.Data)
+		copy(dAtA[i:], m.Data)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.Data)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *RestoreResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RestoreResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RestoreResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Version != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Version))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Count != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Count))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MaintainRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MaintainRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MaintainRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *MaintainResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MaintainResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MaintainResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.DurationMs != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.DurationMs))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.SizeAfter != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.SizeAfter))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.SizeBefore != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.SizeBefore))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.Path) > 0 {
+		i -= len(m.Path)
+		copy(dAtA[i:], m.Path)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.Path)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Engine != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Engine))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StatsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StatsRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StatsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *StatsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StatsResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StatsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.AppliedSeq != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.AppliedSeq))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x80
+	}
+	if m.Primary {
+		i--
+		if m.Primary {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x78
+	}
+	if m.StoredBytes != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.StoredBytes))
+		i--
+		dAtA[i] = 0x70
+	}
+	if m.RawBytes != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.RawBytes))
+		i--
+		dAtA[i] = 0x68
+	}
+	if len(m.Compression) > 0 {
+		i -= len(m.Compression)
+		copy(dAtA[i:], m.Compression)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.Compression)))
+		i--
+		dAtA[i] = 0x62
+	}
+	if m.ReverseIndexBytes != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.ReverseIndexBytes))
+		i--
+		dAtA[i] = 0x58
+	}
+	if m.ForwardIndexBytes != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.ForwardIndexBytes))
+		i--
+		dAtA[i] = 0x50
+	}
+	if m.PostingMax != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.PostingMax))
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.PostingP99 != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.PostingP99))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.PostingP90 != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.PostingP90))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.PostingP50 != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.PostingP50))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.Postings != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Postings))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.PostingLists != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.PostingLists))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.FieldTerms) > 0 {
+		for k := range m.FieldTerms {
+			v := m.FieldTerms[k]
+			baseI := i
+			i = encodeVarintIndex(dAtA, i, uint64(v))
+			i--
+			dAtA[i] = 0x10
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintIndex(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintIndex(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if m.DeletedCount != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.DeletedCount))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.DocCount != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.DocCount))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ReplicationOp) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ReplicationOp) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ReplicationOp) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Term != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Term))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Doc != nil {
+		{
+			size, err := m.Doc.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintIndex(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.DocId) > 0 {
+		i -= len(m.DocId)
+		copy(dAtA[i:], m.DocId)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.DocId)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Seq != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Seq))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *FetchOpsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FetchOpsRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *FetchOpsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Term != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Term))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Since != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Since))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *RecoverRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RecoverRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RecoverRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *RecoveryChunk) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RecoveryChunk) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RecoveryChunk) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Term != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Term))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.Op != nil {
+		{
+			size, err := m.Op.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintIndex(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.Seq != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Seq))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.SnapshotDone {
+		i--
+		if m.SnapshotDone {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Snapshot) > 0 {
+		i -= len(m.Snapshot)
+		copy(dAtA[i:], m.Snapshot)
+		i = encodeVarintIndex(dAtA, i, uint64(len(m.Snapshot)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SlotRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SlotRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SlotRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.VersionOnly {
+		i--
+		if m.VersionOnly {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.Seed != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Seed))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Slot != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Slot))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Slots != 0 {
+		i = encodeVarintIndex(dAtA, i, uint64(m.Slots))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintIndex(dAtA []byte, offset int, v uint64) int {
+	offset -= sovIndex(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *DocId) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.DocId)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.ExpectedVersion != 0 {
+		n += 1 + sovIndex(uint64(m.ExpectedVersion))
+	}
+	return n
+}
+
+func (m *AffectedCount) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Count != 0 {
+		n += 1 + sovIndex(uint64(m.Count))
+	}
+	if m.Version != 0 {
+		n += 1 + sovIndex(uint64(m.Version))
+	}
+	return n
+}
+
+func (m *SearchRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Query != nil {
+		l = m.Query.Size()
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.OnFlag != 0 {
+		n += 1 + sovIndex(uint64(m.OnFlag))
+	}
+	if m.OffFlag != 0 {
+		n += 1 + sovIndex(uint64(m.OffFlag))
+	}
+	if len(m.OrFlags) > 0 {
+		l = 0
+		for _, e := range m.OrFlags {
+			l += sovIndex(uint64(e))
+		}
+		n += 1 + sovIndex(uint64(l)) + l
+	}
+	return n
+}
+
+func (m *SearchResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Documents) > 0 {
+		for _, e := range m.Documents {
+			l = e.Size()
+			n += 1 + l + sovIndex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *CountRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Slots != 0 {
+		n += 1 + sovIndex(uint64(m.Slots))
+	}
+	if m.Seed != 0 {
+		n += 1 + sovIndex(uint64(m.Seed))
+	}
+	if len(m.ExcludeSlots) > 0 {
+		l = 0
+		for _, e := range m.ExcludeSlots {
+			l += sovIndex(uint64(e))
+		}
+		n += 1 + sovIndex(uint64(l)) + l
+	}
+	return n
+}
+
+func (m *DocError) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.DocId)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.Code != 0 {
+		n += 1 + sovIndex(uint64(m.Code))
+	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	return n
+}
+
+func (m *BulkAddResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Count != 0 {
+		n += 1 + sovIndex(uint64(m.Count))
+	}
+	if len(m.Errors) > 0 {
+		for _, e := range m.Errors {
+			l = e.Size()
+			n += 1 + l + sovIndex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *BackupRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.SinceVersion != 0 {
+		n += 1 + sovIndex(uint64(m.SinceVersion))
+	}
+	if m.IncludeReverseIndex {
+		n += 2
+	}
+	return n
+}
+
+func (m *SnapshotChunk) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Data)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	return n
+}
+
+func (m *RestoreResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Count != 0 {
+		n += 1 + sovIndex(uint64(m.Count))
+	}
+	if m.Version != 0 {
+		n += 1 + sovIndex(uint64(m.Version))
+	}
+	return n
+}
+
+func (m *MaintainRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *MaintainResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Engine != 0 {
+		n += 1 + sovIndex(uint64(m.Engine))
+	}
+	l = len(m.Path)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.SizeBefore != 0 {
+		n += 1 + sovIndex(uint64(m.SizeBefore))
+	}
+	if m.SizeAfter != 0 {
+		n += 1 + sovIndex(uint64(m.SizeAfter))
+	}
+	if m.DurationMs != 0 {
+		n += 1 + sovIndex(uint64(m.DurationMs))
+	}
+	return n
+}
+
+func (m *StatsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *StatsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.DocCount != 0 {
+		n += 1 + sovIndex(uint64(m.DocCount))
+	}
+	if m.DeletedCount != 0 {
+		n += 1 + sovIndex(uint64(m.DeletedCount))
+	}
+	if len(m.FieldTerms) > 0 {
+		for k, v := range m.FieldTerms {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovIndex(uint64(len(k))) + 1 + sovIndex(uint64(v))
+			n += mapEntrySize + 1 + sovIndex(uint64(mapEntrySize))
+		}
+	}
+	if m.PostingLists != 0 {
+		n += 1 + sovIndex(uint64(m.PostingLists))
+	}
+	if m.Postings != 0 {
+		n += 1 + sovIndex(uint64(m.Postings))
+	}
+	if m.PostingP50 != 0 {
+		n += 1 + sovIndex(uint64(m.PostingP50))
+	}
+	if m.PostingP90 != 0 {
+		n += 1 + sovIndex(uint64(m.PostingP90))
+	}
+	if m.PostingP99 != 0 {
+		n += 1 + sovIndex(uint64(m.PostingP99))
+	}
+	if m.PostingMax != 0 {
+		n += 1 + sovIndex(uint64(m.PostingMax))
+	}
+	if m.ForwardIndexBytes != 0 {
+		n += 1 + sovIndex(uint64(m.ForwardIndexBytes))
+	}
+	if m.ReverseIndexBytes != 0 {
+		n += 1 + sovIndex(uint64(m.ReverseIndexBytes))
+	}
+	l = len(m.Compression)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.RawBytes != 0 {
+		n += 1 + sovIndex(uint64(m.RawBytes))
+	}
+	if m.StoredBytes != 0 {
+		n += 1 + sovIndex(uint64(m.StoredBytes))
+	}
+	if m.Primary {
+		n += 2
+	}
+	if m.AppliedSeq != 0 {
+		n += 2 + sovIndex(uint64(m.AppliedSeq))
+	}
+	return n
+}
+
+func (m *ReplicationOp) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Seq != 0 {
+		n += 1 + sovIndex(uint64(m.Seq))
+	}
+	l = len(m.DocId)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.Doc != nil {
+		l = m.Doc.Size()
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.Term != 0 {
+		n += 1 + sovIndex(uint64(m.Term))
+	}
+	return n
+}
+
+func (m *FetchOpsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Since != 0 {
+		n += 1 + sovIndex(uint64(m.Since))
+	}
+	if m.Term != 0 {
+		n += 1 + sovIndex(uint64(m.Term))
+	}
+	return n
+}
+
+func (m *RecoverRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *RecoveryChunk) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Snapshot)
+	if l > 0 {
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.SnapshotDone {
+		n += 2
+	}
+	if m.Seq != 0 {
+		n += 1 + sovIndex(uint64(m.Seq))
+	}
+	if m.Op != nil {
+		l = m.Op.Size()
+		n += 1 + l + sovIndex(uint64(l))
+	}
+	if m.Term != 0 {
+		n += 1 + sovIndex(uint64(m.Term))
+	}
+	return n
+}
+
+func (m *SlotRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Slots != 0 {
+		n += 1 + sovIndex(uint64(m.Slots))
+	}
+	if m.Slot != 0 {
+		n += 1 + sovIndex(uint64(m.Slot))
+	}
+	if m.Seed != 0 {
+		n += 1 + sovIndex(uint64(m.Seed))
+	}
+	if m.VersionOnly {
+		n += 2
+	}
+	return n
+}
+
+func sovIndex(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozIndex(x uint64) (n int) {
+	return sovIndex(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *DocId) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DocId: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DocId: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DocId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DocId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpectedVersion", wireType)
+			}
+			m.ExpectedVersion = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ExpectedVersion |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *AffectedCount) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: AffectedCount: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: AffectedCount: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Count", wireType)
+			}
+			m.Count = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Count |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Query", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Query == nil {
+				m.Query = &types.TermQuery{}
+			}
+			if err := m.Query.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OnFlag", wireType)
+			}
+			m.OnFlag = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.OnFlag |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OffFlag", wireType)
+			}
+			m.OffFlag = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.OffFlag |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowIndex
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.OrFlags = append(m.OrFlags, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowIndex
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthIndex
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthIndex
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.OrFlags) == 0 {
+					m.OrFlags = make([]uint64, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowIndex
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.OrFlags = append(m.OrFlags, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field OrFlags", wireType)
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Documents", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Documents = append(m.Documents, &types.Document{})
+			if err := m.Documents[len(m.Documents)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CountRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CountRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CountRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Slots", wireType)
+			}
+			m.Slots = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Slots |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Seed", wireType)
+			}
+			m.Seed = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Seed |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType == 0 {
+				var v uint32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowIndex
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint32(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.ExcludeSlots = append(m.ExcludeSlots, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowIndex
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthIndex
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthIndex
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.ExcludeSlots) == 0 {
+					m.ExcludeSlots = make([]uint32, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowIndex
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint32(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.ExcludeSlots = append(m.ExcludeSlots, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExcludeSlots", wireType)
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DocError) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DocError: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DocError: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DocId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DocId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Code", wireType)
+			}
+			m.Code = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Code |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reason = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *BulkAddResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: BulkAddResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: BulkAddResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Count", wireType)
+			}
+			m.Count = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Count |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Errors", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Errors = append(m.Errors, &DocError{})
+			if err := m.Errors[len(m.Errors)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *BackupRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: BackupRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: BackupRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SinceVersion", wireType)
+			}
+			m.SinceVersion = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SinceVersion |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IncludeReverseIndex", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.IncludeReverseIndex = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SnapshotChunk) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SnapshotChunk: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SnapshotChunk: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Data == nil {
+				m.Data = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RestoreResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RestoreResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RestoreResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Count", wireType)
+			}
+			m.Count = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Count |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MaintainRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MaintainRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MaintainRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MaintainResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MaintainResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MaintainResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Engine", wireType)
+			}
+			m.Engine = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Engine |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Path", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Path = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SizeBefore", wireType)
+			}
+			m.SizeBefore = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SizeBefore |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SizeAfter", wireType)
+			}
+			m.SizeAfter = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SizeAfter |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DurationMs", wireType)
+			}
+			m.DurationMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DurationMs |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StatsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StatsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StatsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StatsResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StatsResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StatsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DocCount", wireType)
+			}
+			m.DocCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DocCount |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DeletedCount", wireType)
+			}
+			m.DeletedCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.DeletedCount |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FieldTerms", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.FieldTerms == nil {
+				m.FieldTerms = make(map[string]int64)
+			}
+			var mapkey string
+			var mapvalue int64
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowIndex
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowIndex
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthIndex
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthIndex
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowIndex
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						mapvalue |= int64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipIndex(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthIndex
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.FieldTerms[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PostingLists", wireType)
+			}
+			m.PostingLists = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PostingLists |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Postings", wireType)
+			}
+			m.Postings = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Postings |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PostingP50", wireType)
+			}
+			m.PostingP50 = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PostingP50 |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PostingP90", wireType)
+			}
+			m.PostingP90 = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PostingP90 |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PostingP99", wireType)
+			}
+			m.PostingP99 = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PostingP99 |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PostingMax", wireType)
+			}
+			m.PostingMax = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PostingMax |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ForwardIndexBytes", wireType)
+			}
+			m.ForwardIndexBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ForwardIndexBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReverseIndexBytes", wireType)
+			}
+			m.ReverseIndexBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ReverseIndexBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Compression", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Compression = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RawBytes", wireType)
+			}
+			m.RawBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RawBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StoredBytes", wireType)
+			}
+			m.StoredBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StoredBytes |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Primary", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Primary = bool(v != 0)
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AppliedSeq", wireType)
+			}
+			m.AppliedSeq = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.AppliedSeq |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
 		}
 	}
-	return n
-}
 
-func (m *CountRequest) Size() (n int) {
-	if m == nil {
-		return 0
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	var l int
-	_ = l
-	return n
-}
-
-func sovIndex(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozIndex(x uint64) (n int) {
-	return sovIndex(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	return nil
 }
-func (m *DocId) Unmarshal(dAtA []byte) error {
+func (m *ReplicationOp) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -785,13 +5191,32 @@ func (m *DocId) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: DocId: wiretype end group for non-group")
+			return fmt.Errorf("proto: ReplicationOp: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DocId: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ReplicationOp: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Seq", wireType)
+			}
+			m.Seq = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Seq |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field DocId", wireType)
 			}
@@ -821,8 +5246,151 @@ func (m *DocId) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.DocId = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
+			m.DocId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Doc", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthIndex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Doc == nil {
+				m.Doc = &types.Document{}
+			}
+			if err := m.Doc.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Term", wireType)
+			}
+			m.Term = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Term |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipIndex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthIndex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FetchOpsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowIndex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FetchOpsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FetchOpsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Since", wireType)
+			}
+			m.Since = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Since |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Term", wireType)
+			}
+			m.Term = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Term |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipIndex(dAtA[iNdEx:])
@@ -844,7 +5412,7 @@ func (m *DocId) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *AffectedCount) Unmarshal(dAtA []byte) error {
+func (m *RecoverRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -867,31 +5435,12 @@ func (m *AffectedCount) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: AffectedCount: wiretype end group for non-group")
+			return fmt.Errorf("proto: RecoverRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: AffectedCount: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: RecoverRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Count", wireType)
-			}
-			m.Count = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowIndex
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Count |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipIndex(dAtA[iNdEx:])
@@ -913,7 +5462,7 @@ func (m *AffectedCount) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchRequest) Unmarshal(dAtA []byte) error {
+func (m *RecoveryChunk) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -936,17 +5485,17 @@ func (m *SearchRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: RecoveryChunk: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: RecoveryChunk: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Query", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Snapshot", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowIndex
@@ -956,33 +5505,31 @@ func (m *SearchRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthIndex
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthIndex
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Query == nil {
-				m.Query = &types.TermQuery{}
-			}
-			if err := m.Query.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Snapshot = append(m.Snapshot[:0], dAtA[iNdEx:postIndex]...)
+			if m.Snapshot == nil {
+				m.Snapshot = []byte{}
 			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field OnFlag", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SnapshotDone", wireType)
 			}
-			m.OnFlag = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowIndex
@@ -992,16 +5539,17 @@ func (m *SearchRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.OnFlag |= uint64(b&0x7F) << shift
+				v |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			m.SnapshotDone = bool(v != 0)
 		case 3:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field OffFlag", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Seq", wireType)
 			}
-			m.OffFlag = 0
+			m.Seq = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowIndex
@@ -1011,140 +5559,14 @@ func (m *SearchRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.OffFlag |= uint64(b&0x7F) << shift
+				m.Seq |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
 		case 4:
-			if wireType == 0 {
-				var v uint64
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowIndex
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					v |= uint64(b&0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				m.OrFlags = append(m.OrFlags, v)
-			} else if wireType == 2 {
-				var packedLen int
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowIndex
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					packedLen |= int(b&0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				if packedLen < 0 {
-					return ErrInvalidLengthIndex
-				}
-				postIndex := iNdEx + packedLen
-				if postIndex < 0 {
-					return ErrInvalidLengthIndex
-				}
-				if postIndex > l {
-					return io.ErrUnexpectedEOF
-				}
-				var elementCount int
-				var count int
-				for _, integer := range dAtA[iNdEx:postIndex] {
-					if integer < 128 {
-						count++
-					}
-				}
-				elementCount = count
-				if elementCount != 0 && len(m.OrFlags) == 0 {
-					m.OrFlags = make([]uint64, 0, elementCount)
-				}
-				for iNdEx < postIndex {
-					var v uint64
-					for shift := uint(0); ; shift += 7 {
-						if shift >= 64 {
-							return ErrIntOverflowIndex
-						}
-						if iNdEx >= l {
-							return io.ErrUnexpectedEOF
-						}
-						b := dAtA[iNdEx]
-						iNdEx++
-						v |= uint64(b&0x7F) << shift
-						if b < 0x80 {
-							break
-						}
-					}
-					m.OrFlags = append(m.OrFlags, v)
-				}
-			} else {
-				return fmt.Errorf("proto: wrong wireType = %d for field OrFlags", wireType)
-			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipIndex(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if (skippy < 0) || (iNdEx+skippy) < 0 {
-				return ErrInvalidLengthIndex
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *SearchResponse) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowIndex
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: SearchResponse: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Documents", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -1171,11 +5593,32 @@ func (m *SearchResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Documents = append(m.Documents, &types.Document{})
-			if err := m.Documents[len(m.Documents)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if m.Op == nil {
+				m.Op = &ReplicationOp{}
+			}
+			if err := m.Op.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Term", wireType)
+			}
+			m.Term = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Term |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipIndex(dAtA[iNdEx:])
@@ -1197,7 +5640,7 @@ func (m *SearchResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *CountRequest) Unmarshal(dAtA []byte) error {
+func (m *SlotRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1220,12 +5663,89 @@ func (m *CountRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: CountRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: SlotRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: CountRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SlotRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Slots", wireType)
+			}
+			m.Slots = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Slots |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Slot", wireType)
+			}
+			m.Slot = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Slot |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Seed", wireType)
+			}
+			m.Seed = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Seed |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VersionOnly", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowIndex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.VersionOnly = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipIndex(dAtA[iNdEx:])
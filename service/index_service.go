@@ -2,16 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/WlayRay/ElectricSearch/internal/kvdb"
 	"github.com/WlayRay/ElectricSearch/types"
 	"github.com/WlayRay/ElectricSearch/util"
-	etcdv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -19,9 +22,10 @@ const (
 )
 
 var (
-	indexName      string
-	currentGroup   string
-	distributedMap map[string]any
+	indexName         string
+	currentGroup      string
+	currentGroupIndex int
+	distributedMap    map[string]any
 )
 
 func init() {
@@ -51,19 +55,36 @@ func init() {
 		panic("group-index invalid!")
 	}
 
+	currentGroupIndex = groupIndex
 	currentGroup = fmt.Sprintf("group-%d", groupIndex)
 }
 
 // IndexServiceWorker 是一个grpc服务，用于索引文档
 type IndexServiceWorker struct {
-	Indexer  *Indexer
-	Hub      *ServiceHub
-	selfAddr string
+	Indexer    *Indexer
+	Hub        IServiceHub
+	Replicator *Replicator // 开启主从复制时不为nil
+	selfAddr   string
+	etcdHub    *ServiceHub          // 使用etcd注册中心时与Hub相同，认领副本位置、选主依赖etcd；使用静态注册时为nil
+	claim      *concurrency.Session // 认领副本位置使用的租约
+	dialer     Dialer               // 为nil时通过网络连接其他worker
 }
 
+// Init 按init.yml初始化注册中心和索引。使用etcd注册中心时etcdEndpoints为etcd集群地址
 func (service *IndexServiceWorker) Init(etcdEndpoints []string, currentGroup, heartRate int) error {
-	Hub := GetServiceHub(etcdEndpoints, int64(heartRate))
-	service.Hub = Hub
+	switch registry, file := registryConfig(); registry {
+	case RegistryEtcd:
+		service.etcdHub = GetServiceHub(etcdEndpoints, int64(heartRate))
+		service.Hub = service.etcdHub
+	case RegistryStatic:
+		hub, err := NewStaticServiceHub(file)
+		if err != nil {
+			return err
+		}
+		service.Hub = hub
+	default:
+		return fmt.Errorf("unknown registry %q, supported: %s, %s", registry, RegistryEtcd, RegistryStatic)
+	}
 	service.Indexer = new(Indexer)
 
 	var docNumEstimate, dbType int
@@ -93,6 +114,9 @@ func (service *IndexServiceWorker) Init(etcdEndpoints []string, currentGroup, he
 			case "bolt":
 				dbType = kvdb.BOLT
 				dbPath += "bolt_db/bolt"
+			case "memory":
+				dbType = kvdb.MEMORY
+				dbPath += "memory_db/memory"
 			default:
 				dbType = kvdb.BADGER
 				dbPath += "badger_db"
@@ -108,9 +132,107 @@ func (service *IndexServiceWorker) Init(etcdEndpoints []string, currentGroup, he
 				dbPath += strconv.Itoa(port)
 			}
 		}
+		// 内存存储引擎默认不持久化，开启memory-aof后才把写操作追加到dbPath
+		if aof, _ := indexConfig["memory-aof"].(bool); dbType == kvdb.MEMORY && !aof {
+			dbPath = ""
+		}
 		util.Log.Println("db path:", dbPath)
 	}
-	return service.Indexer.Init(docNumEstimate, dbType, dbPath)
+
+	// 初始化正排索引value的压缩算法
+	var compression Compression
+	if v, ok := indexConfig["compression"]; ok {
+		var err error
+		if compression, err = ParseCompression(fmt.Sprintf("%v", v)); err != nil {
+			return err
+		}
+	}
+
+	// 开启预写日志，纯内存的正排索引不需要
+	if enabled, _ := indexConfig["wal"].(bool); enabled && len(dbPath) > 0 {
+		service.Indexer.WithWAL(dbPath + "_wal")
+	}
+
+	// 正排索引和预写日志的加密
+	key, err := ParseEncryptionConfig(indexConfig)
+	if err != nil {
+		return err
+	}
+	service.Indexer.WithEncryptionKey(key)
+
+	if err := service.Indexer.Init(docNumEstimate, dbType, dbPath); err != nil {
+		return err
+	}
+	service.Indexer.SetCompression(compression)
+	service.Indexer.StartMaintenance(ParseMaintenanceConfig(indexConfig))
+
+	// 开启主从复制，已应用的序号持久化在正排索引旁边
+	if distributedConfig, ok := util.ConfigMap["distributed"].(map[string]any); ok {
+		if enabled, _ := distributedConfig["replication"].(bool); enabled {
+			if service.etcdHub == nil {
+				return errors.New("replication requires the etcd registry")
+			}
+			var statePath string
+			if len(dbPath) > 0 {
+				statePath = strings.TrimSuffix(dbPath, "/") + ".replication"
+			}
+			service.Replicator = NewReplicator(service.Indexer, statePath)
+			if err := service.Replicator.Load(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RecoverFromPeer 本地索引为空时，从同group中的其他worker拉取快照，返回是否恢复成功。开启主从复制时还会应用快照之后的操作。
+// 需要在Register之前调用，追上之后再注册，避免用空索引提供检索。group中没有可用的worker时返回false，由调用方自行加载数据。
+// 没有开启主从复制时，快照导出之后、Register之前发给group的写请求不会同步到本worker
+func (service *IndexServiceWorker) RecoverFromPeer() bool {
+	if service.Indexer.Count(context.Background()) > 0 {
+		return false
+	}
+	dialer := service.dialer
+	if service.Replicator != nil {
+		dialer = service.Replicator.dialer
+	}
+	for _, peer := range service.Hub.GetServiceEndpoints(currentGroup) {
+		conn, err := dialWorker(peer, dialer)
+		if err != nil {
+			util.Log.Printf("dial peer %s failed: %v", peer, err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ReplicationRecoverTimeout)
+		err = service.recoverFrom(ctx, NewIndexServiceClient(conn))
+		cancel()
+		conn.Close()
+		if err != nil {
+			util.Log.Printf("recover from peer %s failed: %v", peer, err)
+			continue
+		}
+		util.Log.Printf("recovered from peer %s", peer)
+		return true
+	}
+	return false
+}
+
+// 开启主从复制时通过Recover接口恢复快照和之后的操作，否则通过Backup接口导出对方的全量快照，倒排索引在本地重建
+func (service *IndexServiceWorker) recoverFrom(ctx context.Context, client IndexServiceClient) error {
+	if service.Replicator != nil {
+		return service.Replicator.Recover(ctx, client)
+	}
+	stream, err := client.Backup(ctx, &BackupRequest{})
+	if err != nil {
+		return err
+	}
+	_, err = service.Indexer.ReadSnapshot(&snapshotStreamReader{recv: stream.Recv})
+	return err
+}
+
+// WithDialer 设置连接同group中其他worker的方式，需要在RecoverFromPeer之前调用。开启主从复制时使用Replicator的设置
+func (service *IndexServiceWorker) WithDialer(dialer Dialer) *IndexServiceWorker {
+	service.dialer = dialer
+	return service
 }
 
 func (service *IndexServiceWorker) Register(servicePort int) error {
@@ -126,67 +248,317 @@ func (service *IndexServiceWorker) Register(servicePort int) error {
 	// selfLocalIp := "127.0.0.1" // 仅在本机器模拟分布式部署用
 	service.selfAddr = fmt.Sprintf("%s:%d", selfLocalIp, servicePort)
 
-	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
-	defer cancel()
+	// 按集群拓扑认领当前group中的一个副本位置，worker退出或者租约过期后位置自动释放。静态注册时worker列表以文件为准
+	if service.etcdHub != nil {
+		session, err := concurrency.NewSession(service.etcdHub.client, concurrency.WithTTL(int(service.etcdHub.heartRate)))
+		if err != nil {
+			return err
+		}
+		if err := service.claimReplica(session); err != nil {
+			session.Close()
+			return err
+		}
+		service.claim = session
+	}
+
+	// 注册中心负责保活
+	if err := service.Hub.Register(currentGroup, service.selfAddr); err != nil {
+		return err
+	}
+
+	// 参与选主，当选前从主节点追赶
+	if service.Replicator != nil {
+		service.Replicator.Start(service.etcdHub, currentGroup, service.selfAddr, int(service.etcdHub.heartRate))
+	}
+	return nil
+}
+
+// 开启主从复制时写请求由Replicator串行执行并分配序号，不是主节点时拒绝写入
+func (service *IndexServiceWorker) write(fn func() error, docIds ...string) error {
+	if service.Replicator == nil {
+		return fn()
+	}
+	return service.Replicator.Write(fn, docIds...)
+}
 
-	leaseId, err := service.Hub.Register(currentGroup, service.selfAddr, 0)
+// 向索引中添加文档，如果文档已存在则会覆盖。doc.Version非0且与当前版本号不一致时返回FailedPrecondition
+func (service *IndexServiceWorker) AddDoc(ctx context.Context, doc *types.Document) (*AffectedCount, error) {
+	var version uint64
+	err := service.write(func() (err error) {
+		version, err = service.Indexer.AddDocWithVersion(*doc)
+		return err
+	}, doc.Id)
 	if err != nil {
+		return nil, replicationError(err)
+	}
+	var n uint32
+	if version > 0 {
+		n = 1
+	}
+	return &AffectedCount{Count: n, Version: version}, nil
+}
+
+// 局部更新文档，IntId保持不变。patch.Version非0且与当前版本号不一致时返回FailedPrecondition
+func (service *IndexServiceWorker) UpdateDoc(ctx context.Context, patch *types.DocPatch) (*AffectedCount, error) {
+	var version uint64
+	err := service.write(func() (err error) {
+		version, err = service.Indexer.UpdateDocWithVersion(patch)
 		return err
+	}, patch.Id)
+	if err != nil {
+		return nil, replicationError(err)
+	}
+	var n uint32
+	if version > 0 {
+		n = 1
 	}
+	return &AffectedCount{Count: n, Version: version}, nil
+}
+
+// 批量写入时每攒够多少个文档写一次正排索引
+const bulkBatchSize = 500
 
-	if res, err := service.Hub.client.Get(timeoutCtx, ServiceRootPath+indexName+"/"+currentGroup, etcdv3.WithPrefix()); err == nil {
-		if len(res.Kvs) == 1 { // 新group中的worker数量为1时才代表有新group加入集群
-			service.Hub.addIndexGroup()
+// BulkAddDoc 客户端流式发送文档，worker攒批后写入索引，结束时返回成功数和每个失败文档的原因
+func (service *IndexServiceWorker) BulkAddDoc(stream IndexService_BulkAddDocServer) error {
+	response := &BulkAddResponse{}
+	batch := make([]types.Document, 0, bulkBatchSize)
+	flush := func() error {
+		docIds := make([]string, len(batch))
+		for i := range batch {
+			docIds[i] = batch[i].Id
 		}
-	} else {
-		util.Log.Printf("failed to get key %s: %v", ServiceRootPath+indexName+currentGroup, err)
+		err := service.write(func() error {
+			n, docErrors := service.Indexer.BatchAddDoc(stream.Context(), batch)
+			response.Count += uint32(n)
+			response.Errors = append(response.Errors, docErrors...)
+			return nil
+		}, docIds...)
+		batch = batch[:0]
+		return replicationError(err)
 	}
 
-	go func() {
-		for {
-			_, _ = service.Hub.Register(currentGroup, service.selfAddr, leaseId)
-			time.Sleep(time.Duration(service.Hub.heartRate)*time.Second - 100*time.Millisecond)
+	for {
+		doc, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		batch = append(batch, *doc)
+		if len(batch) >= bulkBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
 		}
-	}()
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return stream.SendAndClose(response)
+}
 
-	return nil
+// 从索引上删除文档
+func (service *IndexServiceWorker) DeleteDoc(ctx context.Context, docId *DocId) (*AffectedCount, error) {
+	var n int
+	err := service.write(func() (err error) {
+		n, err = service.Indexer.DeleteDocWithVersion(docId.DocId, docId.ExpectedVersion)
+		return err
+	}, docId.DocId)
+	if err != nil {
+		return nil, replicationError(err)
+	}
+	return &AffectedCount{Count: uint32(n)}, nil
 }
 
-// 向索引中添加文档，如果文档已存在则会覆盖
-func (service *IndexServiceWorker) AddDoc(ctx context.Context, doc *types.Document) (*AffectedCount, error) {
-	n, err := service.Indexer.AddDoc(*doc)
-	return &AffectedCount{uint32(n)}, err
+// ScanSlot 以流的形式返回属于槽位的全部文档，用于重新分片时迁移数据
+func (service *IndexServiceWorker) ScanSlot(request *SlotRequest, stream IndexService_ScanSlotServer) error {
+	if request.Slots == 0 {
+		return status.Error(codes.InvalidArgument, "slots must be positive")
+	}
+	return service.Indexer.ScanSlot(request, stream.Send)
 }
 
-// 从索引上删除文档
-func (service *IndexServiceWorker) DeleteDoc(ctx context.Context, docId *DocId) (*AffectedCount, error) {
-	n := service.Indexer.DeleteDoc(docId.DocId)
-	return &AffectedCount{uint32(n)}, nil
+// ImportDocs 写入从其他group迁移过来的文档，IntId和版本号保持不变
+func (service *IndexServiceWorker) ImportDocs(stream IndexService_ImportDocsServer) error {
+	response := &BulkAddResponse{}
+	for {
+		doc, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		err = service.write(func() error {
+			return service.Indexer.ImportDoc(doc)
+		}, doc.Id)
+		if errors.Is(err, ErrNotPrimary) {
+			return replicationError(err)
+		} else if err != nil {
+			response.Errors = append(response.Errors, newDocError(doc.Id, status.Code(err), err))
+		} else {
+			response.Count++
+		}
+	}
+	return stream.SendAndClose(response)
+}
+
+// DropSlot 删除属于槽位的全部文档，用于重新分片后清理迁移走的数据。每遍历到SlotScanBatch个文档删除一次
+func (service *IndexServiceWorker) DropSlot(ctx context.Context, request *SlotRequest) (*AffectedCount, error) {
+	if request.Slots == 0 {
+		return nil, status.Error(codes.InvalidArgument, "slots must be positive")
+	}
+	request.VersionOnly = true
+	var n int
+	docIds := make([]string, 0, SlotScanBatch)
+	drop := func() error {
+		if len(docIds) == 0 {
+			return nil
+		}
+		err := service.write(func() error {
+			deleted, err := service.Indexer.BatchDeleteDoc(docIds)
+			n += deleted
+			return err
+		}, docIds...)
+		docIds = docIds[:0]
+		return err
+	}
+	err := service.Indexer.ScanSlot(request, func(doc *types.Document) error {
+		docIds = append(docIds, doc.Id)
+		if len(docIds) < SlotScanBatch {
+			return nil
+		}
+		return drop()
+	})
+	if err == nil {
+		err = drop()
+	}
+	if err != nil {
+		return nil, replicationError(err)
+	}
+	return &AffectedCount{Count: uint32(n)}, nil
+}
+
+// 版本冲突转换成FailedPrecondition，便于调用方与其他错误区分
+func versionError(err error) error {
+	if errors.Is(err, ErrVersionConflict) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return err
 }
 
-// 检索，返回文档列表
+// 检索，返回文档列表。调用方取消或者超时时中止检索
 func (service *IndexServiceWorker) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
-	documents := service.Indexer.Search(request.Query, request.OnFlag, request.OffFlag, request.OrFlags)
+	documents, err := service.Indexer.Search(ctx, request.Query, request.OnFlag, request.OffFlag, request.OrFlags)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
 	return &SearchResponse{Documents: documents}, nil
 }
 
+// Count 返回分片的文档数，不包括request.ExcludeSlots中的槽位（槽位迁移期间残留在本分片的文档）
 func (service *IndexServiceWorker) Count(ctx context.Context, request *CountRequest) (*AffectedCount, error) {
-	n := service.Indexer.Count()
-	return &AffectedCount{Count: uint32(n)}, nil
+	n := service.Indexer.Count(ctx)
+	excluded, err := service.Indexer.CountSlots(request.Slots, request.Seed, request.ExcludeSlots)
+	if err != nil {
+		return nil, err
+	}
+	return &AffectedCount{Count: uint32(max(n-excluded, 0))}, nil
+}
+
+// Backup 把当前分片导出为快照，以数据流的形式返回。快照可以通过Restore恢复到同一种存储引擎的worker上
+func (service *IndexServiceWorker) Backup(request *BackupRequest, stream IndexService_BackupServer) error {
+	_, err := service.Indexer.WriteSnapshot(snapshotStreamWriter{send: stream.Send}, request.SinceVersion, request.IncludeReverseIndex)
+	if errors.Is(err, kvdb.ErrIncrementalBackup) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return err
+}
+
+// Restore 从客户端流式上传的快照中恢复分片，恢复期间写请求会被阻塞。
+// 开启主从复制时只能发给主节点，恢复后从节点从主节点的快照重新同步；发给从节点返回Unavailable
+func (service *IndexServiceWorker) Restore(stream IndexService_RestoreServer) error {
+	var info *SnapshotInfo
+	restore := func() (err error) {
+		info, err = service.Indexer.ReadSnapshot(&snapshotStreamReader{recv: stream.Recv})
+		return err
+	}
+	var err error
+	if service.Replicator == nil {
+		err = restore()
+	} else {
+		err = service.Replicator.Reset(restore)
+	}
+	if errors.Is(err, ErrNotPrimary) {
+		return replicationError(err)
+	} else if errors.Is(err, ErrInvalidSnapshot) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&RestoreResponse{Count: uint32(service.Indexer.Count(stream.Context())), Version: info.Version})
+}
+
+// Stats 返回分片的文档数、倒排链分布和存储占用
+func (service *IndexServiceWorker) Stats(ctx context.Context, request *StatsRequest) (*StatsResponse, error) {
+	response := service.Indexer.Stats().response()
+	if service.Replicator != nil {
+		response.Primary = service.Replicator.IsPrimary()
+		response.AppliedSeq = service.Replicator.AppliedSeq()
+	}
+	return response, nil
+}
+
+// FetchOps 返回序号大于request.Since的复制操作，追上之后持续推送新的操作，直到客户端断开
+func (service *IndexServiceWorker) FetchOps(request *FetchOpsRequest, stream IndexService_FetchOpsServer) error {
+	if service.Replicator == nil {
+		return status.Error(codes.Unimplemented, "replication is not enabled")
+	}
+	return service.Replicator.Serve(stream.Context(), request.Since, request.Term, stream.Send)
+}
+
+// Recover 为同group中新加入或者数据为空的worker导出快照，然后发送快照之后的操作
+func (service *IndexServiceWorker) Recover(request *RecoverRequest, stream IndexService_RecoverServer) error {
+	if service.Replicator == nil {
+		return status.Error(codes.Unimplemented, "replication is not enabled")
+	}
+	return service.Replicator.ServeRecovery(stream.Context(), stream.Send)
+}
+
+// Maintain 立即维护一次正排索引，返回维护前后占用的磁盘空间
+func (service *IndexServiceWorker) Maintain(ctx context.Context, request *MaintainRequest) (*MaintainResponse, error) {
+	report, err := service.Indexer.Maintain()
+	if errors.Is(err, kvdb.ErrMaintenanceRunning) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	} else if errors.Is(err, ErrMaintenanceUnsupported) {
+		return nil, status.Error(codes.Unimplemented, err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+	return &MaintainResponse{
+		Engine:     int32(report.Engine),
+		Path:       report.Path,
+		SizeBefore: report.SizeBefore,
+		SizeAfter:  report.SizeAfter,
+		DurationMs: report.Duration.Milliseconds(),
+	}, nil
 }
 
 func (service *IndexServiceWorker) Close() error {
+	// 先退出选主，etcd连接关闭后无法主动让出主节点
+	if service.Replicator != nil {
+		if err := service.Replicator.Close(); err != nil {
+			util.Log.Printf("close replicator failed: %v", err)
+		}
+	}
 	if service.Hub != nil {
 		if err := service.Hub.UnRegister(currentGroup, service.selfAddr); err == nil {
-			timeoutCtx, cancel := util.GetDefaultTimeoutContext()
-			defer cancel()
-
-			if res, err := service.Hub.client.Get(timeoutCtx, ServiceRootPath+indexName+"/"+currentGroup, etcdv3.WithPrefix()); err == nil {
-				if res != nil {
-					if res.Count == 0 {
-						service.Hub.subIndexGroup()
-					}
-				}
+			// 撤销租约，释放认领的副本位置
+			if service.claim != nil {
+				_ = service.claim.Close()
 			}
 			service.Hub.Close()
 		}
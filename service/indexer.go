@@ -1,9 +1,13 @@
 package service
 
 import (
-	"bytes"
-	"encoding/gob"
+	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/WlayRay/ElectricSearch/internal/kvdb"
 	reverseindex "github.com/WlayRay/ElectricSearch/internal/reverse_index"
@@ -13,20 +17,63 @@ import (
 	"github.com/dgryski/go-farm"
 )
 
+// ErrVersionConflict 期望的版本号与正排索引中文档的当前版本号不一致
+var ErrVersionConflict = errors.New("document version conflict")
+
 // 外观模式，把正排和倒排索引2个子系统封装在一起
 type Indexer struct {
 	forwardIndex kvdb.IKeyValueDB
 	reverseIndex reverseindex.IReverseIndex
 	worker       *util.Worker // 雪花算法
+	locks        []sync.Mutex // 按docId分段加锁，保证同一文档的"读版本-校验-写入"是原子的
+	expireQueue  expireQueue  // 设置了过期时间的文档，由后台协程定期清理
+	counter      docCounter
+	versions     versionClock // 文档版本号
+	closeCh      chan struct{}
+
+	compression        Compression // 写入正排索引时使用的压缩算法
+	compressionCounter compressionCounter
+
+	encryptionKey []byte // 正排索引和预写日志的主密钥，为空时不加密
+
+	walDir        string // 为空时不开启预写日志
+	wal           *WAL
+	checkpointing atomic.Bool
+
+	maintenance *kvdb.MaintenanceScheduler // 正排索引的定期维护，存储引擎不支持时为nil
+}
+
+// WithWAL 开启预写日志，日志存放在dir目录下。需要在Init之前调用
+func (indexer *Indexer) WithWAL(dir string) *Indexer {
+	indexer.walDir = dir
+	return indexer
+}
+
+// WithEncryptionKey 对落盘的正排索引和预写日志加密，key为16、24或32字节的主密钥。需要在Init之前调用
+func (indexer *Indexer) WithEncryptionKey(key []byte) *Indexer {
+	indexer.encryptionKey = key
+	return indexer
 }
 
 func (indexer *Indexer) Init(DocNumEstimate int, dbtype int, DataDir string) error {
-	db, err := kvdb.GetKeyValueDB(dbtype, DataDir)
+	db, err := kvdb.GetEncryptedKeyValueDB(dbtype, DataDir, indexer.encryptionKey)
 	if err != nil {
 		return err
 	}
 	indexer.forwardIndex = db
+	// 先读取计数，重放预写日志时在此基础上增减，重放后的checkpoint会持久化计数
+	indexer.loadCounter()
+	if len(indexer.walDir) > 0 {
+		if indexer.wal, err = OpenEncryptedWAL(indexer.walDir, indexer.encryptionKey); err != nil {
+			return err
+		}
+		if err := indexer.replayWAL(); err != nil {
+			return err
+		}
+	}
+	indexer.maintenance = kvdb.NewMaintenanceScheduler(db)
 	indexer.reverseIndex = reverseindex.NewSkipListReverseIndex(DocNumEstimate)
+	indexer.locks = make([]sync.Mutex, 1000)
 
 	// 通过对本机IP哈希生成雪花算法的workerId，可保证workerId唯一
 	ip, _ := util.GetLocalIP()
@@ -36,88 +83,322 @@ func (indexer *Indexer) Init(DocNumEstimate int, dbtype int, DataDir string) err
 		panic(err)
 	}
 	indexer.worker = worker
+	indexer.closeCh = make(chan struct{})
+	go indexer.runExpireSweeper()
 	return nil
 }
 
 func (indexer *Indexer) Close() error {
+	close(indexer.closeCh)
+	if indexer.maintenance != nil {
+		indexer.maintenance.Stop()
+	}
+	if indexer.wal != nil {
+		if err := indexer.Checkpoint(); err != nil {
+			util.Log.Printf("wal checkpoint failed: %v", err)
+		}
+		if err := indexer.wal.Close(); err != nil {
+			util.Log.Printf("close wal failed: %v", err)
+		}
+	}
+	if err := indexer.saveCounter(true); err != nil {
+		util.Log.Printf("save counter failed: %v", err)
+	}
 	return indexer.forwardIndex.Close()
 }
 
 // 倒排索引存储在内存中，系统重启时从正排索引里加载数据
 func (indexer *Indexer) LoadFromIndexFile() int {
+	legacy := 0
+	var maxVersion uint64
 	n := indexer.forwardIndex.IterDB(func(k, v []byte) error {
-		reader := bytes.NewReader(v)
-		decoder := gob.NewDecoder(reader)
-		var doc types.Document
-		err := decoder.Decode(&doc)
+		doc, err := decodeDoc(v)
 		if err != nil {
 			util.Log.Printf("Decode error: %v", err)
 			return nil
 		}
-		indexer.reverseIndex.Add(doc)
+		if isLegacyRecord(v) {
+			legacy++
+		}
+		maxVersion = max(maxVersion, doc.Version)
+		indexer.reverseIndex.Add(*doc)
+		indexer.expireQueue.push(doc)
 		return err
 	})
+	indexer.counter.docs.Store(n) // 进程停止期间可能有文档被存储引擎按TTL删除，以实际加载的数量为准
+	// 升级前的索引没有记录版本号的上限，以已有文档的最大版本号为准
+	if err := indexer.observeVersion(maxVersion); err != nil {
+		util.Log.Printf("save doc version failed: %v", err)
+	}
 	util.Log.Printf("Load %d data from forward index: %s", n, indexer.forwardIndex.GetDbPath())
+	if legacy > 0 {
+		util.Log.Printf("found %d gob encoded documents, migrate them in background", legacy)
+		go func() {
+			if _, err := indexer.MigrateForwardIndex(); err != nil {
+				util.Log.Printf("migrate forward index failed: %v", err)
+			}
+		}()
+	}
 	return int(n)
 }
 
-// 向索引中添加文档，如果文档已存在则先删除
-func (indexer *Indexer) AddDoc(doc types.Document) (int, error) {
+func (indexer *Indexer) getLock(docId string) *sync.Mutex {
+	n := int(farm.Hash32WithSeed([]byte(docId), 0))
+	return &indexer.locks[n%len(indexer.locks)]
+}
+
+// 从正排索引中读取文档，文档不存在时返回nil
+func (indexer *Indexer) getDoc(docId string) *types.Document {
+	docBytes, err := indexer.forwardIndex.Get([]byte(docId))
+	if err != nil || len(docBytes) == 0 {
+		return nil
+	}
+	doc, err := decodeDoc(docBytes)
+	if err != nil {
+		util.Log.Printf("Decode error: %v", err)
+		return nil
+	}
+	return doc
+}
+
+// 向索引中添加文档，如果文档已存在则先删除。doc.Version非0时会先校验文档的当前版本号。ctx已经结束时不写入
+func (indexer *Indexer) AddDoc(ctx context.Context, doc types.Document) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	version, err := indexer.AddDocWithVersion(doc)
+	if err != nil || version == 0 {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// AddDocWithVersion 同AddDoc，返回写入后文档的新版本号
+func (indexer *Indexer) AddDocWithVersion(doc types.Document) (uint64, error) {
 	docId := strings.TrimSpace(doc.Id)
 	if len(docId) == 0 {
 		return 0, nil
 	}
-	indexer.DeleteDoc(docId) // 先从正排和倒排索引上删除文档
+	if doc.Expired(time.Now().Unix()) {
+		return 0, fmt.Errorf("doc %s already expired at %d", docId, doc.ExpireAt)
+	}
+	lock := indexer.getLock(docId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var currentVersion uint64
+	old := indexer.getDoc(docId)
+	if old != nil {
+		currentVersion = old.Version
+	}
+	if doc.Version > 0 && doc.Version != currentVersion {
+		return currentVersion, fmt.Errorf("%w: doc %s expected version %d, current version %d", ErrVersionConflict, docId, doc.Version, currentVersion)
+	}
 
 	doc.IntId = indexer.worker.GetId() // 使用雪花算法生成唯一自增ID
+	version, err := indexer.nextVersion(currentVersion)
+	if err != nil {
+		return 0, err
+	}
+	doc.Version = version
+	if err := indexer.appendWAL(&WALRecord{Op: WALPut, DocId: docId, Doc: &doc}); err != nil {
+		return 0, err
+	}
 
-	// 写入正排索引
-	var value bytes.Buffer
-	encoder := gob.NewEncoder(&value)
-	if err := encoder.Encode(doc); err == nil {
-		_ = indexer.forwardIndex.Set([]byte(docId), value.Bytes())
-	} else {
+	// 先覆盖正排索引，写入失败时旧文档保持不变
+	if err := indexer.putDoc(docId, &doc); err != nil {
+		indexer.revertWAL(docId)
 		return 0, err
 	}
 
-	// 写入倒排索引
+	// 从倒排链上删除旧文档，再写入新文档
+	indexer.removePostings(old)
 	indexer.reverseIndex.Add(doc)
+	indexer.expireQueue.push(&doc)
+	if old == nil {
+		indexer.counter.added(1)
+	}
+	return doc.Version, nil
+}
+
+// 写入正排索引，设置了过期时间的文档交给存储引擎的TTL
+func (indexer *Indexer) putDoc(docId string, doc *types.Document) error {
+	value, err := indexer.encodeDoc(doc)
+	if err != nil {
+		return err
+	}
+	if ttl := ttlOf(doc); ttl > 0 {
+		return indexer.forwardIndex.SetWithTTL([]byte(docId), value, ttl)
+	}
+	return indexer.forwardIndex.Set([]byte(docId), value)
+}
+
+// UpdateDoc 对已存在的文档做局部更新，文档不存在时返回0。ctx已经结束时不更新
+func (indexer *Indexer) UpdateDoc(ctx context.Context, patch *types.DocPatch) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	version, err := indexer.UpdateDocWithVersion(patch)
+	if err != nil || version == 0 {
+		return 0, err
+	}
 	return 1, nil
 }
 
-func (indexer *Indexer) DeleteDoc(docId string) int {
-	n := 0
-	forwardKey := []byte(docId)
-	docBytes, err := indexer.forwardIndex.Get(forwardKey) //先读正排索引，得到IntId和Keywords
-	if err == nil {
-		if len(docBytes) > 0 {
-			n = 1
-			reader := bytes.NewReader(docBytes)
-			decoder := gob.NewDecoder(reader)
-			var doc types.Document
-			err := decoder.Decode(&doc)
-			if err != nil {
-				util.Log.Printf("Decode error: %v", err)
-			} else {
-				// 从倒排索引上删除
-				for _, keyword := range doc.Keywords {
-					indexer.reverseIndex.Delete(doc.IntId, keyword)
-				}
-			}
+// UpdateDocWithVersion 同UpdateDoc，返回更新后文档的新版本号。
+// 只修改增删的Keyword对应的倒排链，IntId保持不变；特征位发生变化时需要刷新该文档所在的全部倒排链
+func (indexer *Indexer) UpdateDocWithVersion(patch *types.DocPatch) (uint64, error) {
+	docId := strings.TrimSpace(patch.Id)
+	if len(docId) == 0 {
+		return 0, nil
+	}
+	lock := indexer.getLock(docId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	doc := indexer.getDoc(docId)
+	var currentVersion uint64
+	if doc != nil {
+		currentVersion = doc.Version
+	}
+	if patch.Version > 0 && patch.Version != currentVersion {
+		return currentVersion, fmt.Errorf("%w: doc %s expected version %d, current version %d", ErrVersionConflict, docId, patch.Version, currentVersion)
+	}
+	if doc == nil {
+		return 0, nil
+	}
+
+	removed := make(map[string]struct{}, len(patch.RemoveKeywords))
+	for _, keyword := range patch.RemoveKeywords {
+		removed[keyword.ToString()] = struct{}{}
+	}
+	keywords := make([]*types.Keyword, 0, len(doc.Keywords)+len(patch.AddKeywords))
+	exists := make(map[string]struct{}, len(doc.Keywords))
+	var removedKeywords []*types.Keyword // 正排索引写入成功后才从倒排链上删除
+	for _, keyword := range doc.Keywords {
+		key := keyword.ToString()
+		if _, ok := removed[key]; ok {
+			removedKeywords = append(removedKeywords, keyword)
+			continue
 		}
-	} else {
-		//util.Log.Printf("DeleteDoc error: %v", err)
+		exists[key] = struct{}{}
+		keywords = append(keywords, keyword)
+	}
+	added := make([]*types.Keyword, 0, len(patch.AddKeywords))
+	for _, keyword := range patch.AddKeywords {
+		key := keyword.ToString()
+		if _, ok := exists[key]; ok || len(key) == 0 {
+			continue
+		}
+		exists[key] = struct{}{}
+		added = append(added, keyword)
+	}
+	keywords = append(keywords, added...)
+
+	bits := (doc.BitsFeature | patch.SetBits) &^ patch.ClearBits
+	bitsChanged := bits != doc.BitsFeature
+	doc.BitsFeature = bits
+	doc.Keywords = keywords
+	if patch.ReplaceBytes {
+		doc.Bytes = patch.Bytes
+	}
+	version, err := indexer.nextVersion(currentVersion)
+	if err != nil {
+		return 0, err
+	}
+	doc.Version = version
+
+	if err := indexer.appendWAL(&WALRecord{Op: WALPut, DocId: docId, Doc: doc}); err != nil {
+		return 0, err
+	}
+	if err := indexer.putDoc(docId, doc); err != nil {
+		indexer.revertWAL(docId)
+		return 0, err
+	}
+
+	for _, keyword := range removedKeywords {
+		indexer.reverseIndex.Delete(doc.IntId, keyword)
+	}
+	// 倒排链上存储了文档的特征位，特征位变化时全部刷新，否则只写入新增的Keyword
+	delta := *doc
+	if !bitsChanged {
+		delta.Keywords = added
+	}
+	indexer.reverseIndex.Add(delta)
+	indexer.expireQueue.push(doc)
+	return doc.Version, nil
+}
+
+// DeleteDoc ctx已经结束时不删除，返回0
+func (indexer *Indexer) DeleteDoc(ctx context.Context, docId string) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+	n, _ := indexer.DeleteDocWithVersion(docId, 0)
+	return n
+}
+
+// DeleteDocWithVersion 从索引上删除文档，忽略docId首尾的空白。expectedVersion非0时，只有文档的当前版本号与之相等才会删除
+func (indexer *Indexer) DeleteDocWithVersion(docId string, expectedVersion uint64) (int, error) {
+	docId = strings.TrimSpace(docId)
+	if len(docId) == 0 {
+		return 0, nil
+	}
+	lock := indexer.getLock(docId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	doc := indexer.getDoc(docId) //先读正排索引，得到IntId和Keywords
+	if expectedVersion > 0 {
+		var currentVersion uint64
+		if doc != nil {
+			currentVersion = doc.Version
+		}
+		if currentVersion != expectedVersion {
+			return 0, fmt.Errorf("%w: doc %s expected version %d, current version %d", ErrVersionConflict, docId, expectedVersion, currentVersion)
+		}
+	}
+	if doc != nil {
+		if err := indexer.appendWAL(&WALRecord{Op: WALDelete, DocId: docId}); err != nil {
+			return 0, err
+		}
+	}
+	n := indexer.deleteDoc(docId, doc)
+	indexer.counter.deleted(n)
+	return n, nil
+}
+
+// 调用方需持有docId对应的锁
+func (indexer *Indexer) deleteDoc(docId string, doc *types.Document) int {
+	n := 0
+	if doc != nil {
+		n = 1
+		indexer.removePostings(doc)
+		indexer.expireQueue.remove(docId)
 	}
 	// 从正排索引上删除
-	_ = indexer.forwardIndex.Delete(forwardKey)
+	_ = indexer.forwardIndex.Delete([]byte(docId))
 	return n
 }
 
-// 检索，返回文档列表
-func (indexer *Indexer) Search(querys *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) []*types.Document {
-	docIds := indexer.reverseIndex.Search(querys, onFlag, offFlag, orFlags)
-	if len(docIds) == 0 {
-		return nil
+// 从倒排索引上删除文档，doc为nil时什么都不做
+func (indexer *Indexer) removePostings(doc *types.Document) {
+	if doc == nil {
+		return
+	}
+	for _, keyword := range doc.Keywords {
+		indexer.reverseIndex.Delete(doc.IntId, keyword)
+	}
+}
+
+// 检索，返回文档列表。ctx结束时中止检索并返回ctx.Err()
+func (indexer *Indexer) Search(ctx context.Context, querys *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) ([]*types.Document, error) {
+	docIds, err := indexer.reverseIndex.Search(ctx, querys, onFlag, offFlag, orFlags)
+	if len(docIds) == 0 || err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	keys := make([][]byte, 0, len(docIds))
@@ -130,30 +411,22 @@ func (indexer *Indexer) Search(querys *types.TermQuery, onFlag, offFlag uint64,
 	}
 
 	results := make([]*types.Document, 0, len(docs))
-	reader := bytes.NewReader([]byte{})
-	// var doc types.Document //千万别把接收每个文档的变量定义放在循环外
+	now := time.Now().Unix()
 	for _, docBytes := range docs {
 		if len(docBytes) > 0 {
-			reader.Reset(docBytes)
-			decoder := gob.NewDecoder(reader)
-			var doc types.Document //一定要把接收每个文档的变量定义放在循环内
-			err := decoder.Decode(&doc)
+			doc, err := decodeDoc(docBytes)
 			if err != nil {
 				util.Log.Printf("Decode error: %v", err)
 				continue
-			} else {
-				results = append(results, &doc)
+			} else if !doc.Expired(now) { // 不支持TTL的存储引擎里可能还留有未清理的过期文档
+				results = append(results, doc)
 			}
 		}
 	}
-	return results
+	return results, nil
 }
 
-func (indexer *Indexer) Count() int {
-	n := 0
-	indexer.forwardIndex.IterKey(func(k []byte) error {
-		n++
-		return nil
-	})
-	return n
+// Count 返回正排索引中的文档数，由写入和删除时维护的计数器得到，不需要遍历正排索引
+func (indexer *Indexer) Count(ctx context.Context) int {
+	return int(indexer.counter.docs.Load())
 }
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+
+	"github.com/dgryski/go-farm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newDocError(docId string, code codes.Code, err error) *DocError {
+	return &DocError{DocId: docId, Code: uint32(code), Reason: err.Error()}
+}
+
+// 批量获取多个docId对应的锁。按锁的下标升序加锁，避免并发的批量写之间死锁
+func (indexer *Indexer) lockDocs(docIds []string) (unlock func()) {
+	indices := make([]int, 0, len(docIds))
+	for _, docId := range docIds {
+		indices = append(indices, int(farm.Hash32WithSeed([]byte(docId), 0))%len(indexer.locks))
+	}
+	slices.Sort(indices)
+	indices = slices.Compact(indices)
+
+	locks := make([]*sync.Mutex, 0, len(indices))
+	for _, i := range indices {
+		indexer.locks[i].Lock()
+		locks = append(locks, &indexer.locks[i])
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// BatchAddDoc 批量写入文档，正排索引的读和写各只用一个事务。
+// 返回写入成功的文档数，以及每个失败文档的原因。同一批次中docId重复时以最后一个为准，ctx已经结束时整批不写入
+func (indexer *Indexer) BatchAddDoc(ctx context.Context, docs []types.Document) (int, []*DocError) {
+	var docErrors []*DocError
+	if err := ctx.Err(); err != nil {
+		for _, doc := range docs {
+			docErrors = append(docErrors, newDocError(doc.Id, status.FromContextError(err).Code(), err))
+		}
+		return 0, docErrors
+	}
+	now := time.Now().Unix()
+
+	// 过滤掉非法的文档，同一个docId只保留最后一个
+	latest := make(map[string]int, len(docs))
+	for i := range docs {
+		docId := strings.TrimSpace(docs[i].Id)
+		if len(docId) == 0 {
+			continue
+		}
+		if docs[i].Expired(now) {
+			docErrors = append(docErrors, newDocError(docId, codes.InvalidArgument, fmt.Errorf("doc %s already expired at %d", docId, docs[i].ExpireAt)))
+			continue
+		}
+		if j, exists := latest[docId]; exists {
+			docErrors = append(docErrors, newDocError(docId, codes.AlreadyExists, fmt.Errorf("doc %s at position %d is superseded by a later one in the same batch", docId, j)))
+		}
+		latest[docId] = i
+	}
+	if len(latest) == 0 {
+		return 0, docErrors
+	}
+
+	docIds := make([]string, 0, len(latest))
+	for docId := range latest {
+		docIds = append(docIds, docId)
+	}
+	unlock := indexer.lockDocs(docIds)
+	defer unlock()
+
+	keys := make([][]byte, 0, len(docIds))
+	for _, docId := range docIds {
+		keys = append(keys, []byte(docId))
+	}
+	oldValues, _ := indexer.forwardIndex.BatchGet(keys) // 读取失败的key对应空value，按新文档处理
+
+	olds := make([]*types.Document, 0, len(docIds))
+	news := make([]*types.Document, 0, len(docIds))
+	newIds := make([]string, 0, len(docIds)) // 去掉首尾空格后的docId，与news一一对应
+	var setKeys, setValues [][]byte
+	for i, docId := range docIds {
+		doc := docs[latest[docId]]
+		var old *types.Document
+		var currentVersion uint64
+		if i < len(oldValues) && len(oldValues[i]) > 0 {
+			var err error
+			if old, err = decodeDoc(oldValues[i]); err == nil {
+				currentVersion = old.Version
+			} else {
+				util.Log.Printf("Decode error: %v", err)
+			}
+		}
+		if doc.Version > 0 && doc.Version != currentVersion {
+			err := fmt.Errorf("%w: doc %s expected version %d, current version %d", ErrVersionConflict, docId, doc.Version, currentVersion)
+			docErrors = append(docErrors, newDocError(docId, codes.FailedPrecondition, err))
+			continue
+		}
+
+		version, err := indexer.nextVersion(currentVersion)
+		if err != nil {
+			docErrors = append(docErrors, newDocError(docId, codes.Internal, err))
+			continue
+		}
+		doc.IntId = indexer.worker.GetId()
+		doc.Version = version
+		olds = append(olds, old)
+		news = append(news, &doc)
+		newIds = append(newIds, docId)
+	}
+
+	// 整批文档共用一次预写日志的fsync
+	records := make([]*WALRecord, 0, len(news))
+	for i, doc := range news {
+		records = append(records, &WALRecord{Op: WALPut, DocId: newIds[i], Doc: doc})
+	}
+	if err := indexer.appendWAL(records...); err != nil {
+		for _, docId := range newIds {
+			docErrors = append(docErrors, newDocError(docId, codes.Internal, err))
+		}
+		return 0, docErrors
+	}
+
+	var failed []string // 已经写入预写日志但没有写入正排索引的文档
+	written := make([]*types.Document, 0, len(news))
+	writtenOlds := make([]*types.Document, 0, len(olds))
+	for i, doc := range news {
+		docId := newIds[i]
+		if ttl := ttlOf(doc); ttl > 0 {
+			// 带过期时间的文档无法合并进同一个批次，单独写入
+			if err := indexer.putDoc(docId, doc); err != nil {
+				docErrors = append(docErrors, newDocError(docId, codes.Internal, err))
+				failed = append(failed, docId)
+				continue
+			}
+		} else {
+			value, err := indexer.encodeDoc(doc)
+			if err != nil {
+				docErrors = append(docErrors, newDocError(docId, codes.Internal, err))
+				failed = append(failed, docId)
+				continue
+			}
+			setKeys = append(setKeys, []byte(docId))
+			setValues = append(setValues, value)
+		}
+		written = append(written, doc)
+		writtenOlds = append(writtenOlds, olds[i])
+	}
+	news, olds = written, writtenOlds
+
+	if len(setKeys) > 0 {
+		if err := indexer.forwardIndex.BatchSet(setKeys, setValues); err != nil {
+			// 整批写入失败，这一批文档都不能进入倒排索引
+			written := make([]*types.Document, 0, len(news))
+			writtenOlds := make([]*types.Document, 0, len(olds))
+			for i, doc := range news {
+				if doc.ExpireAt > 0 {
+					written = append(written, doc)
+					writtenOlds = append(writtenOlds, olds[i])
+				} else {
+					docId := strings.TrimSpace(doc.Id)
+					docErrors = append(docErrors, newDocError(docId, codes.Internal, err))
+					failed = append(failed, docId)
+				}
+			}
+			news, olds = written, writtenOlds
+		}
+	}
+	indexer.revertWAL(failed...)
+
+	for i, doc := range news {
+		if old := olds[i]; old != nil {
+			for _, keyword := range old.Keywords {
+				indexer.reverseIndex.Delete(old.IntId, keyword)
+			}
+		} else {
+			indexer.counter.added(1)
+		}
+		indexer.reverseIndex.Add(*doc)
+		indexer.expireQueue.push(doc)
+	}
+	return len(news), docErrors
+}
+
+// BatchDeleteDoc 批量删除文档，返回删除的文档数。与DeleteDoc一样忽略docId首尾的空白，空的docId被跳过
+func (indexer *Indexer) BatchDeleteDoc(docIds []string) (int, error) {
+	trimmed := make([]string, 0, len(docIds))
+	for _, docId := range docIds {
+		if docId = strings.TrimSpace(docId); len(docId) > 0 {
+			trimmed = append(trimmed, docId)
+		}
+	}
+	docIds = trimmed
+	if len(docIds) == 0 {
+		return 0, nil
+	}
+	unlock := indexer.lockDocs(docIds)
+	defer unlock()
+
+	keys := make([][]byte, 0, len(docIds))
+	for _, docId := range docIds {
+		keys = append(keys, []byte(docId))
+	}
+	values, _ := indexer.forwardIndex.BatchGet(keys)
+	records := make([]*WALRecord, 0, len(docIds))
+	for _, docId := range docIds {
+		records = append(records, &WALRecord{Op: WALDelete, DocId: docId})
+	}
+	if err := indexer.appendWAL(records...); err != nil {
+		return 0, err
+	}
+	if err := indexer.forwardIndex.BatchDelete(keys); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for i, value := range values {
+		if len(value) == 0 {
+			continue
+		}
+		n++
+		indexer.expireQueue.remove(docIds[i])
+		doc, err := decodeDoc(value)
+		if err != nil {
+			util.Log.Printf("Decode error: %v", err)
+			continue
+		}
+		for _, keyword := range doc.Keywords {
+			indexer.reverseIndex.Delete(doc.IntId, keyword)
+		}
+	}
+	indexer.counter.deleted(n)
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+	"github.com/dgryski/go-farm"
+)
+
+// 判断文档是否属于槽位，与ShardMap.SlotOf的算法一致
+func inSlot(docId string, request *SlotRequest) bool {
+	return request.Slots > 0 && farm.Hash32WithSeed([]byte(docId), request.Seed)%request.Slots == request.Slot
+}
+
+// SlotScanBatch ScanSlot每次从正排索引中读取的条数
+var SlotScanBatch = 1000
+
+// ScanSlot 按key的顺序把正排索引中属于槽位的文档依次交给fn，fn返回error时停止遍历并返回该error。
+// request.VersionOnly为true时只保留Id和Version。每次从正排索引中读取SlotScanBatch条，读完一批再调用fn，
+// 内存中最多保留一批文档，fn较慢（例如发给网络对端）时也不会一直占用存储引擎
+func (indexer *Indexer) ScanSlot(request *SlotRequest, fn func(doc *types.Document) error) error {
+	var start, last []byte
+	batch := make([]*types.Document, 0, SlotScanBatch)
+	for {
+		batch = batch[:0]
+		n, err := indexer.forwardIndex.Scan(start, nil, SlotScanBatch, func(k, v []byte) error {
+			last = append(last[:0], k...)
+			if !inSlot(string(k), request) {
+				return nil
+			}
+			doc, err := decodeDoc(v)
+			if err != nil {
+				util.Log.Printf("Decode error: %v", err)
+				return nil
+			}
+			if request.VersionOnly {
+				doc = &types.Document{Id: doc.Id, Version: doc.Version}
+			}
+			batch = append(batch, doc)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, doc := range batch {
+			if err := fn(doc); err != nil {
+				return err
+			}
+		}
+		if n < int64(SlotScanBatch) {
+			return nil
+		}
+		start = kvdb.NextKey(last)
+	}
+}
+
+// CountSlots 统计正排索引中属于slots中任一槽位的文档数，只遍历key，不解码文档。slots为空时返回0
+func (indexer *Indexer) CountSlots(total, seed uint32, slots []uint32) (int, error) {
+	if total == 0 || len(slots) == 0 {
+		return 0, nil
+	}
+	var start, last []byte
+	count := 0
+	for {
+		n, err := indexer.forwardIndex.Scan(start, nil, SlotScanBatch, func(k, v []byte) error {
+			last = append(last[:0], k...)
+			if slices.Contains(slots, farm.Hash32WithSeed(k, seed)%total) {
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if n < int64(SlotScanBatch) {
+			return count, nil
+		}
+		start = kvdb.NextKey(last)
+	}
+}
+
+// ImportDoc 写入从其他group迁移过来的文档，IntId和版本号保持不变
+func (indexer *Indexer) ImportDoc(doc *types.Document) error {
+	docId := strings.TrimSpace(doc.Id)
+	if len(docId) == 0 {
+		return nil
+	}
+	return indexer.putDocState(docId, doc)
+}
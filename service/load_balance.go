@@ -1,12 +1,69 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/dgryski/go-farm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// LoadBalancer 从同一个group的worker中选出一台。每次Take之后都要调用Done报告请求的结果，
+// 依赖在途请求数或者延迟的算法据此更新状态
 type LoadBalancer interface {
 	Take([]string) string
+	Done(endpoint string, latency time.Duration, err error)
+}
+
+// KeyedLoadBalancer 可以按请求的key选择worker的负载均衡算法，相同的key尽量落到同一台worker上
+type KeyedLoadBalancer interface {
+	LoadBalancer
+	TakeByKey(endpoints []string, key string) string
+}
+
+var (
+	PeakEWMADecay        = 10 * time.Second // 峰值EWMA的衰减时间常数，越大历史延迟的影响越久
+	PeakEWMAPenalty      = time.Second      // 请求失败时按该延迟计入EWMA
+	ConsistentHashVN     = 100              // 一致性哈希中每台worker的虚拟节点数
+	ConsistentHashRings  = 64               // 一致性哈希最多缓存的哈希环个数，每个group的worker列表对应一个
+	LoadBalancerStateTTL = 10 * time.Minute // worker超过这个时间没有出现在Take的候选列表中时，删除它的在途请求数和延迟
+)
+
+// ParseLoadBalancer 解析init.yml中的load-balancer配置
+func ParseLoadBalancer(name string) (LoadBalancer, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "round-robin":
+		return new(RoundRobin), nil
+	case "random":
+		return new(RandomSelect), nil
+	case "least-outstanding":
+		return NewLeastOutstanding(), nil
+	case "peak-ewma":
+		return NewPeakEWMA(), nil
+	case "p2c":
+		return NewPowerOfTwoChoices(), nil
+	case "consistent-hash":
+		return NewConsistentHash(), nil
+	}
+	return nil, fmt.Errorf("unknown load balancer %q, supported: round-robin, random, least-outstanding, peak-ewma, p2c, consistent-hash", name)
+}
+
+// 按key选择worker，balancer不支持按key选择或者key为空时退化为Take
+func takeByKey(balancer LoadBalancer, endpoints []string, key string) string {
+	if keyed, ok := balancer.(KeyedLoadBalancer); ok && len(key) > 0 {
+		return keyed.TakeByKey(endpoints, key)
+	}
+	return balancer.Take(endpoints)
 }
 
 type RoundRobin struct {
@@ -17,10 +74,12 @@ func (rr *RoundRobin) Take(endPoints []string) string {
 	if len(endPoints) == 0 {
 		return ""
 	}
-	atomic.AddUint64(&rr.acc, 1)
-	return endPoints[rr.acc%uint64(len(endPoints))]
+	n := atomic.AddUint64(&rr.acc, 1)
+	return endPoints[n%uint64(len(endPoints))]
 }
 
+func (rr *RoundRobin) Done(string, time.Duration, error) {}
+
 type RandomSelect struct{}
 
 func (rs *RandomSelect) Take(endPoints []string) string {
@@ -29,3 +88,269 @@ func (rs *RandomSelect) Take(endPoints []string) string {
 	}
 	return endPoints[rand.Intn(len(endPoints))]
 }
+
+func (rs *RandomSelect) Done(string, time.Duration, error) {}
+
+// 按worker保存的负载均衡状态。一个负载均衡器为所有group选择worker，某次Take的候选列表中没有的worker不一定已经下线，
+// 因此记录每台worker最后一次作为候选的时间，超过LoadBalancerStateTTL的（已经下线或者被替换）定期删除，避免状态一直增长
+type endpointStates[T any] struct {
+	states sync.Map     // endpoint -> *endpointState[T]
+	pruned atomic.Int64 // 上次清理的时间，UnixNano
+}
+
+type endpointState[T any] struct {
+	value T
+	seen  atomic.Int64 // 最后一次作为候选的时间，UnixNano
+}
+
+func (s *endpointStates[T]) load(endpoint string) *endpointState[T] {
+	if v, ok := s.states.Load(endpoint); ok {
+		return v.(*endpointState[T])
+	}
+	v, _ := s.states.LoadOrStore(endpoint, new(endpointState[T]))
+	return v.(*endpointState[T])
+}
+
+func (s *endpointStates[T]) get(endpoint string) *T {
+	return &s.load(endpoint).value
+}
+
+// touch 记录本次Take的候选worker，每隔LoadBalancerStateTTL清理一次过期的状态
+func (s *endpointStates[T]) touch(endpoints []string) {
+	now := time.Now().UnixNano()
+	for _, endpoint := range endpoints {
+		s.load(endpoint).seen.Store(now)
+	}
+	last := s.pruned.Load()
+	if now-last < int64(LoadBalancerStateTTL) || !s.pruned.CompareAndSwap(last, now) {
+		return
+	}
+	s.states.Range(func(endpoint, v any) bool {
+		if now-v.(*endpointState[T]).seen.Load() >= int64(LoadBalancerStateTTL) {
+			s.states.Delete(endpoint)
+		}
+		return true
+	})
+}
+
+// 每台worker的在途请求数，Take时加1，Done时减1
+type outstandingCounter struct {
+	counts endpointStates[atomic.Int64]
+}
+
+func (c *outstandingCounter) get(endpoint string) *atomic.Int64 {
+	return c.counts.get(endpoint)
+}
+
+// touch 每次Take时调用，记录候选的worker
+func (c *outstandingCounter) touch(endpoints []string) {
+	c.counts.touch(endpoints)
+}
+
+func (c *outstandingCounter) done(endpoint string) {
+	if n := c.get(endpoint); n.Add(-1) < 0 {
+		n.Store(0)
+	}
+}
+
+// LeastOutstanding 选择在途请求最少的worker，数量相同时从随机位置开始找第一个
+type LeastOutstanding struct {
+	outstanding outstandingCounter
+}
+
+func NewLeastOutstanding() *LeastOutstanding {
+	return new(LeastOutstanding)
+}
+
+func (lb *LeastOutstanding) Take(endpoints []string) string {
+	if len(endpoints) == 0 {
+		return ""
+	}
+	lb.outstanding.touch(endpoints)
+	offset := rand.Intn(len(endpoints))
+	best := ""
+	var least int64 = math.MaxInt64
+	for i := range endpoints {
+		endpoint := endpoints[(offset+i)%len(endpoints)]
+		if n := lb.outstanding.get(endpoint).Load(); n < least {
+			best, least = endpoint, n
+		}
+	}
+	lb.outstanding.get(best).Add(1)
+	return best
+}
+
+func (lb *LeastOutstanding) Done(endpoint string, _ time.Duration, _ error) {
+	lb.outstanding.done(endpoint)
+}
+
+// PowerOfTwoChoices 随机选两台worker，取在途请求较少的一台。比LeastOutstanding开销小，也不会让所有Sentinel同时涌向同一台worker
+type PowerOfTwoChoices struct {
+	outstanding outstandingCounter
+}
+
+func NewPowerOfTwoChoices() *PowerOfTwoChoices {
+	return new(PowerOfTwoChoices)
+}
+
+func (lb *PowerOfTwoChoices) Take(endpoints []string) string {
+	if len(endpoints) == 0 {
+		return ""
+	}
+	lb.outstanding.touch(endpoints)
+	best := endpoints[rand.Intn(len(endpoints))]
+	if len(endpoints) > 1 {
+		i := rand.Intn(len(endpoints) - 1)
+		if endpoints[i] == best {
+			i = len(endpoints) - 1
+		}
+		if other := endpoints[i]; lb.outstanding.get(other).Load() < lb.outstanding.get(best).Load() {
+			best = other
+		}
+	}
+	lb.outstanding.get(best).Add(1)
+	return best
+}
+
+func (lb *PowerOfTwoChoices) Done(endpoint string, _ time.Duration, _ error) {
+	lb.outstanding.done(endpoint)
+}
+
+// 一台worker的峰值EWMA延迟
+type ewmaState struct {
+	mu      sync.Mutex
+	cost    float64 // 纳秒
+	updated time.Time
+}
+
+// PeakEWMA 按 EWMA延迟 * (在途请求数 + 1) 选择代价最小的worker。延迟升高时立即采用新的延迟，降低时按时间衰减，
+// 变慢的worker很快就会少分到请求。还没有延迟数据的worker代价为0，会优先得到请求
+type PeakEWMA struct {
+	outstanding outstandingCounter
+	states      endpointStates[ewmaState]
+}
+
+func NewPeakEWMA() *PeakEWMA {
+	return new(PeakEWMA)
+}
+
+func (lb *PeakEWMA) state(endpoint string) *ewmaState {
+	return lb.states.get(endpoint)
+}
+
+func (lb *PeakEWMA) cost(endpoint string) float64 {
+	state := lb.state(endpoint)
+	state.mu.Lock()
+	cost := state.cost
+	state.mu.Unlock()
+	return cost * float64(lb.outstanding.get(endpoint).Load()+1)
+}
+
+func (lb *PeakEWMA) Take(endpoints []string) string {
+	if len(endpoints) == 0 {
+		return ""
+	}
+	lb.outstanding.touch(endpoints)
+	lb.states.touch(endpoints)
+	offset := rand.Intn(len(endpoints))
+	best := ""
+	least := math.Inf(1)
+	for i := range endpoints {
+		endpoint := endpoints[(offset+i)%len(endpoints)]
+		if cost := lb.cost(endpoint); cost < least {
+			best, least = endpoint, cost
+		}
+	}
+	lb.outstanding.get(best).Add(1)
+	return best
+}
+
+func (lb *PeakEWMA) Done(endpoint string, latency time.Duration, err error) {
+	lb.outstanding.done(endpoint)
+	if errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled { // 被调用方取消（例如对冲请求的另一方先返回）不代表worker变慢
+		return
+	}
+	if err != nil {
+		latency = max(latency, PeakEWMAPenalty)
+	}
+	state := lb.state(endpoint)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	now := time.Now()
+	if sample := float64(latency); sample > state.cost || state.updated.IsZero() {
+		state.cost = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(state.updated)) / float64(PeakEWMADecay))
+		state.cost = state.cost*w + sample*(1-w)
+	}
+	state.updated = now
+}
+
+// ConsistentHash 按key的一致性哈希选择worker，相同的检索落到同一台worker上以提高缓存命中率；
+// worker增减时只有相邻区间的key会改变归属。没有key时轮询
+type ConsistentHash struct {
+	fallback RoundRobin
+	mu       sync.Mutex
+	rings    map[string]*hashRing // worker列表 -> 哈希环，同一个Sentinel会交替为多个group选择worker
+}
+
+// 一组worker的哈希环
+type hashRing struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+func NewConsistentHash() *ConsistentHash {
+	return new(ConsistentHash)
+}
+
+func (lb *ConsistentHash) Take(endpoints []string) string {
+	return lb.fallback.Take(endpoints)
+}
+
+func (lb *ConsistentHash) TakeByKey(endpoints []string, key string) string {
+	if len(endpoints) == 0 {
+		return ""
+	}
+	ring := lb.hashRing(endpoints)
+	hash := farm.Hash32([]byte(key))
+	i, _ := slices.BinarySearch(ring.points, hash)
+	if i == len(ring.points) {
+		i = 0
+	}
+	return ring.owners[ring.points[i]]
+}
+
+func (lb *ConsistentHash) Done(string, time.Duration, error) {}
+
+// 按worker列表缓存哈希环。缓存的哈希环超过ConsistentHashRings个时全部丢弃，避免worker频繁变化时无限增长
+func (lb *ConsistentHash) hashRing(endpoints []string) *hashRing {
+	sorted := slices.Clone(endpoints)
+	slices.Sort(sorted)
+	members := strings.Join(sorted, ",")
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if ring, ok := lb.rings[members]; ok {
+		return ring
+	}
+	ring := &hashRing{
+		points: make([]uint32, 0, len(sorted)*ConsistentHashVN),
+		owners: make(map[uint32]string, len(sorted)*ConsistentHashVN),
+	}
+	for _, endpoint := range sorted {
+		for i := range ConsistentHashVN {
+			hash := farm.Hash32([]byte(endpoint + "#" + strconv.Itoa(i)))
+			if _, exists := ring.owners[hash]; !exists {
+				ring.points = append(ring.points, hash)
+				ring.owners[hash] = endpoint
+			}
+		}
+	}
+	slices.Sort(ring.points)
+	if lb.rings == nil || len(lb.rings) >= ConsistentHashRings {
+		lb.rings = make(map[string]*hashRing)
+	}
+	lb.rings[members] = ring
+	return ring
+}
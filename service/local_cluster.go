@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var (
+	LocalClusterBufferSize     = 1 << 20 // 每个内存连接的缓冲区大小
+	LocalClusterDocNumEstimate = 10000   // 每个worker预估的文档数量
+)
+
+// LocalCluster 在一个进程内启动 shards×replicas 个IndexServiceWorker，worker之间以及Sentinel与worker之间通过内存中的grpc连接（bufconn）通信，
+// 使用进程内的注册中心，不依赖etcd和网络。用于在go test或者单个进程中验证分片、主从复制和故障切换
+type LocalCluster struct {
+	Hub      *MemoryServiceHub
+	Sentinel *Sentinel
+
+	shards       int
+	replicas     int
+	replication  bool
+	loadBalancer LoadBalancer
+
+	mu    sync.Mutex
+	nodes map[string]*localNode // endpoint -> worker
+	term  uint64                // 最近一次指定主节点的任期，所有分片共用，相当于etcd的revision
+}
+
+type localNode struct {
+	group    string
+	endpoint string
+	worker   *IndexServiceWorker
+	listener *bufconn.Listener
+	server   *grpc.Server // 停止后为nil
+}
+
+// NewLocalCluster 创建有shards个分片、每个分片replicas个worker的本地集群，需要调用Start启动
+func NewLocalCluster(shards, replicas int) *LocalCluster {
+	return &LocalCluster{
+		shards:       shards,
+		replicas:     replicas,
+		loadBalancer: new(RoundRobin),
+		nodes:        make(map[string]*localNode),
+	}
+}
+
+// WithReplication 开启主从复制：每个分片最早启动的worker为主节点，其他worker跟随主节点；主节点停止后由下一个worker接替
+func (c *LocalCluster) WithReplication(enabled bool) *LocalCluster {
+	c.replication = enabled
+	return c
+}
+
+// WithLoadBalancer 设置Sentinel在同一个分片的worker中选择的算法，默认轮询
+func (c *LocalCluster) WithLoadBalancer(loadBalancer LoadBalancer) *LocalCluster {
+	c.loadBalancer = loadBalancer
+	return c
+}
+
+// Endpoint 返回worker在注册中心中的地址，只能通过本地集群的内存连接访问
+func (c *LocalCluster) Endpoint(shard, replica int) string {
+	return fmt.Sprintf("local-%d-%d", shard, replica)
+}
+
+// Worker 返回第shard个分片的第replica个worker
+func (c *LocalCluster) Worker(shard, replica int) *IndexServiceWorker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if node := c.nodes[c.Endpoint(shard, replica)]; node != nil {
+		return node.worker
+	}
+	return nil
+}
+
+// Primary 返回分片当前主节点的编号，没有开启主从复制或者所有worker都已停止时返回-1
+func (c *LocalCluster) Primary(shard int) int {
+	primary := c.Hub.GetPrimaryEndpoint(fmt.Sprintf("group-%d", shard))
+	for replica := range c.replicas {
+		if c.Endpoint(shard, replica) == primary {
+			return replica
+		}
+	}
+	return -1
+}
+
+// Start 创建并启动所有worker，以及前端的Sentinel
+func (c *LocalCluster) Start() error {
+	if c.shards <= 0 || c.replicas <= 0 {
+		return fmt.Errorf("invalid shards %d or replicas %d, should be positive", c.shards, c.replicas)
+	}
+	c.Hub = NewMemoryServiceHub(c.shards, c.replication, c.loadBalancer)
+	for shard := range c.shards {
+		for replica := range c.replicas {
+			indexer := new(Indexer)
+			if err := indexer.Init(LocalClusterDocNumEstimate, kvdb.MEMORY, ""); err != nil {
+				_ = c.Close()
+				return err
+			}
+			node := &localNode{
+				group:    fmt.Sprintf("group-%d", shard),
+				endpoint: c.Endpoint(shard, replica),
+				worker:   &IndexServiceWorker{Indexer: indexer},
+			}
+			c.mu.Lock()
+			c.nodes[node.endpoint] = node
+			c.mu.Unlock()
+			c.start(node)
+		}
+	}
+	c.Sentinel = NewSentinelWithHub(c.Hub).WithDialer(c.dial)
+	util.Log.Printf("local cluster started: %d shards x %d replicas, replication %t", c.shards, c.replicas, c.replication)
+	return nil
+}
+
+// StopWorker 模拟worker宕机：停止grpc服务并从注册中心删除，保留索引数据。停止的是主节点时由同分片的下一个worker接替
+func (c *LocalCluster) StopWorker(shard, replica int) error {
+	node, err := c.node(shard, replica)
+	if err != nil {
+		return err
+	}
+	if node.server == nil {
+		return fmt.Errorf("worker %s is already stopped", node.endpoint)
+	}
+	wasPrimary := c.Hub.GetPrimaryEndpoint(node.group) == node.endpoint
+	_ = c.Hub.UnRegister(node.group, node.endpoint)
+	c.mu.Lock()
+	server := node.server
+	node.server = nil
+	c.mu.Unlock()
+	server.Stop()
+	if node.worker.Replicator != nil {
+		_ = node.worker.Replicator.Close()
+	}
+	util.Log.Printf("local worker %s stopped", node.endpoint)
+
+	if wasPrimary {
+		if primary := c.Hub.GetPrimaryEndpoint(node.group); len(primary) > 0 {
+			c.mu.Lock()
+			next := c.nodes[primary]
+			c.mu.Unlock()
+			next.worker.Replicator.Promote(c.nextTerm())
+			util.Log.Printf("%s fails over from %s to %s", node.group, node.endpoint, primary)
+		}
+	}
+	return nil
+}
+
+// StartWorker 重新启动停止的worker，模拟进程重启后保留了本地数据。开启主从复制时重新注册到分片的末尾，作为从节点追赶主节点
+func (c *LocalCluster) StartWorker(shard, replica int) error {
+	node, err := c.node(shard, replica)
+	if err != nil {
+		return err
+	}
+	if node.server != nil {
+		return fmt.Errorf("worker %s is already running", node.endpoint)
+	}
+	c.start(node)
+	return nil
+}
+
+func (c *LocalCluster) node(shard, replica int) (*localNode, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node := c.nodes[c.Endpoint(shard, replica)]
+	if node == nil {
+		return nil, fmt.Errorf("worker %d of shard %d not found", replica, shard)
+	}
+	return node, nil
+}
+
+func (c *LocalCluster) start(node *localNode) {
+	// Replicator关闭后不能再启动，每次启动创建新的，从已有的数据开始追赶
+	if c.replication {
+		node.worker.Replicator = NewReplicator(node.worker.Indexer, "").WithDialer(c.dial)
+	}
+	listener := bufconn.Listen(LocalClusterBufferSize)
+	server := grpc.NewServer()
+	RegisterIndexServiceServer(server, node.worker)
+	go server.Serve(listener)
+	c.mu.Lock()
+	node.listener, node.server = listener, server
+	c.mu.Unlock()
+
+	_ = c.Hub.Register(node.group, node.endpoint)
+	if c.replication {
+		if c.Hub.GetPrimaryEndpoint(node.group) == node.endpoint {
+			node.worker.Replicator.Promote(c.nextTerm())
+		}
+		node.worker.Replicator.Follow(c.Hub, node.group, node.endpoint)
+	}
+}
+
+func (c *LocalCluster) nextTerm() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.term++
+	return c.term
+}
+
+// dial 通过内存连接访问worker，worker停止时与连接被拒绝一样返回错误
+func (c *LocalCluster) dial(ctx context.Context, endpoint string) (net.Conn, error) {
+	c.mu.Lock()
+	node := c.nodes[endpoint]
+	var listener *bufconn.Listener
+	if node != nil && node.server != nil {
+		listener = node.listener
+	}
+	c.mu.Unlock()
+	if listener == nil {
+		return nil, fmt.Errorf("worker %s is not running", endpoint)
+	}
+	return listener.DialContext(ctx)
+}
+
+// Close 关闭Sentinel和所有worker
+func (c *LocalCluster) Close() error {
+	var errs []error
+	if c.Sentinel != nil {
+		errs = append(errs, c.Sentinel.Close())
+	}
+	c.mu.Lock()
+	nodes := make([]*localNode, 0, len(c.nodes))
+	for _, node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	c.mu.Unlock()
+	for _, node := range nodes {
+		c.mu.Lock()
+		server := node.server
+		node.server = nil
+		c.mu.Unlock()
+		if server != nil {
+			server.Stop()
+		}
+		errs = append(errs, node.worker.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+)
+
+var ErrMaintenanceUnsupported = errors.New("forward index does not support maintenance")
+
+// StartMaintenance 启动正排索引的定期维护：每隔interval维护一次，或者数据比上次维护后增长超过sizeThreshold字节时提前维护
+func (indexer *Indexer) StartMaintenance(interval time.Duration, sizeThreshold int64) {
+	if indexer.maintenance == nil {
+		return
+	}
+	indexer.maintenance.WithInterval(interval).WithSizeThreshold(sizeThreshold).Start()
+}
+
+// Maintain 立即维护一次正排索引，回收磁盘空间
+func (indexer *Indexer) Maintain() (*kvdb.MaintenanceReport, error) {
+	if indexer.maintenance == nil {
+		return nil, ErrMaintenanceUnsupported
+	}
+	return indexer.maintenance.RunNow()
+}
+
+// ParseMaintenanceConfig 从init.yml的index配置中读取维护间隔（maintenance-interval，单位分钟）和触发维护的数据增长量（maintenance-size-threshold，单位MB）
+func ParseMaintenanceConfig(indexConfig map[string]any) (time.Duration, int64) {
+	interval, _ := indexConfig["maintenance-interval"].(int)
+	threshold, _ := indexConfig["maintenance-size-threshold"].(int)
+	return time.Duration(interval) * time.Minute, int64(threshold) << 20
+}
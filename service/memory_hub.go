@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryServiceHub 进程内的注册中心，用于本地集群和测试。worker按注册顺序排列，开启选主时每个group最早注册的worker为主节点，
+// 与etcd选主的规则一致；主节点注销后由下一个worker接替
+type MemoryServiceHub struct {
+	mu           sync.RWMutex
+	groups       map[string][]string
+	electPrimary bool
+	shardMap     *ShardMap
+	loadBalancer LoadBalancer
+}
+
+// NewMemoryServiceHub shards为分片数，electPrimary为true时为每个group指定主节点
+func NewMemoryServiceHub(shards int, electPrimary bool, loadBalancer LoadBalancer) *MemoryServiceHub {
+	return &MemoryServiceHub{
+		groups:       make(map[string][]string),
+		electPrimary: electPrimary,
+		shardMap:     NewShardMap(shards, 0),
+		loadBalancer: loadBalancer,
+	}
+}
+
+func (hub *MemoryServiceHub) Register(group, endpoint string) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if !slices.Contains(hub.groups[group], endpoint) {
+		hub.groups[group] = append(hub.groups[group], endpoint)
+	}
+	return nil
+}
+
+func (hub *MemoryServiceHub) UnRegister(group, endpoint string) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.groups[group] = slices.DeleteFunc(hub.groups[group], func(e string) bool { return e == endpoint })
+	return nil
+}
+
+func (hub *MemoryServiceHub) GetServiceEndpoints(group string) []string {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	return slices.Clone(hub.groups[group])
+}
+
+// 根据负载均衡，从众多endpoint中选择一个。调用方不报告请求结果，需要报告结果时使用GetLoadBalancer
+func (hub *MemoryServiceHub) GetServiceEndpoint(group string) string {
+	endpoint := hub.loadBalancer.Take(hub.GetServiceEndpoints(group))
+	if len(endpoint) > 0 {
+		hub.loadBalancer.Done(endpoint, 0, context.Canceled)
+	}
+	return endpoint
+}
+
+// GetPrimaryEndpoint 开启选主时返回group中最早注册的worker
+func (hub *MemoryServiceHub) GetPrimaryEndpoint(group string) string {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	if !hub.electPrimary || len(hub.groups[group]) == 0 {
+		return ""
+	}
+	return hub.groups[group][0]
+}
+
+func (hub *MemoryServiceHub) GetLoadBalancer() LoadBalancer {
+	return hub.loadBalancer
+}
+
+func (hub *MemoryServiceHub) GetShardMap() *ShardMap {
+	return hub.shardMap
+}
+
+func (hub *MemoryServiceHub) Close() {}
@@ -0,0 +1,682 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+	etcdv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ReplicationLogSize        = 100000           // 内存中保留的最近操作数，落后更多的从节点无法通过FetchOps追赶
+	ReplicationSeqReserve     = uint64(1000)     // 主节点每次预留并持久化的序号数，异常退出后从预留的上限继续分配，保证序号不重复
+	ReplicationSaveInterval   = time.Second      // 从节点持久化已应用序号的间隔
+	ReplicationRetryInterval  = 1 * time.Second  // 选主或连接主节点失败后的重试间隔
+	ReplicationRecoverTimeout = 30 * time.Minute // 从其他节点的快照恢复的超时时间
+)
+
+// ErrNotPrimary 开启主从复制后，写请求只能发给所在group的主节点
+var ErrNotPrimary = errors.New("worker is not the primary of its group")
+
+// 持久化到正排索引旁边的文件中。Applied是已应用到索引的最大序号，AppliedTerm是该序号对应操作的任期，
+// Term是见过的最大任期，Reserved是主节点已预留的序号上限
+type replicationState struct {
+	Applied     uint64
+	AppliedTerm uint64
+	Term        uint64
+	Reserved    uint64
+}
+
+// Replicator 主从复制。每个group通过etcd选出一个主节点，写请求只发给主节点，主节点为每次写入分配连续递增的序号并追加到操作日志；
+// 从节点通过FetchOps从主节点拉取已应用序号之后的操作，按序号顺序应用到本地索引。
+// 操作记录的是写入后文档的完整状态，从节点异常退出后从持久化的序号重新拉取，重复应用不影响结果。
+// 每次选主任期递增，日志中的位置由(任期, 序号)确定：故障切换后新主节点会重新分配旧主节点用过的序号，
+// 从节点的位置与主节点的日志不一致时不能继续追赶，需要从主节点的快照恢复。
+// 复制是异步的：主节点应用写入后就返回给客户端，不等待从节点，主节点宕机时还没有被拉取的操作会丢失
+type Replicator struct {
+	indexer   *Indexer
+	statePath string // 为空时不持久化序号
+
+	writeMu  sync.Mutex // 主节点的写入和从节点的应用都是串行的，保证序号的顺序就是写入索引的顺序
+	primary  atomic.Bool
+	joined   atomic.Bool // 是否已经加入复制（选主、跟随或者从其他节点追赶），加入之前独立运行，相当于主节点
+	term     uint64      // 见过的最大任期，主节点用它给新的操作编号，受writeMu保护
+	reserved uint64      // 受writeMu保护
+	saved    uint64      // 上次持久化的已应用序号，受writeMu保护
+
+	mu          sync.Mutex // 保护下面的操作日志
+	applied     uint64     // 最后一条操作的序号
+	appliedTerm uint64     // 最后一条操作的任期
+	base        uint64     // ops中第一条操作之前的序号，序号不小于base的从节点才能从日志中追赶
+	baseTerm    uint64     // 序号base对应操作的任期
+	ops         []*ReplicationOp
+	notify      chan struct{} // 有新操作时关闭并替换，用于唤醒FetchOps
+
+	ctx          context.Context
+	cancel       context.CancelFunc
+	followCancel context.CancelFunc // 受mu保护
+	wg           sync.WaitGroup
+	dialer       Dialer // 为nil时通过网络连接主节点
+}
+
+// NewReplicator statePath为已应用序号的持久化文件，为空时重启后从0开始追赶
+func NewReplicator(indexer *Indexer, statePath string) *Replicator {
+	r := &Replicator{
+		indexer:   indexer,
+		statePath: statePath,
+		notify:    make(chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r
+}
+
+// Load 读取持久化的序号，需要在Start和处理请求之前调用
+func (r *Replicator) Load() error {
+	if len(r.statePath) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(r.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var state replicationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid replication state file %s: %w", r.statePath, err)
+	}
+	r.applied, r.base, r.saved, r.reserved = state.Applied, state.Applied, state.Applied, state.Reserved
+	r.appliedTerm, r.baseTerm, r.term = state.AppliedTerm, state.AppliedTerm, max(state.Term, state.AppliedTerm)
+	return nil
+}
+
+func (r *Replicator) save(state replicationState) error {
+	if len(r.statePath) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := r.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.statePath)
+}
+
+// IsPrimary 当前是否为所在group的主节点
+func (r *Replicator) IsPrimary() bool {
+	return r.primary.Load()
+}
+
+// AppliedSeq 已应用到本地索引的最大序号
+func (r *Replicator) AppliedSeq() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.applied
+}
+
+// AppliedTerm 已应用的最大序号对应操作的任期
+func (r *Replicator) AppliedTerm() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.appliedTerm
+}
+
+// 已应用的位置(序号, 任期)
+func (r *Replicator) position() (uint64, uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.applied, r.appliedTerm
+}
+
+// Promote 以任期term成为主节点，此后接受写请求并从已应用的序号继续分配。term需要大于group内之前所有主节点的任期，
+// etcd选主时使用选主key的revision；不大于本节点见过的任期时改用见过的任期加1。由选主协程调用，没有etcd时也可以手动指定
+func (r *Replicator) Promote(term uint64) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if term <= r.term {
+		term = r.term + 1
+	}
+	r.term = term
+	r.mu.Lock()
+	if r.followCancel != nil {
+		r.followCancel()
+	}
+	// 上次作为主节点异常退出时，预留范围内的序号可能已经发给了从节点，跳过它们避免重复
+	if r.reserved > r.applied {
+		util.Log.Printf("skip replication seq %d-%d reserved before last crash", r.applied+1, r.reserved)
+		r.applied, r.base, r.ops = r.reserved, r.reserved, nil
+		r.appliedTerm, r.baseTerm = term, term
+	}
+	r.mu.Unlock()
+	r.primary.Store(true)
+}
+
+// 失去主节点身份后不再接受写请求。预留但没有分配出去的序号可以释放掉
+func (r *Replicator) demote() {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.primary.Store(false)
+	r.reserved = r.AppliedSeq()
+}
+
+// Write 在主节点上执行写操作fn，fn成功后把docIds写入后的状态作为操作追加到日志，fn失败时不分配序号。
+// 已加入复制但不是主节点时返回ErrNotPrimary；没有注册到集群（例如单独启动或者嵌入到其他进程中）的worker不参与选主，直接写入
+func (r *Replicator) Write(fn func() error, docIds ...string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if !r.IsPrimary() && r.joined.Load() {
+		return ErrNotPrimary
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	ops := make([]*ReplicationOp, 0, len(docIds))
+	for _, docId := range docIds {
+		if docId = strings.TrimSpace(docId); len(docId) > 0 {
+			ops = append(ops, &ReplicationOp{DocId: docId, Doc: r.indexer.getDoc(docId), Term: r.term})
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	next := r.applied + uint64(len(ops))
+	r.mu.Unlock()
+	if next > r.reserved {
+		applied, appliedTerm := r.position()
+		if saveErr := r.save(replicationState{Applied: applied, AppliedTerm: appliedTerm, Term: r.term, Reserved: next + ReplicationSeqReserve}); saveErr != nil {
+			// 预留失败时仍然复制，只是异常退出后可能复用序号
+			util.Log.Printf("reserve replication seq failed: %v", saveErr)
+		} else {
+			r.reserved = next + ReplicationSeqReserve
+		}
+	}
+	r.mu.Lock()
+	for _, op := range ops {
+		r.applied++
+		op.Seq = r.applied
+	}
+	r.appliedTerm = r.term
+	r.appendLog(ops...)
+	r.mu.Unlock()
+	return nil
+}
+
+// Reset 在主节点上执行替换整个索引的操作fn（例如从快照恢复），这类操作无法按文档记录到日志。
+// 无论fn是否成功都清空操作日志并跳过一个序号，从节点拉取时收到OutOfRange，从主节点的快照重新恢复。不是主节点时返回ErrNotPrimary
+func (r *Replicator) Reset(fn func() error) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if !r.IsPrimary() && r.joined.Load() {
+		return ErrNotPrimary
+	}
+	err := fn()
+
+	r.mu.Lock()
+	r.applied++
+	r.base, r.ops = r.applied, nil
+	r.appliedTerm, r.baseTerm = r.term, r.term
+	applied := r.applied
+	close(r.notify)
+	r.notify = make(chan struct{})
+	r.mu.Unlock()
+	if applied > r.reserved {
+		if saveErr := r.save(replicationState{Applied: applied, AppliedTerm: r.term, Term: r.term, Reserved: applied + ReplicationSeqReserve}); saveErr != nil {
+			util.Log.Printf("reserve replication seq failed: %v", saveErr)
+		} else {
+			r.reserved = applied + ReplicationSeqReserve
+		}
+	}
+	util.Log.Printf("replication log reset at seq %d, replicas will recover from snapshot", applied)
+	return err
+}
+
+// 调用方需持有mu
+func (r *Replicator) appendLog(ops ...*ReplicationOp) {
+	r.ops = append(r.ops, ops...)
+	if len(r.ops) > 2*ReplicationLogSize {
+		drop := len(r.ops) - ReplicationLogSize
+		r.base, r.baseTerm = r.ops[drop-1].Seq, r.ops[drop-1].Term
+		r.ops = append([]*ReplicationOp(nil), r.ops[drop:]...)
+	}
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+// 序号seq对应操作的任期，调用方需持有mu，seq在[base, applied]之间
+func (r *Replicator) termAt(seq uint64) uint64 {
+	if seq == r.base {
+		return r.baseTerm
+	}
+	return r.ops[seq-r.base-1].Term
+}
+
+// 调用方需持有mu，since不小于base
+func (r *Replicator) opsSince(since uint64) []*ReplicationOp {
+	i := int(since - r.base)
+	if i >= len(r.ops) {
+		return nil
+	}
+	return r.ops[i:]
+}
+
+// Serve 把序号大于since的操作依次交给send，追上之后等待新的操作，直到ctx取消或send出错。term是从节点在序号since的任期。
+// 日志中已经没有since之后的全部操作，或者since对应操作的任期与term不一致（从节点跟随过其他主节点）时返回OutOfRange，从节点需要先从其他节点恢复数据
+func (r *Replicator) Serve(ctx context.Context, since, term uint64, send func(op *ReplicationOp) error) error {
+	r.mu.Lock()
+	if since >= r.base && since <= r.applied && r.termAt(since) != term {
+		current := r.termAt(since)
+		r.mu.Unlock()
+		return status.Errorf(codes.OutOfRange, "replication seq %d has term %d, but the replica applied it at term %d", since, current, term)
+	}
+	r.mu.Unlock()
+	return r.serve(ctx, since, 0, send)
+}
+
+// until非0时发送到序号until为止
+func (r *Replicator) serve(ctx context.Context, since, until uint64, send func(op *ReplicationOp) error) error {
+	for until == 0 || since < until {
+		r.mu.Lock()
+		if since < r.base || since > r.applied {
+			base, applied := r.base, r.applied
+			r.mu.Unlock()
+			return status.Errorf(codes.OutOfRange, "replication seq %d is not in the log range [%d, %d]", since, base, applied)
+		}
+		ops := r.opsSince(since)
+		notify := r.notify
+		r.mu.Unlock()
+
+		for _, op := range ops {
+			if until > 0 && op.Seq > until {
+				return nil
+			}
+			if err := send(op); err != nil {
+				return err
+			}
+			since = op.Seq
+		}
+		if len(ops) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-r.ctx.Done():
+				return status.Error(codes.Unavailable, "replicator closed")
+			case <-notify:
+			}
+		}
+	}
+	return nil
+}
+
+// CatchUp 从client所在的主节点拉取已应用序号之后的操作并按顺序应用，直到流结束、出错或ctx取消
+func (r *Replicator) CatchUp(ctx context.Context, client IndexServiceClient) error {
+	r.joined.Store(true)
+	since, term := r.position()
+	stream, err := client.FetchOps(ctx, &FetchOpsRequest{Since: since, Term: term})
+	if err != nil {
+		return err
+	}
+	for {
+		op, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := r.apply(op); err != nil {
+			return err
+		}
+	}
+}
+
+// 从节点按序号顺序应用一条操作
+func (r *Replicator) apply(op *ReplicationOp) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if r.IsPrimary() {
+		return ErrNotPrimary // 已经成为主节点，不再接受其他节点的操作
+	}
+	return r.applyLocked(op)
+}
+
+// 调用方需持有writeMu
+func (r *Replicator) applyLocked(op *ReplicationOp) error {
+	applied, appliedTerm := r.position()
+	if op.Seq <= applied {
+		// 更新的任期重新分配了已经应用过的序号，本地应用的是旧主节点没有复制出去的操作，只能从快照恢复
+		if op.Term > appliedTerm {
+			return status.Errorf(codes.OutOfRange, "replication seq %d is reissued at term %d, local log diverged at term %d", op.Seq, op.Term, appliedTerm)
+		}
+		return nil
+	}
+	if op.Seq != applied+1 {
+		return fmt.Errorf("replication seq gap: applied %d, received %d", applied, op.Seq)
+	}
+	if op.Term < appliedTerm {
+		return fmt.Errorf("replication op %d from stale term %d, applied term %d", op.Seq, op.Term, appliedTerm)
+	}
+	if err := r.indexer.putDocState(op.DocId, op.Doc); err != nil {
+		return fmt.Errorf("apply replication op %d: %w", op.Seq, err)
+	}
+	// 从节点也保留操作日志，成为主节点后其他从节点可以继续追赶
+	r.mu.Lock()
+	r.applied, r.appliedTerm = op.Seq, op.Term
+	r.appendLog(op)
+	r.mu.Unlock()
+	r.term = max(r.term, op.Term)
+	return nil
+}
+
+// ServeRecovery 为恢复中的节点导出全量快照，然后发送快照之后的操作，直到追上导出结束时已应用的序号。
+// 快照只包含正排索引，导出期间不阻塞写入，由恢复的节点重建倒排索引。
+// 快照开始前记下已应用的序号，导出期间的写入可能已经包含在快照中，再应用一次不影响结果
+func (r *Replicator) ServeRecovery(ctx context.Context, send func(chunk *RecoveryChunk) error) error {
+	seq, term := r.position()
+	writer := snapshotStreamWriter{send: func(chunk *SnapshotChunk) error {
+		return send(&RecoveryChunk{Snapshot: chunk.Data})
+	}}
+	if _, err := r.indexer.WriteSnapshot(writer, 0, false); err != nil {
+		return err
+	}
+	if err := send(&RecoveryChunk{SnapshotDone: true, Seq: seq, Term: term}); err != nil {
+		return err
+	}
+	return r.serve(ctx, seq, r.AppliedSeq(), func(op *ReplicationOp) error {
+		return send(&RecoveryChunk{Op: op})
+	})
+}
+
+// Recover 通过client所在节点的Recover接口恢复数据：用快照替换本地索引，再按顺序应用快照之后的操作。恢复期间不接受其他写入
+func (r *Replicator) Recover(ctx context.Context, client IndexServiceClient) error {
+	r.joined.Store(true)
+	stream, err := client.Recover(ctx, &RecoverRequest{})
+	if err != nil {
+		return err
+	}
+
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	if r.IsPrimary() {
+		return ErrNotPrimary
+	}
+
+	var seq, term uint64
+	done := false
+	recv := func() (*SnapshotChunk, error) {
+		if done {
+			return nil, io.EOF
+		}
+		chunk, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if chunk.SnapshotDone {
+			done, seq, term = true, chunk.Seq, chunk.Term
+			return nil, io.EOF
+		}
+		return &SnapshotChunk{Data: chunk.Snapshot}, nil
+	}
+	if _, err := r.indexer.ReadSnapshot(&snapshotStreamReader{recv: recv}); err != nil {
+		return err
+	}
+	for !done { // 快照的结尾可能还没有读到SnapshotDone
+		if _, err := recv(); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	// 本地原有的操作日志已经失效，从快照的序号重新开始
+	r.mu.Lock()
+	r.applied, r.base, r.ops = seq, seq, nil
+	r.appliedTerm, r.baseTerm = term, term
+	r.mu.Unlock()
+	r.reserved = seq
+	r.term = max(r.term, term)
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if chunk.Op == nil {
+			continue
+		}
+		if err := r.applyLocked(chunk.Op); err != nil {
+			return err
+		}
+	}
+
+	applied, appliedTerm := r.position()
+	if err := r.save(replicationState{Applied: applied, AppliedTerm: appliedTerm, Term: r.term, Reserved: applied}); err != nil {
+		return err
+	}
+	r.saved = applied
+	util.Log.Printf("recovered %d docs up to replication seq %d", r.indexer.Count(ctx), applied)
+	return nil
+}
+
+// putDocState 把文档设置为给定的状态，IntId和版本号与doc保持一致，用于复制和迁移。doc为nil或者已经过期时删除文档
+func (indexer *Indexer) putDocState(docId string, doc *types.Document) error {
+	lock := indexer.getLock(docId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	old := indexer.getDoc(docId)
+	if doc == nil || doc.Expired(time.Now().Unix()) {
+		if old != nil {
+			if err := indexer.appendWAL(&WALRecord{Op: WALDelete, DocId: docId}); err != nil {
+				return err
+			}
+		}
+		indexer.counter.deleted(indexer.deleteDoc(docId, old))
+		return nil
+	}
+
+	if err := indexer.observeVersion(doc.Version); err != nil {
+		return err
+	}
+	if err := indexer.appendWAL(&WALRecord{Op: WALPut, DocId: docId, Doc: doc}); err != nil {
+		return err
+	}
+	// 先覆盖正排索引，写入失败时旧文档保持不变；IntId可能与旧文档相同，先删除旧的倒排再写入
+	if err := indexer.putDoc(docId, doc); err != nil {
+		indexer.revertWAL(docId)
+		return err
+	}
+	indexer.removePostings(old)
+	indexer.reverseIndex.Add(*doc)
+	indexer.expireQueue.push(doc)
+	if old == nil {
+		indexer.counter.added(1)
+	}
+	return nil
+}
+
+// 选主用的etcd key前缀，不在group的服务注册前缀之下
+func primaryPrefix(group string) string {
+	return ServiceRootPath + indexName + "/primary/" + group
+}
+
+// WithDialer 设置连接主节点的方式，需要在Start或Follow之前调用
+func (r *Replicator) WithDialer(dialer Dialer) *Replicator {
+	r.dialer = dialer
+	return r
+}
+
+// Start 通过etcd参与group的选主，当选前跟随当前的主节点。self是本节点对外提供服务的地址，ttl是选主租约的有效期，单位秒
+func (r *Replicator) Start(hub *ServiceHub, group, self string, ttl int) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.campaign(hub.client, group, self, ttl)
+	}()
+	r.Follow(hub, group, self)
+}
+
+// Follow 不是主节点时跟随注册中心中group的主节点，并定期持久化已应用的序号。不参与选主，由调用方通过Promote指定主节点
+func (r *Replicator) Follow(hub IServiceHub, group, self string) {
+	r.joined.Store(true)
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		r.follow(hub, group, self)
+	}()
+	go func() {
+		defer r.wg.Done()
+		r.saveLoop()
+	}()
+}
+
+func (r *Replicator) campaign(client *etcdv3.Client, group, self string, ttl int) {
+	for r.ctx.Err() == nil {
+		session, err := concurrency.NewSession(client, concurrency.WithTTL(ttl), concurrency.WithContext(r.ctx))
+		if err != nil {
+			util.Log.Printf("create election session failed: %v", err)
+			r.sleep(ReplicationRetryInterval)
+			continue
+		}
+		election := concurrency.NewElection(session, primaryPrefix(group))
+		// 阻塞直到当选
+		if err := election.Campaign(r.ctx, self); err != nil {
+			session.Close()
+			r.sleep(ReplicationRetryInterval)
+			continue
+		}
+		// 选主key的revision在etcd中全局递增，作为任期
+		r.Promote(uint64(election.Rev()))
+		util.Log.Printf("%s becomes the primary of %s at seq %d, term %d", self, group, r.AppliedSeq(), r.currentTerm())
+
+		select {
+		case <-session.Done():
+			r.demote()
+			util.Log.Printf("%s lost the primary of %s", self, group)
+		case <-r.ctx.Done():
+			r.demote()
+			timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+			_ = election.Resign(timeoutCtx)
+			cancel()
+			session.Close()
+			return
+		}
+	}
+}
+
+// 不是主节点时持续从主节点拉取操作
+func (r *Replicator) follow(hub IServiceHub, group, self string) {
+	for r.ctx.Err() == nil {
+		primary := hub.GetPrimaryEndpoint(group)
+		if r.IsPrimary() || len(primary) == 0 || primary == self {
+			r.sleep(ReplicationRetryInterval)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(r.ctx)
+		r.mu.Lock()
+		r.followCancel = cancel
+		r.mu.Unlock()
+		err := r.followPrimary(ctx, primary)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			util.Log.Printf("replicate from primary %s failed at seq %d: %v", primary, r.AppliedSeq(), err)
+		}
+		r.sleep(ReplicationRetryInterval)
+	}
+}
+
+func (r *Replicator) followPrimary(ctx context.Context, primary string) error {
+	conn, err := dialWorker(primary, r.dialer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := NewIndexServiceClient(conn)
+	err = r.CatchUp(ctx, client)
+	// 落后太多或者与主节点的序号不一致，用主节点的快照恢复
+	if status.Code(err) == codes.OutOfRange {
+		util.Log.Printf("can not catch up with primary %s: %v, recover from its snapshot", primary, err)
+		ctx, cancel := context.WithTimeout(ctx, ReplicationRecoverTimeout)
+		defer cancel()
+		return r.Recover(ctx, client)
+	}
+	return err
+}
+
+// 定期持久化已应用的序号，异常退出后最多重复应用一个间隔内的操作
+func (r *Replicator) saveLoop() {
+	ticker := time.NewTicker(ReplicationSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.saveApplied(); err != nil {
+				util.Log.Printf("save replication state failed: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Replicator) saveApplied() error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	applied, appliedTerm := r.position()
+	if applied == r.saved {
+		return nil
+	}
+	if err := r.save(replicationState{Applied: applied, AppliedTerm: appliedTerm, Term: r.term, Reserved: r.reserved}); err != nil {
+		return err
+	}
+	r.saved = applied
+	return nil
+}
+
+func (r *Replicator) currentTerm() uint64 {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	return r.term
+}
+
+func (r *Replicator) sleep(d time.Duration) {
+	select {
+	case <-r.ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// Close 退出选主并停止复制。正常退出时序号都已分配出去，不需要再预留
+func (r *Replicator) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	applied, appliedTerm := r.position()
+	r.reserved = applied
+	return r.save(replicationState{Applied: applied, AppliedTerm: appliedTerm, Term: r.term, Reserved: applied})
+}
+
+// 把复制相关的错误转换成grpc状态码
+func replicationError(err error) error {
+	if errors.Is(err, ErrNotPrimary) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return versionError(err)
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+	etcdv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ReshardSettleDelay = 5 * time.Second // 修改分片映射后等待使用旧映射的请求结束，Sentinel每次请求都会重新读取映射
+	ReshardCopyPasses  = 3               // 暂停槽位的写入之前最多同步几轮，写入不频繁时一轮就能追平
+)
+
+// ErrReshardRunning 同一时间只能有一个协调者
+var ErrReshardRunning = errors.New("another shard coordinator is running")
+
+// ShardCoordinator 重新分片的协调者。规划好的槽位迁移记录在etcd的分片映射中，逐个按以下阶段执行：
+//  1. copying：写请求同时发给源group和目标group，协调者比较两边的版本号，把不一致的文档从源group同步到目标group
+//  2. switching：暂停该槽位的写入，最后同步一次，保证两边完全一致
+//  3. cleaning：槽位改为属于目标group，检索和写入都只使用目标group，协调者删除源group上的残留数据
+//
+// 每个阶段的切换都会写入etcd，协调者中断后调用Resume从记录的阶段继续
+type ShardCoordinator struct {
+	sentinel *Sentinel
+	client   *etcdv3.Client
+}
+
+func NewShardCoordinator(sentinel *Sentinel) (*ShardCoordinator, error) {
+	hub := sentinel.etcdHub()
+	if hub == nil {
+		return nil, errors.New("resharding requires the etcd registry")
+	}
+	return &ShardCoordinator{sentinel: sentinel, client: hub.client}, nil
+}
+
+// ShardMap 返回当前的分片映射
+func (c *ShardCoordinator) ShardMap() (*ShardMap, error) {
+	shardMap, _, err := LoadShardMap(c.client)
+	return shardMap, err
+}
+
+// AddGroup 把一部分槽位迁移到新的group，使各group的槽位数大致相同。新group需要已经在拓扑中声明并且有worker注册
+func (c *ShardCoordinator) AddGroup(group int) error {
+	topology, _, err := LoadTopology(c.client)
+	if err != nil {
+		return err
+	}
+	if index := topology.Index(indexName); index == nil || group >= index.Shards {
+		return fmt.Errorf("group-%d is not declared in the topology, raise the shard count with electricsearch topology first", group)
+	}
+	if len(c.sentinel.Hub.GetServiceEndpoints(fmt.Sprintf("group-%d", group))) == 0 {
+		return fmt.Errorf("group-%d has no worker", group)
+	}
+	return c.withLock(func() error {
+		if err := c.plan(func(shardMap *ShardMap) error { return shardMap.PlanAddGroup(group) }); err != nil {
+			return err
+		}
+		return c.run()
+	})
+}
+
+// RemoveGroup 把group的全部槽位迁移给其他group，完成后该group的worker可以下线
+func (c *ShardCoordinator) RemoveGroup(group int) error {
+	return c.withLock(func() error {
+		if err := c.plan(func(shardMap *ShardMap) error { return shardMap.PlanRemoveGroup(group) }); err != nil {
+			return err
+		}
+		return c.run()
+	})
+}
+
+// Resume 继续执行未完成的槽位迁移
+func (c *ShardCoordinator) Resume() error {
+	return c.withLock(c.run)
+}
+
+func (c *ShardCoordinator) withLock(fn func() error) error {
+	session, err := concurrency.NewSession(c.client)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	mutex := concurrency.NewMutex(session, ServiceRootPath+indexName+"/reshard-lock")
+	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+	defer cancel()
+	if err := mutex.TryLock(timeoutCtx); errors.Is(err, concurrency.ErrLocked) {
+		return ErrReshardRunning
+	} else if err != nil {
+		return err
+	}
+	defer func() {
+		timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+		defer cancel()
+		_ = mutex.Unlock(timeoutCtx)
+	}()
+	return fn()
+}
+
+func (c *ShardCoordinator) plan(fn func(shardMap *ShardMap) error) error {
+	shardMap, revision, err := LoadShardMap(c.client)
+	if err != nil {
+		return err
+	}
+	if shardMap == nil {
+		if c.sentinel.getShardMap() == nil {
+			return fmt.Errorf("there is no group can be used")
+		}
+		if shardMap, revision, err = LoadShardMap(c.client); err != nil {
+			return err
+		}
+	}
+	shardMap = shardMap.Clone()
+	if err := fn(shardMap); err != nil {
+		return err
+	}
+	if err := c.save(shardMap, revision); err != nil {
+		return err
+	}
+	util.Log.Printf("planned %d slot migrations, epoch %d", len(shardMap.Migrations), shardMap.Epoch)
+	return nil
+}
+
+// 保存分片映射，然后等待使用旧映射的请求结束
+func (c *ShardCoordinator) save(shardMap *ShardMap, revision int64) error {
+	if err := SaveShardMap(c.client, shardMap, revision); err != nil {
+		return err
+	}
+	time.Sleep(ReshardSettleDelay)
+	return nil
+}
+
+func (c *ShardCoordinator) run() error {
+	for {
+		shardMap, revision, err := LoadShardMap(c.client)
+		if err != nil {
+			return err
+		}
+		if shardMap == nil || len(shardMap.Migrations) == 0 {
+			return nil
+		}
+		migration := shardMap.Migrations[0]
+		request := &SlotRequest{Slots: uint32(len(shardMap.Slots)), Slot: uint32(migration.Slot), Seed: shardMap.Seed}
+		next := shardMap.Clone()
+		next.Epoch++
+
+		switch migration.State {
+		case MigrationCopying:
+			for pass := 0; pass < ReshardCopyPasses; pass++ {
+				changed, err := c.syncSlot(migration, request)
+				if err != nil {
+					return err
+				}
+				if changed == 0 {
+					break
+				}
+			}
+			next.Migrations[0].State = MigrationSwitching
+		case MigrationSwitching:
+			if _, err := c.syncSlot(migration, request); err != nil {
+				return err
+			}
+			next.Slots[migration.Slot] = migration.To
+			next.Migrations[0].State = MigrationCleaning
+		case MigrationCleaning:
+			n, err := c.dropSlot(migration.From, request)
+			if err != nil {
+				return err
+			}
+			util.Log.Printf("dropped %d docs of slot %d from group-%d", n, migration.Slot, migration.From)
+			next.Migrations = slices.Delete(next.Migrations, 0, 1)
+		default:
+			return fmt.Errorf("unknown state %q of slot %d", migration.State, migration.Slot)
+		}
+
+		if err := c.save(next, revision); err != nil {
+			return err
+		}
+		util.Log.Printf("slot %d from group-%d to group-%d: %s -> %s, epoch %d", migration.Slot, migration.From, migration.To, migration.State, stateOf(next, migration.Slot), next.Epoch)
+	}
+}
+
+func stateOf(shardMap *ShardMap, slot int) string {
+	if migration := shardMap.migration(slot); migration != nil {
+		return migration.State
+	}
+	return "done"
+}
+
+// syncSlot 比较两边的版本号，把源group上新增或者修改过的文档写入目标group，删除目标group上多出来的文档，返回同步的文档数。
+// 先读目标group再读源group，两次读取之间双写的文档在源group上一定能读到。
+// 只有目标group的版本号保存在内存中，源group的文档边读边分批写入目标group
+func (c *ShardCoordinator) syncSlot(migration SlotMigration, request *SlotRequest) (int, error) {
+	versionRequest := *request
+	versionRequest.VersionOnly = true
+	targetVersions := make(map[string]uint64)
+	err := c.scanSlot(migration.To, &versionRequest, func(doc *types.Document) error {
+		targetVersions[doc.Id] = doc.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sourceDocs, imported := 0, 0
+	changed := make([]*types.Document, 0, SlotScanBatch)
+	flush := func() error {
+		if err := c.importDocs(migration.To, changed); err != nil {
+			return err
+		}
+		imported += len(changed)
+		changed = changed[:0]
+		return nil
+	}
+	err = c.scanSlot(migration.From, request, func(doc *types.Document) error {
+		sourceDocs++
+		version, ok := targetVersions[doc.Id]
+		delete(targetVersions, doc.Id)
+		if ok && version == doc.Version {
+			return nil
+		}
+		if changed = append(changed, doc); len(changed) < SlotScanBatch {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := c.deleteDocs(migration.To, targetVersions); err != nil {
+		return 0, err
+	}
+	util.Log.Printf("sync slot %d from group-%d to group-%d: %d source docs, %d imported, %d deleted",
+		migration.Slot, migration.From, migration.To, sourceDocs, imported, len(targetVersions))
+	return imported + len(targetVersions), nil
+}
+
+// 读取时优先使用主节点，从节点可能还没有追上
+func (c *ShardCoordinator) readClient(group int) (IndexServiceClient, error) {
+	endpoints, _ := c.sentinel.writeEndpoints(fmt.Sprintf("group-%d", group))
+	for _, endpoint := range endpoints {
+		if conn := c.sentinel.GetGrpcConn(endpoint); conn != nil {
+			return NewIndexServiceClient(conn), nil
+		}
+	}
+	return nil, fmt.Errorf("group-%d has no available worker", group)
+}
+
+// 写入发给主节点，没有主节点时发给所有worker
+func (c *ShardCoordinator) writeClients(group int) ([]IndexServiceClient, error) {
+	endpoints, _ := c.sentinel.writeEndpoints(fmt.Sprintf("group-%d", group))
+	clients := make([]IndexServiceClient, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		conn := c.sentinel.GetGrpcConn(endpoint)
+		if conn == nil {
+			return nil, fmt.Errorf("failed to get connection for endpoint %s", endpoint)
+		}
+		clients = append(clients, NewIndexServiceClient(conn))
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("group-%d has no worker", group)
+	}
+	return clients, nil
+}
+
+// 把group中属于槽位的文档依次交给fn，fn返回error时停止读取
+func (c *ShardCoordinator) scanSlot(group int, request *SlotRequest, fn func(doc *types.Document) error) error {
+	client, err := c.readClient(group)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.ScanSlot(ctx, request)
+	if err != nil {
+		return err
+	}
+	for {
+		doc, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("scan slot %d of group-%d: %w", request.Slot, group, err)
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *ShardCoordinator) importDocs(group int, docs []*types.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	clients, err := c.writeClients(group)
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		stream, err := client.ImportDocs(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if err := stream.Send(doc); err != nil {
+				if err == io.EOF { // 服务端提前结束了流，真正的错误需要通过CloseAndRecv获取
+					break
+				}
+				return err
+			}
+		}
+		response, err := stream.CloseAndRecv()
+		if err != nil {
+			return fmt.Errorf("import docs to group-%d: %w", group, err)
+		}
+		if len(response.Errors) > 0 {
+			return fmt.Errorf("import doc %s to group-%d: %s", response.Errors[0].DocId, group, response.Errors[0].Reason)
+		}
+	}
+	return nil
+}
+
+// 按版本号删除，删除前又被写入的文档会因为版本冲突而保留
+func (c *ShardCoordinator) deleteDocs(group int, versions map[string]uint64) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	clients, err := c.writeClients(group)
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		for docId, version := range versions {
+			_, err := client.DeleteDoc(context.Background(), &DocId{DocId: docId, ExpectedVersion: version})
+			if err != nil && status.Code(err) != codes.FailedPrecondition {
+				return fmt.Errorf("delete doc %s from group-%d: %w", docId, group, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *ShardCoordinator) dropSlot(group int, request *SlotRequest) (int, error) {
+	clients, err := c.writeClients(group)
+	if err != nil {
+		return 0, err
+	}
+	var n uint32
+	for _, client := range clients {
+		affected, err := client.DropSlot(context.Background(), request)
+		if err != nil {
+			return 0, fmt.Errorf("drop slot %d of group-%d: %w", request.Slot, group, err)
+		}
+		n = max(n, affected.Count)
+	}
+	return int(n), nil
+}
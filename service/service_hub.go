@@ -1,8 +1,8 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,10 +16,11 @@ import (
 
 // 服务注册中心
 type ServiceHub struct {
-	client       *etcdv3.Client
-	heartRate    int64    // server 每间隔heartRate向etcd发送心跳，同时续约
-	watched      sync.Map // 存储已经监听过的service
-	loadBalancer LoadBalancer
+	client        *etcdv3.Client
+	heartRate     int64    // server 每间隔heartRate向etcd发送心跳，同时续约
+	watched       sync.Map // 存储已经监听过的service
+	registrations sync.Map // 注册的key -> 停止续约的context.CancelFunc
+	loadBalancer  LoadBalancer
 }
 
 // 使用单例模式创建ServiceHub，包外需通过GetServiceHub获取实例
@@ -29,47 +30,76 @@ var (
 
 func GetServiceHub(etcdEndpoints []string, heartRate int64) *ServiceHub {
 	if serviceHub == nil {
+		loadBalancer, err := configuredLoadBalancer()
+		if err != nil {
+			util.Log.Fatalf("invalid load-balancer: %v", err)
+		}
 		if etcdClient, err := etcd.GetEtcdClient(etcdEndpoints); err != nil {
 			util.Log.Fatalf("etcd client init failed: %v", err)
 		} else {
 			serviceHub = &ServiceHub{
 				client:       etcdClient,
 				heartRate:    heartRate,
-				loadBalancer: &RoundRobin{}, // TODO 将使用的负载均衡算法放到配置文件中
+				loadBalancer: loadBalancer,
 			}
 		}
 	}
 	return serviceHub
 }
 
-func (Hub *ServiceHub) Register(group, endpoint string, leaseID etcdv3.LeaseID) (etcdv3.LeaseID, error) {
+func serviceKey(group, endpoint string) string {
+	return ServiceRootPath + indexName + "/" + group + "/" + endpoint
+}
+
+// Register 把worker写入etcd，key绑定一个有效期为heartRate秒的租约，之后在后台按心跳续约直到UnRegister或者Close。
+// 租约过期（例如与etcd断开太久）时重新创建租约并注册
+func (Hub *ServiceHub) Register(group, endpoint string) error {
+	key := serviceKey(group, endpoint)
+	leaseID, err := Hub.putWithLease(key)
+	if err != nil {
+		util.Log.Printf("register service %s endpoint %s failed: %v", group, endpoint, err)
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if previous, loaded := Hub.registrations.Swap(key, cancel); loaded {
+		previous.(context.CancelFunc)()
+	}
+	go Hub.keepAlive(ctx, key, leaseID)
+	return nil
+}
+
+// 创建一个有效期为heartRate的租约（单位：秒），写入绑定该租约的key
+func (Hub *ServiceHub) putWithLease(key string) (etcdv3.LeaseID, error) {
 	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
 	defer cancel()
+	lease, err := Hub.client.Grant(timeoutCtx, Hub.heartRate)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := Hub.client.Put(timeoutCtx, key, "", etcdv3.WithLease(lease.ID)); err != nil {
+		return 0, err
+	}
+	return lease.ID, nil
+}
 
-	if leaseID <= 0 {
-		// 创建一个有效期为heartRate的租约（单位：秒）
-		if lease, err := Hub.client.Grant(timeoutCtx, Hub.heartRate); err != nil {
-			util.Log.Printf("create lease failed: %v", err)
-			return 0, err
-		} else {
-			keys := ServiceRootPath + indexName + "/" + group + "/" + endpoint
-			// 服务注册(向ETCD中写入一个key)
-			if _, err := Hub.client.Put(timeoutCtx, keys, "", etcdv3.WithLease(lease.ID)); err != nil {
-				util.Log.Printf("register service %s endpoint %s failed: %v", group, endpoint, err)
-				return leaseID, err
-			} else {
-				return leaseID, nil
+func (Hub *ServiceHub) keepAlive(ctx context.Context, key string, leaseID etcdv3.LeaseID) {
+	ticker := time.NewTicker(time.Duration(Hub.heartRate)*time.Second - 100*time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+		_, err := Hub.client.KeepAliveOnce(timeoutCtx, leaseID)
+		cancel()
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			if leaseID, err = Hub.putWithLease(key); err != nil {
+				util.Log.Printf("re-register %s failed: %v", key, err)
 			}
-		}
-	} else {
-		// 续租
-		if _, err := Hub.client.KeepAliveOnce(timeoutCtx, leaseID); !errors.Is(err, rpctypes.ErrLeaseNotFound) {
-			return Hub.Register(group, endpoint, leaseID)
 		} else if err != nil {
 			util.Log.Printf("keep lease %d failed: %v", leaseID, err)
-			return 0, err
-		} else {
-			return leaseID, nil
 		}
 	}
 }
@@ -79,7 +109,10 @@ func (Hub *ServiceHub) UnRegister(group, endpoint string) error {
 	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
 	defer cancel()
 
-	key := ServiceRootPath + indexName + "/" + group + "/" + endpoint
+	key := serviceKey(group, endpoint)
+	if cancel, loaded := Hub.registrations.LoadAndDelete(key); loaded {
+		cancel.(context.CancelFunc)()
+	}
 	if _, err := Hub.client.Delete(timeoutCtx, key); err != nil {
 		util.Log.Printf("unregister worker %s endpoint %s failed: %v", group, endpoint, err)
 		return err
@@ -111,129 +144,83 @@ func (Hub *ServiceHub) GetServiceEndpoints(group string) []string {
 	}
 }
 
-// 根据负载均衡，从众多endpoint中选择一个
+// 根据负载均衡，从众多endpoint中选择一个。调用方不报告请求结果，选择后立即结束跟踪，需要报告结果时使用GetLoadBalancer
 func (Hub *ServiceHub) GetServiceEndpoint(group string) string {
-	return Hub.loadBalancer.Take(Hub.GetServiceEndpoints(group))
+	endpoint := Hub.loadBalancer.Take(Hub.GetServiceEndpoints(group))
+	if len(endpoint) > 0 {
+		Hub.loadBalancer.Done(endpoint, 0, context.Canceled)
+	}
+	return endpoint
 }
 
-// 关闭etcd客户端连接
-func (Hub *ServiceHub) Close() {
-	_ = Hub.client.Close()
+// GetLoadBalancer 返回配置的负载均衡算法，调用方按请求自行选择worker并通过Done报告结果
+func (Hub *ServiceHub) GetLoadBalancer() LoadBalancer {
+	return Hub.loadBalancer
 }
 
-func (Hub *ServiceHub) addIndexGroup() int {
+// GetPrimaryEndpoint 返回group当前的主节点，没有开启主从复制或者正在选主时返回空字符串
+func (Hub *ServiceHub) GetPrimaryEndpoint(group string) string {
 	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
 	defer cancel()
 
-	// 获取分布式锁
-	lock, err := etcd.AcquireDistributedLock(Hub.client, ServiceRootPath+indexName+"/group-lock", 10, 100*time.Millisecond, 2)
+	// 与etcd选主的规则一致，创建时间最早的候选者为主节点
+	resp, err := Hub.client.Get(timeoutCtx, primaryPrefix(group)+"/", etcdv3.WithFirstCreate()...)
 	if err != nil {
-		panic(err)
+		util.Log.Printf("get primary of group %s failed: %v", group, err)
+		return ""
 	}
-	defer func() {
-		if err := lock.Release(); err != nil {
-			util.Log.Fatalf("failed to release lock: %v", err)
-		}
-	}()
-
-	key := ServiceRootPath + indexName + "/total-shards"
-	if res, err := Hub.client.Get(timeoutCtx, key, etcdv3.WithPrefix()); err == nil {
-		if res == nil {
-			return 0
-		}
-
-		var (
-			count int
-			err   error
-		)
-		if len(res.Kvs) > 0 {
-			value := res.Kvs[0].Value
-			count, err = strconv.Atoi(string(value))
-		}
-		if err != nil {
-			util.Log.Printf("failed to convert value to int: %v", err)
-			return 0
-		}
-		count++
-		_, err = Hub.client.Put(timeoutCtx, key, strconv.Itoa(count))
-		if err != nil {
-			util.Log.Printf("failed to put updated value to etcd: %v", err)
-			return 0
-		}
-		return count
+	if len(resp.Kvs) == 0 {
+		return ""
 	}
-	return 0
+	return string(resp.Kvs[0].Value)
 }
 
-func (Hub *ServiceHub) subIndexGroup() {
-	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
-	defer cancel()
-
-	// 获取分布式锁
-	lock, err := etcd.AcquireDistributedLock(Hub.client, ServiceRootPath+indexName+"/group-lock", 10, 100*time.Millisecond, 2)
-	if err != nil {
-		panic(err)
-	}
-	defer func() {
-		if err := lock.Release(); err != nil {
-			util.Log.Fatalf("failed to release lock: %v", err)
-		}
-	}()
+// 停止续约，关闭etcd客户端连接
+func (Hub *ServiceHub) Close() {
+	Hub.registrations.Range(func(key, cancel any) bool {
+		cancel.(context.CancelFunc)()
+		return true
+	})
+	_ = Hub.client.Close()
+}
 
-	key := ServiceRootPath + indexName + "/total-shards"
-	if res, err := Hub.client.Get(timeoutCtx, key, etcdv3.WithPrefix()); err == nil {
-		if res == nil {
-			return
-		}
-		value := res.Kvs[0].Value
-		count, err := strconv.Atoi(string(value))
+// GetShardMap 读取etcd中的分片映射。还没有分片映射时按拓扑中的分片数创建，与按 hash % 分组数量 路由的结果相同，
+// 此后修改拓扑中的分片数不再改变已有文档的路由，需要通过ShardCoordinator迁移槽位。没有可用的group时返回nil
+func (Hub *ServiceHub) GetShardMap() *ShardMap {
+	for range 3 {
+		shardMap, _, err := LoadShardMap(Hub.client)
 		if err != nil {
-			util.Log.Printf("failed to convert value to int: %v", err)
-			return
+			util.Log.Printf("load shard map failed: %s", err)
+			return nil
 		}
-		count--
-		if count <= 0 {
-			_, _ = Hub.client.Delete(timeoutCtx, key)
-			return
+		if shardMap != nil {
+			return shardMap
 		}
-		_, err = Hub.client.Put(timeoutCtx, key, strconv.Itoa(count))
-		if err != nil {
-			util.Log.Printf("failed to put updated value to etcd: %v", err)
-			return
+		groupCount := Hub.groupCount()
+		if groupCount == 0 {
+			return nil
+		}
+		shardMap = NewShardMap(groupCount, 0)
+		if err := SaveShardMap(Hub.client, shardMap, 0); err == nil {
+			util.Log.Printf("create shard map with %d groups", groupCount)
+			return shardMap
+		} else if !errors.Is(err, ErrShardMapChanged) {
+			util.Log.Printf("create shard map failed: %s", err)
+			return nil
 		}
-		return
 	}
+	return nil
 }
 
-func (Hub *ServiceHub) CountIndexGroup() int {
-	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
-	defer cancel()
-
-	// 获取分布式锁
-	lock, err := etcd.AcquireDistributedLock(Hub.client, ServiceRootPath+indexName+"/group-lock", 10, 100*time.Millisecond, 2)
+// groupCount 获取拓扑中声明的当前索引的分片数量
+func (Hub *ServiceHub) groupCount() int {
+	topology, _, err := LoadTopology(Hub.client)
 	if err != nil {
-		panic(err)
+		util.Log.Printf("load topology failed: %s", err)
+		return 0
 	}
-	defer func() {
-		if err := lock.Release(); err != nil {
-			util.Log.Fatalf("failed to release lock: %v", err)
-		}
-	}()
-
-	key := ServiceRootPath + indexName + "/total-shards"
-	if res, err := Hub.client.Get(timeoutCtx, key, etcdv3.WithPrefix()); err == nil {
-		if res == nil {
-			return 0
-		}
-		if res.Count > 0 {
-			value := res.Kvs[0].Value
-			count, err := strconv.Atoi(string(value))
-			if err != nil {
-				util.Log.Printf("failed to convert value to int: %v", err)
-				return 0
-			}
-			return count
-		}
+	if index := topology.Index(indexName); index != nil {
+		return index.Shards
 	}
 	return 0
 }
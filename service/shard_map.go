@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/WlayRay/ElectricSearch/util"
+	"github.com/dgryski/go-farm"
+	etcdv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrShardMapChanged = errors.New("shard map was modified concurrently")
+	ErrSlotMigrating   = errors.New("slot is switching to its new group, retry later")
+)
+
+// 重新分片时每个group至少拥有的槽位数，槽位不够时先拆分
+var ReshardMinSlotsPerGroup = 4
+
+// 槽位迁移的阶段
+const (
+	MigrationCopying   = "copying"   // 写请求先发给From再发给To，检索只用From；协调者把槽位的数据同步到To
+	MigrationSwitching = "switching" // 暂停槽位的写入，协调者做最后一次同步
+	MigrationCleaning  = "cleaning"  // 槽位已经属于To，协调者删除From上的残留数据
+)
+
+// SlotMigration 把一个槽位从From迁移到To
+type SlotMigration struct {
+	Slot  int
+	From  int
+	To    int
+	State string
+}
+
+// ShardMap 分片映射，保存在etcd中。文档按 hash(docId) % len(Slots) 落到槽位，Slots[i]是槽位i所属的group。
+// 槽位数翻倍时 hash % 2n % n == hash % n，每个槽位拆成两个而文档的归属不变，因此可以先细分槽位，再把部分槽位迁移到其他group。
+// 每次修改Epoch加1
+type ShardMap struct {
+	Epoch      uint64
+	Seed       uint32
+	Slots      []int
+	Migrations []SlotMigration
+}
+
+// NewShardMap 槽位数等于group数时，与按 hash % groupCount 路由的结果相同
+func NewShardMap(groupCount int, seed uint32) *ShardMap {
+	m := &ShardMap{Epoch: 1, Seed: seed, Slots: make([]int, groupCount)}
+	for i := range m.Slots {
+		m.Slots[i] = i
+	}
+	return m
+}
+
+func (m *ShardMap) Clone() *ShardMap {
+	return &ShardMap{
+		Epoch:      m.Epoch,
+		Seed:       m.Seed,
+		Slots:      slices.Clone(m.Slots),
+		Migrations: slices.Clone(m.Migrations),
+	}
+}
+
+// SlotOf 文档所在的槽位
+func (m *ShardMap) SlotOf(docId string) int {
+	return int(farm.Hash32WithSeed([]byte(docId), m.Seed) % uint32(len(m.Slots)))
+}
+
+// ReadGroup 检索时文档以哪个group上的数据为准
+func (m *ShardMap) ReadGroup(docId string) int {
+	return m.Slots[m.SlotOf(docId)]
+}
+
+func (m *ShardMap) migration(slot int) *SlotMigration {
+	for i := range m.Migrations {
+		if m.Migrations[i].Slot == slot {
+			return &m.Migrations[i]
+		}
+	}
+	return nil
+}
+
+// WriteGroups 写请求要发送的group，第一个是文档所属的group，槽位迁移中时第二个是迁移的目标group。
+// 槽位正在切换时返回ErrSlotMigrating
+func (m *ShardMap) WriteGroups(docId string) ([]int, error) {
+	slot := m.SlotOf(docId)
+	if migration := m.migration(slot); migration != nil {
+		switch migration.State {
+		case MigrationCopying:
+			return []int{migration.From, migration.To}, nil
+		case MigrationSwitching:
+			return nil, ErrSlotMigrating
+		}
+	}
+	return []int{m.Slots[slot]}, nil
+}
+
+// foreignSlots 迁移中不属于group、但group上可能有数据的槽位：复制中的目标group，或者清理中的源group
+func (m *ShardMap) foreignSlots(group int) []uint32 {
+	var slots []uint32
+	for _, migration := range m.Migrations {
+		if (migration.From == group || migration.To == group) && m.Slots[migration.Slot] != group {
+			slots = append(slots, uint32(migration.Slot))
+		}
+	}
+	return slots
+}
+
+// Groups 拥有槽位的全部group，从小到大排列
+func (m *ShardMap) Groups() []int {
+	groups := slices.Clone(m.Slots)
+	slices.Sort(groups)
+	return slices.Compact(groups)
+}
+
+// Split 每个槽位拆成两个，文档的归属不变
+func (m *ShardMap) Split() {
+	n := len(m.Slots)
+	m.Slots = append(m.Slots, m.Slots...)
+	for i := range m.Migrations {
+		// 迁移中的槽位拆开后两半都要迁移
+		half := m.Migrations[i]
+		half.Slot += n
+		m.Migrations = append(m.Migrations, half)
+	}
+	m.Epoch++
+}
+
+// 每个group拥有的槽位，不包括迁移中的槽位
+func (m *ShardMap) slotsOfGroups() map[int][]int {
+	owned := make(map[int][]int)
+	for slot, group := range m.Slots {
+		if m.migration(slot) == nil {
+			owned[group] = append(owned[group], slot)
+		}
+	}
+	return owned
+}
+
+// PlanAddGroup 规划把一部分槽位迁移到新的group，使各group的槽位数大致相同。槽位不够时先拆分。
+// 只修改映射中的迁移计划，数据由ShardCoordinator迁移
+func (m *ShardMap) PlanAddGroup(group int) error {
+	if len(m.Migrations) > 0 {
+		return fmt.Errorf("there are %d unfinished slot migrations", len(m.Migrations))
+	}
+	if slices.Contains(m.Slots, group) {
+		return fmt.Errorf("group-%d already owns slots", group)
+	}
+	groupCount := len(m.Groups()) + 1
+	for len(m.Slots) < ReshardMinSlotsPerGroup*groupCount {
+		m.Split()
+	}
+	owned := m.slotsOfGroups()
+	for moved := 0; moved < len(m.Slots)/groupCount; moved++ {
+		// 每次从槽位最多的group中取一个
+		from := -1
+		for g, slots := range owned {
+			if from < 0 || len(slots) > len(owned[from]) || (len(slots) == len(owned[from]) && g < from) {
+				from = g
+			}
+		}
+		slot := owned[from][len(owned[from])-1]
+		owned[from] = owned[from][:len(owned[from])-1]
+		m.Migrations = append(m.Migrations, SlotMigration{Slot: slot, From: from, To: group, State: MigrationCopying})
+	}
+	m.Epoch++
+	return nil
+}
+
+// PlanRemoveGroup 规划把group的全部槽位迁移给其他group，每次交给槽位最少的group
+func (m *ShardMap) PlanRemoveGroup(group int) error {
+	if len(m.Migrations) > 0 {
+		return fmt.Errorf("there are %d unfinished slot migrations", len(m.Migrations))
+	}
+	owned := m.slotsOfGroups()
+	slots, ok := owned[group]
+	if !ok {
+		return fmt.Errorf("group-%d does not own any slot", group)
+	}
+	delete(owned, group)
+	if len(owned) == 0 {
+		return fmt.Errorf("can not remove the last group-%d", group)
+	}
+	for _, slot := range slots {
+		to := -1
+		for g, s := range owned {
+			if to < 0 || len(s) < len(owned[to]) || (len(s) == len(owned[to]) && g < to) {
+				to = g
+			}
+		}
+		owned[to] = append(owned[to], slot)
+		m.Migrations = append(m.Migrations, SlotMigration{Slot: slot, From: group, To: to, State: MigrationCopying})
+	}
+	m.Epoch++
+	return nil
+}
+
+func shardMapKey(index string) string {
+	return ServiceRootPath + index + "/shard-map"
+}
+
+// LoadShardMap 读取etcd中的分片映射，还没有创建时返回nil。revision用于SaveShardMap的并发校验
+func LoadShardMap(client *etcdv3.Client) (m *ShardMap, revision int64, err error) {
+	return loadShardMapOf(client, indexName)
+}
+
+func loadShardMapOf(client *etcdv3.Client, index string) (m *ShardMap, revision int64, err error) {
+	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+	defer cancel()
+	resp, err := client.Get(timeoutCtx, shardMapKey(index))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+	m = new(ShardMap)
+	if err := json.Unmarshal(resp.Kvs[0].Value, m); err != nil {
+		return nil, 0, fmt.Errorf("invalid shard map: %w", err)
+	}
+	return m, resp.Kvs[0].ModRevision, nil
+}
+
+// SaveShardMap 只有etcd中的分片映射在revision之后没有被修改过才会写入，否则返回ErrShardMapChanged。revision为0表示新建
+func SaveShardMap(client *etcdv3.Client, m *ShardMap, revision int64) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+	defer cancel()
+	resp, err := client.Txn(timeoutCtx).
+		If(etcdv3.Compare(etcdv3.ModRevision(shardMapKey(indexName)), "=", revision)).
+		Then(etcdv3.OpPut(shardMapKey(indexName), string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrShardMapChanged
+	}
+	return nil
+}
+
+// ShardFilter 返回判断文档是否属于当前group的函数，用于从外部数据源构建索引。没有可用的group时返回nil
+func (service *IndexServiceWorker) ShardFilter() func(docId string) bool {
+	shardMap := service.Hub.GetShardMap()
+	if shardMap == nil {
+		return nil
+	}
+	return func(docId string) bool {
+		return shardMap.ReadGroup(docId) == currentGroupIndex
+	}
+}
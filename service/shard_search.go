@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	SearchTimeout        = 5 * time.Second                                         // 调用方的ctx没有截止时间时，检索、计数请求等待worker返回的最长时间
+	HedgeMinSamples      = 100                                                     // 记录的延迟少于该数量时不对冲
+	HedgeMinDelay        = 10 * time.Millisecond                                   // 对冲请求最早在多久之后发送，避免延迟很低时对冲过多
+	DefaultSearchOptions = SearchOptions{AllowPartialResults: true, MaxRetries: 3} // NewSentinel使用的选项
+	latencyWindow        = 1000                                                    // 计算延迟分位数时使用最近多少次请求
+)
+
+// SearchOptions 控制Sentinel如何处理部分group失败或者变慢的检索请求
+type SearchOptions struct {
+	AllowPartialResults bool    // 为true时返回成功的group的结果和失败汇总；为false时任一group失败都返回错误
+	MaxRetries          int     // 一次请求中worker失败后最多换几次同group的其他worker重试，所有group共用
+	HedgePercentile     float64 // 请求超过最近检索延迟的该分位数（如0.95）仍未返回时，向同group的另一台worker发送相同的请求，0表示不对冲
+	MaxHedges           int     // 一次请求中最多发送几个对冲请求，所有group共用
+}
+
+// ShardFailure 一个group失败的原因
+type ShardFailure struct {
+	Group    string
+	Endpoint string // 没有可用的worker时为空
+	Code     codes.Code
+	Reason   string
+}
+
+// ShardsSummary 一次请求涉及的group数量，以及成功和失败的情况
+type ShardsSummary struct {
+	Total      int
+	Successful int
+	Failed     int
+	Failures   []ShardFailure
+	Retries    int // 换worker重试的次数
+	Hedges     int // 发送对冲请求的次数
+}
+
+// SearchResult 检索结果，Shards记录了每个group是否成功返回
+type SearchResult struct {
+	Documents []*types.Document
+	Shards    *ShardsSummary
+}
+
+// ShardFailureError 有group失败且不允许部分结果，或者所有group都失败时返回的错误，可以通过errors.As获取失败汇总
+type ShardFailureError struct {
+	Shards *ShardsSummary
+}
+
+func (e *ShardFailureError) Error() string {
+	reasons := make([]string, 0, len(e.Shards.Failures))
+	for _, failure := range e.Shards.Failures {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", failure.Group, failure.Reason))
+	}
+	return fmt.Sprintf("%d of %d groups failed: %s", e.Shards.Failed, e.Shards.Total, strings.Join(reasons, "; "))
+}
+
+// WithSearchOptions 设置Search和Count使用的选项，默认为DefaultSearchOptions
+func (sentinel *Sentinel) WithSearchOptions(options SearchOptions) *Sentinel {
+	sentinel.searchOptions = options
+	return sentinel
+}
+
+// latencyRecorder 记录最近若干次成功请求的延迟，用于计算对冲的阈值
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (recorder *latencyRecorder) observe(latency time.Duration) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.samples) < latencyWindow {
+		recorder.samples = append(recorder.samples, latency)
+		return
+	}
+	recorder.samples[recorder.next] = latency
+	recorder.next = (recorder.next + 1) % latencyWindow
+}
+
+// percentile 返回延迟的分位数，样本不足minSamples时返回0
+func (recorder *latencyRecorder) percentile(p float64, minSamples int) time.Duration {
+	recorder.mu.Lock()
+	samples := slices.Clone(recorder.samples)
+	recorder.mu.Unlock()
+	if len(samples) == 0 || len(samples) < minSamples {
+		return 0
+	}
+	slices.Sort(samples)
+	return samples[min(int(p*float64(len(samples))), len(samples)-1)]
+}
+
+// 对冲请求的等待时间，不对冲时返回0
+func (recorder *latencyRecorder) hedgeDelay(options SearchOptions) time.Duration {
+	if options.HedgePercentile <= 0 || options.MaxHedges <= 0 {
+		return 0
+	}
+	delay := recorder.percentile(options.HedgePercentile, HedgeMinSamples)
+	if delay == 0 {
+		return 0
+	}
+	return max(delay, HedgeMinDelay)
+}
+
+// ctx没有设置截止时间时使用SearchTimeout
+func withSearchTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, SearchTimeout)
+}
+
+// 一次请求中所有group共用的重试和对冲次数
+type requestBudget struct {
+	retries atomic.Int32
+	hedges  atomic.Int32
+}
+
+func (budget *requestBudget) take(counter *atomic.Int32) bool {
+	return counter.Add(-1) >= 0
+}
+
+// 可以换一台worker重试的错误
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
+
+type attemptResult[T any] struct {
+	endpoint string
+	value    T
+	err      error
+}
+
+// scatter 从每个group中按负载均衡选一台worker并发执行call，call的参数包括group的编号，key用于按请求选择worker的负载均衡算法，为空时不按key选择。
+// worker失败时在预算内换同group的另一台worker重试；开启对冲时，请求超过延迟阈值仍未返回就再发给另一台worker，使用先返回的结果
+func scatter[T any](sentinel *Sentinel, ctx context.Context, groups []int, key string, options SearchOptions, latency *latencyRecorder, call func(ctx context.Context, groupIndex int, client IndexServiceClient) (T, error)) (map[int]T, *ShardsSummary) {
+	summary := &ShardsSummary{Total: len(groups)}
+	results := make(map[int]T, len(groups))
+	budget := new(requestBudget)
+	budget.retries.Store(int32(options.MaxRetries))
+	budget.hedges.Store(int32(options.MaxHedges))
+	hedgeDelay := latency.hedgeDelay(options)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(groups))
+	for _, groupIndex := range groups {
+		go func() {
+			defer wg.Done()
+			group := fmt.Sprintf("group-%d", groupIndex)
+			value, retries, hedges, failure := callGroup(sentinel, ctx, group, key, budget, latency, hedgeDelay, func(ctx context.Context, client IndexServiceClient) (T, error) {
+				return call(ctx, groupIndex, client)
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			summary.Retries += retries
+			summary.Hedges += hedges
+			if failure != nil {
+				summary.Failures = append(summary.Failures, *failure)
+				return
+			}
+			results[groupIndex] = value
+			summary.Successful++
+		}()
+	}
+	wg.Wait()
+	summary.Failed = len(summary.Failures)
+	return results, summary
+}
+
+// callGroup 在group内执行call，返回结果、重试次数、对冲次数，失败时返回最后一次失败的原因
+func callGroup[T any](sentinel *Sentinel, ctx context.Context, group, key string, budget *requestBudget, latency *latencyRecorder, hedgeDelay time.Duration, call func(ctx context.Context, client IndexServiceClient) (T, error)) (value T, retries, hedges int, failure *ShardFailure) {
+	balancer := sentinel.Hub.GetLoadBalancer()
+	endpoints := sentinel.Hub.GetServiceEndpoints(group)
+	if len(endpoints) == 0 {
+		return value, 0, 0, &ShardFailure{Group: group, Code: codes.Unavailable, Reason: "no worker available"}
+	}
+	endpoints = sentinel.availableEndpoints(endpoints) // 跳过熔断的worker
+	first := takeByKey(balancer, endpoints, key)
+	if len(first) == 0 {
+		return value, 0, 0, &ShardFailure{Group: group, Code: codes.Unavailable, Reason: "circuit breakers of all workers are open"}
+	}
+	tried := map[string]bool{first: true}
+	next := func() string { // 重试和对冲时从同group还没有尝试过的worker中选
+		untried := make([]string, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			if !tried[endpoint] {
+				untried = append(untried, endpoint)
+			}
+		}
+		endpoint := balancer.Take(untried)
+		if len(endpoint) > 0 {
+			tried[endpoint] = true
+		}
+		return endpoint
+	}
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel() // 有结果后取消其他还在执行的请求
+	resultCh := make(chan attemptResult[T])
+	inflight := 0
+	attempt := func(endpoint string) {
+		inflight++
+		go func() {
+			conn := sentinel.GetGrpcConn(endpoint)
+			if conn == nil {
+				err := status.Error(codes.Unavailable, "failed to get connection")
+				balancer.Done(endpoint, 0, err)
+				select {
+				case resultCh <- attemptResult[T]{endpoint: endpoint, err: err}:
+				case <-attemptCtx.Done():
+				}
+				return
+			}
+			begin := time.Now()
+			value, err := call(attemptCtx, NewIndexServiceClient(conn))
+			balancer.Done(endpoint, time.Since(begin), err)
+			if err == nil {
+				latency.observe(time.Since(begin))
+			}
+			select {
+			case resultCh <- attemptResult[T]{endpoint: endpoint, value: value, err: err}:
+			case <-attemptCtx.Done(): // 已经有结果或者请求超时
+			}
+		}()
+	}
+	attempt(first)
+
+	var hedgeTimer <-chan time.Time
+	if hedgeDelay > 0 {
+		timer := time.NewTimer(hedgeDelay)
+		defer timer.Stop()
+		hedgeTimer = timer.C
+	}
+	for {
+		select {
+		case result := <-resultCh:
+			inflight--
+			if result.err == nil {
+				return result.value, retries, hedges, nil
+			}
+			util.Log.Printf("%s failed on worker %s: %s", group, result.endpoint, result.err)
+			failure = &ShardFailure{Group: group, Endpoint: result.endpoint, Code: status.Code(result.err), Reason: status.Convert(result.err).Message()}
+			if retryable(result.err) && ctx.Err() == nil {
+				if endpoint := next(); len(endpoint) > 0 && budget.take(&budget.retries) {
+					retries++
+					attempt(endpoint)
+				}
+			}
+			if inflight == 0 {
+				return value, retries, hedges, failure
+			}
+		case <-ctx.Done():
+			return value, retries, hedges, &ShardFailure{Group: group, Endpoint: first, Code: status.FromContextError(ctx.Err()).Code(), Reason: ctx.Err().Error()}
+		case <-hedgeTimer:
+			hedgeTimer = nil // 每个group最多对冲一次
+			if endpoint := next(); len(endpoint) > 0 && budget.take(&budget.hedges) {
+				hedges++
+				attempt(endpoint)
+			}
+		}
+	}
+}
+
+// 所有group都失败，或者不允许部分结果时有group失败，返回ShardFailureError
+func (summary *ShardsSummary) err(options SearchOptions) error {
+	if summary.Failed == 0 {
+		return nil
+	}
+	if summary.Successful == 0 || !options.AllowPartialResults {
+		return &ShardFailureError{Shards: summary}
+	}
+	return nil
+}
+
+// SearchWithOptions 在每个group上检索，返回成功的group的结果和失败汇总。
+// 不允许部分结果时有group失败，或者所有group都失败时，返回的错误为ShardFailureError，结果中仍然包含失败汇总
+func (sentinel *Sentinel) SearchWithOptions(ctx context.Context, query *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64, options SearchOptions) (*SearchResult, error) {
+	shardMap := sentinel.getShardMap()
+	if shardMap == nil {
+		return nil, fmt.Errorf("there is no group can be used")
+	}
+	ctx, cancel := withSearchTimeout(ctx)
+	defer cancel()
+
+	groups := shardMap.Groups()
+	responses, summary := scatter(sentinel, ctx, groups, query.ToString(), options, &sentinel.searchLatency, func(ctx context.Context, _ int, client IndexServiceClient) (*SearchResponse, error) {
+		return client.Search(ctx, &SearchRequest{
+			Query:   query,
+			OnFlag:  onFlag,
+			OffFlag: offFlag,
+			OrFlags: orFlags,
+		})
+	})
+	result := &SearchResult{Shards: summary}
+	if err := summary.err(options); err != nil {
+		return result, err
+	}
+	result.Documents = make([]*types.Document, 0, 1500)
+	for groupIndex, response := range responses {
+		for _, doc := range response.Documents {
+			// 槽位迁移期间文档可能同时存在于两个group，只保留所属group返回的
+			if shardMap.ReadGroup(doc.Id) == groupIndex {
+				result.Documents = append(result.Documents, doc)
+			}
+		}
+	}
+	return result, nil
+}
+
+// CountWithOptions 汇总每个group的文档数，失败的处理与SearchWithOptions相同。
+// 槽位迁移期间文档可能同时存在于两个group，只统计槽位当前所属的group
+func (sentinel *Sentinel) CountWithOptions(ctx context.Context, options SearchOptions) (int, *ShardsSummary, error) {
+	shardMap := sentinel.getShardMap()
+	if shardMap == nil {
+		return 0, nil, fmt.Errorf("there is no group can be used")
+	}
+	ctx, cancel := withSearchTimeout(ctx)
+	defer cancel()
+
+	groups := shardMap.Groups()
+	requests := make(map[int]*CountRequest, len(groups))
+	for _, group := range groups {
+		requests[group] = &CountRequest{Slots: uint32(len(shardMap.Slots)), Seed: shardMap.Seed, ExcludeSlots: shardMap.foreignSlots(group)}
+	}
+	responses, summary := scatter(sentinel, ctx, groups, "", options, &sentinel.countLatency, func(ctx context.Context, groupIndex int, client IndexServiceClient) (*AffectedCount, error) {
+		return client.Count(ctx, requests[groupIndex])
+	})
+	if err := summary.err(options); err != nil {
+		return 0, summary, err
+	}
+	var n int
+	for _, response := range responses {
+		n += int(response.Count)
+	}
+	return n, summary, nil
+}
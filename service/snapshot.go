@@ -0,0 +1,448 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	reverseindex "github.com/WlayRay/ElectricSearch/internal/reverse_index"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+// 分片快照文件格式：
+//
+//	magic(6字节) + 格式版本(1字节) + 存储引擎(1字节) + since(8字节) + 创建时间(8字节) + WAL序号(8字节，格式版本2开始)
+//	若干个段：段类型(1字节) + 若干个数据块(长度uvarint + 数据)，以长度为0的数据块结尾
+//	结束标记(1字节) + 快照中数据的最大版本号(8字节)
+//
+// 正排索引段是存储引擎原生的备份数据，只能恢复到同一种存储引擎中
+const (
+	snapshotMagic   = "ESSNAP"
+	snapshotFormat  = 2
+	snapshotBufSize = 64 << 10
+)
+
+// 快照中的段类型
+const (
+	sectionEnd byte = iota
+	sectionForwardIndex
+	sectionReverseIndex
+	sectionExpireQueue
+)
+
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// SnapshotInfo 分片快照的元信息
+type SnapshotInfo struct {
+	Engine          int    // 正排索引的存储引擎，kvdb.BOLT、kvdb.BADGER或kvdb.MEMORY
+	Since           uint64 // 0表示全量快照，否则只包含版本号大于Since的数据
+	Version         uint64 // 快照中数据的最大版本号
+	CreatedAt       int64  // 创建时间（Unix时间戳，单位秒）
+	Seq             uint64 // 快照至少包含了预写日志中序号不大于Seq的所有操作
+	HasReverseIndex bool   // 是否包含倒排索引
+}
+
+// 把p切成若干个数据块写入w
+type chunkWriter struct {
+	w io.Writer
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := cw.w.Write(binary.AppendUvarint(nil, uint64(len(p)))); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
+
+// 写入段的结束标记
+func (cw *chunkWriter) Close() error {
+	_, err := cw.w.Write(binary.AppendUvarint(nil, 0))
+	return err
+}
+
+// 读取一个段中的数据块，读到段的结束标记时返回io.EOF
+type chunkReader struct {
+	r      *bufio.Reader
+	remain uint64
+	done   bool
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for cr.remain == 0 {
+		if cr.done {
+			return 0, io.EOF
+		}
+		n, err := binary.ReadUvarint(cr.r)
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if n == 0 {
+			cr.done = true
+		}
+		cr.remain = n
+	}
+	if uint64(len(p)) > cr.remain {
+		p = p[:cr.remain]
+	}
+	n, err := cr.r.Read(p)
+	cr.remain -= uint64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(reader *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	return string(b), nil
+}
+
+func writeSection(w *bufio.Writer, section byte, fn func(w io.Writer) error) error {
+	if err := w.WriteByte(section); err != nil {
+		return err
+	}
+	cw := &chunkWriter{w: w}
+	buffered := bufio.NewWriterSize(cw, snapshotBufSize)
+	if err := fn(buffered); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+func readSnapshotHeader(reader *bufio.Reader) (*SnapshotInfo, error) {
+	header := make([]byte, len(snapshotMagic)+18)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidSnapshot)
+	}
+	header = header[len(snapshotMagic):]
+	if header[0] == 0 || header[0] > snapshotFormat {
+		return nil, fmt.Errorf("%w: unsupported format %d", ErrInvalidSnapshot, header[0])
+	}
+	info := &SnapshotInfo{
+		Engine:    int(header[1]),
+		Since:     binary.BigEndian.Uint64(header[2:]),
+		CreatedAt: int64(binary.BigEndian.Uint64(header[10:])),
+	}
+	if header[0] >= 2 {
+		var seq [8]byte
+		if _, err := io.ReadFull(reader, seq[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		info.Seq = binary.BigEndian.Uint64(seq[:])
+	}
+	return info, nil
+}
+
+// 一次性获取全部文档锁，阻塞所有写操作
+func (indexer *Indexer) lockAll() (unlock func()) {
+	for i := range indexer.locks {
+		indexer.locks[i].Lock()
+	}
+	return func() {
+		for i := len(indexer.locks) - 1; i >= 0; i-- {
+			indexer.locks[i].Unlock()
+		}
+	}
+}
+
+// WriteSnapshot 把当前分片导出为快照写入w。
+// since为0时导出全量数据，否则只导出版本号大于since的增量数据（仅badger支持，包括删除标记）。
+// 只导出正排索引时，全部文档锁只在记下预写日志序号时短暂持有，导出期间检索和写入都可以继续进行，
+// 快照包含序号不大于Seq的全部写入，也可能包含导出期间的写入，需要从Seq之后的操作追赶。
+// withReverseIndex为true时同时导出倒排索引，恢复时无需重建。为了让正排和倒排索引保持一致，导出期间要持有全部文档锁，
+// 所以先导出到本地的临时文件，释放锁之后再写入w，写请求被阻塞的时间与w的速度无关。增量快照不包含倒排索引
+func (indexer *Indexer) WriteSnapshot(w io.Writer, since uint64, withReverseIndex bool) (*SnapshotInfo, error) {
+	engine := kvdb.EngineOf(indexer.forwardIndex)
+	if engine < 0 {
+		return nil, fmt.Errorf("unsupported forward index %T", indexer.forwardIndex)
+	}
+	_, ok := indexer.reverseIndex.(reverseindex.ISnapshotter)
+	info := &SnapshotInfo{
+		Engine:          engine,
+		Since:           since,
+		CreatedAt:       time.Now().Unix(),
+		HasReverseIndex: withReverseIndex && ok && since == 0,
+	}
+
+	// 拿到全部文档锁时，序号不大于LastSeq的操作都已经写入了索引
+	unlock := indexer.lockAll()
+	info.Seq = indexer.LastSeq()
+	if !info.HasReverseIndex {
+		unlock()
+		if err := indexer.writeSnapshot(w, info); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+
+	spool, err := os.CreateTemp("", "snapshot-*")
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	err = indexer.writeSnapshot(spool, info)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyBuffer(w, spool, make([]byte, snapshotBufSize)); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// 按info导出快照，导出倒排索引时调用方需要持有全部文档锁
+func (indexer *Indexer) writeSnapshot(w io.Writer, info *SnapshotInfo) error {
+	writer := bufio.NewWriterSize(w, snapshotBufSize)
+	header := []byte(snapshotMagic)
+	header = append(header, snapshotFormat, byte(info.Engine))
+	header = binary.BigEndian.AppendUint64(header, info.Since)
+	header = binary.BigEndian.AppendUint64(header, uint64(info.CreatedAt))
+	header = binary.BigEndian.AppendUint64(header, info.Seq)
+	if _, err := writer.Write(header); err != nil {
+		return err
+	}
+	err := writeSection(writer, sectionForwardIndex, func(w io.Writer) error {
+		var err error
+		info.Version, err = indexer.forwardIndex.Backup(w, info.Since)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if info.HasReverseIndex {
+		reverse := indexer.reverseIndex.(reverseindex.ISnapshotter)
+		if err := writeSection(writer, sectionReverseIndex, reverse.Snapshot); err != nil {
+			return err
+		}
+		if err := writeSection(writer, sectionExpireQueue, indexer.expireQueue.snapshot); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.WriteByte(sectionEnd); err != nil {
+		return err
+	}
+	if _, err := writer.Write(binary.BigEndian.AppendUint64(nil, info.Version)); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// ReadSnapshot 从WriteSnapshot导出的快照中恢复分片，恢复期间写请求会被阻塞。
+// 全量快照会先清空当前分片；增量快照在已有数据上合并，需要按导出的顺序依次恢复。
+// 快照中没有倒排索引时，恢复完正排索引后重建倒排索引
+func (indexer *Indexer) ReadSnapshot(r io.Reader) (*SnapshotInfo, error) {
+	reader := bufio.NewReaderSize(r, snapshotBufSize)
+	info, err := readSnapshotHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	if engine := kvdb.EngineOf(indexer.forwardIndex); info.Engine != engine {
+		return nil, fmt.Errorf("snapshot of engine %d can not be restored to engine %d", info.Engine, engine)
+	}
+	reverse, ok := indexer.reverseIndex.(reverseindex.ISnapshotter)
+	if !ok {
+		return nil, fmt.Errorf("reverse index %T can not be restored", indexer.reverseIndex)
+	}
+
+	unlock := indexer.lockAll()
+	defer unlock()
+	full := info.Since == 0
+	if full {
+		if err := indexer.forwardIndex.DropAll(); err != nil {
+			return nil, err
+		}
+	}
+	reverse.Reset()
+	indexer.expireQueue.reset()
+
+	for {
+		section, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if section == sectionEnd {
+			var version [8]byte
+			if _, err := io.ReadFull(reader, version[:]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+			info.Version = binary.BigEndian.Uint64(version[:])
+			break
+		}
+
+		cr := &chunkReader{r: reader}
+		switch section {
+		case sectionForwardIndex:
+			err = indexer.forwardIndex.Restore(cr)
+		case sectionReverseIndex:
+			if full {
+				err = reverse.LoadSnapshot(cr)
+				info.HasReverseIndex = err == nil
+			}
+		case sectionExpireQueue:
+			if full {
+				err = indexer.expireQueue.load(cr)
+			}
+		default:
+			err = fmt.Errorf("%w: unknown section %d", ErrInvalidSnapshot, section)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(io.Discard, cr); err != nil { // 跳过段中未读完的数据
+			return nil, err
+		}
+	}
+
+	if !info.HasReverseIndex {
+		indexer.LoadFromIndexFile()
+	} else {
+		// 不需要重建倒排索引，但要重新统计文档数，之后分配的版本号也要比快照中的文档大
+		var maxVersion uint64
+		n := indexer.forwardIndex.IterDB(func(k, v []byte) error {
+			if doc, err := decodeDoc(v); err == nil {
+				maxVersion = max(maxVersion, doc.Version)
+			}
+			return nil
+		})
+		indexer.counter.docs.Store(n)
+		if err := indexer.observeVersion(maxVersion); err != nil {
+			return nil, err
+		}
+	}
+	// 预写日志中的记录已经被快照覆盖，不能在重启时重放
+	if indexer.wal != nil {
+		if err := indexer.forwardIndex.Sync(); err != nil {
+			return nil, err
+		}
+		if err := indexer.wal.Checkpoint(max(indexer.wal.LastSeq(), info.Seq)); err != nil {
+			return nil, err
+		}
+	}
+	util.Log.Printf("restore snapshot created at %d, since %d, version %d", info.CreatedAt, info.Since, info.Version)
+	return info, nil
+}
+
+// ReadSnapshotInfo 读取快照文件的元信息，会读完整个r
+func ReadSnapshotInfo(r io.Reader) (*SnapshotInfo, error) {
+	reader := bufio.NewReaderSize(r, snapshotBufSize)
+	info, err := readSnapshotHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		section, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		if section == sectionEnd {
+			var version [8]byte
+			if _, err := io.ReadFull(reader, version[:]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+			info.Version = binary.BigEndian.Uint64(version[:])
+			return info, nil
+		}
+		if section == sectionReverseIndex {
+			info.HasReverseIndex = true
+		}
+		if _, err := io.Copy(io.Discard, &chunkReader{r: reader}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// gRPC单条消息的上限是4M，快照按1M切分后发送
+const snapshotChunkSize = 1 << 20
+
+type snapshotStreamWriter struct {
+	send func(*SnapshotChunk) error
+}
+
+func (sw snapshotStreamWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		size := min(len(p), snapshotChunkSize)
+		if err := sw.send(&SnapshotChunk{Data: p[:size]}); err != nil {
+			return n, err
+		}
+		n += size
+		p = p[size:]
+	}
+	return n, nil
+}
+
+type snapshotStreamReader struct {
+	recv func() (*SnapshotChunk, error)
+	data []byte
+}
+
+func (sr *snapshotStreamReader) Read(p []byte) (int, error) {
+	for len(sr.data) == 0 {
+		chunk, err := sr.recv()
+		if err != nil {
+			return 0, err
+		}
+		sr.data = chunk.Data
+	}
+	n := copy(p, sr.data)
+	sr.data = sr.data[n:]
+	return n, nil
+}
+
+// BackupShard 通过worker的Backup接口把分片快照下载到w，比如一个本地文件
+func BackupShard(ctx context.Context, client IndexServiceClient, w io.Writer, request *BackupRequest) error {
+	stream, err := client.Backup(ctx, request)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, &snapshotStreamReader{recv: stream.Recv})
+	return err
+}
+
+// RestoreShard 通过worker的Restore接口把r中的分片快照上传到worker
+func RestoreShard(ctx context.Context, client IndexServiceClient, r io.Reader) (*RestoreResponse, error) {
+	stream, err := client.Restore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(snapshotStreamWriter{send: stream.Send}, r); err != nil {
+		return nil, err
+	}
+	return stream.CloseAndRecv()
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/util"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RegistryEtcd   = "etcd"   // worker注册到etcd，支持拓扑、主从复制和重新分片
+	RegistryStatic = "static" // worker列表写在YAML文件中，不依赖etcd
+)
+
+var StaticRegistryReloadInterval = 5 * time.Second // 检查静态注册文件是否修改的间隔
+
+// ErrStaticGroupsChanged 运行中修改了静态注册文件中的group数量。分片映射按group数量创建，数量变化会改变已有文档的路由，需要迁移数据后重启
+var ErrStaticGroupsChanged = errors.New("the number of groups in the registry file changed")
+
+// StaticRegistry 静态注册文件的内容，Groups[i]为group-i的worker列表
+type StaticRegistry struct {
+	Groups [][]string `yaml:"groups"`
+}
+
+// StaticServiceHub 从YAML文件读取每个group的worker，不依赖etcd，适合不需要动态扩缩容的小集群。
+// 文件修改后自动重新加载；没有主节点，写请求发给group内的所有worker；分片映射按启动时的group数量创建，运行中修改group数量的文件不会被加载
+type StaticServiceHub struct {
+	path         string
+	registry     atomic.Pointer[StaticRegistry]
+	shardMap     atomic.Pointer[ShardMap]
+	loadBalancer LoadBalancer
+	stop         chan struct{}
+	closeOnce    sync.Once
+}
+
+// NewStaticServiceHub 加载静态注册文件，并在后台每隔StaticRegistryReloadInterval检查文件是否修改
+func NewStaticServiceHub(path string) (*StaticServiceHub, error) {
+	loadBalancer, err := configuredLoadBalancer()
+	if err != nil {
+		return nil, err
+	}
+	hub := &StaticServiceHub{path: path, loadBalancer: loadBalancer, stop: make(chan struct{})}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := hub.reload(); err != nil {
+		return nil, err
+	}
+	go hub.watch(info.ModTime())
+	return hub, nil
+}
+
+// LoadStaticRegistry 读取并校验静态注册文件
+func LoadStaticRegistry(path string) (*StaticRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	registry := new(StaticRegistry)
+	if err := yaml.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("invalid registry file %s: %w", path, err)
+	}
+	if len(registry.Groups) == 0 {
+		return nil, fmt.Errorf("registry file %s has no group", path)
+	}
+	seen := make(map[string]int)
+	for i, endpoints := range registry.Groups {
+		for _, endpoint := range endpoints {
+			if len(strings.TrimSpace(endpoint)) == 0 {
+				return nil, fmt.Errorf("group-%d has an empty endpoint", i)
+			}
+			if group, ok := seen[endpoint]; ok {
+				return nil, fmt.Errorf("worker %s is listed in both group-%d and group-%d", endpoint, group, i)
+			}
+			seen[endpoint] = i
+		}
+	}
+	return registry, nil
+}
+
+// 第一次加载时按group数量创建分片映射，之后group数量变化时返回ErrStaticGroupsChanged
+func (hub *StaticServiceHub) reload() error {
+	registry, err := LoadStaticRegistry(hub.path)
+	if err != nil {
+		return err
+	}
+	if current := hub.registry.Load(); current == nil {
+		hub.shardMap.Store(NewShardMap(len(registry.Groups), 0))
+	} else if len(current.Groups) != len(registry.Groups) {
+		return fmt.Errorf("%w from %d to %d, restart after migrating the docs", ErrStaticGroupsChanged, len(current.Groups), len(registry.Groups))
+	}
+	hub.registry.Store(registry)
+	return nil
+}
+
+// 文件修改后重新加载，新内容无效时保留原来的worker列表。modTime只在这个协程中读写
+func (hub *StaticServiceHub) watch(modTime time.Time) {
+	ticker := time.NewTicker(StaticRegistryReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hub.stop:
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(hub.path)
+		if err != nil {
+			util.Log.Printf("stat registry file %s failed: %v", hub.path, err)
+			continue
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime() // 加载失败时等文件再次修改后重试
+		if err := hub.reload(); err != nil {
+			util.Log.Printf("reload registry file %s failed, keep the previous workers: %v", hub.path, err)
+			continue
+		}
+		util.Log.Printf("reload registry file %s: %v", hub.path, hub.registry.Load().Groups)
+	}
+}
+
+func (hub *StaticServiceHub) groupOf(group string) int {
+	var index int
+	if _, err := fmt.Sscanf(group, "group-%d", &index); err != nil {
+		return -1
+	}
+	return index
+}
+
+// Register 只检查worker是否列在group中，worker列表以文件为准
+func (hub *StaticServiceHub) Register(group, endpoint string) error {
+	if !slices.Contains(hub.GetServiceEndpoints(group), endpoint) {
+		return fmt.Errorf("worker %s is not listed in %s of registry file %s", endpoint, group, hub.path)
+	}
+	return nil
+}
+
+// UnRegister 静态注册不需要注销，下线worker时从文件中删除
+func (hub *StaticServiceHub) UnRegister(group, endpoint string) error {
+	return nil
+}
+
+func (hub *StaticServiceHub) GetServiceEndpoints(group string) []string {
+	registry := hub.registry.Load()
+	index := hub.groupOf(group)
+	if index < 0 || index >= len(registry.Groups) {
+		return nil
+	}
+	return slices.Clone(registry.Groups[index])
+}
+
+// 根据负载均衡，从众多endpoint中选择一个。调用方不报告请求结果，需要报告结果时使用GetLoadBalancer
+func (hub *StaticServiceHub) GetServiceEndpoint(group string) string {
+	endpoint := hub.loadBalancer.Take(hub.GetServiceEndpoints(group))
+	if len(endpoint) > 0 {
+		hub.loadBalancer.Done(endpoint, 0, context.Canceled)
+	}
+	return endpoint
+}
+
+// GetPrimaryEndpoint 静态注册不选主，总是返回空字符串
+func (hub *StaticServiceHub) GetPrimaryEndpoint(group string) string {
+	return ""
+}
+
+func (hub *StaticServiceHub) GetLoadBalancer() LoadBalancer {
+	return hub.loadBalancer
+}
+
+// GetShardMap 按文件中的group数量创建的分片映射
+func (hub *StaticServiceHub) GetShardMap() *ShardMap {
+	return hub.shardMap.Load()
+}
+
+func (hub *StaticServiceHub) Close() {
+	hub.closeOnce.Do(func() { close(hub.stop) })
+}
+
+// registryConfig 读取init.yml中的注册中心类型，static时同时返回注册文件的路径
+func registryConfig() (registry, file string) {
+	distributed, _ := util.ConfigMap["distributed"].(map[string]any)
+	registry, _ = distributed["registry"].(string)
+	if len(registry) == 0 {
+		registry = RegistryEtcd
+	}
+	file, _ = distributed["registry-file"].(string)
+	if len(file) > 0 && !filepath.IsAbs(file) {
+		file = util.RootPath + file
+	}
+	return registry, file
+}
+
+// configuredLoadBalancer 按init.yml中的load-balancer创建负载均衡算法
+func configuredLoadBalancer() (LoadBalancer, error) {
+	distributed, _ := util.ConfigMap["distributed"].(map[string]any)
+	name, _ := distributed["load-balancer"].(string)
+	return ParseLoadBalancer(name)
+}
+
+// NewConfiguredServiceHub 按init.yml中的registry创建注册中心：etcd时使用带缓存和限流的ServiceHubProxy，static时使用StaticServiceHub
+func NewConfiguredServiceHub(etcdEndpoints []string, heartRate int64, qps int) (IServiceHub, error) {
+	switch registry, file := registryConfig(); registry {
+	case RegistryEtcd:
+		return GetServiceHubProxy(etcdEndpoints, heartRate, qps), nil
+	case RegistryStatic:
+		if len(file) == 0 {
+			return nil, errors.New("registry-file is required for the static registry")
+		}
+		return NewStaticServiceHub(file)
+	default:
+		return nil, fmt.Errorf("unknown registry %q, supported: %s, %s", registry, RegistryEtcd, RegistryStatic)
+	}
+}
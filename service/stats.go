@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	reverseindex "github.com/WlayRay/ElectricSearch/internal/reverse_index"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+// 文档计数，写入和删除时维护，Count不需要遍历正排索引
+type docCounter struct {
+	docs       atomic.Int64 // 正排索引中的文档数
+	deletedNum atomic.Int64 // 累计删除（包括过期清理）的文档数
+	saveMu     sync.Mutex   // 串行写计数文件
+}
+
+func (c *docCounter) added(n int) {
+	c.docs.Add(int64(n))
+}
+
+func (c *docCounter) deleted(n int) {
+	c.docs.Add(-int64(n))
+	c.deletedNum.Add(int64(n))
+}
+
+// DocVersionReserve 每次预留并持久化的文档版本号个数，异常退出后从预留的上限继续分配
+var DocVersionReserve = uint64(1000)
+
+// 索引内单调递增的文档版本号。文档删除后重新写入时不会复用以前的版本号，
+// 按版本号做条件写入或删除的调用方不会把新文档误认为旧文档
+type versionClock struct {
+	mu       sync.Mutex    // 串行分配版本号
+	last     atomic.Uint64 // 已分配或者见过的最大版本号
+	reserved atomic.Uint64 // 已持久化的版本号上限
+}
+
+// 分配一个比current和之前分配过的版本号都大的版本号
+func (indexer *Indexer) nextVersion(current uint64) (uint64, error) {
+	clock := &indexer.versions
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	version := max(current, clock.last.Load()) + 1
+	if err := indexer.reserveVersion(version); err != nil {
+		return 0, err
+	}
+	clock.last.Store(version)
+	return version, nil
+}
+
+// 复制、迁移写入了带版本号的文档，之后分配的版本号要比它大
+func (indexer *Indexer) observeVersion(version uint64) error {
+	clock := &indexer.versions
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	if version <= clock.last.Load() {
+		return nil
+	}
+	if err := indexer.reserveVersion(version); err != nil {
+		return err
+	}
+	clock.last.Store(version)
+	return nil
+}
+
+// 版本号超过已持久化的上限时，先持久化新的上限再使用，调用方需持有versions.mu
+func (indexer *Indexer) reserveVersion(version uint64) error {
+	clock := &indexer.versions
+	if version <= clock.reserved.Load() {
+		return nil
+	}
+	old := clock.reserved.Swap(version + DocVersionReserve)
+	if err := indexer.saveCounter(false); err != nil {
+		clock.reserved.Store(old)
+		return err
+	}
+	return nil
+}
+
+// 持久化到正排索引旁边的文件中。Clean为false表示进程没有正常退出，重启时文档数需要重新统计。
+// Versions是版本号的上限，之前分配过的版本号都不大于它
+type counterFile struct {
+	Docs     int64
+	Deleted  int64
+	Versions uint64
+	Clean    bool
+}
+
+// 计数文件的路径，纯内存的正排索引不持久化
+func (indexer *Indexer) counterPath() string {
+	path := indexer.forwardIndex.GetDbPath()
+	if len(path) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(path, "/") + ".stats"
+}
+
+// 读取计数文件。上次没有正常退出或者文件不存在时，遍历正排索引重新统计文档数，累计删除数沿用文件中的值
+func (indexer *Indexer) loadCounter() {
+	var saved counterFile
+	if path := indexer.counterPath(); len(path) > 0 {
+		if data, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(data, &saved); err != nil {
+				util.Log.Printf("invalid counter file %s: %v", path, err)
+				saved = counterFile{}
+			}
+		}
+	}
+	indexer.counter.deletedNum.Store(saved.Deleted)
+	indexer.versions.last.Store(saved.Versions)
+	indexer.versions.reserved.Store(saved.Versions)
+	if saved.Clean {
+		indexer.counter.docs.Store(saved.Docs)
+	} else {
+		indexer.recount()
+	}
+	// 标记为运行中，异常退出后下次启动会重新统计
+	if err := indexer.saveCounter(false); err != nil {
+		util.Log.Printf("save counter failed: %v", err)
+	}
+}
+
+// 正常退出时记下已分配的最大版本号，否则记下预留的上限
+func (indexer *Indexer) saveCounter(clean bool) error {
+	path := indexer.counterPath()
+	if len(path) == 0 {
+		return nil
+	}
+	indexer.counter.saveMu.Lock()
+	defer indexer.counter.saveMu.Unlock()
+	versions := indexer.versions.reserved.Load()
+	if clean {
+		versions = indexer.versions.last.Load()
+	}
+	data, err := json.Marshal(counterFile{
+		Docs:     indexer.counter.docs.Load(),
+		Deleted:  indexer.counter.deletedNum.Load(),
+		Versions: versions,
+		Clean:    clean,
+	})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// 遍历正排索引的key重新统计文档数
+func (indexer *Indexer) recount() {
+	n := indexer.forwardIndex.IterKey(func(k []byte) error {
+		return nil
+	})
+	indexer.counter.docs.Store(n)
+}
+
+// IndexStats 索引的统计信息
+type IndexStats struct {
+	Docs              int64          // 文档数
+	Deleted           int64          // 累计删除（包括过期清理）的文档数
+	FieldTerms        map[string]int // 每个Field下不同Keyword的数量
+	PostingLists      int            // 非空倒排链的数量
+	Postings          int64          // 所有倒排链的元素总数
+	PostingP50        int            // 倒排链长度的分位数
+	PostingP90        int
+	PostingP99        int
+	PostingMax        int
+	ForwardIndexBytes int64 // 正排索引占用的磁盘空间，存储引擎不支持统计时为0
+	ReverseIndexBytes int64 // 倒排索引估算的内存占用
+	Compression       CompressionStats
+}
+
+// DeletedCount 累计删除（包括过期清理）的文档数
+func (indexer *Indexer) DeletedCount() int {
+	return int(indexer.counter.deletedNum.Load())
+}
+
+// Stats 统计索引的规模。倒排链的统计需要遍历整个倒排索引
+func (indexer *Indexer) Stats() *IndexStats {
+	stats := &IndexStats{
+		Docs:        indexer.counter.docs.Load(),
+		Deleted:     indexer.counter.deletedNum.Load(),
+		Compression: indexer.CompressionStats(),
+	}
+	if sized, ok := indexer.forwardIndex.(kvdb.IMaintainable); ok {
+		stats.ForwardIndexBytes = sized.DiskSize()
+	}
+	if reporter, ok := indexer.reverseIndex.(reverseindex.IStatsReporter); ok {
+		reverse := reporter.Stats()
+		stats.FieldTerms = reverse.FieldTerms
+		stats.PostingLists = reverse.PostingLists
+		stats.Postings = reverse.Postings
+		stats.PostingP50 = reverse.PostingP50
+		stats.PostingP90 = reverse.PostingP90
+		stats.PostingP99 = reverse.PostingP99
+		stats.PostingMax = reverse.PostingMax
+		stats.ReverseIndexBytes = reverse.MemoryBytes
+	}
+	return stats
+}
+
+func (stats *IndexStats) response() *StatsResponse {
+	fieldTerms := make(map[string]int64, len(stats.FieldTerms))
+	for field, n := range stats.FieldTerms {
+		fieldTerms[field] = int64(n)
+	}
+	return &StatsResponse{
+		DocCount:          stats.Docs,
+		DeletedCount:      stats.Deleted,
+		FieldTerms:        fieldTerms,
+		PostingLists:      int64(stats.PostingLists),
+		Postings:          stats.Postings,
+		PostingP50:        int64(stats.PostingP50),
+		PostingP90:        int64(stats.PostingP90),
+		PostingP99:        int64(stats.PostingP99),
+		PostingMax:        int64(stats.PostingMax),
+		ForwardIndexBytes: stats.ForwardIndexBytes,
+		ReverseIndexBytes: stats.ReverseIndexBytes,
+		Compression:       stats.Compression.Codec,
+		RawBytes:          stats.Compression.RawBytes,
+		StoredBytes:       stats.Compression.StoredBytes,
+	}
+}
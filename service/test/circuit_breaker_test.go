@@ -0,0 +1,121 @@
+package servicetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func setBreakerKnobs(t *testing.T) {
+	minRequests, openDuration, probes := service.BreakerMinRequests, service.BreakerOpenDuration, service.BreakerHalfOpenProbes
+	service.BreakerMinRequests, service.BreakerOpenDuration, service.BreakerHalfOpenProbes = 4, 50*time.Millisecond, 2
+	t.Cleanup(func() {
+		service.BreakerMinRequests, service.BreakerOpenDuration, service.BreakerHalfOpenProbes = minRequests, openDuration, probes
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	setBreakerKnobs(t)
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	breaker := service.NewCircuitBreaker()
+	// 请求本身导致的错误和被取消的请求不会熔断
+	for range 10 {
+		breaker.Record(time.Millisecond, status.Error(codes.FailedPrecondition, "version conflict"))
+		breaker.Record(time.Millisecond, context.Canceled)
+	}
+	if stats := breaker.Stats(); stats.State != service.BreakerClosed || stats.Requests != 10 || stats.Failures != 0 {
+		t.Fatalf("expect closed without failures, got %+v", stats)
+	}
+
+	breaker = service.NewCircuitBreaker()
+	for range 4 {
+		if !breaker.Allow() {
+			t.Fatal("closed breaker rejected request")
+		}
+		breaker.Record(time.Millisecond, unavailable)
+	}
+	if breaker.State() != service.BreakerOpen || breaker.Allow() || breaker.Ready() {
+		t.Fatalf("expect open, got %s", breaker.State())
+	}
+
+	// 半开状态只放行BreakerHalfOpenProbes个探测请求，全部成功后恢复
+	time.Sleep(60 * time.Millisecond)
+	if state := breaker.State(); state != service.BreakerHalfOpen {
+		t.Fatalf("expect half-open, got %s", state)
+	}
+	if !breaker.Allow() || !breaker.Allow() || breaker.Allow() {
+		t.Fatal("half-open breaker should allow exactly 2 probes")
+	}
+	breaker.Record(time.Millisecond, nil)
+	breaker.Record(time.Millisecond, nil)
+	if stats := breaker.Stats(); stats.State != service.BreakerClosed || stats.Requests != 0 {
+		t.Fatalf("expect closed with empty window, got %+v", stats)
+	}
+
+	// 探测失败时重新熔断
+	for range 4 {
+		breaker.Record(time.Millisecond, unavailable)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !breaker.Allow() {
+		t.Fatal("half-open breaker rejected probe")
+	}
+	breaker.Record(time.Millisecond, unavailable)
+	if state := breaker.State(); state != service.BreakerOpen {
+		t.Fatalf("expect open after failed probe, got %s", state)
+	}
+
+	// 慢请求比例过高时熔断
+	slow := service.NewCircuitBreaker()
+	for range 4 {
+		slow.Record(service.BreakerSlowCallDuration, nil)
+	}
+	if stats := slow.Stats(); stats.State != service.BreakerOpen || stats.Slow != 4 {
+		t.Fatalf("expect open by slow calls, got %+v", stats)
+	}
+}
+
+func TestSentinelBreaker(t *testing.T) {
+	setBreakerKnobs(t)
+	sentinel := new(service.Sentinel)
+	endpoint := "127.0.0.1:1" // 没有worker监听的端口
+
+	for i := range 10 {
+		conn := sentinel.GetGrpcConn(endpoint)
+		if conn == nil {
+			if i < service.BreakerMinRequests {
+				t.Fatalf("breaker opened after %d requests", i)
+			}
+			break
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := service.NewIndexServiceClient(conn).Count(ctx, &service.CountRequest{})
+		cancel()
+		if err == nil {
+			t.Fatal("count on closed port succeeded")
+		}
+	}
+	stats, ok := sentinel.BreakerStates()[endpoint]
+	if !ok || stats.State != service.BreakerOpen {
+		t.Fatalf("expect open breaker of %s, got %+v", endpoint, stats)
+	}
+
+	// 熔断后worker恢复前的请求直接被拒绝
+	time.Sleep(60 * time.Millisecond)
+	conn := sentinel.GetGrpcConn(endpoint)
+	if conn == nil {
+		t.Fatal("half-open breaker should allow probes")
+	}
+	_, err := service.NewIndexServiceClient(conn).Count(context.Background(), &service.CountRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expect unavailable, got %v", err)
+	}
+	if state := sentinel.BreakerStates()[endpoint].State; state != service.BreakerOpen {
+		t.Fatalf("expect open after failed probe, got %s", state)
+	}
+}
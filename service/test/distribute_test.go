@@ -1,6 +1,7 @@
 package servicetest
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
@@ -75,7 +76,7 @@ func TestIndexCluster(t *testing.T) {
 		Keywords:    []*types.Keyword{{Field: "content", Word: "唐朝"}, {Field: "content", Word: "文物"}, {Field: "title", Word: book.Title}},
 		Bytes:       book.Serialize(),
 	}
-	n, err := sentinel.AddDoc(doc)
+	n, err := sentinel.AddDoc(context.Background(), doc)
 	if err != nil {
 		fmt.Println(err)
 		t.Fail()
@@ -85,7 +86,7 @@ func TestIndexCluster(t *testing.T) {
 	//测试Search接口
 	query := types.NewTermQuery("content", "文物")
 	query = query.And(types.NewTermQuery("content", "唐朝"))
-	docs := sentinel.Search(query, 0, 0, nil)
+	docs := mustSearch(t, sentinel, query, 0, 0, nil)
 	if err != nil {
 		fmt.Println(err)
 		t.Fail()
@@ -104,12 +105,12 @@ func TestIndexCluster(t *testing.T) {
 		}
 		//测试Delete接口
 		if len(docId) > 0 {
-			n := sentinel.DeleteDoc(docId)
+			n := sentinel.DeleteDoc(context.Background(), docId)
 			fmt.Printf("删除%d个doc\n", n)
 		}
 
 		//测试Search接口
-		docs := sentinel.Search(query, 0, 0, nil)
+		docs := mustSearch(t, sentinel, query, 0, 0, nil)
 		if len(docs) == 0 {
 			fmt.Println("无搜索结果")
 		} else {
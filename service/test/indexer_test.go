@@ -2,15 +2,22 @@ package servicetest
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/WlayRay/ElectricSearch/internal/kvdb"
 	"github.com/WlayRay/ElectricSearch/service"
 	"github.com/WlayRay/ElectricSearch/types"
 	"github.com/WlayRay/ElectricSearch/util"
+	"google.golang.org/grpc/codes"
 )
 
 type Book struct {
@@ -104,9 +111,9 @@ func TestSearch(t *testing.T) {
 		Bytes:       book3.Serialize(),
 	}
 
-	es.AddDoc(doc1)
-	es.AddDoc(doc2)
-	es.AddDoc(doc3)
+	es.AddDoc(context.Background(), doc1)
+	es.AddDoc(context.Background(), doc2)
+	es.AddDoc(context.Background(), doc3)
 
 	q1 := types.NewTermQuery("title", "生命起源")
 	q2 := types.NewTermQuery("content", "文物")
@@ -122,7 +129,7 @@ func TestSearch(t *testing.T) {
 	var onFlag uint64 = 0b10000
 	var offFlag uint64 = 0b01000
 	orFlags := []uint64{uint64(0b00010), uint64(0b00101)}
-	docs := es.Search(q8, onFlag, offFlag, orFlags) //检索
+	docs := mustSearch(t, es, q8, onFlag, offFlag, orFlags) //检索
 	for _, doc := range docs {
 		book := DeserializeBook(doc.Bytes) //检索的结果是二进流，需要自反序列化
 		if book != nil {
@@ -131,8 +138,8 @@ func TestSearch(t *testing.T) {
 	}
 	fmt.Println(strings.Repeat("-", 50))
 
-	es.DeleteDoc(doc2.Id)
-	docs = es.Search(q8, onFlag, offFlag, orFlags) //检索
+	es.DeleteDoc(context.Background(), doc2.Id)
+	docs = mustSearch(t, es, q8, onFlag, offFlag, orFlags) //检索
 	for _, doc := range docs {
 		book := DeserializeBook(doc.Bytes) //检索的结果是二进流，需要自反序列化
 		if book != nil {
@@ -141,8 +148,8 @@ func TestSearch(t *testing.T) {
 	}
 	fmt.Println(strings.Repeat("-", 50))
 
-	es.AddDoc(doc2)
-	docs = es.Search(q8, onFlag, offFlag, orFlags) //检索
+	es.AddDoc(context.Background(), doc2)
+	docs = mustSearch(t, es, q8, onFlag, offFlag, orFlags) //检索
 	for _, doc := range docs {
 		book := DeserializeBook(doc.Bytes) //检索的结果是二进流，需要自反序列化
 		if book != nil {
@@ -183,7 +190,7 @@ func TestLoadFromIndexFile(t *testing.T) {
 	var onFlag uint64 = 0b10000
 	var offFlag uint64 = 0b01000
 	orFlags := []uint64{uint64(0b00010), uint64(0b00101)}
-	docs := indexer.Search(q8, onFlag, offFlag, orFlags) //检索
+	docs := mustSearch(t, indexer, q8, onFlag, offFlag, orFlags) //检索
 	for _, doc := range docs {
 		book := DeserializeBook(doc.Bytes) //检索的结果是二进流，需要自反序列化
 		if book != nil {
@@ -206,8 +213,8 @@ func TestLoadFromIndexFile(t *testing.T) {
 		Bytes:       book2.Serialize(),
 	}
 
-	indexer.DeleteDoc(doc2.Id)
-	docs = indexer.Search(q8, onFlag, offFlag, orFlags) //检索
+	indexer.DeleteDoc(context.Background(), doc2.Id)
+	docs = mustSearch(t, indexer, q8, onFlag, offFlag, orFlags) //检索
 	for _, doc := range docs {
 		book := DeserializeBook(doc.Bytes) //检索的结果是二进流，需要自反序列化
 		if book != nil {
@@ -216,8 +223,8 @@ func TestLoadFromIndexFile(t *testing.T) {
 	}
 	fmt.Println(strings.Repeat("-", 50))
 
-	indexer.AddDoc(doc2)
-	docs = indexer.Search(q8, onFlag, offFlag, orFlags) //检索
+	indexer.AddDoc(context.Background(), doc2)
+	docs = mustSearch(t, indexer, q8, onFlag, offFlag, orFlags) //检索
 	for _, doc := range docs {
 		book := DeserializeBook(doc.Bytes) //检索的结果是二进流，需要自反序列化
 		if book != nil {
@@ -226,3 +233,693 @@ func TestLoadFromIndexFile(t *testing.T) {
 	}
 	fmt.Println(strings.Repeat("-", 50))
 }
+
+func TestDocVersion(t *testing.T) {
+	path := util.RootPath + "data/local_db/version_badger"
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, dbType, path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { indexer.Close() }()
+
+	doc := types.Document{
+		Id:       "version_doc",
+		Keywords: []*types.Keyword{{Field: "content", Word: "版本"}},
+	}
+	indexer.DeleteDoc(context.Background(), doc.Id)
+
+	v1, err := indexer.AddDocWithVersion(doc)
+	if err != nil || v1 == 0 {
+		t.Fatalf("first add: version %d, err %v", v1, err)
+	}
+	v2, err := indexer.AddDocWithVersion(doc) // Version为0，不做校验
+	if err != nil || v2 != v1+1 {
+		t.Fatalf("second add: version %d, err %v", v2, err)
+	}
+
+	doc.Version = v1 // 期望的版本号已过期
+	if _, err := indexer.AddDocWithVersion(doc); !errors.Is(err, service.ErrVersionConflict) {
+		t.Fatalf("expect version conflict, got %v", err)
+	}
+	doc.Version = v2
+	v3, err := indexer.AddDocWithVersion(doc)
+	if err != nil || v3 != v2+1 {
+		t.Fatalf("conditional add: version %d, err %v", v3, err)
+	}
+
+	docs := mustSearch(t, indexer, types.NewTermQuery("content", "版本"), 0, 0, nil)
+	if len(docs) != 1 || docs[0].Version != v3 {
+		t.Fatalf("search after update: %v", docs)
+	}
+
+	if _, err := indexer.DeleteDocWithVersion(doc.Id, v2); !errors.Is(err, service.ErrVersionConflict) {
+		t.Fatalf("expect version conflict on delete, got %v", err)
+	}
+	if n, err := indexer.DeleteDocWithVersion(doc.Id, v3); err != nil || n != 1 {
+		t.Fatalf("conditional delete: n %d, err %v", n, err)
+	}
+
+	// 删除后重新写入，版本号不能与删除前的重复，重启之后也一样
+	doc.Version = 0
+	v4, err := indexer.AddDocWithVersion(doc)
+	if err != nil || v4 <= v3 {
+		t.Fatalf("add after delete: version %d, err %v", v4, err)
+	}
+	if _, err := indexer.DeleteDocWithVersion(doc.Id, v3); !errors.Is(err, service.ErrVersionConflict) {
+		t.Fatalf("delete with the version before re-add, got %v", err)
+	}
+	indexer.DeleteDoc(context.Background(), doc.Id)
+	indexer.Close()
+	indexer = new(service.Indexer)
+	if err := indexer.Init(100, dbType, path); err != nil {
+		t.Fatal(err)
+	}
+	if v5, err := indexer.AddDocWithVersion(doc); err != nil || v5 <= v4 {
+		t.Fatalf("add after restart: version %d, err %v", v5, err)
+	}
+}
+
+func TestUpdateDoc(t *testing.T) {
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, dbType, util.RootPath+"data/local_db/update_badger"); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+
+	doc := types.Document{
+		Id:          "update_doc",
+		BitsFeature: 0b0011,
+		Keywords:    []*types.Keyword{{Field: "content", Word: "唐朝"}, {Field: "content", Word: "文物"}},
+		Bytes:       []byte("v1"),
+	}
+	indexer.DeleteDoc(context.Background(), doc.Id)
+	if _, err := indexer.AddDoc(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	docs := mustSearch(t, indexer, types.NewTermQuery("content", "唐朝"), 0, 0, nil)
+	if len(docs) != 1 {
+		t.Fatalf("search before update: %v", docs)
+	}
+	intId, version := docs[0].IntId, docs[0].Version
+
+	n, err := indexer.UpdateDoc(context.Background(), &types.DocPatch{
+		Id:             doc.Id,
+		SetBits:        0b1000,
+		ClearBits:      0b0001,
+		AddKeywords:    []*types.Keyword{{Field: "content", Word: "宋朝"}},
+		RemoveKeywords: []*types.Keyword{{Field: "content", Word: "唐朝"}},
+		Bytes:          []byte("v2"),
+		ReplaceBytes:   true,
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("update: n %d, err %v", n, err)
+	}
+
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "唐朝"), 0, 0, nil); len(docs) != 0 {
+		t.Fatalf("removed keyword still searchable: %v", docs)
+	}
+	docs = mustSearch(t, indexer, types.NewTermQuery("content", "宋朝").And(types.NewTermQuery("content", "文物")), 0b1010, 0b0001, nil)
+	if len(docs) != 1 {
+		t.Fatalf("search after update: %v", docs)
+	}
+	if docs[0].IntId != intId || docs[0].BitsFeature != 0b1010 || string(docs[0].Bytes) != "v2" || docs[0].Version != version+1 {
+		t.Fatalf("unexpected doc after update: %v", docs[0])
+	}
+
+	if n, _ := indexer.UpdateDoc(context.Background(), &types.DocPatch{Id: "not_exists", SetBits: 1}); n != 0 {
+		t.Fatalf("update missing doc affected %d", n)
+	}
+}
+
+func TestDocExpire(t *testing.T) {
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, dbType, util.RootPath+"data/local_db/expire_badger"); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+
+	query := types.NewTermQuery("content", "新闻")
+	doc := types.Document{
+		Id:       "expire_doc",
+		Keywords: []*types.Keyword{{Field: "content", Word: "新闻"}},
+		ExpireAt: time.Now().Unix() + 1,
+	}
+	if _, err := indexer.AddDoc(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if docs := mustSearch(t, indexer, query, 0, 0, nil); len(docs) != 1 {
+		t.Fatalf("search before expire: %v", docs)
+	}
+
+	time.Sleep(2 * time.Second)
+	if docs := mustSearch(t, indexer, query, 0, 0, nil); len(docs) != 0 {
+		t.Fatalf("expired doc still searchable: %v", docs)
+	}
+	if n := indexer.SweepExpired(); n != 1 {
+		t.Fatalf("sweep %d expired docs", n)
+	}
+
+	doc.ExpireAt = time.Now().Unix() - 1
+	if _, err := indexer.AddDoc(context.Background(), doc); err == nil {
+		t.Fatal("add an already expired doc should fail")
+	}
+}
+
+// 内存存储读不到已过期的文档，清理时仍然要记录删除并删掉正排索引中的数据
+func TestDocExpireMemory(t *testing.T) {
+	dir := t.TempDir()
+	indexer := new(service.Indexer).WithWAL(filepath.Join(dir, "expire_memory_wal"))
+	if err := indexer.Init(100, kvdb.MEMORY, filepath.Join(dir, "expire_memory")); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+
+	doc := types.Document{
+		Id:       "expire_memory_doc",
+		Keywords: []*types.Keyword{{Field: "content", Word: "新闻"}},
+		ExpireAt: time.Now().Unix() + 1,
+	}
+	if _, err := indexer.AddDoc(context.Background(), doc); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+	seq := indexer.LastSeq()
+	if n := indexer.SweepExpired(); n != 1 {
+		t.Fatalf("sweep %d expired docs", n)
+	}
+	if indexer.LastSeq() != seq+1 {
+		t.Fatalf("sweep should append a delete record, seq %d -> %d", seq, indexer.LastSeq())
+	}
+	if stats := indexer.Stats(); stats.Docs != 0 || stats.Deleted != 1 {
+		t.Fatalf("stats after sweep: %+v", stats)
+	}
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "新闻"), 0, 0, nil); len(docs) != 0 {
+		t.Fatalf("expired doc still searchable: %v", docs)
+	}
+}
+
+func TestBatchAddDoc(t *testing.T) {
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, dbType, util.RootPath+"data/local_db/batch_badger"); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+	indexer.BatchDeleteDoc([]string{"batch_1", "batch_2", "batch_3"})
+
+	docs := []types.Document{
+		{Id: "batch_1", Keywords: []*types.Keyword{{Field: "content", Word: "批量"}}},
+		{Id: "batch_2", Keywords: []*types.Keyword{{Field: "content", Word: "批量"}}},
+		{Id: "batch_2", Keywords: []*types.Keyword{{Field: "content", Word: "批量"}, {Field: "content", Word: "重复"}}},
+		{Id: "batch_3", Keywords: []*types.Keyword{{Field: "content", Word: "批量"}}, Version: 5}, // 文档不存在，版本冲突
+		{Id: ""},
+	}
+	n, docErrors := indexer.BatchAddDoc(context.Background(), docs)
+	if n != 2 || len(docErrors) != 2 {
+		t.Fatalf("batch add: n %d, errors %v", n, docErrors)
+	}
+	for _, docError := range docErrors {
+		fmt.Println(docError.DocId, codes.Code(docError.Code), docError.Reason)
+	}
+
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "批量"), 0, 0, nil); len(docs) != 2 {
+		t.Fatalf("search after batch add: %v", docs)
+	}
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "重复"), 0, 0, nil); len(docs) != 1 || docs[0].Id != "batch_2" {
+		t.Fatalf("the last duplicated doc should win: %v", docs)
+	}
+
+	// 再写一次，旧的倒排需要被替换掉
+	n, docErrors = indexer.BatchAddDoc(context.Background(), docs[:2])
+	if n != 2 || len(docErrors) != 0 {
+		t.Fatalf("batch add again: n %d, errors %v", n, docErrors)
+	}
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "重复"), 0, 0, nil); len(docs) != 0 {
+		t.Fatalf("stale keyword still searchable: %v", docs)
+	}
+
+	// 与DeleteDoc一样忽略docId首尾的空白
+	if n, err := indexer.BatchDeleteDoc([]string{" batch_1", "batch_2 ", "batch_3", " "}); err != nil || n != 2 {
+		t.Fatalf("batch delete: n %d, err %v", n, err)
+	}
+	if n := indexer.Count(context.Background()); n != 0 {
+		t.Fatalf("%d docs left after batch delete", n)
+	}
+}
+
+// 批量写入正排索引失败时，预写日志中要补上文档实际的状态，重放时不能写入失败的文档
+func TestBatchAddDocWALRevert(t *testing.T) {
+	dir := t.TempDir()
+	walDir := filepath.Join(dir, "revert_bolt_wal")
+	indexer := new(service.Indexer).WithWAL(walDir)
+	if err := indexer.Init(100, kvdb.BOLT, filepath.Join(dir, "revert_bolt")); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+
+	keywords := []*types.Keyword{{Field: "content", Word: "回滚"}}
+	version, err := indexer.AddDocWithVersion(types.Document{Id: "revert_0", Keywords: keywords})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bolt的key最长32KB，整批写入失败
+	hugeId := strings.Repeat("x", 40000)
+	docs := []types.Document{
+		{Id: "revert_0", Keywords: []*types.Keyword{{Field: "content", Word: "覆盖"}}},
+		{Id: hugeId, Keywords: keywords},
+	}
+	if n, errs := indexer.BatchAddDoc(context.Background(), docs); n != 0 || len(errs) != 2 {
+		t.Fatalf("batch add: n %d, errors %v", n, errs)
+	}
+
+	// 复制一份预写日志，模拟此时宕机后重放
+	copyDir := filepath.Join(dir, "copy_wal")
+	if err := os.MkdirAll(copyDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(walDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(walDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(copyDir, entry.Name()), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wal, err := service.OpenWAL(copyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	replayed := make(map[string]*service.WALRecord)
+	if _, err := wal.Replay(func(record *service.WALRecord) error {
+		replayed[record.DocId] = record
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if record := replayed["revert_0"]; record == nil || record.Op != service.WALPut || record.Doc.Version != version {
+		t.Fatalf("last wal record of revert_0: %v", record)
+	}
+	if record := replayed[hugeId]; record == nil || record.Op != service.WALDelete {
+		t.Fatalf("failed doc is still in wal: %v", record)
+	}
+}
+
+func TestMigrateForwardIndex(t *testing.T) {
+	path := util.RootPath + "data/local_db/migrate_badger"
+	db, err := kvdb.GetKeyValueDB(dbType, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟旧版本用gob编码写入的文档
+	for i, word := range []string{"迁移", "兼容"} {
+		doc := types.Document{Id: fmt.Sprintf("legacy_%d", i), IntId: uint64(i + 1), Keywords: []*types.Keyword{{Field: "content", Word: word}}}
+		var value bytes.Buffer
+		if err := gob.NewEncoder(&value).Encode(doc); err != nil {
+			t.Fatal(err)
+		}
+		db.Set([]byte(doc.Id), value.Bytes())
+	}
+	db.Close()
+
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, dbType, path); err != nil {
+		t.Fatal(err)
+	}
+	indexer.LoadFromIndexFile()
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "迁移"), 0, 0, nil); len(docs) != 1 {
+		t.Fatalf("search gob encoded doc: %v", docs)
+	}
+	if _, err := indexer.MigrateForwardIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "兼容"), 0, 0, nil); len(docs) != 1 || docs[0].IntId != 2 {
+		t.Fatalf("search migrated doc: %v", docs)
+	}
+	indexer.Close()
+
+	db, err = kvdb.GetKeyValueDB(dbType, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.IterDB(func(k, v []byte) error {
+		if v[0] != 1 {
+			t.Errorf("doc %s is not migrated, first byte %d", k, v[0])
+		}
+		return nil
+	})
+}
+
+func TestCompression(t *testing.T) {
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, dbType, util.RootPath+"data/local_db/compression_badger"); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+
+	content := []byte(strings.Repeat("冰雪奇缘2 中文版电影原声带 (Frozen 2 (Mandarin Original Motion Picture", 20))
+	for i, name := range []string{"zstd", "snappy", "none"} {
+		compression, err := service.ParseCompression(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexer.SetCompression(compression) // 不同压缩算法写入的文档可以共存
+		doc := types.Document{
+			Id:       fmt.Sprintf("compression_%d", i),
+			Keywords: []*types.Keyword{{Field: "content", Word: "压缩"}},
+			Bytes:    content,
+		}
+		if _, err := indexer.AddDoc(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs := mustSearch(t, indexer, types.NewTermQuery("content", "压缩"), 0, 0, nil)
+	if len(docs) != 3 {
+		t.Fatalf("search compressed docs: %d", len(docs))
+	}
+	for _, doc := range docs {
+		if !bytes.Equal(doc.Bytes, content) {
+			t.Fatalf("doc %s content mismatch", doc.Id)
+		}
+	}
+
+	stats := indexer.CompressionStats()
+	fmt.Printf("codec %s, records %d, compressed %d, raw %d bytes, stored %d bytes, ratio %.3f\n",
+		stats.Codec, stats.Records, stats.Compressed, stats.RawBytes, stats.StoredBytes, stats.Ratio())
+	if stats.Compressed != 2 || stats.Ratio() >= 1 {
+		t.Fatalf("unexpected compression stats: %+v", stats)
+	}
+	if _, err := service.ParseCompression("lz4"); err == nil {
+		t.Fatal("lz4 should be unsupported")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	source := new(service.Indexer)
+	if err := source.Init(100, dbType, util.RootPath+"data/local_db/snapshot_source_badger"); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	for i, word := range []string{"备份", "恢复", "快照"} {
+		doc := types.Document{Id: fmt.Sprintf("doc_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: word}}}
+		if i == 2 {
+			doc.ExpireAt = time.Now().Add(time.Hour).Unix()
+		}
+		source.AddDoc(context.Background(), doc)
+	}
+
+	var full, incremental bytes.Buffer
+	info, err := source.WriteSnapshot(&full, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.DeleteDoc(context.Background(), "doc_0")
+	source.AddDoc(context.Background(), types.Document{Id: "doc_3", Keywords: []*types.Keyword{{Field: "content", Word: "增量"}}})
+	if _, err := source.WriteSnapshot(&incremental, info.Version, true); err != nil {
+		t.Fatal(err)
+	}
+	if meta, err := service.ReadSnapshotInfo(bytes.NewReader(incremental.Bytes())); err != nil || meta.HasReverseIndex || meta.Since != info.Version {
+		t.Fatalf("incremental snapshot info %+v, err %v", meta, err)
+	}
+
+	target := new(service.Indexer)
+	if err := target.Init(100, dbType, util.RootPath+"data/local_db/snapshot_target_badger"); err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	target.AddDoc(context.Background(), types.Document{Id: "stale", Keywords: []*types.Keyword{{Field: "content", Word: "备份"}}})
+
+	if restored, err := target.ReadSnapshot(&full); err != nil || !restored.HasReverseIndex {
+		t.Fatalf("restore full snapshot %+v, err %v", restored, err)
+	}
+	if docs := mustSearch(t, target, types.NewTermQuery("content", "备份"), 0, 0, nil); len(docs) != 1 || docs[0].Id != "doc_0" {
+		t.Fatalf("search after full restore: %v", docs)
+	}
+	if _, err := target.ReadSnapshot(&incremental); err != nil {
+		t.Fatal(err)
+	}
+	if n := target.Count(context.Background()); n != 3 {
+		t.Fatalf("count after incremental restore %d", n)
+	}
+	if docs := mustSearch(t, target, types.NewTermQuery("content", "备份"), 0, 0, nil); len(docs) != 0 {
+		t.Fatalf("deleted doc is restored: %v", docs)
+	}
+	if docs := mustSearch(t, target, types.NewTermQuery("content", "增量"), 0, 0, nil); len(docs) != 1 {
+		t.Fatalf("search after incremental restore: %v", docs)
+	}
+}
+
+func TestWAL(t *testing.T) {
+	dbPath := util.RootPath + "data/local_db/wal_badger"
+	walDir := dbPath + "_wal"
+	cleanup := func() {
+		os.RemoveAll(dbPath)
+		os.RemoveAll(walDir)
+	}
+	cleanup() // 上次运行留下的数据会被当成已有的索引
+	t.Cleanup(cleanup)
+
+	// 模拟写入预写日志之后、修改索引之前宕机
+	wal, err := service.OpenWAL(walDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &types.Document{Id: "wal_0", IntId: 1, Version: 1, Keywords: []*types.Keyword{{Field: "content", Word: "日志"}}}
+	if err := wal.Append(&service.WALRecord{Op: service.WALPut, DocId: doc.Id, Doc: doc}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	indexer := new(service.Indexer).WithWAL(walDir)
+	if err := indexer.Init(100, dbType, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	indexer.LoadFromIndexFile()
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "日志"), 0, 0, nil); len(docs) != 1 || docs[0].Id != "wal_0" {
+		t.Fatalf("search replayed doc: %v", docs)
+	}
+
+	// 并发写入共用fsync，每个操作都有自己的序号
+	seq := indexer.LastSeq()
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			indexer.AddDoc(context.Background(), types.Document{Id: fmt.Sprintf("wal_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "日志"}}})
+		}(i)
+	}
+	wg.Wait()
+	indexer.DeleteDoc(context.Background(), "wal_0")
+	if n := indexer.LastSeq() - seq; n != 21 {
+		t.Fatalf("expect 21 wal records, got %d", n)
+	}
+	indexer.Close()
+
+	indexer = new(service.Indexer).WithWAL(walDir)
+	if err := indexer.Init(100, dbType, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+	if n := indexer.Count(context.Background()); n != 20 {
+		t.Fatalf("count after reopen %d", n)
+	}
+	if indexer.LastSeq() != seq+21 {
+		t.Fatalf("wal sequence is not persisted: %d", indexer.LastSeq())
+	}
+}
+
+// 异常退出时预写日志中还有没持久化的记录，重启重放后文档数和累计删除数仍然正确
+func TestWALReplayCounter(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "counter_bolt")
+	walDir := dbPath + "_wal"
+	ctx := context.Background()
+
+	indexer := new(service.Indexer).WithWAL(walDir)
+	if err := indexer.Init(100, kvdb.BOLT, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		indexer.AddDoc(ctx, types.Document{Id: fmt.Sprintf("counter_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "计数"}}})
+	}
+	indexer.DeleteDoc(ctx, "counter_0")
+	indexer.DeleteDoc(ctx, "counter_1")
+	if err := indexer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟写入预写日志之后、修改索引之前宕机：删除一个已有文档，新增一个文档
+	wal, err := service.OpenWAL(walDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &types.Document{Id: "counter_5", IntId: 5, Version: 1, Keywords: []*types.Keyword{{Field: "content", Word: "计数"}}}
+	if err := wal.Append(&service.WALRecord{Op: service.WALDelete, DocId: "counter_2"}, &service.WALRecord{Op: service.WALPut, DocId: doc.Id, Doc: doc}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	indexer = new(service.Indexer).WithWAL(walDir)
+	if err := indexer.Init(100, kvdb.BOLT, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if stats := indexer.Stats(); stats.Docs != 3 || stats.Deleted != 3 {
+		t.Fatalf("after replay docs=%d deleted=%d", stats.Docs, stats.Deleted)
+	}
+	if err := indexer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重放时的checkpoint没有丢掉累计删除数
+	indexer = new(service.Indexer).WithWAL(walDir)
+	if err := indexer.Init(100, kvdb.BOLT, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+	if stats := indexer.Stats(); stats.Docs != 3 || stats.Deleted != 3 {
+		t.Fatalf("after reopen docs=%d deleted=%d", stats.Docs, stats.Deleted)
+	}
+}
+
+func TestEncryptedIndexer(t *testing.T) {
+	dbPath := util.RootPath + "data/local_db/encrypted_bolt"
+	walDir := dbPath + "_wal"
+	os.RemoveAll(dbPath)
+	os.RemoveAll(walDir)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	// 预写日志中的文档内容也是密文
+	wal, err := service.OpenEncryptedWAL(walDir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &types.Document{Id: "enc_0", IntId: 1, Version: 1, Bytes: []byte("敏感内容"), Keywords: []*types.Keyword{{Field: "content", Word: "加密"}}}
+	if err := wal.Append(&service.WALRecord{Op: service.WALPut, DocId: doc.Id, Doc: doc}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+	walData, _ := os.ReadFile(filepath.Join(walDir, "wal.log"))
+	if bytes.Contains(walData, []byte("敏感内容")) {
+		t.Fatal("wal stores documents in plain form")
+	}
+
+	indexer := new(service.Indexer).WithWAL(walDir).WithEncryptionKey(key)
+	if err := indexer.Init(100, kvdb.BOLT, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	indexer.LoadFromIndexFile()
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "加密"), 0, 0, nil); len(docs) != 1 || string(docs[0].Bytes) != "敏感内容" {
+		t.Fatalf("search replayed doc: %v", docs)
+	}
+	indexer.Close()
+
+	if data, _ := os.ReadFile(dbPath); bytes.Contains(data, []byte("敏感内容")) {
+		t.Fatal("forward index stores documents in plain form")
+	}
+	if err := new(service.Indexer).Init(100, kvdb.BOLT, dbPath); !errors.Is(err, kvdb.ErrEncryptionKeyRequired) {
+		t.Fatalf("open without key: %v", err)
+	}
+
+	// 更换主密钥前预写日志必须已经全部持久化，换密钥后用新密钥写日志
+	wal, err = service.OpenEncryptedWAL(walDir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc = &types.Document{Id: "enc_1", IntId: 2, Version: 1, Keywords: []*types.Keyword{{Field: "content", Word: "加密"}}}
+	if err := wal.Append(&service.WALRecord{Op: service.WALPut, DocId: doc.Id, Doc: doc}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+	if err := service.ClearWAL(walDir, key); !errors.Is(err, service.ErrWALPending) {
+		t.Fatalf("clear wal with pending records: %v", err)
+	}
+	indexer = new(service.Indexer).WithWAL(walDir).WithEncryptionKey(key)
+	if err := indexer.Init(100, kvdb.BOLT, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	indexer.Close()
+	if err := service.ClearWAL(walDir, key); err != nil {
+		t.Fatal(err)
+	}
+	newKey := bytes.Repeat([]byte{9}, 32)
+	if err := kvdb.RewrapKey(kvdb.BOLT, dbPath, key, newKey); err != nil {
+		t.Fatal(err)
+	}
+	indexer = new(service.Indexer).WithWAL(walDir).WithEncryptionKey(newKey)
+	if err := indexer.Init(100, kvdb.BOLT, dbPath); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+	indexer.LoadFromIndexFile()
+	if docs := mustSearch(t, indexer, types.NewTermQuery("content", "加密"), 0, 0, nil); len(docs) != 2 {
+		t.Fatalf("search after rekey: %v", docs)
+	}
+}
+
+func TestStats(t *testing.T) {
+	path := util.RootPath + "data/local_db/stats_bolt"
+	os.Remove(path)
+	os.Remove(path + ".stats")
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, kvdb.BOLT, path); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		keywords := []*types.Keyword{{Field: "content", Word: "统计"}, {Field: "author", Word: fmt.Sprintf("作者%d", i%3)}}
+		indexer.AddDoc(context.Background(), types.Document{Id: fmt.Sprintf("stats_%d", i), Keywords: keywords})
+	}
+	indexer.AddDoc(context.Background(), types.Document{Id: "stats_0", Keywords: []*types.Keyword{{Field: "content", Word: "统计"}}}) //覆盖不改变文档数
+	indexer.DeleteDoc(context.Background(), "stats_9")
+	indexer.BatchDeleteDoc([]string{"stats_8", "stats_7", "not_exists"})
+	// 过期前被删除的文档不会被重复计数
+	indexer.AddDoc(context.Background(), types.Document{Id: "stats_expire", Keywords: []*types.Keyword{{Field: "content", Word: "统计"}}, ExpireAt: time.Now().Unix() + 1})
+	indexer.AddDoc(context.Background(), types.Document{Id: "stats_deleted", Keywords: []*types.Keyword{{Field: "content", Word: "统计"}}, ExpireAt: time.Now().Unix() + 1})
+	indexer.DeleteDoc(context.Background(), "stats_deleted")
+	time.Sleep(1100 * time.Millisecond)
+	if n := indexer.SweepExpired(); n != 1 {
+		t.Fatalf("sweep %d expired docs", n)
+	}
+	if indexer.Count(context.Background()) != 7 || indexer.DeletedCount() != 5 {
+		t.Fatalf("count=%d deleted=%d", indexer.Count(context.Background()), indexer.DeletedCount())
+	}
+
+	stats := indexer.Stats()
+	if stats.FieldTerms["content"] != 1 || stats.FieldTerms["author"] != 3 {
+		t.Fatalf("field terms %v", stats.FieldTerms)
+	}
+	// 倒排链长度：content 7，作者0 2（stats_3、stats_6），作者1 2（stats_1、stats_4），作者2 2（stats_2、stats_5）
+	if stats.Postings != 13 || stats.PostingMax != 7 || stats.PostingP50 != 2 {
+		t.Fatalf("postings=%d max=%d p50=%d", stats.Postings, stats.PostingMax, stats.PostingP50)
+	}
+	if stats.ForwardIndexBytes == 0 || stats.ReverseIndexBytes == 0 {
+		t.Fatalf("forward=%d reverse=%d", stats.ForwardIndexBytes, stats.ReverseIndexBytes)
+	}
+	indexer.Close()
+
+	// 正常退出后计数从文件中恢复
+	indexer = new(service.Indexer)
+	if err := indexer.Init(100, kvdb.BOLT, path); err != nil {
+		t.Fatal(err)
+	}
+	defer indexer.Close()
+	if indexer.Count(context.Background()) != 7 || indexer.DeletedCount() != 5 {
+		t.Fatalf("after reopen count=%d deleted=%d", indexer.Count(context.Background()), indexer.DeletedCount())
+	}
+}
+
+// 检索出错时终止测试
+func mustSearch(t *testing.T, indexer service.IIndexer, query *types.TermQuery, onFlag, offFlag uint64, orFlags []uint64) []*types.Document {
+	t.Helper()
+	docs, err := indexer.Search(context.Background(), query, onFlag, offFlag, orFlags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
@@ -1,6 +1,8 @@
 package servicetest
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
@@ -27,7 +29,9 @@ func testLB(balancer service.LoadBalancer) {
 			for i := 0; i < LOOP; i++ {
 				endpoint := balancer.Take(endpoints)
 				selected <- endpoint
-				time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
+				latency := time.Duration(rand.Intn(50)) * time.Millisecond
+				time.Sleep(latency)
+				balancer.Done(endpoint, latency, nil)
 			}
 		}()
 	}
@@ -62,3 +66,123 @@ func TestRoudRobin(t *testing.T) {
 	balancer = new(service.RoundRobin)
 	testLB(balancer)
 }
+
+func TestLeastOutstanding(t *testing.T) {
+	balancer = service.NewLeastOutstanding()
+	testLB(balancer)
+
+	lb := service.NewLeastOutstanding()
+	busy := lb.Take(endpoints[:1])
+	lb.Take(endpoints[:1])
+	for range 10 {
+		endpoint := lb.Take(endpoints)
+		if endpoint == busy {
+			t.Fatalf("busy endpoint %s should not be selected", busy)
+		}
+		lb.Done(endpoint, time.Millisecond, nil)
+	}
+
+	// 长时间没有作为候选的worker的状态被删除，重新上线后不再被当作繁忙
+	defer func(ttl time.Duration) { service.LoadBalancerStateTTL = ttl }(service.LoadBalancerStateTTL)
+	service.LoadBalancerStateTTL = 20 * time.Millisecond
+	lb = service.NewLeastOutstanding()
+	for range 5 {
+		lb.Take([]string{"offline"})
+	}
+	time.Sleep(2 * service.LoadBalancerStateTTL)
+	lb.Take([]string{"online"})
+	if endpoint := lb.Take([]string{"offline", "online"}); endpoint != "offline" {
+		t.Fatalf("state of the offline endpoint was not pruned, took %s", endpoint)
+	}
+}
+
+func TestPowerOfTwoChoices(t *testing.T) {
+	balancer = service.NewPowerOfTwoChoices()
+	testLB(balancer)
+
+	// 只有两台时每次都会比较这两台
+	lb := service.NewPowerOfTwoChoices()
+	busy := lb.Take(endpoints[:1])
+	for range 10 {
+		endpoint := lb.Take(endpoints[:2])
+		if endpoint == busy {
+			t.Fatalf("busy endpoint %s should not be selected", busy)
+		}
+		lb.Done(endpoint, time.Millisecond, nil)
+	}
+}
+
+func TestPeakEWMA(t *testing.T) {
+	balancer = service.NewPeakEWMA()
+	testLB(balancer)
+
+	lb := service.NewPeakEWMA()
+	for _, endpoint := range endpoints {
+		lb.Take([]string{endpoint})
+		latency := time.Millisecond
+		if endpoint == endpoints[0] {
+			latency = 100 * time.Millisecond
+		}
+		lb.Done(endpoint, latency, nil)
+	}
+	for range 10 {
+		endpoint := lb.Take(endpoints)
+		if endpoint == endpoints[0] {
+			t.Fatalf("slow endpoint %s should not be selected", endpoint)
+		}
+		lb.Done(endpoint, time.Millisecond, nil)
+	}
+	// 失败按惩罚延迟计入，取消不计入
+	lb.Take(endpoints[1:2])
+	lb.Done(endpoints[1], time.Millisecond, errors.New("unavailable"))
+	lb.Take(endpoints[2:3])
+	lb.Done(endpoints[2], time.Millisecond, context.Canceled)
+	if endpoint := lb.Take(endpoints); endpoint != endpoints[2] {
+		t.Fatalf("expect %s, got %s", endpoints[2], endpoint)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	balancer = service.NewConsistentHash()
+	testLB(balancer)
+
+	lb := service.NewConsistentHash()
+	keys := make([]string, 1000)
+	owners := make(map[string]string, len(keys))
+	counts := make(map[string]int, len(endpoints))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("content=%d", i)
+		owners[keys[i]] = lb.TakeByKey(endpoints, keys[i])
+		counts[owners[keys[i]]]++
+	}
+	for _, endpoint := range endpoints {
+		if counts[endpoint] < len(keys)/len(endpoints)/2 {
+			t.Fatalf("endpoint %s only owns %d keys: %v", endpoint, counts[endpoint], counts)
+		}
+	}
+	// 顺序不同的同一组worker结果相同
+	reversed := []string{endpoints[2], endpoints[1], endpoints[0]}
+	for _, key := range keys {
+		if endpoint := lb.TakeByKey(reversed, key); endpoint != owners[key] {
+			t.Fatalf("key %s moved from %s to %s", key, owners[key], endpoint)
+		}
+	}
+	// 去掉一台worker，只有属于它的key改变归属
+	for _, key := range keys {
+		endpoint := lb.TakeByKey(endpoints[1:], key)
+		if owners[key] != endpoints[0] && endpoint != owners[key] {
+			t.Fatalf("key %s moved from %s to %s", key, owners[key], endpoint)
+		}
+	}
+}
+
+func TestParseLoadBalancer(t *testing.T) {
+	for _, name := range []string{"", "round-robin", "random", "least-outstanding", "peak-ewma", "p2c", "consistent-hash"} {
+		if _, err := service.ParseLoadBalancer(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := service.ParseLoadBalancer("fastest"); err == nil {
+		t.Fatal("expect error for unknown load balancer")
+	}
+}
@@ -0,0 +1,180 @@
+package servicetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/service"
+	"github.com/WlayRay/ElectricSearch/types"
+)
+
+func addLocalDocs(t *testing.T, sentinel *service.Sentinel, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		doc := types.Document{Id: fmt.Sprintf("doc-%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "go"}}}
+		if _, err := sentinel.AddDoc(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func waitCount(t *testing.T, indexer service.IIndexer, want int) {
+	t.Helper()
+	for deadline := time.Now().Add(3 * time.Second); indexer.Count(context.Background()) != want; {
+		if time.Now().After(deadline) {
+			t.Fatalf("count %d, want %d", indexer.Count(context.Background()), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLocalCluster(t *testing.T) {
+	cluster := service.NewLocalCluster(3, 2)
+	if err := cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	addLocalDocs(t, cluster.Sentinel, 0, 60)
+	// 没有主从复制时写请求发给分片内的所有worker
+	total := 0
+	for shard := range 3 {
+		n := cluster.Worker(shard, 0).Indexer.Count(context.Background())
+		if n == 0 || n == 60 {
+			t.Fatalf("shard %d has %d docs", shard, n)
+		}
+		if m := cluster.Worker(shard, 1).Indexer.Count(context.Background()); m != n {
+			t.Fatalf("replicas of shard %d have %d and %d docs", shard, n, m)
+		}
+		total += n
+	}
+	if total != 60 {
+		t.Fatalf("shards have %d docs in total", total)
+	}
+	if docs := mustSearch(t, cluster.Sentinel, types.NewTermQuery("content", "go"), 0, 0, nil); len(docs) != 60 {
+		t.Fatalf("found %d docs", len(docs))
+	}
+
+	// 停止一个worker后同分片的另一个worker继续提供检索
+	if err := cluster.StopWorker(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := cluster.Sentinel.Count(context.Background()); n != 60 {
+		t.Fatalf("count %d after stopping a worker", n)
+	}
+	if err := cluster.StartWorker(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.StartWorker(1, 0); err == nil {
+		t.Fatal("started a running worker")
+	}
+
+	// 没有主节点时任一worker写入失败，这次写入不算成功。停止的worker仍然留在注册中心，模拟worker不可达
+	if err := cluster.StopWorker(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.Hub.Register("group-2", cluster.Endpoint(2, 1)); err != nil {
+		t.Fatal(err)
+	}
+	partial := 0
+	for i := 60; i < 80; i++ {
+		doc := types.Document{Id: fmt.Sprintf("doc-%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "go"}}}
+		n, err := cluster.Sentinel.AddDoc(context.Background(), doc)
+		if errors.Is(err, service.ErrPartialWrite) {
+			if n != 0 {
+				t.Fatalf("partial write of %s affected %d docs", doc.Id, n)
+			}
+			partial++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if partial == 0 {
+		t.Fatal("no partial write reported")
+	}
+}
+
+func TestLocalClusterFailover(t *testing.T) {
+	interval := service.ReplicationRetryInterval
+	service.ReplicationRetryInterval = 20 * time.Millisecond
+	defer func() { service.ReplicationRetryInterval = interval }()
+
+	cluster := service.NewLocalCluster(2, 3).WithReplication(true)
+	if err := cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	addLocalDocs(t, cluster.Sentinel, 0, 40)
+	for shard := range 2 {
+		if primary := cluster.Primary(shard); primary != 0 {
+			t.Fatalf("primary of shard %d is %d", shard, primary)
+		}
+		n := cluster.Worker(shard, 0).Indexer.Count(context.Background())
+		for replica := 1; replica < 3; replica++ {
+			waitCount(t, cluster.Worker(shard, replica).Indexer, n)
+		}
+	}
+
+	// 主节点宕机后下一个worker接替，写请求发给新的主节点，剩下的从节点跟随新的主节点
+	if err := cluster.StopWorker(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if primary := cluster.Primary(0); primary != 1 {
+		t.Fatalf("primary of shard 0 is %d after failover", primary)
+	}
+	addLocalDocs(t, cluster.Sentinel, 40, 80)
+	if docs := mustSearch(t, cluster.Sentinel, types.NewTermQuery("content", "go"), 0, 0, nil); len(docs) != 80 {
+		t.Fatalf("found %d docs after failover", len(docs))
+	}
+	n := cluster.Worker(0, 1).Indexer.Count(context.Background())
+	waitCount(t, cluster.Worker(0, 2).Indexer, n)
+
+	// 原来的主节点重启后作为从节点追上新的主节点
+	if err := cluster.StartWorker(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if primary := cluster.Primary(0); primary != 1 {
+		t.Fatalf("primary of shard 0 is %d after restart", primary)
+	}
+	waitCount(t, cluster.Worker(0, 0).Indexer, n)
+	if cluster.Worker(0, 0).Replicator.IsPrimary() {
+		t.Fatal("restarted worker is still primary")
+	}
+}
+
+func TestLocalClusterMigratingCount(t *testing.T) {
+	cluster := service.NewLocalCluster(2, 1)
+	if err := cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	addLocalDocs(t, cluster.Sentinel, 0, 40)
+
+	// 把槽位0的文档复制到group-1，模拟迁移中两个group都有这些文档
+	request := &service.SlotRequest{Slots: 2, Slot: 0}
+	copied := 0
+	if err := cluster.Worker(0, 0).Indexer.ScanSlot(request, func(doc *types.Document) error {
+		copied++
+		return cluster.Worker(1, 0).Indexer.ImportDoc(doc)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if copied == 0 {
+		t.Fatal("no doc in slot 0")
+	}
+
+	shardMap := cluster.Hub.GetShardMap()
+	shardMap.Migrations = []service.SlotMigration{{Slot: 0, From: 0, To: 1, State: service.MigrationCopying}}
+	if n := cluster.Sentinel.Count(context.Background()); n != 40 {
+		t.Fatalf("count %d while copying", n)
+	}
+	shardMap.Slots[0] = 1
+	shardMap.Migrations[0].State = service.MigrationCleaning
+	if n := cluster.Sentinel.Count(context.Background()); n != 40 {
+		t.Fatalf("count %d while cleaning", n)
+	}
+}
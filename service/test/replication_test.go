@@ -0,0 +1,416 @@
+package servicetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/service"
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func newReplicaWorker(t *testing.T, path string) *service.IndexServiceWorker {
+	indexer := new(service.Indexer)
+	if err := indexer.Init(100, kvdb.BOLT, path); err != nil {
+		t.Fatal(err)
+	}
+	replicator := service.NewReplicator(indexer, path+".replication")
+	if err := replicator.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return &service.IndexServiceWorker{Indexer: indexer, Replicator: replicator}
+}
+
+// 在后台从主节点追赶，返回的函数停止追赶
+func followPrimary(replicator *service.Replicator, client service.IndexServiceClient) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		replicator.CatchUp(ctx, client)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func waitApplied(t *testing.T, replicator *service.Replicator, seq uint64) {
+	for deadline := time.Now().Add(3 * time.Second); replicator.AppliedSeq() < seq; {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica applied %d, want %d", replicator.AppliedSeq(), seq)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	primaryPath := util.RootPath + "data/local_db/replication_primary"
+	replicaPath := util.RootPath + "data/local_db/replication_replica"
+	for _, path := range []string{primaryPath, replicaPath} {
+		os.Remove(path)
+		os.Remove(path + ".stats")
+		os.Remove(path + ".replication")
+	}
+
+	primary := newReplicaWorker(t, primaryPath)
+	defer primary.Close()
+	primary.Replicator.Promote(1) // 没有etcd，手动指定主节点
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	service.RegisterIndexServiceServer(server, primary)
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := service.NewIndexServiceClient(conn)
+	ctx := context.Background()
+
+	replica := newReplicaWorker(t, replicaPath)
+	stop := followPrimary(replica.Replicator, client)
+
+	for i := 0; i < 5; i++ {
+		doc := &types.Document{Id: fmt.Sprintf("rep_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "复制"}}}
+		if _, err := client.AddDoc(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.UpdateDoc(ctx, &types.DocPatch{Id: "rep_0", AddKeywords: []*types.Keyword{{Field: "content", Word: "更新"}}})
+	client.DeleteDoc(ctx, &service.DocId{DocId: "rep_4"})
+	// 失败的写入（例如版本冲突）不分配序号
+	if _, err := client.DeleteDoc(ctx, &service.DocId{DocId: "rep_1", ExpectedVersion: 9}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("delete with wrong version: %v", err)
+	}
+	if seq := primary.Replicator.AppliedSeq(); seq != 7 {
+		t.Fatalf("primary applied seq %d", seq)
+	}
+	waitApplied(t, replica.Replicator, 7)
+	if n := replica.Indexer.Count(context.Background()); n != 4 {
+		t.Fatalf("replica count %d", n)
+	}
+	docs := mustSearch(t, replica.Indexer, types.NewTermQuery("content", "更新"), 0, 0, nil)
+	if len(docs) != 1 {
+		t.Fatalf("replica updated doc: %v", docs)
+	}
+	primaryDocs := mustSearch(t, primary.Indexer, types.NewTermQuery("content", "更新"), 0, 0, nil)
+	if docs[0].IntId != primaryDocs[0].IntId || docs[0].Version != primaryDocs[0].Version {
+		t.Fatalf("replica doc %v, primary doc %v", docs[0], primaryDocs[0])
+	}
+	// 从节点不接受写请求
+	if _, err := replica.AddDoc(ctx, &types.Document{Id: "rep_x"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("write to replica: %v", err)
+	}
+
+	// 从节点下线期间主节点继续写入，重启后从持久化的序号追赶
+	stop()
+	if err := replica.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 5; i < 10; i++ {
+		client.AddDoc(ctx, &types.Document{Id: fmt.Sprintf("rep_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "复制"}}})
+	}
+	client.DeleteDoc(ctx, &service.DocId{DocId: "rep_0"})
+
+	replica = newReplicaWorker(t, replicaPath)
+	defer replica.Close()
+	if seq := replica.Replicator.AppliedSeq(); seq != 7 {
+		t.Fatalf("replica applied seq after restart %d", seq)
+	}
+	stop = followPrimary(replica.Replicator, client)
+	defer stop()
+	waitApplied(t, replica.Replicator, primary.Replicator.AppliedSeq())
+	if n, m := replica.Indexer.Count(context.Background()), primary.Indexer.Count(context.Background()); n != m || n != 8 {
+		t.Fatalf("replica count %d, primary count %d", n, m)
+	}
+
+	// 超出日志范围的序号无法追赶
+	stream, err := client.FetchOps(ctx, &service.FetchOpsRequest{Since: 1000})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.OutOfRange {
+		t.Fatalf("fetch ops out of range: %v", err)
+	}
+}
+
+// 启动worker的grpc服务，返回连接它的client
+func serveReplica(t *testing.T, worker *service.IndexServiceWorker) service.IndexServiceClient {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	service.RegisterIndexServiceServer(server, worker)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return service.NewIndexServiceClient(conn)
+}
+
+// 故障切换后新主节点重新分配了旧主节点用过的序号，跟随过旧主节点的从节点不能跳过这些序号，需要从快照恢复
+func TestReplicationTermDivergence(t *testing.T) {
+	paths := make([]string, 3)
+	workers := make([]*service.IndexServiceWorker, 3)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%sdata/local_db/term_%d", util.RootPath, i)
+		os.Remove(paths[i])
+		os.Remove(paths[i] + ".stats")
+		os.Remove(paths[i] + ".replication")
+		workers[i] = newReplicaWorker(t, paths[i])
+		defer workers[i].Close()
+	}
+	oldPrimary, newPrimary, replica := workers[0], workers[1], workers[2]
+	ctx := context.Background()
+	addDoc := func(worker *service.IndexServiceWorker, docId string) {
+		if _, err := worker.AddDoc(ctx, &types.Document{Id: docId, Keywords: []*types.Keyword{{Field: "content", Word: "任期"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldPrimary.Replicator.Promote(1)
+	oldClient := serveReplica(t, oldPrimary)
+	for i := 0; i < 3; i++ {
+		addDoc(oldPrimary, fmt.Sprintf("term_%d", i))
+	}
+	stop := followPrimary(newPrimary.Replicator, oldClient)
+	waitApplied(t, newPrimary.Replicator, 3)
+	stop()
+	// 序号4、5只复制到了replica
+	addDoc(oldPrimary, "term_old_3")
+	addDoc(oldPrimary, "term_old_4")
+	stop = followPrimary(replica.Replicator, oldClient)
+	waitApplied(t, replica.Replicator, 5)
+	stop()
+
+	newPrimary.Replicator.Promote(2)
+	addDoc(newPrimary, "term_new_3")
+	addDoc(newPrimary, "term_new_4")
+	if seq, term := newPrimary.Replicator.AppliedSeq(), newPrimary.Replicator.AppliedTerm(); seq != 5 || term != 2 {
+		t.Fatalf("new primary at seq %d term %d", seq, term)
+	}
+	newClient := serveReplica(t, newPrimary)
+	if err := replica.Replicator.CatchUp(ctx, newClient); status.Code(err) != codes.OutOfRange {
+		t.Fatalf("catch up from a diverged log: %v", err)
+	}
+	if err := replica.Replicator.Recover(ctx, newClient); err != nil {
+		t.Fatal(err)
+	}
+	if seq, term := replica.Replicator.AppliedSeq(), replica.Replicator.AppliedTerm(); seq != 5 || term != 2 {
+		t.Fatalf("replica at seq %d term %d after recovery", seq, term)
+	}
+	docs := mustSearch(t, replica.Indexer, types.NewTermQuery("content", "任期"), 0, 0, nil)
+	ids := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		ids[doc.Id] = true
+	}
+	if len(docs) != 5 || !ids["term_new_3"] || ids["term_old_3"] {
+		t.Fatalf("replica docs after recovery: %v", ids)
+	}
+}
+
+func TestPeerRecovery(t *testing.T) {
+	primaryPath := util.RootPath + "data/local_db/recovery_primary"
+	peerPath := util.RootPath + "data/local_db/recovery_peer"
+	for _, path := range []string{primaryPath, peerPath} {
+		os.Remove(path)
+		os.Remove(path + ".stats")
+		os.Remove(path + ".replication")
+	}
+
+	primary := newReplicaWorker(t, primaryPath)
+	defer primary.Close()
+	primary.Replicator.Promote(1)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	service.RegisterIndexServiceServer(server, primary)
+	go server.Serve(lis)
+	defer server.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := service.NewIndexServiceClient(conn)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		client.AddDoc(ctx, &types.Document{Id: fmt.Sprintf("peer_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "恢复"}}})
+	}
+	client.DeleteDoc(ctx, &service.DocId{DocId: "peer_0"})
+
+	// 新加入的worker从快照恢复，倒排索引随快照一起恢复
+	peer := newReplicaWorker(t, peerPath)
+	defer peer.Close()
+	if err := peer.Replicator.Recover(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	if seq := peer.Replicator.AppliedSeq(); seq != primary.Replicator.AppliedSeq() {
+		t.Fatalf("peer applied %d, primary applied %d", seq, primary.Replicator.AppliedSeq())
+	}
+	if docs := mustSearch(t, peer.Indexer, types.NewTermQuery("content", "恢复"), 0, 0, nil); len(docs) != 19 {
+		t.Fatalf("search %d docs after recovery", len(docs))
+	}
+
+	// 恢复之后继续复制新的操作
+	stop := followPrimary(peer.Replicator, client)
+	defer stop()
+	client.DeleteDoc(ctx, &service.DocId{DocId: "peer_1"})
+	waitApplied(t, peer.Replicator, primary.Replicator.AppliedSeq())
+	if n := peer.Indexer.Count(context.Background()); n != 18 {
+		t.Fatalf("peer count %d", n)
+	}
+
+	// 替换整个索引的操作只能在主节点上执行，之后从节点从快照重新恢复
+	if err := peer.Replicator.Reset(func() error { return nil }); !errors.Is(err, service.ErrNotPrimary) {
+		t.Fatalf("reset on replica: %v", err)
+	}
+	if err := primary.Replicator.Reset(func() error {
+		primary.Indexer.DeleteDoc(ctx, "peer_2")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if err := peer.Replicator.CatchUp(ctx, client); status.Code(err) != codes.OutOfRange {
+		t.Fatalf("catch up after reset: %v", err)
+	}
+	if err := peer.Replicator.Recover(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	if n := peer.Indexer.Count(context.Background()); n != 17 {
+		t.Fatalf("peer count %d after reset", n)
+	}
+}
+
+func TestPeerRecoveryWithoutReplication(t *testing.T) {
+	sourcePath := util.RootPath + "data/local_db/recovery_source"
+	targetPath := util.RootPath + "data/local_db/recovery_target"
+	for _, path := range []string{sourcePath, targetPath} {
+		os.Remove(path)
+		os.Remove(path + ".stats")
+	}
+	newWorker := func(path string) *service.IndexServiceWorker {
+		indexer := new(service.Indexer)
+		if err := indexer.Init(100, kvdb.BOLT, path); err != nil {
+			t.Fatal(err)
+		}
+		return &service.IndexServiceWorker{Indexer: indexer}
+	}
+
+	source := newWorker(sourcePath)
+	defer source.Close()
+	for i := 0; i < 20; i++ {
+		source.Indexer.AddDoc(context.Background(), types.Document{Id: fmt.Sprintf("source_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "恢复"}}})
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	service.RegisterIndexServiceServer(server, source)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	// 没有开启主从复制的worker同样从group中的其他worker恢复，不可用的worker被跳过
+	hub := service.NewMemoryServiceHub(1, false, new(service.RoundRobin))
+	hub.Register("group-0", "127.0.0.1:1")
+	hub.Register("group-0", lis.Addr().String())
+	target := newWorker(targetPath)
+	defer target.Close()
+	target.Hub = hub
+	if !target.RecoverFromPeer() {
+		t.Fatal("recover from peer failed")
+	}
+	if docs := mustSearch(t, target.Indexer, types.NewTermQuery("content", "恢复"), 0, 0, nil); len(docs) != 20 {
+		t.Fatalf("search %d docs after recovery", len(docs))
+	}
+	// 本地已有数据时不再恢复
+	if target.RecoverFromPeer() {
+		t.Fatal("recovered a non-empty index")
+	}
+}
+
+func TestSlotMigration(t *testing.T) {
+	sourcePath := util.RootPath + "data/local_db/slot_source"
+	targetPath := util.RootPath + "data/local_db/slot_target"
+	for _, path := range []string{sourcePath, targetPath} {
+		os.Remove(path)
+		os.Remove(path + ".stats")
+		os.Remove(path + ".replication")
+	}
+	source := newReplicaWorker(t, sourcePath)
+	defer source.Close()
+	source.Replicator.Promote(1)
+	target := newReplicaWorker(t, targetPath)
+	defer target.Close()
+	target.Replicator.Promote(1)
+	ctx := context.Background()
+
+	for i := 0; i < 40; i++ {
+		source.AddDoc(ctx, &types.Document{Id: fmt.Sprintf("slot_%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "迁移"}}})
+	}
+	batch := service.SlotScanBatch
+	service.SlotScanBatch = 7 // 分多批读取
+	defer func() { service.SlotScanBatch = batch }()
+	request := &service.SlotRequest{Slots: 4, Slot: 1}
+	docs := scanSlot(t, source.Indexer, request)
+	if len(docs) == 0 || len(docs) == 40 {
+		t.Fatalf("%d docs in slot", len(docs))
+	}
+	for _, doc := range docs {
+		if err := target.Indexer.ImportDoc(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := mustSearch(t, target.Indexer, types.NewTermQuery("content", "迁移"), 0, 0, nil); len(hits) != len(docs) {
+		t.Fatalf("search %d docs after import, want %d", len(hits), len(docs))
+	}
+	versionRequest := *request
+	versionRequest.VersionOnly = true
+	if versions := scanSlot(t, target.Indexer, &versionRequest); len(versions) != len(docs) || len(versions[0].Keywords) > 0 || versions[0].Version == 0 {
+		t.Fatalf("version only scan: %v", versions)
+	}
+
+	affected, err := source.DropSlot(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(affected.Count) != len(docs) || source.Indexer.Count(context.Background()) != 40-len(docs) {
+		t.Fatalf("dropped %d, %d docs left", affected.Count, source.Indexer.Count(context.Background()))
+	}
+}
+
+func scanSlot(t *testing.T, indexer *service.Indexer, request *service.SlotRequest) []*types.Document {
+	var docs []*types.Document
+	if err := indexer.ScanSlot(request, func(doc *types.Document) error {
+		docs = append(docs, doc)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
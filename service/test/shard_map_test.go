@@ -0,0 +1,84 @@
+package servicetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/WlayRay/ElectricSearch/service"
+	"github.com/dgryski/go-farm"
+)
+
+func TestShardMap(t *testing.T) {
+	shardMap := service.NewShardMap(3, 0)
+	for i := 0; i < 1000; i++ {
+		docId := fmt.Sprintf("doc_%d", i)
+		if group := shardMap.ReadGroup(docId); group != int(farm.Hash32WithSeed([]byte(docId), 0)%3) {
+			t.Fatalf("%s routes to group-%d", docId, group)
+		}
+	}
+
+	// 拆分槽位不改变文档的归属
+	split := shardMap.Clone()
+	split.Split()
+	split.Split()
+	if len(split.Slots) != 12 {
+		t.Fatalf("%d slots after split", len(split.Slots))
+	}
+	for i := 0; i < 1000; i++ {
+		docId := fmt.Sprintf("doc_%d", i)
+		if split.ReadGroup(docId) != shardMap.ReadGroup(docId) {
+			t.Fatalf("%s moved after split", docId)
+		}
+	}
+
+	// 新增group后各group的槽位数大致相同
+	if err := shardMap.PlanAddGroup(3); err != nil {
+		t.Fatal(err)
+	}
+	if len(shardMap.Slots) != 24 || len(shardMap.Migrations) != 6 {
+		t.Fatalf("%d slots, %d migrations", len(shardMap.Slots), len(shardMap.Migrations))
+	}
+	if err := shardMap.PlanAddGroup(4); err == nil {
+		t.Fatal("plan with unfinished migrations")
+	}
+	migration := shardMap.Migrations[0]
+	var docId string
+	for i := 0; ; i++ {
+		if docId = fmt.Sprintf("doc_%d", i); shardMap.SlotOf(docId) == migration.Slot {
+			break
+		}
+	}
+	if groups, err := shardMap.WriteGroups(docId); err != nil || len(groups) != 2 || groups[0] != migration.From || groups[1] != 3 {
+		t.Fatalf("write groups while copying: %v %v", groups, err)
+	}
+	shardMap.Migrations[0].State = service.MigrationSwitching
+	if _, err := shardMap.WriteGroups(docId); err != service.ErrSlotMigrating {
+		t.Fatalf("write while switching: %v", err)
+	}
+
+	// 完成迁移后移除group，槽位交给其他group
+	for _, migration := range shardMap.Migrations {
+		shardMap.Slots[migration.Slot] = migration.To
+	}
+	shardMap.Migrations = nil
+	if groups, err := shardMap.WriteGroups(docId); err != nil || len(groups) != 1 || groups[0] != 3 {
+		t.Fatalf("write groups after migration: %v %v", groups, err)
+	}
+	if err := shardMap.PlanRemoveGroup(0); err != nil {
+		t.Fatal(err)
+	}
+	owned := make(map[int]int)
+	for _, group := range shardMap.Slots {
+		owned[group]++
+	}
+	for _, migration := range shardMap.Migrations {
+		owned[migration.From]--
+		owned[migration.To]++
+	}
+	if owned[0] != 0 || owned[1] != 8 || owned[2] != 8 || owned[3] != 8 {
+		t.Fatalf("slots after removing group-0: %v", owned)
+	}
+	if err := service.NewShardMap(1, 0).PlanRemoveGroup(0); err == nil {
+		t.Fatal("removed the last group")
+	}
+}
@@ -0,0 +1,137 @@
+package servicetest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/service"
+	"github.com/WlayRay/ElectricSearch/types"
+	"google.golang.org/grpc"
+)
+
+func writeRegistry(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStaticServiceHub(t *testing.T) {
+	interval := service.StaticRegistryReloadInterval
+	service.StaticRegistryReloadInterval = 20 * time.Millisecond
+	defer func() { service.StaticRegistryReloadInterval = interval }()
+
+	path := filepath.Join(t.TempDir(), "registry.yml")
+	writeRegistry(t, path, "groups:\n  - [\"10.0.0.1:12308\", \"10.0.0.2:12308\"]\n  - [\"10.0.0.3:12308\"]\n", time.Now())
+	hub, err := service.NewStaticServiceHub(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hub.Close()
+
+	if endpoints := hub.GetServiceEndpoints("group-0"); !slices.Equal(endpoints, []string{"10.0.0.1:12308", "10.0.0.2:12308"}) {
+		t.Fatalf("group-0 endpoints %v", endpoints)
+	}
+	if endpoints := hub.GetServiceEndpoints("group-2"); len(endpoints) > 0 {
+		t.Fatalf("undeclared group-2 has endpoints %v", endpoints)
+	}
+	if err := hub.Register("group-1", "10.0.0.3:12308"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.Register("group-1", "10.0.0.1:12308"); err == nil {
+		t.Fatal("registered worker not listed in group-1")
+	}
+	if primary := hub.GetPrimaryEndpoint("group-0"); primary != "" {
+		t.Fatalf("static registry has primary %s", primary)
+	}
+	if groups := hub.GetShardMap().Groups(); !slices.Equal(groups, []int{0, 1}) {
+		t.Fatalf("shard map groups %v", groups)
+	}
+
+	// 修改文件后自动重新加载
+	writeRegistry(t, path, "groups:\n  - [\"10.0.0.1:12308\"]\n  - [\"10.0.0.3:12308\", \"10.0.0.4:12308\"]\n", time.Now().Add(time.Second))
+	for deadline := time.Now().Add(2 * time.Second); len(hub.GetServiceEndpoints("group-1")) != 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("registry file was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 文件内容无效时保留原来的worker列表
+	writeRegistry(t, path, "groups:\n  - [\"10.0.0.1:12308\"]\n  - [\"10.0.0.1:12308\"]\n", time.Now().Add(2*time.Second))
+	time.Sleep(100 * time.Millisecond)
+	if endpoints := hub.GetServiceEndpoints("group-1"); !slices.Equal(endpoints, []string{"10.0.0.3:12308", "10.0.0.4:12308"}) {
+		t.Fatalf("group-1 endpoints %v after invalid reload", endpoints)
+	}
+
+	// group数量变化会改变文档的路由，不加载
+	writeRegistry(t, path, "groups:\n  - [\"10.0.0.1:12308\"]\n  - [\"10.0.0.3:12308\"]\n  - [\"10.0.0.5:12308\"]\n", time.Now().Add(3*time.Second))
+	time.Sleep(100 * time.Millisecond)
+	if endpoints := hub.GetServiceEndpoints("group-2"); len(endpoints) > 0 {
+		t.Fatalf("group-2 endpoints %v after changing the group count", endpoints)
+	}
+	if groups := hub.GetShardMap().Groups(); !slices.Equal(groups, []int{0, 1}) {
+		t.Fatalf("shard map groups %v after changing the group count", groups)
+	}
+
+	if _, err := service.LoadStaticRegistry(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Fatal("loaded missing registry file")
+	}
+}
+
+// 不依赖etcd，Sentinel通过静态注册文件找到每个group的worker
+func TestSentinelWithStaticHub(t *testing.T) {
+	groups := make([]string, 0, 2)
+	for range 2 {
+		indexer := new(service.Indexer)
+		if err := indexer.Init(100, kvdb.MEMORY, ""); err != nil {
+			t.Fatal(err)
+		}
+		worker := &service.IndexServiceWorker{Indexer: indexer}
+		defer worker.Close()
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := grpc.NewServer()
+		service.RegisterIndexServiceServer(server, worker)
+		go server.Serve(lis)
+		defer server.Stop()
+		groups = append(groups, fmt.Sprintf("  - [\"%s\"]\n", lis.Addr()))
+	}
+	path := filepath.Join(t.TempDir(), "registry.yml")
+	writeRegistry(t, path, "groups:\n"+groups[0]+groups[1], time.Now())
+	hub, err := service.NewStaticServiceHub(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sentinel := service.NewSentinelWithHub(hub)
+	defer sentinel.Close()
+
+	ctx := context.Background()
+	for i := range 20 {
+		doc := types.Document{Id: fmt.Sprintf("doc-%d", i), Keywords: []*types.Keyword{{Field: "content", Word: "go"}}}
+		if _, err := sentinel.AddDoc(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := sentinel.Count(ctx); n != 20 {
+		t.Fatalf("count %d, want 20", n)
+	}
+	if docs := mustSearch(t, sentinel, types.NewTermQuery("content", "go"), 0, 0, nil); len(docs) != 20 {
+		t.Fatalf("found %d docs, want 20", len(docs))
+	}
+	if stats := sentinel.Stats(); len(stats) != 2 {
+		t.Fatalf("stats from %d groups, want 2", len(stats))
+	}
+}
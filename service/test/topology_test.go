@@ -0,0 +1,50 @@
+package servicetest
+
+import (
+	"testing"
+
+	"github.com/WlayRay/ElectricSearch/service"
+)
+
+func TestTopology(t *testing.T) {
+	topology := new(service.Topology)
+	if err := topology.SetIndex("video", 0, 2); err == nil {
+		t.Fatal("declared index without shards")
+	}
+	if err := topology.SetIndex("video", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if index := topology.Index("video"); index == nil || index.Shards != 2 || index.Replicas != 2 {
+		t.Fatalf("index topology %+v", index)
+	}
+	if topology.Index("music") != nil {
+		t.Fatal("undeclared index found")
+	}
+
+	if err := topology.Assign("video", "10.0.0.1:12308", 2); err == nil {
+		t.Fatal("assigned worker to undeclared group")
+	}
+	if err := topology.Assign("video", "10.0.0.1:12308", 1); err != nil {
+		t.Fatal(err)
+	}
+	// 有worker指定在group-1时不能减少分片数
+	if err := topology.SetIndex("video", 1, 2); err == nil {
+		t.Fatal("shrank shards with assigned worker")
+	}
+	if err := topology.Unassign("video", "10.0.0.1:12308"); err != nil {
+		t.Fatal(err)
+	}
+	if err := topology.SetIndex("video", 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := topology.Unassign("video", "10.0.0.1:12308"); err == nil {
+		t.Fatal("unassigned twice")
+	}
+
+	if err := topology.RemoveIndex("video"); err != nil {
+		t.Fatal(err)
+	}
+	if err := topology.RemoveIndex("video"); err == nil {
+		t.Fatal("removed twice")
+	}
+}
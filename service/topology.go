@@ -0,0 +1,329 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/WlayRay/ElectricSearch/util"
+	etcdv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+var ErrTopologyChanged = errors.New("topology was modified concurrently")
+
+// Topology 集群拓扑，保存在etcd中，只能通过管理接口修改。worker启动时按拓扑认领分片中的一个副本位置，
+// 认领记录绑定worker的租约，worker异常退出后租约过期，位置自动释放
+type Topology struct {
+	Version uint64
+	Indexes map[string]*IndexTopology
+}
+
+// IndexTopology 一个索引的拓扑
+type IndexTopology struct {
+	Shards      int            // 分片（group）数量，编号为0到Shards-1
+	Replicas    int            // 每个分片最多有几个worker
+	Assignments map[string]int // 指定worker（endpoint）只能加入哪个分片，不为空时只有列出的worker可以认领
+}
+
+func topologyKey() string {
+	return ServiceRootPath + "topology"
+}
+
+func claimPrefix(index, group string) string {
+	return ServiceRootPath + index + "/claims/" + group + "/"
+}
+
+// Index 返回索引的拓扑，没有声明时返回nil
+func (t *Topology) Index(name string) *IndexTopology {
+	if t == nil {
+		return nil
+	}
+	return t.Indexes[name]
+}
+
+// SetIndex 声明索引的分片数和副本数，已经存在时只修改这两项
+func (t *Topology) SetIndex(name string, shards, replicas int) error {
+	if len(name) == 0 {
+		return errors.New("index name is empty")
+	}
+	if shards <= 0 || replicas <= 0 {
+		return fmt.Errorf("invalid shards %d or replicas %d, should be positive", shards, replicas)
+	}
+	if t.Indexes == nil {
+		t.Indexes = make(map[string]*IndexTopology)
+	}
+	index := t.Indexes[name]
+	if index == nil {
+		index = new(IndexTopology)
+		t.Indexes[name] = index
+	}
+	for endpoint, group := range index.Assignments {
+		if group >= shards {
+			return fmt.Errorf("worker %s is assigned to group-%d, unassign it first", endpoint, group)
+		}
+	}
+	index.Shards = shards
+	index.Replicas = replicas
+	return nil
+}
+
+// RemoveIndex 删除索引的拓扑，之后该索引的worker无法启动
+func (t *Topology) RemoveIndex(name string) error {
+	if t.Index(name) == nil {
+		return fmt.Errorf("index %s not found", name)
+	}
+	delete(t.Indexes, name)
+	return nil
+}
+
+// Assign 指定worker只能加入group
+func (t *Topology) Assign(name, endpoint string, group int) error {
+	index := t.Index(name)
+	if index == nil {
+		return fmt.Errorf("index %s not found", name)
+	}
+	if group < 0 || group >= index.Shards {
+		return fmt.Errorf("group-%d out of range, index %s has %d shards", group, name, index.Shards)
+	}
+	if len(endpoint) == 0 {
+		return errors.New("endpoint is empty")
+	}
+	if index.Assignments == nil {
+		index.Assignments = make(map[string]int)
+	}
+	index.Assignments[endpoint] = group
+	return nil
+}
+
+// Unassign 取消worker的指定
+func (t *Topology) Unassign(name, endpoint string) error {
+	index := t.Index(name)
+	if index == nil {
+		return fmt.Errorf("index %s not found", name)
+	}
+	if _, ok := index.Assignments[endpoint]; !ok {
+		return fmt.Errorf("worker %s is not assigned", endpoint)
+	}
+	delete(index.Assignments, endpoint)
+	return nil
+}
+
+// LoadTopology 读取etcd中的集群拓扑，还没有创建时返回nil。revision用于SaveTopology的并发校验
+func LoadTopology(client *etcdv3.Client) (t *Topology, revision int64, err error) {
+	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+	defer cancel()
+	resp, err := client.Get(timeoutCtx, topologyKey())
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+	t = new(Topology)
+	if err := json.Unmarshal(resp.Kvs[0].Value, t); err != nil {
+		return nil, 0, fmt.Errorf("invalid topology: %w", err)
+	}
+	return t, resp.Kvs[0].ModRevision, nil
+}
+
+// SaveTopology 只有etcd中的拓扑在revision之后没有被修改过才会写入，否则返回ErrTopologyChanged。revision为0表示新建
+func SaveTopology(client *etcdv3.Client, t *Topology, revision int64) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+	defer cancel()
+	resp, err := client.Txn(timeoutCtx).
+		If(etcdv3.Compare(etcdv3.ModRevision(topologyKey()), "=", revision)).
+		Then(etcdv3.OpPut(topologyKey(), string(data))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrTopologyChanged
+	}
+	return nil
+}
+
+// UpdateTopology 读取拓扑，交给fn修改后写回，并发修改时重试。fn返回错误时不写入
+func UpdateTopology(client *etcdv3.Client, fn func(t *Topology) error) (*Topology, error) {
+	for range 3 {
+		t, revision, err := LoadTopology(client)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			t = new(Topology)
+		}
+		if err := fn(t); err != nil {
+			return nil, err
+		}
+		t.Version++
+		if err := SaveTopology(client, t, revision); err == nil {
+			return t, nil
+		} else if !errors.Is(err, ErrTopologyChanged) {
+			return nil, err
+		}
+	}
+	return nil, ErrTopologyChanged
+}
+
+// TopologyAdmin 修改集群拓扑的管理接口，会检查修改是否与分片映射冲突
+type TopologyAdmin struct {
+	client *etcdv3.Client
+}
+
+func NewTopologyAdmin(client *etcdv3.Client) *TopologyAdmin {
+	return &TopologyAdmin{client: client}
+}
+
+// Topology 返回当前的集群拓扑
+func (admin *TopologyAdmin) Topology() (*Topology, error) {
+	t, _, err := LoadTopology(admin.client)
+	return t, err
+}
+
+// SetIndex 声明索引的分片数和副本数。减少分片数前需要先通过重新分片把多出来的group的槽位迁走
+func (admin *TopologyAdmin) SetIndex(name string, shards, replicas int) (*Topology, error) {
+	shardMap, _, err := loadShardMapOf(admin.client, name)
+	if err != nil {
+		return nil, err
+	}
+	if shardMap != nil {
+		for _, group := range shardMap.Groups() {
+			if group >= shards {
+				return nil, fmt.Errorf("group-%d still owns slots, move them with reshard --remove-group %d first", group, group)
+			}
+		}
+		for _, migration := range shardMap.Migrations {
+			if migration.To >= shards {
+				return nil, fmt.Errorf("slot %d is migrating to group-%d", migration.Slot, migration.To)
+			}
+		}
+	}
+	return UpdateTopology(admin.client, func(t *Topology) error { return t.SetIndex(name, shards, replicas) })
+}
+
+// RemoveIndex 删除索引的拓扑
+func (admin *TopologyAdmin) RemoveIndex(name string) (*Topology, error) {
+	return UpdateTopology(admin.client, func(t *Topology) error { return t.RemoveIndex(name) })
+}
+
+// Assign 指定worker只能加入group，对已经认领了位置的worker在下次启动时生效
+func (admin *TopologyAdmin) Assign(name, endpoint string, group int) (*Topology, error) {
+	return UpdateTopology(admin.client, func(t *Topology) error { return t.Assign(name, endpoint, group) })
+}
+
+// Unassign 取消worker的指定
+func (admin *TopologyAdmin) Unassign(name, endpoint string) (*Topology, error) {
+	return UpdateTopology(admin.client, func(t *Topology) error { return t.Unassign(name, endpoint) })
+}
+
+// Claims 返回索引每个group中认领了位置的worker
+func (admin *TopologyAdmin) Claims(name string) (map[int][]string, error) {
+	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+	defer cancel()
+	prefix := ServiceRootPath + name + "/claims/"
+	resp, err := admin.client.Get(timeoutCtx, prefix, etcdv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	claims := make(map[int][]string)
+	for _, kv := range resp.Kvs {
+		group, _, _ := strings.Cut(strings.TrimPrefix(string(kv.Key), prefix), "/")
+		groupIndex, err := strconv.Atoi(strings.TrimPrefix(group, "group-"))
+		if err != nil {
+			continue
+		}
+		claims[groupIndex] = append(claims[groupIndex], string(kv.Value))
+	}
+	for _, endpoints := range claims {
+		slices.Sort(endpoints)
+	}
+	return claims, nil
+}
+
+// 索引还没有声明拓扑时，按init.yml中的shards和replicas声明，之后只能通过管理接口修改
+func bootstrapTopology(client *etcdv3.Client) (*IndexTopology, error) {
+	t, _, err := LoadTopology(client)
+	if err != nil {
+		return nil, err
+	}
+	if index := t.Index(indexName); index != nil {
+		return index, nil
+	}
+	shards, _ := distributedMap["shards"].(int)
+	replicas, _ := distributedMap["replicas"].(int)
+	t, err = UpdateTopology(client, func(t *Topology) error {
+		if t.Index(indexName) != nil { // 其他worker已经声明
+			return nil
+		}
+		util.Log.Printf("declare topology of index %s: %d shards, %d replicas", indexName, shards, replicas)
+		return t.SetIndex(indexName, shards, replicas)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("declare topology of index %s (set shards and replicas in init.yml, or use electricsearch topology): %w", indexName, err)
+	}
+	return t.Index(indexName), nil
+}
+
+// claimReplica 按拓扑认领当前group中的一个副本位置，认领记录绑定session的租约。
+// 当前group不在拓扑中、worker被指定给其他group、或者副本位置已满时返回错误
+func (service *IndexServiceWorker) claimReplica(session *concurrency.Session) error {
+	index, err := bootstrapTopology(service.etcdHub.client)
+	if err != nil {
+		return err
+	}
+	if currentGroupIndex >= index.Shards {
+		return fmt.Errorf("%s is not declared, index %s has %d shards", currentGroup, indexName, index.Shards)
+	}
+	if len(index.Assignments) > 0 {
+		group, ok := index.Assignments[service.selfAddr]
+		if !ok {
+			return fmt.Errorf("worker %s is not assigned to any group of index %s", service.selfAddr, indexName)
+		}
+		if group != currentGroupIndex {
+			return fmt.Errorf("worker %s is assigned to group-%d, not %s", service.selfAddr, group, currentGroup)
+		}
+	}
+
+	timeoutCtx, cancel := util.GetDefaultTimeoutContext()
+	defer cancel()
+	for replica := range index.Replicas {
+		key := claimPrefix(indexName, currentGroup) + strconv.Itoa(replica)
+		resp, err := service.etcdHub.client.Txn(timeoutCtx).
+			If(etcdv3.Compare(etcdv3.CreateRevision(key), "=", 0)).
+			Then(etcdv3.OpPut(key, service.selfAddr, etcdv3.WithLease(session.Lease()))).
+			Else(etcdv3.OpGet(key)).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			util.Log.Printf("worker %s claimed replica %d of %s", service.selfAddr, replica, currentGroup)
+			return nil
+		}
+		// 同一个worker重启时，上次的认领可能还没有过期
+		kvs := resp.Responses[0].GetResponseRange().Kvs
+		if len(kvs) > 0 && string(kvs[0].Value) == service.selfAddr {
+			resp, err := service.etcdHub.client.Txn(timeoutCtx).
+				If(etcdv3.Compare(etcdv3.ModRevision(key), "=", kvs[0].ModRevision)).
+				Then(etcdv3.OpPut(key, service.selfAddr, etcdv3.WithLease(session.Lease()))).
+				Commit()
+			if err != nil {
+				return err
+			}
+			if resp.Succeeded {
+				util.Log.Printf("worker %s reclaimed replica %d of %s", service.selfAddr, replica, currentGroup)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("all %d replicas of %s are claimed", index.Replicas, currentGroup)
+}
@@ -0,0 +1,509 @@
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WlayRay/ElectricSearch/internal/kvdb"
+	"github.com/WlayRay/ElectricSearch/types"
+	"github.com/WlayRay/ElectricSearch/util"
+)
+
+// 预写日志中的操作类型
+const (
+	WALPut    byte = iota + 1 // 写入文档的完整内容
+	WALDelete                 // 删除文档
+)
+
+const (
+	walFileName        = "wal.log"
+	walCheckpointName  = "checkpoint"
+	walRecordHeaderLen = 8 // 记录长度(4字节) + crc32(4字节)
+)
+
+// WAL文件超过这个大小后做一次checkpoint，把已经写入正排索引的记录截掉
+var WALCheckpointSize int64 = 64 << 20
+
+var ErrWALClosed = errors.New("wal is closed")
+
+// ErrWALPending 预写日志中还有没有持久化到正排索引的记录
+var ErrWALPending = errors.New("wal has records not applied to the forward index")
+
+// WALRecord 预写日志中的一条记录，对应一个文档上的一次逻辑操作
+type WALRecord struct {
+	Seq   uint64          // 全局递增的序号，由WAL分配
+	Op    byte            // WALPut或WALDelete
+	DocId string          // 去掉首尾空格后的文档ID
+	Doc   *types.Document // Op为WALPut时是写入后文档的完整内容
+}
+
+// 记录格式：长度(4字节) + crc32(4字节) + Seq(8字节) + Op(1字节) + DocId长度(uvarint) + DocId + Doc(protobuf)。
+// 开启加密时Doc用cipher加密，与正排索引一样DocId保持明文
+func (record *WALRecord) encode(cipher *kvdb.Cipher) ([]byte, error) {
+	body := binary.BigEndian.AppendUint64(nil, record.Seq)
+	body = append(body, record.Op)
+	body = appendString(body, record.DocId)
+	if record.Op == WALPut {
+		docBytes, err := record.Doc.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if cipher != nil {
+			docBytes = cipher.Seal(docBytes)
+		}
+		body = append(body, docBytes...)
+	}
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(body))
+	return append(buf, body...), nil
+}
+
+func decodeWALRecord(body []byte, cipher *kvdb.Cipher) (*WALRecord, error) {
+	if len(body) < 9 {
+		return nil, fmt.Errorf("wal record too short: %d bytes", len(body))
+	}
+	record := &WALRecord{Seq: binary.BigEndian.Uint64(body), Op: body[8]}
+	body = body[9:]
+	n, k := binary.Uvarint(body)
+	if k <= 0 || uint64(len(body)-k) < n {
+		return nil, fmt.Errorf("invalid doc id of wal record %d", record.Seq)
+	}
+	record.DocId = string(body[k : k+int(n)])
+	body = body[k+int(n):]
+	switch record.Op {
+	case WALPut:
+		if cipher != nil {
+			var err error
+			if body, err = cipher.Open(body); err != nil {
+				return nil, fmt.Errorf("decrypt wal record %d: %w", record.Seq, err)
+			}
+		}
+		record.Doc = &types.Document{}
+		if err := record.Doc.Unmarshal(body); err != nil {
+			return nil, err
+		}
+	case WALDelete:
+	default:
+		return nil, fmt.Errorf("invalid operation %d of wal record %d", record.Op, record.Seq)
+	}
+	return record, nil
+}
+
+// WAL 索引的预写日志。先把操作写入日志并fsync，再修改正排和倒排索引，重启时重放checkpoint之后的记录。
+// 并发的写请求共用一次fsync（group commit）
+type WAL struct {
+	dir    string
+	cipher *kvdb.Cipher // 为nil时不加密
+	mu     sync.Mutex   // 保护file、writer、seq、size和waiters
+	file   *os.File
+	// 写入的记录先放在writer里，由syncLoop统一flush和fsync
+	writer     *bufio.Writer
+	seq        uint64 // 最后一条记录的序号
+	checkpoint uint64 // 序号不大于checkpoint的记录都已持久化到正排索引
+	size       int64
+	waiters    []chan error
+	closed     bool
+
+	syncMu  sync.Mutex // fsync期间不能截断文件
+	syncCh  chan struct{}
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+// OpenWAL 打开dir目录下的预写日志，目录不存在时自动创建
+func OpenWAL(dir string) (*WAL, error) {
+	return OpenEncryptedWAL(dir, nil)
+}
+
+// OpenEncryptedWAL 与OpenWAL相同，key非空时用它加密日志中的文档内容
+func OpenEncryptedWAL(dir string, key []byte) (*WAL, error) {
+	var cipher *kvdb.Cipher
+	if len(key) > 0 {
+		var err error
+		if cipher, err = kvdb.NewCipher(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	wal := &WAL{
+		dir:     dir,
+		cipher:  cipher,
+		syncCh:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+	var err error
+	if wal.checkpoint, err = readWALCheckpoint(dir); err != nil {
+		return nil, err
+	}
+	wal.seq = wal.checkpoint
+
+	// 找到最后一条完整的记录，截掉写了一半的记录
+	validSize, lastSeq, err := wal.scan(nil)
+	if err != nil {
+		return nil, err
+	}
+	wal.seq = max(wal.seq, lastSeq)
+	file, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	wal.file = file
+	wal.writer = bufio.NewWriterSize(file, 64<<10)
+	wal.size = validSize
+
+	wal.wg.Add(1)
+	go wal.syncLoop()
+	return wal, nil
+}
+
+func readWALCheckpoint(dir string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, walCheckpointName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	checkpoint, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wal checkpoint: %w", err)
+	}
+	return checkpoint, nil
+}
+
+// ClearWAL 离线清空dir目录下已经全部checkpoint的预写日志，key为日志当前使用的密钥。
+// 日志中的文档用主密钥加密，更换主密钥前需要清空，否则旧记录无法用新密钥解密。
+// 还有没持久化到正排索引的记录时返回ErrWALPending，需要先启动并正常停止一次索引服务。目录不存在时什么都不做
+func ClearWAL(dir string, key []byte) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	wal := &WAL{dir: dir}
+	if len(key) > 0 {
+		var err error
+		if wal.cipher, err = kvdb.NewCipher(key); err != nil {
+			return err
+		}
+	}
+	var err error
+	if wal.checkpoint, err = readWALCheckpoint(dir); err != nil {
+		return err
+	}
+	pending, err := wal.Replay(func(*WALRecord) error { return nil })
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d records in %s", ErrWALPending, pending, dir)
+	}
+	// 保留checkpoint文件，序号继续递增
+	if err := os.Truncate(filepath.Join(dir, walFileName), 0); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// 顺序读取日志文件，对每条记录调用fn。返回最后一条完整记录的结束位置和序号
+func (wal *WAL) scan(fn func(record *WALRecord) error) (int64, uint64, error) {
+	file, err := os.Open(filepath.Join(wal.dir, walFileName))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	var lastSeq uint64
+	header := make([]byte, walRecordHeaderLen)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err != io.EOF {
+				util.Log.Printf("wal %s is truncated at offset %d", wal.dir, offset)
+			}
+			return offset, lastSeq, nil
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(reader, body); err != nil {
+			util.Log.Printf("wal %s is truncated at offset %d", wal.dir, offset)
+			return offset, lastSeq, nil
+		}
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:]) {
+			util.Log.Printf("wal %s has a corrupted record at offset %d", wal.dir, offset)
+			return offset, lastSeq, nil
+		}
+		record, err := decodeWALRecord(body, wal.cipher)
+		if err != nil {
+			return offset, lastSeq, err
+		}
+		if fn != nil {
+			if err := fn(record); err != nil {
+				return offset, lastSeq, err
+			}
+		}
+		offset += int64(walRecordHeaderLen + len(body))
+		lastSeq = record.Seq
+	}
+}
+
+// Replay 按顺序对checkpoint之后的每条记录调用fn，返回重放的记录数
+func (wal *WAL) Replay(fn func(record *WALRecord) error) (int, error) {
+	n := 0
+	_, _, err := wal.scan(func(record *WALRecord) error {
+		if record.Seq <= wal.checkpoint {
+			return nil
+		}
+		n++
+		return fn(record)
+	})
+	return n, err
+}
+
+// Append 给每条记录分配序号并写入日志，等到fsync完成后返回
+func (wal *WAL) Append(records ...*WALRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	done := make(chan error, 1)
+	wal.mu.Lock()
+	if wal.closed {
+		wal.mu.Unlock()
+		return ErrWALClosed
+	}
+	for _, record := range records {
+		wal.seq++
+		record.Seq = wal.seq
+		buf, err := record.encode(wal.cipher)
+		if err == nil {
+			_, err = wal.writer.Write(buf)
+		}
+		if err != nil {
+			wal.mu.Unlock()
+			return err
+		}
+		wal.size += int64(len(buf))
+	}
+	wal.waiters = append(wal.waiters, done)
+	wal.mu.Unlock()
+
+	select {
+	case wal.syncCh <- struct{}{}:
+	default: // syncLoop已经收到通知，会把这次写入一起fsync
+	}
+	return <-done
+}
+
+func (wal *WAL) syncLoop() {
+	defer wal.wg.Done()
+	for {
+		select {
+		case <-wal.syncCh:
+			wal.sync()
+		case <-wal.closeCh:
+			wal.sync()
+			return
+		}
+	}
+}
+
+// 把缓冲区中的记录写入文件并fsync，然后唤醒等待这些记录的写请求
+func (wal *WAL) sync() {
+	wal.syncMu.Lock()
+	defer wal.syncMu.Unlock()
+
+	wal.mu.Lock()
+	err := wal.writer.Flush()
+	waiters := wal.waiters
+	wal.waiters = nil
+	wal.mu.Unlock()
+	if len(waiters) == 0 {
+		return
+	}
+
+	if err == nil {
+		err = wal.file.Sync()
+	}
+	for _, done := range waiters {
+		done <- err
+	}
+}
+
+// LastSeq 最后一条记录的序号
+func (wal *WAL) LastSeq() uint64 {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	return wal.seq
+}
+
+// Size 日志文件当前的大小
+func (wal *WAL) Size() int64 {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	return wal.size
+}
+
+// Checkpoint 序号不大于seq的记录都已经持久化到正排索引，可以从日志中删掉。
+// 调用方需保证此时没有并发的Append，并且seq之后没有记录
+func (wal *WAL) Checkpoint(seq uint64) error {
+	wal.syncMu.Lock()
+	defer wal.syncMu.Unlock()
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	// 先持久化checkpoint再截断日志，中间宕机只会多重放一些记录
+	tmpPath := filepath.Join(wal.dir, walCheckpointName+".tmp")
+	if err := os.WriteFile(tmpPath, []byte(strconv.FormatUint(seq, 10)), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(wal.dir, walCheckpointName)); err != nil {
+		return err
+	}
+	wal.checkpoint = seq
+	wal.seq = max(wal.seq, seq)
+
+	if err := wal.writer.Flush(); err != nil {
+		return err
+	}
+	if err := wal.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := wal.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	wal.size = 0
+	return nil
+}
+
+// Close 等待缓冲区中的记录fsync后关闭日志文件
+func (wal *WAL) Close() error {
+	wal.mu.Lock()
+	if wal.closed {
+		wal.mu.Unlock()
+		return nil
+	}
+	wal.closed = true
+	wal.mu.Unlock()
+
+	close(wal.closeCh)
+	wal.wg.Wait()
+	return wal.file.Close()
+}
+
+// 写入预写日志，未开启WAL时什么都不做。调用方需持有相关文档的锁，保证日志的顺序与修改索引的顺序一致
+func (indexer *Indexer) appendWAL(records ...*WALRecord) error {
+	if indexer.wal == nil {
+		return nil
+	}
+	if err := indexer.wal.Append(records...); err != nil {
+		return err
+	}
+	if indexer.wal.Size() >= WALCheckpointSize && indexer.checkpointing.CompareAndSwap(false, true) {
+		go func() { // 调用方持有文档锁，checkpoint需要在后台等待所有锁
+			defer indexer.checkpointing.Store(false)
+			if err := indexer.Checkpoint(); err != nil {
+				util.Log.Printf("wal checkpoint failed: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// 预写日志已经追加、写入正排索引却失败时，按正排索引中实际的状态再追加一条记录，避免重放时写入失败的文档。
+// 调用方需持有这些文档的锁
+func (indexer *Indexer) revertWAL(docIds ...string) {
+	if indexer.wal == nil || len(docIds) == 0 {
+		return
+	}
+	records := make([]*WALRecord, 0, len(docIds))
+	for _, docId := range docIds {
+		if doc := indexer.getDoc(docId); doc != nil {
+			records = append(records, &WALRecord{Op: WALPut, DocId: docId, Doc: doc})
+		} else {
+			records = append(records, &WALRecord{Op: WALDelete, DocId: docId})
+		}
+	}
+	if err := indexer.appendWAL(records...); err != nil {
+		util.Log.Printf("revert wal of %d docs failed: %v", len(docIds), err)
+	}
+}
+
+// 把checkpoint之后的记录重新写入正排索引，并按key原来是否存在更新计数。倒排索引随后由LoadFromIndexFile从正排索引重建
+func (indexer *Indexer) replayWAL() error {
+	now := time.Now().Unix()
+	n, err := indexer.wal.Replay(func(record *WALRecord) error {
+		existed := indexer.forwardIndex.Has([]byte(record.DocId))
+		if record.Op == WALPut && !record.Doc.Expired(now) {
+			if err := indexer.putDoc(record.DocId, record.Doc); err != nil {
+				return err
+			}
+			if !existed {
+				indexer.counter.added(1)
+			}
+			return nil
+		}
+		if err := indexer.forwardIndex.Delete([]byte(record.DocId)); err != nil {
+			return err
+		}
+		if existed {
+			indexer.counter.deleted(1)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		util.Log.Printf("replay %d records from wal %s", n, indexer.walDir)
+		return indexer.checkpoint()
+	}
+	return nil
+}
+
+// Checkpoint 把正排索引持久化到磁盘后截断预写日志，期间写请求会被阻塞
+func (indexer *Indexer) Checkpoint() error {
+	if indexer.wal == nil {
+		return nil
+	}
+	unlock := indexer.lockAll()
+	defer unlock()
+	return indexer.checkpoint()
+}
+
+// 调用方需持有全部文档锁
+func (indexer *Indexer) checkpoint() error {
+	if err := indexer.forwardIndex.Sync(); err != nil {
+		return err
+	}
+	if err := indexer.saveCounter(false); err != nil { // 顺便持久化计数，异常退出时保留累计删除数
+		return err
+	}
+	return indexer.wal.Checkpoint(indexer.wal.LastSeq())
+}
+
+// LastSeq 最后一次写操作在预写日志中的序号，未开启WAL时返回0。序号全局递增，可用于复制和快照
+func (indexer *Indexer) LastSeq() uint64 {
+	if indexer.wal == nil {
+		return 0
+	}
+	return indexer.wal.LastSeq()
+}
@@ -7,3 +7,8 @@ func (kw *Keyword) ToString() string {
 		return ""
 	}
 }
+
+// Expired 文档设置了过期时间并且在now（Unix时间戳，单位秒）之前已过期
+func (doc *Document) Expired(now int64) bool {
+	return doc.ExpireAt > 0 && doc.ExpireAt <= now
+}
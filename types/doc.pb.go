@@ -80,6 +80,8 @@ type Document struct {
 	BitsFeature uint64     `protobuf:"varint,3,opt,name=BitsFeature,proto3" json:"BitsFeature,omitempty"`
 	Keywords    []*Keyword `protobuf:"bytes,4,rep,name=Keywords,proto3" json:"Keywords,omitempty"`
 	Bytes       []byte     `protobuf:"bytes,5,opt,name=Bytes,proto3" json:"Bytes,omitempty"`
+	Version     uint64     `protobuf:"varint,6,opt,name=Version,proto3" json:"Version,omitempty"`
+	ExpireAt    int64      `protobuf:"varint,7,opt,name=ExpireAt,proto3" json:"ExpireAt,omitempty"`
 }
 
 func (m *Document) Reset()         { *m = Document{} }
@@ -150,30 +152,154 @@ func (m *Document) GetBytes() []byte {
 	return nil
 }
 
+func (m *Document) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Document) GetExpireAt() int64 {
+	if m != nil {
+		return m.ExpireAt
+	}
+	return 0
+}
+
+type DocPatch struct {
+	Id             string     `protobuf:"bytes,1,opt,name=Id,proto3" json:"Id,omitempty"`
+	SetBits        uint64     `protobuf:"varint,2,opt,name=SetBits,proto3" json:"SetBits,omitempty"`
+	ClearBits      uint64     `protobuf:"varint,3,opt,name=ClearBits,proto3" json:"ClearBits,omitempty"`
+	AddKeywords    []*Keyword `protobuf:"bytes,4,rep,name=AddKeywords,proto3" json:"AddKeywords,omitempty"`
+	RemoveKeywords []*Keyword `protobuf:"bytes,5,rep,name=RemoveKeywords,proto3" json:"RemoveKeywords,omitempty"`
+	Bytes          []byte     `protobuf:"bytes,6,opt,name=Bytes,proto3" json:"Bytes,omitempty"`
+	ReplaceBytes   bool       `protobuf:"varint,7,opt,name=ReplaceBytes,proto3" json:"ReplaceBytes,omitempty"`
+	Version        uint64     `protobuf:"varint,8,opt,name=Version,proto3" json:"Version,omitempty"`
+}
+
+func (m *DocPatch) Reset()         { *m = DocPatch{} }
+func (m *DocPatch) String() string { return proto.CompactTextString(m) }
+func (*DocPatch) ProtoMessage()    {}
+func (*DocPatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_37cb16cf10c66117, []int{2}
+}
+func (m *DocPatch) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DocPatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DocPatch.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *DocPatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DocPatch.Merge(m, src)
+}
+func (m *DocPatch) XXX_Size() int {
+	return m.Size()
+}
+func (m *DocPatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_DocPatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DocPatch proto.InternalMessageInfo
+
+func (m *DocPatch) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *DocPatch) GetSetBits() uint64 {
+	if m != nil {
+		return m.SetBits
+	}
+	return 0
+}
+
+func (m *DocPatch) GetClearBits() uint64 {
+	if m != nil {
+		return m.ClearBits
+	}
+	return 0
+}
+
+func (m *DocPatch) GetAddKeywords() []*Keyword {
+	if m != nil {
+		return m.AddKeywords
+	}
+	return nil
+}
+
+func (m *DocPatch) GetRemoveKeywords() []*Keyword {
+	if m != nil {
+		return m.RemoveKeywords
+	}
+	return nil
+}
+
+func (m *DocPatch) GetBytes() []byte {
+	if m != nil {
+		return m.Bytes
+	}
+	return nil
+}
+
+func (m *DocPatch) GetReplaceBytes() bool {
+	if m != nil {
+		return m.ReplaceBytes
+	}
+	return false
+}
+
+func (m *DocPatch) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Keyword)(nil), "raybox.data.Keyword")
 	proto.RegisterType((*Document)(nil), "raybox.data.Document")
+	proto.RegisterType((*DocPatch)(nil), "raybox.data.DocPatch")
 }
 
 func init() { proto.RegisterFile("doc.proto", fileDescriptor_37cb16cf10c66117) }
 
 var fileDescriptor_37cb16cf10c66117 = []byte{
-	// 226 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4c, 0xc9, 0x4f, 0xd6,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x2e, 0x4a, 0xac, 0x4c, 0xca, 0xaf, 0xd0, 0x4b, 0x49,
-	0x2c, 0x49, 0x54, 0x32, 0xe6, 0x62, 0xf7, 0x4e, 0xad, 0x2c, 0xcf, 0x2f, 0x4a, 0x11, 0x12, 0xe1,
-	0x62, 0x75, 0xcb, 0x4c, 0xcd, 0x49, 0x91, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x82, 0x70, 0x84,
-	0x84, 0xb8, 0x58, 0xc2, 0xf3, 0x8b, 0x52, 0x24, 0x98, 0xc0, 0x82, 0x60, 0xb6, 0xd2, 0x2c, 0x46,
-	0x2e, 0x0e, 0x97, 0xfc, 0xe4, 0xd2, 0xdc, 0xd4, 0xbc, 0x12, 0x21, 0x3e, 0x2e, 0x26, 0x4f, 0x98,
-	0x1e, 0x26, 0x4f, 0xb0, 0x31, 0x9e, 0x79, 0x25, 0x9e, 0x10, 0x1d, 0x2c, 0x41, 0x10, 0x8e, 0x90,
-	0x02, 0x17, 0xb7, 0x53, 0x66, 0x49, 0xb1, 0x5b, 0x6a, 0x62, 0x49, 0x69, 0x51, 0xaa, 0x04, 0x33,
-	0x58, 0x0e, 0x59, 0x48, 0xc8, 0x80, 0x8b, 0x03, 0xea, 0x92, 0x62, 0x09, 0x16, 0x05, 0x66, 0x0d,
-	0x6e, 0x23, 0x11, 0x3d, 0x24, 0x97, 0xea, 0x41, 0x25, 0x83, 0xe0, 0xaa, 0x40, 0x36, 0x39, 0x55,
-	0x96, 0xa4, 0x16, 0x4b, 0xb0, 0x2a, 0x30, 0x6a, 0xf0, 0x04, 0x41, 0x38, 0x4e, 0xf2, 0x27, 0x1e,
-	0xc9, 0x31, 0x5e, 0x78, 0x24, 0xc7, 0xf8, 0xe0, 0x91, 0x1c, 0xe3, 0x84, 0xc7, 0x72, 0x0c, 0x17,
-	0x1e, 0xcb, 0x31, 0xdc, 0x78, 0x2c, 0xc7, 0x10, 0xc5, 0x5a, 0x52, 0x59, 0x90, 0x5a, 0x9c, 0xc4,
-	0x06, 0x0e, 0x06, 0x63, 0x40, 0x00, 0x00, 0x00, 0xff, 0xff, 0x1b, 0xe6, 0x5d, 0xfb, 0x13, 0x01,
-	0x00, 0x00,
+	// 379 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x52, 0xcd, 0xaa, 0xda, 0x40,
+	0x18, 0x75, 0xe2, 0x4f, 0xe2, 0x44, 0x5c, 0x0c, 0x2e, 0x86, 0x52, 0x42, 0xc8, 0xa6, 0x59, 0xc5,
+	0x52, 0xa1, 0xab, 0x6e, 0xb4, 0x55, 0x08, 0xdd, 0x94, 0x11, 0x2a, 0x74, 0x37, 0x4e, 0x3e, 0x6a,
+	0x20, 0x3a, 0x61, 0x32, 0xb6, 0xe6, 0x2d, 0xfa, 0x00, 0x7d, 0xa0, 0x2e, 0x5d, 0x76, 0x79, 0xd1,
+	0x17, 0xb9, 0x38, 0xf1, 0x27, 0xde, 0x0b, 0x97, 0xbb, 0xcb, 0x39, 0xe7, 0x3b, 0xf9, 0xbe, 0x73,
+	0x18, 0xdc, 0x4d, 0xa4, 0x88, 0x72, 0x25, 0xb5, 0x24, 0xae, 0xe2, 0xe5, 0x52, 0xee, 0xa2, 0x84,
+	0x6b, 0x1e, 0x8c, 0xb0, 0xfd, 0x15, 0xca, 0xdf, 0x52, 0x25, 0x64, 0x80, 0xdb, 0xb3, 0x14, 0xb2,
+	0x84, 0x22, 0x1f, 0x85, 0x5d, 0x56, 0x01, 0x42, 0x70, 0x6b, 0x21, 0x55, 0x42, 0x2d, 0x43, 0x9a,
+	0xef, 0x60, 0x8f, 0xb0, 0xf3, 0x45, 0x8a, 0xed, 0x1a, 0x36, 0x9a, 0xf4, 0xb1, 0x15, 0x5f, 0x3c,
+	0x56, 0x6c, 0x7e, 0x13, 0x6f, 0x74, 0x5c, 0x39, 0x5a, 0xac, 0x02, 0xc4, 0xc7, 0xee, 0x24, 0xd5,
+	0xc5, 0x0c, 0xb8, 0xde, 0x2a, 0xa0, 0x4d, 0xa3, 0xd5, 0x29, 0xf2, 0x1e, 0x3b, 0xe7, 0x4b, 0x0a,
+	0xda, 0xf2, 0x9b, 0xa1, 0xfb, 0x61, 0x10, 0xd5, 0x2e, 0x8d, 0xce, 0x22, 0xbb, 0x4e, 0x9d, 0x36,
+	0x4d, 0x4a, 0x0d, 0x05, 0x6d, 0xfb, 0x28, 0xec, 0xb1, 0x0a, 0x10, 0x8a, 0xed, 0xef, 0xa0, 0x8a,
+	0x54, 0x6e, 0x68, 0xc7, 0x6c, 0xb9, 0x40, 0xf2, 0x06, 0x3b, 0xd3, 0x5d, 0x9e, 0x2a, 0x18, 0x6b,
+	0x6a, 0xfb, 0x28, 0x6c, 0xb2, 0x2b, 0x0e, 0xfe, 0x5a, 0x26, 0xd2, 0x37, 0xae, 0xc5, 0xea, 0x59,
+	0x24, 0x8a, 0xed, 0x39, 0xe8, 0xd3, 0xb1, 0xe7, 0x50, 0x17, 0x48, 0xde, 0xe2, 0xee, 0xe7, 0x0c,
+	0xb8, 0x32, 0x5a, 0x15, 0xea, 0x46, 0x90, 0x8f, 0xd8, 0x1d, 0x27, 0xc9, 0xab, 0x52, 0xd5, 0x07,
+	0xc9, 0x27, 0xdc, 0x67, 0xb0, 0x96, 0xbf, 0xe0, 0x6a, 0x6d, 0xbf, 0x60, 0x7d, 0x32, 0x7b, 0xab,
+	0xa5, 0x53, 0xaf, 0x25, 0xc0, 0x3d, 0x06, 0x79, 0xc6, 0x05, 0x54, 0xe2, 0xa9, 0x00, 0x87, 0xdd,
+	0x71, 0xf5, 0xea, 0x9c, 0xbb, 0xea, 0x26, 0xe3, 0x7f, 0x07, 0x0f, 0xed, 0x0f, 0x1e, 0x7a, 0x38,
+	0x78, 0xe8, 0xcf, 0xd1, 0x6b, 0xec, 0x8f, 0x5e, 0xe3, 0xff, 0xd1, 0x6b, 0xfc, 0x78, 0xf7, 0x33,
+	0xd5, 0xab, 0xed, 0x32, 0x12, 0x72, 0x3d, 0x5c, 0x64, 0xbc, 0x64, 0xbc, 0x1c, 0x4e, 0x33, 0x10,
+	0x5a, 0xa5, 0x62, 0x0e, 0x5c, 0x89, 0xd5, 0x50, 0x97, 0x39, 0x14, 0xcb, 0x8e, 0x79, 0x7d, 0xa3,
+	0xc7, 0x01, 0x00, 0xee, 0x04, 0xe2, 0x08, 0x8a, 0x02, 0x00, 0x00,
 }
 
 func (m *Keyword) Marshal() (dAtA []byte, err error) {
@@ -233,6 +359,16 @@ func (m *Document) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.ExpireAt != 0 {
+		i = encodeVarintDoc(dAtA, i, uint64(m.ExpireAt))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.Version != 0 {
+		i = encodeVarintDoc(dAtA, i, uint64(m.Version))
+		i--
+		dAtA[i] = 0x30
+	}
 	if len(m.Bytes) > 0 {
 		i -= len(m.Bytes)
 		copy(dAtA[i:], m.Bytes)
@@ -274,6 +410,96 @@ func (m *Document) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *DocPatch) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DocPatch) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DocPatch) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Version != 0 {
+		i = encodeVarintDoc(dAtA, i, uint64(m.Version))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.ReplaceBytes {
+		i--
+		if m.ReplaceBytes {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x38
+	}
+	if len(m.Bytes) > 0 {
+		i -= len(m.Bytes)
+		copy(dAtA[i:], m.Bytes)
+		i = encodeVarintDoc(dAtA, i, uint64(len(m.Bytes)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if len(m.RemoveKeywords) > 0 {
+		for iNdEx := len(m.RemoveKeywords) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.RemoveKeywords[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintDoc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x2a
+		}
+	}
+	if len(m.AddKeywords) > 0 {
+		for iNdEx := len(m.AddKeywords) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.AddKeywords[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintDoc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x22
+		}
+	}
+	if m.ClearBits != 0 {
+		i = encodeVarintDoc(dAtA, i, uint64(m.ClearBits))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.SetBits != 0 {
+		i = encodeVarintDoc(dAtA, i, uint64(m.SetBits))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Id) > 0 {
+		i -= len(m.Id)
+		copy(dAtA[i:], m.Id)
+		i = encodeVarintDoc(dAtA, i, uint64(len(m.Id)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintDoc(dAtA []byte, offset int, v uint64) int {
 	offset -= sovDoc(v)
 	base := offset
@@ -328,6 +554,53 @@ func (m *Document) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovDoc(uint64(l))
 	}
+	if m.Version != 0 {
+		n += 1 + sovDoc(uint64(m.Version))
+	}
+	if m.ExpireAt != 0 {
+		n += 1 + sovDoc(uint64(m.ExpireAt))
+	}
+	return n
+}
+
+func (m *DocPatch) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Id)
+	if l > 0 {
+		n += 1 + l + sovDoc(uint64(l))
+	}
+	if m.SetBits != 0 {
+		n += 1 + sovDoc(uint64(m.SetBits))
+	}
+	if m.ClearBits != 0 {
+		n += 1 + sovDoc(uint64(m.ClearBits))
+	}
+	if len(m.AddKeywords) > 0 {
+		for _, e := range m.AddKeywords {
+			l = e.Size()
+			n += 1 + l + sovDoc(uint64(l))
+		}
+	}
+	if len(m.RemoveKeywords) > 0 {
+		for _, e := range m.RemoveKeywords {
+			l = e.Size()
+			n += 1 + l + sovDoc(uint64(l))
+		}
+	}
+	l = len(m.Bytes)
+	if l > 0 {
+		n += 1 + l + sovDoc(uint64(l))
+	}
+	if m.ReplaceBytes {
+		n += 2
+	}
+	if m.Version != 0 {
+		n += 1 + sovDoc(uint64(m.Version))
+	}
 	return n
 }
 
@@ -618,6 +891,305 @@ func (m *Document) Unmarshal(dAtA []byte) error {
 				m.Bytes = []byte{}
 			}
 			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpireAt", wireType)
+			}
+			m.ExpireAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ExpireAt |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipDoc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthDoc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DocPatch) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowDoc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DocPatch: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DocPatch: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthDoc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthDoc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Id = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SetBits", wireType)
+			}
+			m.SetBits = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SetBits |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClearBits", wireType)
+			}
+			m.ClearBits = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ClearBits |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AddKeywords", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthDoc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthDoc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AddKeywords = append(m.AddKeywords, &Keyword{})
+			if err := m.AddKeywords[len(m.AddKeywords)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RemoveKeywords", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthDoc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthDoc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RemoveKeywords = append(m.RemoveKeywords, &Keyword{})
+			if err := m.RemoveKeywords[len(m.RemoveKeywords)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Bytes", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthDoc
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthDoc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Bytes = append(m.Bytes[:0], dAtA[iNdEx:postIndex]...)
+			if m.Bytes == nil {
+				m.Bytes = []byte{}
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplaceBytes", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ReplaceBytes = bool(v != 0)
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowDoc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipDoc(dAtA[iNdEx:])
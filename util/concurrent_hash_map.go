@@ -45,6 +45,15 @@ func (c *ConcurrentHashMap) Set(key string, value any) {
 	c.childMaps[segment][key] = value
 }
 
+// Clear 删除所有的key
+func (c *ConcurrentHashMap) Clear() {
+	for i := range c.childMaps {
+		c.locks[i].Lock()
+		clear(c.childMaps[i])
+		c.locks[i].Unlock()
+	}
+}
+
 // 迭代器模式
 type MapEntry struct {
 	Key   string